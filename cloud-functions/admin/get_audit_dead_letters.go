@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/TakeoffTech/site-info-svc/common"
+	auditModels "github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+)
+
+// This file has the function and handler to list the audit messages which could not be pushed as audit logs
+var getAuditDeadLettersPath = urit.MustCreateTemplate("/siteInfoservice/auditDeadLetters")
+
+func init() {
+	functions.HTTP("GetAuditDeadLetters", getAuditDeadLetters)
+}
+
+var getAuditDeadLetters = authorization.Authorize("get_audit_dead_letters.getAuditDeadLetters",
+	authorization.OnAllRetailers(common.RoleAdmin), getAuditDeadLettersHandler)
+
+func getAuditDeadLettersHandler(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("get_audit_dead_letters.getAuditDeadLettersHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+
+	_, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(common.GetMandatoryHeaders(), utils.AddPaginationHeaderIfNotAdded(request)...),
+		RequiredPath:    getAuditDeadLettersPath,
+		RequestMethod:   http.MethodGet,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	var err error
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
+	pageSize := utils.GetPageSizeFromHeader(request, logger)
+
+	startAfter, err = cloud.DecodeNextPageToken(request, "")
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	data, startAfter, err := dbClient.GetAll(ctx, common.AuditDeadLetterCollection,
+		cloud.Page{
+			StartAfter: startAfter,
+			PageSize:   pageSize,
+			OrderBy:    []cloud.Order{{Field: common.ID, Sort: common.SortAscending}},
+		}, nil)
+	if err != nil {
+		logger.Errorf("Internal server error while fetching the audit dead letters from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		if err != nil {
+			logger.Errorf("Error occurred while creating the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+	}
+	deadLetter := &auditModels.DeadLetter{}
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPage, deadLetter)
+}
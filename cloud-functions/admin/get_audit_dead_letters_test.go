@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	auditModels "github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getDeadLettersRepository(t *testing.T, count int) *cloud.MemoryRepository {
+	repository := cloud.NewMemoryRepository()
+	for i := 0; i < count; i++ {
+		deadLetter := audit.NewDeadLetter([]byte(fmt.Sprintf("{\"path\":%d}", i)), "invalid audit path")
+		_, err := repository.Save(context.Background(), common.AuditDeadLetterCollection, deadLetter.ID, deadLetter)
+		assert.Nil(t, err)
+	}
+
+	return repository
+}
+
+func Test_getAuditDeadLettersHandler(t *testing.T) {
+	t.Run("Invalid request method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		getAuditDeadLettersHandler(w, getRequest("/siteInfoservice/auditDeadLetters", nil), mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Error while fetching the dead letters", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetAll", mock.Anything, common.AuditDeadLetterCollection, mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout"))
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/auditDeadLetters", nil)
+		r.Method = http.MethodGet
+		getAuditDeadLettersHandler(w, r, fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Dead letters listed page by page", func(t *testing.T) {
+		repository := getDeadLettersRepository(t, 3)
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/auditDeadLetters", map[string]string{common.HeaderPageSize: "2"})
+		r.Method = http.MethodGet
+		getAuditDeadLettersHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		var deadLetters []auditModels.DeadLetter
+		assert.Nil(t, json.Unmarshal(bytes, &deadLetters))
+		assert.Equal(t, 2, len(deadLetters))
+		assert.Equal(t, "invalid audit path", deadLetters[0].Reason)
+		pageToken := response.Header.Get(common.HeaderNextPageToken)
+		assert.NotEmpty(t, pageToken)
+
+		w = httptest.NewRecorder()
+		r = getRequest("/siteInfoservice/auditDeadLetters",
+			map[string]string{common.HeaderPageSize: "2", common.HeaderPageToken: pageToken})
+		r.Method = http.MethodGet
+		getAuditDeadLettersHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &deadLetters))
+		assert.Equal(t, 1, len(deadLetters))
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+	})
+}
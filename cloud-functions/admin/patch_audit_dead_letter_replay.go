@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/TakeoffTech/site-info-svc/common"
+	auditModels "github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+)
+
+// This file has the function and handler to publish a dead lettered audit message again to the audit topic,
+// once the reason it could not be pushed is fixed
+var patchAuditDeadLetterReplayPath = urit.MustCreateTemplate(fmt.Sprintf(
+	"/siteInfoservice/auditDeadLetters/{%s}:%s", common.PathParamDeadLetterID, common.PathParamReplay))
+
+func init() {
+	functions.HTTP("PatchAuditDeadLetterReplay", patchAuditDeadLetterReplay)
+}
+
+var patchAuditDeadLetterReplay = authorization.Authorize("patch_audit_dead_letter_replay.patchAuditDeadLetterReplay",
+	authorization.OnAllRetailers(common.RoleAdmin),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		patchAuditDeadLetterReplayHandler(responseWriter, request, dbClient,
+			cloud.NewPubSubRepository(request.Context()))
+	})
+
+func patchAuditDeadLetterReplayHandler(responseWriter http.ResponseWriter, request *http.Request,
+	dbClient cloud.DB, pubsubClient cloud.Queue) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("patch_audit_dead_letter_replay.patchAuditDeadLetterReplayHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: common.GetMandatoryHeaders(),
+		RequiredPath:    patchAuditDeadLetterReplayPath,
+		RequestMethod:   http.MethodPatch,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	deadLetterID := pathParams[common.PathParamDeadLetterID]
+	data, err := dbClient.GetByID(ctx, common.AuditDeadLetterCollection, deadLetterID, false)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			response.RespondWithNotFoundErrorMessage(responseWriter, request,
+				fmt.Sprintf("Dead letter ID %s does not exist", deadLetterID), err)
+		} else {
+			logger.Errorf("Error occurred while fetching the dead letter from DB : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+		}
+
+		return
+	}
+
+	var deadLetter auditModels.DeadLetter
+	err = utils.ConvertToObject(data, &deadLetter)
+	if err != nil {
+		logger.Errorf("Error while converting data got from DB to dead letter struct : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	if !json.Valid([]byte(deadLetter.Payload)) {
+		logger.Debugf("Dead letter %s payload is not a JSON message", deadLetterID)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Dead letter ID %s payload is not a valid JSON message and cannot be replayed",
+					deadLetterID), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	// the dead letter is deleted before publishing as a message which fails again is dead lettered again
+	// by the audit pusher under the same ID
+	_, err = dbClient.Delete(ctx, common.AuditDeadLetterCollection, deadLetterID)
+	if err != nil {
+		logger.Errorf("Error while deleting the dead letter %s : %v", deadLetterID, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	// the audit log ID is derived from the message, so replaying a message which was pushed meanwhile is a no-op
+	err = pubsubClient.Publish(ctx, os.Getenv(common.EnvAuditLogTopic), json.RawMessage(deadLetter.Payload))
+	if err != nil {
+		logger.Errorf("Error while publishing the dead letter %s : %v", deadLetterID, err)
+		if _, err = dbClient.Save(ctx, common.AuditDeadLetterCollection, deadLetterID, deadLetter); err != nil {
+			logger.Errorf("Error while restoring the dead letter %s, payload : %s : %v", deadLetterID,
+				deadLetter.Payload, err)
+		}
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	logger.Debugf("Dead letter %s replayed", deadLetterID)
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusOK, fmt.Sprintf("Dead letter %s replayed", deadLetterID), nil),
+		response.GetCommonResponseHeaders(request))
+}
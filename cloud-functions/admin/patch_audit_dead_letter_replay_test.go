@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func init() {
+	_ = os.Setenv(common.EnvAuditLogTopic, "AUDIT_LOG_TOPIC")
+}
+
+func getDeadLetterRepository(t *testing.T, payload string) (*cloud.MemoryRepository, string) {
+	repository := cloud.NewMemoryRepository()
+	deadLetter := audit.NewDeadLetter([]byte(payload), "invalid change type 'rename'")
+	_, err := repository.Save(context.Background(), common.AuditDeadLetterCollection, deadLetter.ID, deadLetter)
+	assert.Nil(t, err)
+
+	return repository, deadLetter.ID
+}
+
+func Test_patchAuditDeadLetterReplayHandler(t *testing.T) {
+	payload := "{\"path\":\"retailers/r12345/audit\",\"change_type\":\"rename\"}"
+
+	t.Run("Dead letter does not exist", func(t *testing.T) {
+		repository, _ := getDeadLetterRepository(t, payload)
+		w := httptest.NewRecorder()
+		patchAuditDeadLetterReplayHandler(w, getRequest("/siteInfoservice/auditDeadLetters/d12345:replay", nil),
+			repository, mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":404,\"message\":\"Dead letter ID d12345 does not exist\"}", string(bytes))
+	})
+
+	t.Run("Error while fetching the dead letter", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, common.AuditDeadLetterCollection, "d12345", false).
+			Return(nil, status.Error(codes.Unavailable, "connection timeout"))
+		w := httptest.NewRecorder()
+		patchAuditDeadLetterReplayHandler(w, getRequest("/siteInfoservice/auditDeadLetters/d12345:replay", nil),
+			fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Payload which is not JSON cannot be replayed", func(t *testing.T) {
+		repository, deadLetterID := getDeadLetterRepository(t, "{invalid")
+		w := httptest.NewRecorder()
+		patchAuditDeadLetterReplayHandler(w,
+			getRequest("/siteInfoservice/auditDeadLetters/"+deadLetterID+":replay", nil), repository, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	})
+
+	t.Run("Dead letter restored when publishing fails", func(t *testing.T) {
+		repository, deadLetterID := getDeadLetterRepository(t, payload)
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "AUDIT_LOG_TOPIC", mock.Anything).
+			Return(errors.New("connection timeout")).Once()
+		w := httptest.NewRecorder()
+		patchAuditDeadLetterReplayHandler(w,
+			getRequest("/siteInfoservice/auditDeadLetters/"+deadLetterID+":replay", nil), repository, pubSubClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+		_, err := repository.GetByID(context.Background(), common.AuditDeadLetterCollection, deadLetterID, false)
+		assert.Nil(t, err)
+	})
+
+	t.Run("Successful replay", func(t *testing.T) {
+		repository, deadLetterID := getDeadLetterRepository(t, payload)
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "AUDIT_LOG_TOPIC", json.RawMessage(payload)).Return(nil).Once()
+		w := httptest.NewRecorder()
+		patchAuditDeadLetterReplayHandler(w,
+			getRequest("/siteInfoservice/auditDeadLetters/"+deadLetterID+":replay", nil), repository, pubSubClient)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		_, err := repository.GetByID(context.Background(), common.AuditDeadLetterCollection, deadLetterID, false)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file has the function and handler to write the geohash of the sites and the spokes of a retailer
+// which were created before the geohash was kept, so they are found by the near queries
+var patchGeohashesBackfillPath = urit.MustCreateTemplate("/siteInfoservice/geohashes:backfill")
+
+func init() {
+	functions.HTTP("PatchGeohashesBackfill", patchGeohashesBackfill)
+}
+
+var patchGeohashesBackfill = authorization.Authorize("patch_geohashes_backfill.patchGeohashesBackfill",
+	authorization.OnAllRetailers(common.RoleAdmin), patchGeohashesBackfillHandler)
+
+func patchGeohashesBackfillHandler(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("patch_geohashes_backfill.patchGeohashesBackfillHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	_, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(spokes.GetRequiredHeaders(), utils.AddPaginationHeaderIfNotAdded(request)...),
+		RequiredPath:    patchGeohashesBackfillPath,
+		RequestMethod:   http.MethodPatch,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, false) {
+		return
+	}
+
+	backfill := &geohashesBackfiller{
+		dbClient:        dbClient,
+		collectionPaths: []string{utils.GetSitePath(retailerID), utils.GetSpokePath(retailerID)},
+		deadline:        time.Now().Add(backfillTimeBudget),
+	}
+	if request.Header.Get(common.HeaderPageToken) != "" {
+		cursor, err := utils.DecodeNextPageToken(request, "")
+		if err == nil {
+			err = backfill.resumeFrom(cursor)
+		}
+		if err != nil {
+			logger.Errorf("Error occurred while decoding the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+	}
+
+	completed, err := backfill.run(ctx)
+	if err != nil {
+		logger.Errorf("Error while backfilling the geohashes after %d documents : %v", backfill.backfilled, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	var nextPageToken string
+	if !completed {
+		nextPageToken, err = utils.GetNextPageToken(request, backfill.cursor())
+		if err != nil {
+			logger.Errorf("Error occurred while creating the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+	}
+
+	logger.Debugf("%d geohashes backfilled, completed : %t", backfill.backfilled, completed)
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusOK, fmt.Sprintf("%d geohashes backfilled", backfill.backfilled), nil),
+		response.GetCommonResponseHeaders(request).WithHeader(common.HeaderNextPageToken, nextPageToken))
+}
+
+// geohashesBackfiller writes the geohash of the location of the documents of the collections in turn
+// a page at a time, keeping track of the collection and the last visited document as the collectionIndex
+// and the documentID so that the backfill can be resumed from there
+type geohashesBackfiller struct {
+	dbClient        cloud.DB
+	collectionPaths []string
+	deadline        time.Time
+	visited         int
+	backfilled      int
+	collectionIndex int
+	documentID      string
+}
+
+// cursor returns the position of the backfill as "<collection index>/<document ID>"
+func (b *geohashesBackfiller) cursor() string {
+	return strconv.Itoa(b.collectionIndex) + common.RequeueCursorSeparator + b.documentID
+}
+
+// resumeFrom sets the position of the backfill to the cursor returned by cursor
+func (b *geohashesBackfiller) resumeFrom(cursor string) error {
+	index, documentID, found := strings.Cut(cursor, common.RequeueCursorSeparator)
+	collectionIndex, err := strconv.Atoi(index)
+	if !found || err != nil || collectionIndex < 0 || collectionIndex >= len(b.collectionPaths) {
+		return fmt.Errorf("invalid geohash backfill cursor %s", cursor)
+	}
+	b.collectionIndex, b.documentID = collectionIndex, documentID
+
+	return nil
+}
+
+// run backfills the documents of the collections ordered by ID starting after b.documentID of the collection
+// at b.collectionIndex, returns false if the time budget ran out before all the documents were visited
+func (b *geohashesBackfiller) run(ctx context.Context) (bool, error) {
+	for b.collectionIndex < len(b.collectionPaths) {
+		// a request runs out of time only once it visited some documents so that the backfill always progresses
+		if b.visited > 0 && time.Now().After(b.deadline) {
+			return false, nil
+		}
+		var startAfter *cloud.Cursor
+		if b.documentID != "" {
+			startAfter = &cloud.Cursor{ID: b.documentID}
+		}
+		collectionPath := b.collectionPaths[b.collectionIndex]
+		documents, _, err := b.dbClient.GetAll(ctx, collectionPath,
+			cloud.Page{StartAfter: startAfter, PageSize: common.RequeuePageSize}, nil)
+		if err != nil {
+			return false, err
+		}
+		for _, document := range documents {
+			if err := b.backfill(ctx, collectionPath, document); err != nil {
+				return false, err
+			}
+			b.documentID = fmt.Sprint(document[common.ID])
+		}
+		if len(documents) < common.RequeuePageSize {
+			b.collectionIndex++
+			b.documentID = ""
+		}
+	}
+
+	return true, nil
+}
+
+// backfill writes the geohash of the location of the document unless it is up-to-date already
+func (b *geohashesBackfiller) backfill(ctx context.Context, collectionPath string,
+	document map[string]interface{}) error {
+	b.visited++
+	var location models.Location
+	if err := utils.ConvertToObject(document[common.Location], &location); err != nil {
+		return err
+	}
+	if location.Latitude == nil || location.Longitude == nil {
+		return nil
+	}
+	geohash := utils.GetGeohash(*location.Latitude, *location.Longitude, common.GeohashPrecision)
+	if document[common.Geohash] == geohash {
+		return nil
+	}
+	_, err := b.dbClient.Update(ctx, collectionPath, fmt.Sprint(document[common.ID]),
+		[]firestore.Update{{Path: common.Geohash, Value: geohash}})
+	if err != nil {
+		return err
+	}
+	b.backfilled++
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getGeohashesBackfillRepository returns the test repository with two more sites and another spoke of r11111
+// with a location, the first site with its geohash written already
+func getGeohashesBackfillRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := getTestRepository(t)
+	latitude, longitude := 57.64911, 10.40744
+	location := &models.Location{Latitude: &latitude, Longitude: &longitude}
+	for _, site := range []sites.Site{
+		{ID: "s3r11111", RetailerID: "r11111", Location: location, Geohash: "u4pruydqqv"},
+		{ID: "s4r11111", RetailerID: "r11111", Location: location},
+	} {
+		_, err := repository.Save(ctx, utils.GetSitePath("r11111"), site.ID, site)
+		assert.Nil(t, err)
+	}
+	spoke := spokes.Spoke{ID: "p22222", RetailerID: "r11111", Location: location}
+	_, err := repository.Save(ctx, utils.GetSpokePath("r11111"), spoke.ID, spoke)
+	assert.Nil(t, err)
+
+	return repository
+}
+
+func Test_patchGeohashesBackfillHandler(t *testing.T) {
+	t.Run("Retailer header not passed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		patchGeohashesBackfillHandler(w, getRequest("/siteInfoservice/geohashes:backfill", nil), mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Geohashes written on the sites and the spokes without one", func(t *testing.T) {
+		repository := getGeohashesBackfillRepository(t)
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/geohashes:backfill", map[string]string{common.HeaderRetailerID: "r11111"})
+		patchGeohashesBackfillHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"2 geohashes backfilled\"}", string(bytes))
+		for collectionPath, documentID := range map[string]string{
+			utils.GetSitePath("r11111"):  "s4r11111",
+			utils.GetSpokePath("r11111"): "p22222",
+		} {
+			data, err := repository.GetByID(context.Background(), collectionPath, documentID, false)
+			assert.Nil(t, err)
+			assert.Equal(t, "u4pruydqqv", data[common.Geohash])
+		}
+	})
+
+	t.Run("Backfill resumed with the page token when out of time", func(t *testing.T) {
+		backfillTimeBudget = 0
+		defer func() { backfillTimeBudget = common.RequeueTimeBudget }()
+		repository := getGeohashesBackfillRepository(t)
+		latitude, longitude := 10.0, 10.0
+		for i := 0; i < common.RequeuePageSize; i++ {
+			site := sites.Site{ID: fmt.Sprintf("s%05d", i), RetailerID: "r11111",
+				Location: &models.Location{Latitude: &latitude, Longitude: &longitude}}
+			_, err := repository.Save(context.Background(), utils.GetSitePath("r11111"), site.ID, site)
+			assert.Nil(t, err)
+		}
+
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/geohashes:backfill", map[string]string{common.HeaderRetailerID: "r11111"})
+		patchGeohashesBackfillHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"100 geohashes backfilled\"}", string(bytes))
+		pageToken := response.Header.Get(common.HeaderNextPageToken)
+		assert.NotEmpty(t, pageToken)
+
+		// the sites are all visited, the backfill stops before the spokes
+		w = httptest.NewRecorder()
+		r.Header.Set(common.HeaderPageToken, pageToken)
+		patchGeohashesBackfillHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"1 geohashes backfilled\"}", string(bytes))
+		pageToken = response.Header.Get(common.HeaderNextPageToken)
+		assert.NotEmpty(t, pageToken)
+
+		w = httptest.NewRecorder()
+		r.Header.Set(common.HeaderPageToken, pageToken)
+		patchGeohashesBackfillHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"1 geohashes backfilled\"}", string(bytes))
+	})
+
+	t.Run("Error while fetching the sites", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r11111", false).
+			Return(map[string]interface{}{"id": "r11111"}, nil)
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSitePath("r11111"), mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout")).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/geohashes:backfill", map[string]string{common.HeaderRetailerID: "r11111"})
+		patchGeohashesBackfillHandler(w, r, fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
@@ -0,0 +1,189 @@
+package admin
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+	"time"
+)
+
+// This file has the function and handler to copy the sites and the spokes onto the site spoke mappings of a retailer
+// which were attached before the mappings kept a copy of them, so they are listed with the site spokes and spoke sites
+var patchSiteSpokesBackfillPath = urit.MustCreateTemplate("/siteInfoservice/siteSpokes:backfill")
+
+// backfillTimeBudget is the time after which the backfill stops and responds with a page token to resume from
+var backfillTimeBudget = common.RequeueTimeBudget
+
+func init() {
+	functions.HTTP("PatchSiteSpokesBackfill", patchSiteSpokesBackfill)
+}
+
+var patchSiteSpokesBackfill = authorization.Authorize("patch_site_spokes_backfill.patchSiteSpokesBackfill",
+	authorization.OnAllRetailers(common.RoleAdmin), patchSiteSpokesBackfillHandler)
+
+func patchSiteSpokesBackfillHandler(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("patch_site_spokes_backfill.patchSiteSpokesBackfillHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	_, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(spokes.GetRequiredHeaders(), utils.AddPaginationHeaderIfNotAdded(request)...),
+		RequiredPath:    patchSiteSpokesBackfillPath,
+		RequestMethod:   http.MethodPatch,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, false) {
+		return
+	}
+
+	backfill := &siteSpokesBackfiller{
+		dbClient:   dbClient,
+		retailerID: retailerID,
+		deadline:   time.Now().Add(backfillTimeBudget),
+	}
+	if request.Header.Get(common.HeaderPageToken) != "" {
+		cursor, err := utils.DecodeNextPageToken(request, "")
+		if err != nil {
+			logger.Errorf("Error occurred while decoding the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+		backfill.documentID = cursor
+	}
+
+	completed, err := backfill.run(ctx)
+	if err != nil {
+		logger.Errorf("Error while backfilling the site spokes after %d site spokes : %v", backfill.backfilled, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	var nextPageToken string
+	if !completed {
+		nextPageToken, err = utils.GetNextPageToken(request, backfill.documentID)
+		if err != nil {
+			logger.Errorf("Error occurred while creating the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+	}
+
+	logger.Debugf("%d site spokes backfilled, completed : %t", backfill.backfilled, completed)
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusOK, fmt.Sprintf("%d site spokes backfilled", backfill.backfilled), nil),
+		response.GetCommonResponseHeaders(request).WithHeader(common.HeaderNextPageToken, nextPageToken))
+}
+
+// siteSpokesBackfiller copies the sites and the spokes onto the site spoke mappings of the retailer a page at a time,
+// keeping track of the last visited mapping as the documentID so that the backfill can be resumed from there
+type siteSpokesBackfiller struct {
+	dbClient   cloud.DB
+	retailerID string
+	deadline   time.Time
+	visited    int
+	backfilled int
+	documentID string
+}
+
+// run backfills the site spoke mappings ordered by ID starting after b.documentID,
+// returns false if the time budget ran out before all the mappings were visited
+func (b *siteSpokesBackfiller) run(ctx context.Context) (bool, error) {
+	for {
+		// a request runs out of time only once it visited some mappings so that the backfill always progresses
+		if b.visited > 0 && time.Now().After(b.deadline) {
+			return false, nil
+		}
+		var startAfter *cloud.Cursor
+		if b.documentID != "" {
+			startAfter = &cloud.Cursor{ID: b.documentID}
+		}
+		siteSpokes, _, err := b.dbClient.GetAll(ctx, utils.GetSiteSpokePath(b.retailerID),
+			cloud.Page{StartAfter: startAfter, PageSize: common.RequeuePageSize}, nil)
+		if err != nil {
+			return false, err
+		}
+		for _, siteSpoke := range siteSpokes {
+			if err := b.backfill(ctx, siteSpoke); err != nil {
+				return false, err
+			}
+			b.documentID = fmt.Sprint(siteSpoke[common.ID])
+		}
+		if len(siteSpokes) < common.RequeuePageSize {
+			return true, nil
+		}
+	}
+}
+
+// backfill copies the site and the spoke onto the site spoke mapping unless it has a copy of them already.
+// A site or a spoke updated before the backfill leaves only the updated fields on the mapping, so it is copied again
+func (b *siteSpokesBackfiller) backfill(ctx context.Context, siteSpoke map[string]interface{}) error {
+	b.visited++
+	var updates []firestore.Update
+	if !hasCopy(siteSpoke, common.SiteSpokeSite) {
+		var site sites.Site
+		err := b.getDocument(ctx, utils.GetSitePath(b.retailerID), fmt.Sprint(siteSpoke[common.SiteID]), &site)
+		if err != nil {
+			return err
+		}
+		updates = append(updates, firestore.Update{Path: common.SiteSpokeSite, Value: site})
+	}
+	if !hasCopy(siteSpoke, common.SiteSpokeSpoke) {
+		var spoke spokes.Spoke
+		err := b.getDocument(ctx, utils.GetSpokePath(b.retailerID), fmt.Sprint(siteSpoke[common.SpokeID]), &spoke)
+		if err != nil {
+			return err
+		}
+		updates = append(updates, firestore.Update{Path: common.SiteSpokeSpoke, Value: spoke})
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	_, err := b.dbClient.Update(ctx, utils.GetSiteSpokePath(b.retailerID), fmt.Sprint(siteSpoke[common.ID]), updates)
+	if err != nil {
+		return err
+	}
+	b.backfilled++
+
+	return nil
+}
+
+// getDocument converts the document into the entity, including a deactivated document
+func (b *siteSpokesBackfiller) getDocument(ctx context.Context, collectionPath string, documentID string,
+	entity any) error {
+	data, err := b.dbClient.GetByID(ctx, collectionPath, documentID, false)
+	if err != nil {
+		return err
+	}
+
+	return utils.ConvertToObject(data, entity)
+}
+
+// hasCopy returns whether the site spoke mapping has a complete copy of the entity under the field
+func hasCopy(siteSpoke map[string]interface{}, field string) bool {
+	entity, ok := siteSpoke[field].(map[string]interface{})
+
+	return ok && entity[common.ID] != nil
+}
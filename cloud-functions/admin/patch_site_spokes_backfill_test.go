@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getBackfillRepository returns the test repository with a spoke attached to two more sites of r11111,
+// without a copy of the site and the spoke and with only the name of the spoke updated before the backfill
+func getBackfillRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := getTestRepository(t)
+	_, err := repository.Save(ctx, utils.GetSitePath("r11111"), "s3r11111",
+		sites.Site{ID: "s3r11111", RetailerID: "r11111"})
+	assert.Nil(t, err)
+	for _, siteSpoke := range []map[string]interface{}{
+		{"id": "s2r11111_p11111", "site_id": "s2r11111", "spoke_id": "p11111", "retailer_id": "r11111"},
+		{"id": "s3r11111_p11111", "site_id": "s3r11111", "spoke_id": "p11111", "retailer_id": "r11111",
+			"spoke": map[string]interface{}{"name": "renamed"}},
+	} {
+		_, err := repository.Save(ctx, utils.GetSiteSpokePath("r11111"), fmt.Sprint(siteSpoke["id"]), siteSpoke)
+		assert.Nil(t, err)
+	}
+
+	return repository
+}
+
+func Test_patchSiteSpokesBackfillHandler(t *testing.T) {
+	t.Run("Retailer header not passed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		patchSiteSpokesBackfillHandler(w, getRequest("/siteInfoservice/siteSpokes:backfill", nil), mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Retailer not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/siteSpokes:backfill", map[string]string{common.HeaderRetailerID: "r99999"})
+		patchSiteSpokesBackfillHandler(w, r, getTestRepository(t))
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("Sites and spokes copied onto the site spokes without a complete copy", func(t *testing.T) {
+		repository := getBackfillRepository(t)
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/siteSpokes:backfill", map[string]string{common.HeaderRetailerID: "r11111"})
+		patchSiteSpokesBackfillHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"2 site spokes backfilled\"}", string(bytes))
+		for _, siteSpokeID := range []string{"s1r11111_p11111", "s2r11111_p11111", "s3r11111_p11111"} {
+			data, err := repository.GetByID(context.Background(), utils.GetSiteSpokePath("r11111"), siteSpokeID, false)
+			assert.Nil(t, err)
+			var siteSpoke spokes.SiteSpoke
+			assert.Nil(t, utils.ConvertToObject(data, &siteSpoke))
+			assert.Equal(t, siteSpokeID[:8], siteSpoke.Site.ID)
+			assert.Equal(t, "p11111", siteSpoke.Spoke.ID)
+			assert.Equal(t, "r11111", siteSpoke.Spoke.RetailerID)
+		}
+	})
+
+	t.Run("Backfill resumed with the page token when out of time", func(t *testing.T) {
+		backfillTimeBudget = 0
+		defer func() { backfillTimeBudget = common.RequeueTimeBudget }()
+		repository := getBackfillRepository(t)
+		for i := 0; i < common.RequeuePageSize; i++ {
+			site := sites.Site{ID: fmt.Sprintf("s%05d", i), RetailerID: "r11111"}
+			_, err := repository.Save(context.Background(), utils.GetSitePath("r11111"), site.ID, site)
+			assert.Nil(t, err)
+			siteSpoke := spokes.SiteSpoke{ID: site.ID + "_p11111", SiteID: site.ID, SpokeID: "p11111", RetailerID: "r11111"}
+			_, err = repository.Save(context.Background(), utils.GetSiteSpokePath("r11111"), siteSpoke.ID, siteSpoke)
+			assert.Nil(t, err)
+		}
+
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/siteSpokes:backfill", map[string]string{common.HeaderRetailerID: "r11111"})
+		patchSiteSpokesBackfillHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"100 site spokes backfilled\"}", string(bytes))
+		pageToken := response.Header.Get(common.HeaderNextPageToken)
+		assert.NotEmpty(t, pageToken)
+
+		w = httptest.NewRecorder()
+		r.Header.Set(common.HeaderPageToken, pageToken)
+		patchSiteSpokesBackfillHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"2 site spokes backfilled\"}", string(bytes))
+	})
+
+	t.Run("Error while fetching the site spokes", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r11111", false).
+			Return(map[string]interface{}{"id": "r11111"}, nil)
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSiteSpokePath("r11111"), mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout")).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/siteSpokes:backfill", map[string]string{common.HeaderRetailerID: "r11111"})
+		patchSiteSpokesBackfillHandler(w, r, fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
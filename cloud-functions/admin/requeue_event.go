@@ -0,0 +1,317 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	retailers "github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// This file has the function and handler to requeue the creation or deletion events of all the entities
+// of a type, for new downstream consumers to bootstrap and for consumers which missed events to recover
+var requeueEventPath = urit.MustCreateTemplate(fmt.Sprintf("/siteInfoservice/{%s}:requeue_event",
+	common.PathParamEntity))
+
+// requeueTimeBudget is the time after which the requeue stops and responds with a page token to resume from
+var requeueTimeBudget = common.RequeueTimeBudget
+
+// requeueEntity describes where the documents of an entity type are and the messages published for them
+type requeueEntity struct {
+	topic         string
+	encryptionKey string
+	// collectionPath returns the path of the documents of the retailer, nil for the retailers themselves
+	collectionPath func(retailerID string, deletion bool) string
+	message        func(document map[string]interface{}, changeType string) (any, error)
+}
+
+var requeueEntities = map[string]requeueEntity{
+	common.EntityRetailer: {
+		topic:         common.EnvRetailerMessageTopic,
+		encryptionKey: common.RetailersEncryptionKey,
+		message:       getRetailerMessage,
+	},
+	common.EntitySite: {
+		topic:          common.EnvSiteMessageTopic,
+		encryptionKey:  common.SitesEncryptionKey,
+		collectionPath: func(retailerID string, _ bool) string { return utils.GetSitePath(retailerID) },
+		message:        getSiteMessage,
+	},
+	common.EntitySpoke: {
+		topic:          common.EnvSpokeMessageTopic,
+		encryptionKey:  common.SpokesEncryptionKey,
+		collectionPath: getSpokeCollectionPath,
+		message:        getSpokeMessage,
+	},
+}
+
+func init() {
+	functions.HTTP("RequeueEvent", requeueEvent)
+}
+
+var requeueEvent = authorization.Authorize("requeue_event.requeueEvent",
+	authorization.OnAllRetailers(common.RoleAdmin),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		requeueEventHandler(responseWriter, request, dbClient, cloud.NewPubSubRepository(request.Context()))
+	})
+
+func requeueEventHandler(responseWriter http.ResponseWriter, request *http.Request,
+	dbClient cloud.DB, pubsubClient cloud.Queue) {
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("requeue_event.requeueEventHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(common.GetMandatoryHeaders(), utils.AddPaginationHeaderIfNotAdded(request)...),
+		RequiredPath:    requeueEventPath,
+		RequestMethod:   http.MethodPatch,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	entityName := strings.ToLower(pathParams[common.PathParamEntity])
+	entity, ok := requeueEntities[entityName]
+	if !ok {
+		logger.Debugf("Invalid entity got from request : %s", entityName)
+		response.RespondWithResponseObject(responseWriter, response.NewResponse(http.StatusBadRequest,
+			fmt.Sprintf("Invalid entity '%s' received in the request", entityName), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	if retailerID != "" && !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, false) {
+		return
+	}
+
+	requeue := &requeuer{
+		dbClient:     dbClient,
+		pubsubClient: pubsubClient,
+		entity:       entity,
+		deletion:     strings.ToLower(request.URL.Query().Get(common.QueryParamDeletion)) == common.True,
+		deadline:     time.Now().Add(requeueTimeBudget),
+	}
+	if request.Header.Get(common.HeaderPageToken) != "" {
+		cursor, err := utils.DecodeNextPageToken(request, entity.encryptionKey)
+		if err != nil {
+			logger.Errorf("Error occurred while decoding the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+		requeue.retailerID, requeue.documentID, _ = strings.Cut(cursor, common.RequeueCursorSeparator)
+	}
+
+	completed, err := requeue.run(ctx, retailerID)
+	if err != nil {
+		logger.Errorf("Error while requeueing the %s events after %d events : %v", entityName, requeue.requeued, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	var nextPageToken string
+	if !completed {
+		nextPageToken, err = utils.GetNextPageToken(request,
+			requeue.retailerID+common.RequeueCursorSeparator+requeue.documentID)
+		if err != nil {
+			logger.Errorf("Error occurred while creating the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+	}
+
+	logger.Debugf("%d %s events requeued, completed : %t", requeue.requeued, entityName, completed)
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusOK, fmt.Sprintf("%d %s events requeued", requeue.requeued, entityName), nil),
+		response.GetCommonResponseHeaders(request).WithHeader(common.HeaderNextPageToken, nextPageToken))
+}
+
+// requeuer publishes the events of the documents a page at a time, keeping track of the last published document
+// as the retailerID and documentID so that the requeue can be resumed from there
+type requeuer struct {
+	dbClient     cloud.DB
+	pubsubClient cloud.Queue
+	entity       requeueEntity
+	deletion     bool
+	deadline     time.Time
+	visited      int
+	requeued     int
+	retailerID   string
+	documentID   string
+}
+
+// run requeues the events of the retailer if passed else of all the retailers,
+// returns false if the time budget ran out before all the events were requeued
+func (r *requeuer) run(ctx context.Context, retailerID string) (bool, error) {
+	if r.entity.collectionPath == nil {
+		if retailerID != "" {
+			retailer, err := r.dbClient.GetByID(ctx, common.RetailersCollection, retailerID, false)
+			if err != nil {
+				return false, err
+			}
+
+			return true, r.publish(ctx, retailer)
+		}
+
+		return r.walk(ctx, common.RetailersCollection, r.retailerID,
+			func(retailer map[string]interface{}) (bool, error) {
+				r.retailerID = fmt.Sprint(retailer[common.ID])
+
+				return true, r.publish(ctx, retailer)
+			})
+	}
+
+	if retailerID != "" {
+		r.retailerID = retailerID
+
+		return r.runRetailer(ctx, r.documentID)
+	}
+	// finish the retailer the previous request stopped at before moving on to the next ones
+	if r.retailerID != "" {
+		completed, err := r.runRetailer(ctx, r.documentID)
+		if err != nil || !completed {
+			return completed, err
+		}
+	}
+
+	return r.walk(ctx, common.RetailersCollection, r.retailerID, func(retailer map[string]interface{}) (bool, error) {
+		r.retailerID = fmt.Sprint(retailer[common.ID])
+		r.documentID = ""
+
+		return r.runRetailer(ctx, "")
+	})
+}
+
+// runRetailer requeues the events of the documents of r.retailerID starting after startAfterID
+func (r *requeuer) runRetailer(ctx context.Context, startAfterID string) (bool, error) {
+	return r.walk(ctx, r.entity.collectionPath(r.retailerID, r.deletion), startAfterID,
+		func(document map[string]interface{}) (bool, error) {
+			r.documentID = fmt.Sprint(document[common.ID])
+
+			return true, r.publish(ctx, document)
+		})
+}
+
+// walk calls visit for the documents under the collectionPath ordered by ID starting after startAfterID,
+// returns false if the time budget ran out or visit returned false before all the documents were visited
+func (r *requeuer) walk(ctx context.Context, collectionPath string, startAfterID string,
+	visit func(document map[string]interface{}) (bool, error)) (bool, error) {
+	for {
+		// a request runs out of time only once it visited some documents so that the requeue always progresses
+		if r.visited > 0 && time.Now().After(r.deadline) {
+			return false, nil
+		}
+		var startAfter *cloud.Cursor
+		if startAfterID != "" {
+			startAfter = &cloud.Cursor{ID: startAfterID}
+		}
+		// the documents are ordered by their ID only
+		documents, lastDoc, err := r.dbClient.GetAll(ctx, collectionPath,
+			cloud.Page{StartAfter: startAfter, PageSize: common.RequeuePageSize}, nil)
+		if err != nil {
+			return false, err
+		}
+		for _, document := range documents {
+			if next, err := visit(document); err != nil || !next {
+				return false, err
+			}
+		}
+		if len(documents) < common.RequeuePageSize {
+			return true, nil
+		}
+		startAfterID = lastDoc.ID
+	}
+}
+
+// publish publishes the create event of the document if it is active,
+// or the delete event if it is deactivated when requeueing deletions
+func (r *requeuer) publish(ctx context.Context, document map[string]interface{}) error {
+	r.visited++
+	if (document[common.DeactivatedTime] != nil) != r.deletion {
+		return nil
+	}
+	changeType := common.ChangeTypeCreate
+	if r.deletion {
+		changeType = common.ChangeTypeDelete
+	}
+	message, err := r.entity.message(document, changeType)
+	if err != nil {
+		return err
+	}
+	if err := r.pubsubClient.Publish(ctx, os.Getenv(r.entity.topic), message); err != nil {
+		return err
+	}
+	r.requeued++
+
+	return nil
+}
+
+func getRetailerMessage(document map[string]interface{}, changeType string) (any, error) {
+	var retailer retailers.Retailer
+	if err := utils.ConvertToObject(document, &retailer); err != nil {
+		return nil, err
+	}
+	// the retailer deactivations are published with the deactivate change type
+	if changeType == common.ChangeTypeDelete {
+		changeType = common.AuditTypeDeactivate
+	}
+
+	return retailers.GetPubSubRetailerMessage(retailer.ID, changeType), nil
+}
+
+func getSiteMessage(document map[string]interface{}, changeType string) (any, error) {
+	var site sites.Site
+	if err := utils.ConvertToObject(document, &site); err != nil {
+		return nil, err
+	}
+
+	return sites.GetPubSubSiteMessage(site.RetailerID, site.ID, changeType), nil
+}
+
+// getSpokeCollectionPath returns the site spoke mappings for the creations as a spoke is created per site,
+// and the spokes themselves for the deletions as the detached mappings are not kept
+func getSpokeCollectionPath(retailerID string, deletion bool) string {
+	if deletion {
+		return utils.GetSpokePath(retailerID)
+	}
+
+	return utils.GetSiteSpokePath(retailerID)
+}
+
+func getSpokeMessage(document map[string]interface{}, changeType string) (any, error) {
+	if changeType == common.ChangeTypeDelete {
+		var spoke spokes.Spoke
+		if err := utils.ConvertToObject(document, &spoke); err != nil {
+			return nil, err
+		}
+
+		return spokes.GetPubSubSpokeMessage(spoke.RetailerID, "", spoke.ID, "", changeType), nil
+	}
+	var siteSpoke spokes.SiteSpoke
+	if err := utils.ConvertToObject(document, &siteSpoke); err != nil {
+		return nil, err
+	}
+
+	return spokes.GetPubSubSpokeMessage(siteSpoke.RetailerID, siteSpoke.SiteID, siteSpoke.SpokeID, siteSpoke.ID,
+		changeType), nil
+}
@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	retailers "github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func init() {
+	_ = os.Setenv(common.EnvProjectID, "project-id")
+	_ = os.Setenv(common.EnvRetailerMessageTopic, "RETAILER_MESSAGE_TOPIC")
+	_ = os.Setenv(common.EnvSiteMessageTopic, "SITE_MESSAGE_TOPIC")
+	_ = os.Setenv(common.EnvSpokeMessageTopic, "SPOKE_MESSAGE_TOPIC")
+	_ = os.Setenv(common.EnvPageTokenKeys, "test:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	_ = os.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+}
+
+func getRequest(url string, headers map[string]string) *http.Request {
+	request := httptest.NewRequest(http.MethodPatch, url, nil)
+	request.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
+	request.Header.Set(common.HeaderXCorrelationID, "c12345")
+	for header, value := range headers {
+		request.Header.Set(header, value)
+	}
+
+	return request
+}
+
+// getTestRepository returns a repository with an active and a deactivated retailer,
+// each with an active and a deactivated site, and a spoke attached to the active site of the active retailer
+func getTestRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := cloud.NewMemoryRepository()
+	deactivatedTime := time.Now().UTC()
+	for _, retailer := range []retailers.Retailer{
+		{ID: "r11111", Name: "active"},
+		{ID: "r22222", Name: "deactivated", DeactivatedTime: &deactivatedTime},
+	} {
+		_, err := repository.Save(ctx, common.RetailersCollection, retailer.ID, retailer)
+		assert.Nil(t, err)
+		for _, site := range []sites.Site{
+			{ID: "s1" + retailer.ID, RetailerID: retailer.ID, DeactivatedTime: retailer.DeactivatedTime},
+			{ID: "s2" + retailer.ID, RetailerID: retailer.ID, DeactivatedTime: &deactivatedTime},
+		} {
+			_, err = repository.Save(ctx, utils.GetSitePath(retailer.ID), site.ID, site)
+			assert.Nil(t, err)
+		}
+	}
+	spoke := spokes.Spoke{ID: "p11111", RetailerID: "r11111"}
+	_, err := repository.Save(ctx, utils.GetSpokePath("r11111"), spoke.ID, spoke)
+	assert.Nil(t, err)
+	siteSpoke := spokes.NewSiteSpoke(sites.Site{ID: "s1r11111", RetailerID: "r11111"}, spoke, common.User)
+	_, err = repository.Save(ctx, utils.GetSiteSpokePath("r11111"), siteSpoke.ID, siteSpoke)
+	assert.Nil(t, err)
+
+	return repository
+}
+
+func Test_requeueEventHandler(t *testing.T) {
+	t.Run("Invalid request method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/site:requeue_event", nil)
+		r.Method = http.MethodPost
+		requeueEventHandler(w, r, mocks.NewDB(t), mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Invalid entity", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/store:requeue_event", nil)
+		requeueEventHandler(w, r, mocks.NewDB(t), mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Invalid entity 'store' received in the request\"}", string(bytes))
+	})
+
+	t.Run("Retailer not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/site:requeue_event", map[string]string{common.HeaderRetailerID: "r99999"})
+		requeueEventHandler(w, r, getTestRepository(t), mocks.NewQueue(t))
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("Retailer create events", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "RETAILER_MESSAGE_TOPIC",
+			retailers.GetPubSubRetailerMessage("r11111", common.ChangeTypeCreate)).Return(nil).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/retailer:requeue_event", nil)
+		requeueEventHandler(w, r, getTestRepository(t), pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"1 retailer events requeued\"}", string(bytes))
+	})
+
+	t.Run("Retailer deletion events", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "RETAILER_MESSAGE_TOPIC",
+			retailers.GetPubSubRetailerMessage("r22222", common.AuditTypeDeactivate)).Return(nil).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/retailer:requeue_event?deletion=true", nil)
+		requeueEventHandler(w, r, getTestRepository(t), pubSubClient)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("Site deletion events of all the retailers", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		for _, site := range []string{"s1r22222", "s2r11111", "s2r22222"} {
+			pubSubClient.On("Publish", mock.Anything, "SITE_MESSAGE_TOPIC",
+				sites.GetPubSubSiteMessage(site[2:], site, common.ChangeTypeDelete)).Return(nil).Once()
+		}
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/site:requeue_event?deletion=true", nil)
+		requeueEventHandler(w, r, getTestRepository(t), pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"3 site events requeued\"}", string(bytes))
+	})
+
+	t.Run("Site create events of a retailer", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "SITE_MESSAGE_TOPIC",
+			sites.GetPubSubSiteMessage("r11111", "s1r11111", common.ChangeTypeCreate)).Return(nil).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/site:requeue_event", map[string]string{common.HeaderRetailerID: "r11111"})
+		requeueEventHandler(w, r, getTestRepository(t), pubSubClient)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("Spoke create events", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "SPOKE_MESSAGE_TOPIC",
+			spokes.GetPubSubSpokeMessage("r11111", "s1r11111", "p11111", "s1r11111_p11111", common.ChangeTypeCreate)).
+			Return(nil).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/spoke:requeue_event", nil)
+		requeueEventHandler(w, r, getTestRepository(t), pubSubClient)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("Error while publishing", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "SITE_MESSAGE_TOPIC", mock.Anything).
+			Return(errors.New("connection timeout")).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/site:requeue_event", nil)
+		requeueEventHandler(w, r, getTestRepository(t), pubSubClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Error while fetching the documents", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetAll", mock.Anything, common.RetailersCollection, mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout")).Once()
+		w := httptest.NewRecorder()
+		r := getRequest("/siteInfoservice/retailer:requeue_event", nil)
+		requeueEventHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Requeue resumed with the page token when out of time", func(t *testing.T) {
+		requeueTimeBudget = 0
+		defer func() { requeueTimeBudget = common.RequeueTimeBudget }()
+		repository := getTestRepository(t)
+		for i := 0; i < common.RequeuePageSize; i++ {
+			site := sites.Site{ID: fmt.Sprintf("s%05d", i), RetailerID: "r11111"}
+			_, err := repository.Save(context.Background(), utils.GetSitePath("r11111"), site.ID, site)
+			assert.Nil(t, err)
+		}
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, "SITE_MESSAGE_TOPIC", mock.Anything).Return(nil)
+
+		w := httptest.NewRecorder()
+		requeueEventHandler(w, getRequest("/siteInfoservice/site:requeue_event", nil), repository, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"100 site events requeued\"}", string(bytes))
+		pageToken := response.Header.Get(common.HeaderNextPageToken)
+		assert.NotEmpty(t, pageToken)
+
+		// every request visits a page before running out of time, so the requeue completes
+		for requests := 0; pageToken != "" && requests < 5; requests++ {
+			w = httptest.NewRecorder()
+			requeueEventHandler(w, getRequest("/siteInfoservice/site:requeue_event",
+				map[string]string{common.HeaderPageToken: pageToken}), repository, pubSubClient)
+			assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+			pageToken = w.Result().Header.Get(common.HeaderNextPageToken)
+		}
+		assert.Empty(t, pageToken)
+		pubSubClient.AssertNumberOfCalls(t, "Publish", common.RequeuePageSize+1)
+	})
+}
@@ -3,10 +3,12 @@ package audit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
 	retailers "github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
 	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
 	"github.com/TakeoffTech/site-info-svc/common/audit/models"
@@ -14,10 +16,10 @@ import (
 	"github.com/TakeoffTech/site-info-svc/common/logging"
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/fatih/structs"
-	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -43,9 +45,11 @@ func PushAudit(ctx context.Context, event event.Event) error {
 		return err
 	}
 
-	return pushAuditLog(ctx, msg.Message.Data, cloud.NewFirestoreRepository(ctx))
+	return pushAuditLog(ctx, msg.Message.Data, cloud.NewDBRepository(ctx))
 }
 
+// pushAuditLog saves the audit log of the message under the ID derived from the message so that redeliveries
+// are no-ops. A message which can never be saved is dead lettered and acknowledged instead of being retried forever
 func pushAuditLog(ctx context.Context, data []byte, dbClient cloud.DB) error {
 	logger := logging.GetLoggerFromContext(ctx)
 	var pubsubAuditMsg audit.PubSubAuditMessage
@@ -53,40 +57,73 @@ func pushAuditLog(ctx context.Context, data []byte, dbClient cloud.DB) error {
 	if err != nil {
 		logger.Errorf("Error occurred while converting data to audit struct: %v", err)
 
-		return err
+		return pushDeadLetter(ctx, data, fmt.Sprintf("invalid audit message : %v", err), dbClient)
 	}
-	auditLog := getAuditLog(&pubsubAuditMsg)
 	logger = logging.GetLoggerWithXCorrelationID(pubsubAuditMsg.XCorrelationID)
-	logger.Debugf("Audit Entity to be pushed %+v", auditLog)
+	if err = validateAuditMessage(&pubsubAuditMsg); err != nil {
+		logger.Errorf("Invalid audit message received : %v", err)
 
-	var retryCount int
-	uniqueID := uuid.NewString()
-	for retryCount = 0; retryCount < common.MaxRetryCount; retryCount++ {
-		updateTime, err := dbClient.Save(ctx, pubsubAuditMsg.Path, uniqueID, auditLog)
-		if err != nil {
-			if status.Code(err) == codes.AlreadyExists {
-				logger.Infof("Audit Log with ID %s already exists. Now generating new uuid for saving", uniqueID)
-				uniqueID = uuid.NewString()
-
-				continue
-			} else {
-				logger.Errorf("Error occurred while saving audit entity %v", err)
-			}
+		return pushDeadLetter(ctx, data, err.Error(), dbClient)
+	}
+	auditID, err := audit.GetAuditLogID(&pubsubAuditMsg)
+	if err != nil {
+		logger.Errorf("Error occurred while generating the audit log id : %v", err)
 
-			return err
-		}
+		return pushDeadLetter(ctx, data, fmt.Sprintf("audit log id not generated : %v", err), dbClient)
+	}
+	auditLog := getAuditLog(&pubsubAuditMsg)
+	logger.Debugf("Audit Entity to be pushed %+v", auditLog)
+
+	updateTime, err := dbClient.Save(ctx, pubsubAuditMsg.Path, auditID, auditLog)
+	switch status.Code(err) {
+	case codes.OK:
 		logger.Infof("Audit Entity pushed successfully in %s at %v", pubsubAuditMsg.Path, updateTime)
+	case codes.AlreadyExists:
+		logger.Infof("Audit Log with ID %s already exists in %s, ignoring the redelivered message",
+			auditID, pubsubAuditMsg.Path)
+	case codes.InvalidArgument:
+		logger.Errorf("Audit entity rejected by the DB : %v", err)
 
-		break
+		return pushDeadLetter(ctx, data, fmt.Sprintf("audit log rejected : %v", err), dbClient)
+	default:
+		logger.Errorf("Error occurred while saving audit entity %v", err)
+
+		return err
 	}
 
-	if retryCount == common.MaxRetryCount {
-		logger.Errorf("Unable to create retailer after %d retires "+
-			"as function was unable to generate unique id : %v", common.MaxRetryCount, err)
+	return nil
+}
 
-		return fmt.Errorf("unable to create retailer after %d retires as function was unable to generate unique id",
-			common.MaxRetryCount)
+// validateAuditMessage returns an error if the message can never be saved as an audit log
+func validateAuditMessage(msg *audit.PubSubAuditMessage) error {
+	// a collection path has an odd number of segments
+	if msg.Path == "" || len(strings.Split(msg.Path, "/"))%2 == 0 {
+		return fmt.Errorf("invalid audit path '%s'", msg.Path)
 	}
+	if msg.ChangedAt == nil {
+		return errors.New("changed_at is missing")
+	}
+	switch msg.ChangeType {
+	case common.AuditTypeCreate, common.AuditTypeUpdate, common.AuditTypeDeactivate, common.AuditTypeUndelete,
+		common.AuditTypeAttach, common.AuditTypeDetach:
+		return nil
+	default:
+		return fmt.Errorf("invalid change type '%s'", msg.ChangeType)
+	}
+}
+
+// pushDeadLetter saves the payload in the dead letter collection, returning an error only if it could not be saved
+// so that the message is retried rather than lost
+func pushDeadLetter(ctx context.Context, data []byte, reason string, dbClient cloud.DB) error {
+	logger := logging.GetLoggerFromContext(ctx)
+	deadLetter := audit.NewDeadLetter(data, reason)
+	_, err := dbClient.Save(ctx, common.AuditDeadLetterCollection, deadLetter.ID, deadLetter)
+	if err != nil && status.Code(err) != codes.AlreadyExists {
+		logger.Errorf("Error occurred while saving the dead letter of the audit message : %v", err)
+
+		return err
+	}
+	logger.Infof("Audit message dead lettered with ID %s : %s", deadLetter.ID, reason)
 
 	return nil
 }
@@ -95,35 +132,36 @@ func pushAuditLog(ctx context.Context, data []byte, dbClient cloud.DB) error {
 // auditLog object which will be saved to the firestore
 func getAuditLog(msg *audit.PubSubAuditMessage) *models.AuditLog {
 	var diffs []models.Diff
-	auditFields := getAuditChangeDetailFields(msg)
+	auditFields, oldEntity, newEntity := expandAttributes(getAuditChangeDetailFields(msg), msg.OldEntity,
+		msg.NewEntity)
 	switch msg.ChangeType {
-	case common.AuditTypeCreate:
+	case common.AuditTypeCreate, common.AuditTypeUndelete, common.AuditTypeAttach:
 		for _, key := range auditFields {
-			if msg.NewEntity[key] != nil {
+			if newEntity[key] != nil {
 				diffs = append(diffs, models.Diff{
 					Field:    key,
 					OldValue: nil,
-					NewValue: msg.NewEntity[key],
+					NewValue: newEntity[key],
 				})
 			}
 		}
-	case common.AuditTypeDeactivate:
+	case common.AuditTypeDeactivate, common.AuditTypeDetach:
 		for _, key := range auditFields {
-			if msg.OldEntity[key] != nil {
+			if oldEntity[key] != nil {
 				diffs = append(diffs, models.Diff{
 					Field:    key,
-					OldValue: msg.OldEntity[key],
+					OldValue: oldEntity[key],
 					NewValue: nil,
 				})
 			}
 		}
 	case common.AuditTypeUpdate:
 		for _, key := range auditFields {
-			if !reflect.DeepEqual(msg.OldEntity[key], msg.NewEntity[key]) {
+			if !reflect.DeepEqual(oldEntity[key], newEntity[key]) {
 				diffs = append(diffs, models.Diff{
 					Field:    key,
-					OldValue: msg.OldEntity[key],
-					NewValue: msg.NewEntity[key],
+					OldValue: oldEntity[key],
+					NewValue: newEntity[key],
 				})
 			}
 		}
@@ -142,12 +180,19 @@ func getAuditChangeDetailFields(msg *audit.PubSubAuditMessage) []string {
 		fields = structs.Fields(retailers.Retailer{})
 	case common.EntitySite:
 		fields = structs.Fields(sites.Site{})
+	case common.EntitySpoke:
+		fields = structs.Fields(spokes.Spoke{})
+	case common.EntitySiteSpoke:
+		// all the site spoke fields are disallowed, the attachment is identified by the site and the spoke
+		return []string{common.SiteID, common.SpokeID}
 	default:
 		return extractFields(msg.NewEntity)
 	}
 	for _, field := range fields {
 		if !strings.Contains(field.Tag(common.Validate), common.Disallowed) {
-			auditFields = append(auditFields, field.Tag(common.Firestore))
+			// the firestore tag is the field name followed by its options, such as omitempty
+			name, _, _ := strings.Cut(field.Tag(common.Firestore), ",")
+			auditFields = append(auditFields, name)
 		}
 	}
 
@@ -162,3 +207,51 @@ func extractFields(entity map[string]interface{}) []string {
 
 	return keys
 }
+
+// expandAttributes returns the audit fields and the entities with the attributes of a site or a spoke expanded into
+// a field per attribute, named attributes.<name>, so the changes of the attributes are diffed like the other fields
+func expandAttributes(auditFields []string, oldEntity map[string]interface{},
+	newEntity map[string]interface{}) ([]string, map[string]interface{}, map[string]interface{}) {
+	for index, field := range auditFields {
+		if field != common.Attributes {
+			continue
+		}
+		expandedOld, oldNames := expandEntityAttributes(oldEntity)
+		expandedNew, newNames := expandEntityAttributes(newEntity)
+		names := oldNames
+		for _, name := range newNames {
+			if _, ok := expandedOld[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		fields := append(append(append([]string{}, auditFields[:index]...), names...), auditFields[index+1:]...)
+
+		return fields, expandedOld, expandedNew
+	}
+
+	return auditFields, oldEntity, newEntity
+}
+
+// expandEntityAttributes returns a copy of the entity with a field per attribute instead of the attributes, along
+// with the names of the fields
+func expandEntityAttributes(entity map[string]interface{}) (map[string]interface{}, []string) {
+	attributes, ok := entity[common.Attributes].(map[string]interface{})
+	if !ok {
+		return entity, nil
+	}
+	expanded := make(map[string]interface{}, len(entity)+len(attributes))
+	for key, value := range entity {
+		if key != common.Attributes {
+			expanded[key] = value
+		}
+	}
+	names := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		field := common.Attributes + "." + name
+		names = append(names, field)
+		expanded[field] = value
+	}
+
+	return expanded, names
+}
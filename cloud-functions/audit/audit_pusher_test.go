@@ -5,16 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	retailer "github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
 	"github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/fatih/structs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 	"os"
 	"testing"
 	"time"
@@ -31,7 +32,13 @@ func Test_getAuditChangeDetailFields(t *testing.T) {
 	t.Run("Get Audit Fields for entity Retailer", func(t *testing.T) {
 		msg := &audit.PubSubAuditMessage{EntityChanged: common.EntityRetailer}
 		fields := getAuditChangeDetailFields(msg)
-		assert.Equal(t, []string{"name"}, fields)
+		assert.Equal(t, []string{"name", "attribute_schema"}, fields)
+	})
+	t.Run("Get Audit Fields for entity Spoke", func(t *testing.T) {
+		msg := &audit.PubSubAuditMessage{EntityChanged: common.EntitySpoke}
+		fields := getAuditChangeDetailFields(msg)
+		assert.Equal(t, []string{"name", "location", "address", "service_area", "operating_hours", "attributes"},
+			fields)
 	})
 	t.Run("Get Audit Fields when entity not matched", func(t *testing.T) {
 		msg := &audit.PubSubAuditMessage{EntityChanged: "invalid"}
@@ -80,6 +87,17 @@ func Test_getAuditEntity(t *testing.T) {
 		assert.Contains(t, auditLog.ChangeDetails, models.Diff{NewValue: map[string]interface{}{"lat": 50, "long": 50}, Field: "location"})
 	})
 
+	t.Run("Get entity audit for retailer undelete", func(t *testing.T) {
+		currentTime := time.Now()
+		msg := audit.GetPubSubAuditMessage("path", "123", "user",
+			common.AuditTypeUndelete, common.EntityRetailer, &currentTime,
+			structs.Map(retailer.Retailer{Name: "RetailerName", DeactivatedTime: &currentTime}),
+			structs.Map(retailer.Retailer{Name: "RetailerName"}))
+		auditLog := getAuditLog(msg)
+		assert.Equal(t, "undelete", auditLog.ChangeType)
+		assert.Equal(t, []models.Diff{{NewValue: "RetailerName", Field: "name"}}, auditLog.ChangeDetails)
+	})
+
 	t.Run("Get entity audit for retailer update", func(t *testing.T) {
 		currentTime := time.Now()
 		expires := currentTime.Add(common.DataRetentionTime)
@@ -131,6 +149,29 @@ func Test_getAuditEntity(t *testing.T) {
 			Field:    "location"})
 	})
 
+	t.Run("Get entity audit for site attributes update", func(t *testing.T) {
+		currentTime := time.Now()
+		oldSite := map[string]interface{}{
+			"name":       "Site Name",
+			"attributes": map[string]interface{}{"brand": "Acme", "floors": 2, "tags": []interface{}{"a"}},
+		}
+		newSite := map[string]interface{}{
+			"name":       "Site Name",
+			"attributes": map[string]interface{}{"brand": "Acme", "floors": 3, "parking": true},
+		}
+		msg := audit.GetPubSubAuditMessage("path", "123", "user",
+			common.AuditTypeUpdate, common.EntitySite, &currentTime,
+			oldSite, newSite)
+		auditLog := getAuditLog(msg)
+		assert.Equal(t, []models.Diff{
+			{Field: "attributes.floors", OldValue: 2, NewValue: 3},
+			{Field: "attributes.parking", OldValue: nil, NewValue: true},
+			{Field: "attributes.tags", OldValue: []interface{}{"a"}, NewValue: nil},
+		}, auditLog.ChangeDetails)
+		assert.Equal(t, map[string]interface{}{"brand": "Acme", "floors": 2, "tags": []interface{}{"a"}},
+			oldSite["attributes"])
+	})
+
 	t.Run("Get entity audit for site status update", func(t *testing.T) {
 		currentTime := time.Now()
 		expires := currentTime.Add(common.DataRetentionTime)
@@ -191,6 +232,29 @@ func Test_getAuditEntity(t *testing.T) {
 		assert.Contains(t, auditLog.ChangeDetails, models.Diff{OldValue: map[string]interface{}{"lat": 50, "long": 50},
 			Field: "location"})
 	})
+
+	t.Run("Get entity audit for spoke attach", func(t *testing.T) {
+		currentTime := time.Now()
+		msg := audit.GetPubSubAuditMessage("path", "123", "user",
+			common.AuditTypeAttach, common.EntitySiteSpoke, &currentTime,
+			nil, structs.Map(spokes.NewSiteSpoke(sites.Site{ID: "s12345", RetailerID: "r12345"},
+				spokes.Spoke{ID: "p12345", RetailerID: "r12345"}, "user")))
+		auditLog := getAuditLog(msg)
+		assert.Equal(t, "attach", auditLog.ChangeType)
+		assert.Equal(t, []models.Diff{{NewValue: "s12345", Field: "site_id"}, {NewValue: "p12345", Field: "spoke_id"}},
+			auditLog.ChangeDetails)
+	})
+
+	t.Run("Get entity audit for spoke detach", func(t *testing.T) {
+		currentTime := time.Now()
+		msg := audit.GetPubSubAuditMessage("path", "123", "user",
+			common.AuditTypeDetach, common.EntitySiteSpoke, &currentTime,
+			structs.Map(spokes.SiteSpoke{SiteID: "s12345", SpokeID: "p12345"}), nil)
+		auditLog := getAuditLog(msg)
+		assert.Equal(t, "detach", auditLog.ChangeType)
+		assert.Equal(t, []models.Diff{{OldValue: "s12345", Field: "site_id"}, {OldValue: "p12345", Field: "spoke_id"}},
+			auditLog.ChangeDetails)
+	})
 }
 
 func Test_pushAuditEntity(t *testing.T) {
@@ -220,33 +284,68 @@ func Test_pushAuditEntity(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
-	t.Run("Push Audit Entity Failed with already exist error after retries", func(t *testing.T) {
-		firestore := mocks.NewDB(t)
+	t.Run("Redelivered message saved only once", func(t *testing.T) {
+		repository := cloud.NewMemoryRepository()
 		currentTime := time.Now().UTC().Round(time.Second)
-		msg := audit.GetPubSubAuditMessage("path", "123", "user",
+		msg := audit.GetPubSubAuditMessage("retailers/r12345/audit", "123", "user",
 			common.AuditTypeCreate, common.EntityRetailer, &currentTime,
 			nil, structs.Map(retailer.Retailer{Name: "RetailerName"}))
 		bytes, _ := json.Marshal(msg)
-		firestore.On("Save", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(currentTime, status.Error(codes.AlreadyExists, "document id already exist"))
-		err := pushAuditLog(context.Background(), bytes, firestore)
-		assert.NotNil(t, err)
+		assert.Nil(t, pushAuditLog(context.Background(), bytes, repository))
+		assert.Nil(t, pushAuditLog(context.Background(), bytes, repository))
+		auditLogs, _, err := repository.GetAll(context.Background(), msg.Path,
+			cloud.Page{PageSize: 10}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(auditLogs))
 	})
 
-	t.Run("Push Audit Entity Failed with already exist success after 1 retry", func(t *testing.T) {
-		firestore := mocks.NewDB(t)
+	t.Run("Different changes saved separately", func(t *testing.T) {
+		repository := cloud.NewMemoryRepository()
 		currentTime := time.Now().UTC().Round(time.Second)
-		msg := audit.GetPubSubAuditMessage("path", "123", "user",
-			common.AuditTypeCreate, common.EntityRetailer, &currentTime,
+		for _, name := range []string{"RetailerName", "NewRetailerName"} {
+			msg := audit.GetPubSubAuditMessage("retailers/r12345/audit", "123", "user",
+				common.AuditTypeCreate, common.EntityRetailer, &currentTime,
+				nil, structs.Map(retailer.Retailer{Name: name}))
+			bytes, _ := json.Marshal(msg)
+			assert.Nil(t, pushAuditLog(context.Background(), bytes, repository))
+		}
+		auditLogs, _, err := repository.GetAll(context.Background(), "retailers/r12345/audit",
+			cloud.Page{PageSize: 10}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(auditLogs))
+	})
+
+	t.Run("Bad data bytes dead lettered", func(t *testing.T) {
+		repository := cloud.NewMemoryRepository()
+		assert.Nil(t, pushAuditLog(context.Background(), []byte("{invalid"), repository))
+		// the redelivery of the same payload is dead lettered once
+		assert.Nil(t, pushAuditLog(context.Background(), []byte("{invalid"), repository))
+		deadLetters, _, err := repository.GetAll(context.Background(), common.AuditDeadLetterCollection,
+			cloud.Page{PageSize: 10}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(deadLetters))
+		assert.Equal(t, "{invalid", deadLetters[0]["payload"])
+	})
+
+	t.Run("Unknown change type dead lettered", func(t *testing.T) {
+		repository := cloud.NewMemoryRepository()
+		currentTime := time.Now().UTC().Round(time.Second)
+		msg := audit.GetPubSubAuditMessage("retailers/r12345/audit", "123", "user",
+			"rename", common.EntityRetailer, &currentTime,
 			nil, structs.Map(retailer.Retailer{Name: "RetailerName"}))
 		bytes, _ := json.Marshal(msg)
-		firestore.On("Save", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(currentTime, status.Error(codes.AlreadyExists, "document id already exist")).Once()
-		firestore.On("Save", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(currentTime, nil).Once()
-		err := pushAuditLog(context.Background(), bytes, firestore)
+		assert.Nil(t, pushAuditLog(context.Background(), bytes, repository))
+		deadLetters, _, err := repository.GetAll(context.Background(), common.AuditDeadLetterCollection,
+			cloud.Page{PageSize: 10}, nil)
 		assert.Nil(t, err)
+		assert.Equal(t, 1, len(deadLetters))
+		assert.Equal(t, "invalid change type 'rename'", deadLetters[0]["reason"])
 	})
 
-	t.Run("Bad data bytes passed", func(t *testing.T) {
+	t.Run("Error while saving the dead letter", func(t *testing.T) {
 		firestore := mocks.NewDB(t)
+		firestore.On("Save", mock.Anything, common.AuditDeadLetterCollection, mock.Anything, mock.Anything).
+			Return(time.Time{}, errors.New("connection timeout"))
 		err := pushAuditLog(context.Background(), nil, firestore)
 		assert.NotNil(t, err)
 	})
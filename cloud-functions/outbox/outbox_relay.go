@@ -0,0 +1,59 @@
+package outbox
+
+import (
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+)
+
+// This file has the function and handler to publish the undelivered outbox events,
+// it is meant to be invoked periodically e.g. by cloud scheduler
+var relayOutboxPath = urit.MustCreateTemplate("/outbox:relay")
+
+func init() {
+	functions.HTTP("RelayOutbox", relayOutbox)
+}
+
+var relayOutbox = authorization.Authorize("outbox_relay.relayOutbox",
+	authorization.OnAllRetailers(common.RoleAdmin),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		relayOutboxHandler(responseWriter, request, dbClient,
+			cloud.NewPubSubRepository(request.Context()))
+	})
+
+func relayOutboxHandler(responseWriter http.ResponseWriter, request *http.Request,
+	dbClient cloud.DB, pubsubClient cloud.Queue) {
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("outbox_relay.relayOutboxHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	_, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredPath:  relayOutboxPath,
+		RequestMethod: http.MethodPost,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	delivered, err := outbox.Relay(ctx, dbClient, pubsubClient)
+	if err != nil {
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusOK, fmt.Sprintf("%d events delivered", delivered), nil),
+		response.GetCommonResponseHeaders(request))
+}
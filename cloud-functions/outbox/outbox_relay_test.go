@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	err := os.Setenv(common.EnvProjectID, "project-id")
+	if err != nil {
+		return
+	}
+	err = os.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+	if err != nil {
+		return
+	}
+}
+
+func Test_relayOutbox(t *testing.T) {
+	t.Run("Request of a caller without the admin role on all the retailers", func(t *testing.T) {
+		grantsFile := filepath.Join(t.TempDir(), "grants.json")
+		assert.Nil(t, os.WriteFile(grantsFile,
+			[]byte("{\"scheduler@example.com\":[{\"retailer_id\":\"*\",\"role\":\"editor\"}]}"), 0o600))
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeGateway)
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile, grantsFile)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/outbox:relay", nil)
+		r.Header.Set(common.DefaultIdentityGatewayHeader, "scheduler@example.com")
+		relayOutbox(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+	})
+
+	t.Run("Unauthenticated request", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeGateway)
+		w := httptest.NewRecorder()
+		relayOutbox(w, httptest.NewRequest(http.MethodPost, "/outbox:relay", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func Test_relayOutboxHandler(t *testing.T) {
+	t.Run("Invalid request method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/outbox:relay", nil)
+		relayOutboxHandler(w, r, mocks.NewDB(t), mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Error while fetching the undelivered events", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetAll", mock.Anything, common.OutboxCollection, mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout"))
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/outbox:relay", nil)
+		relayOutboxHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Undelivered events published", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		createdTime := time.Now().UTC()
+		fireStoreClient.On("GetAll", mock.Anything, common.OutboxCollection, mock.Anything, mock.Anything).
+			Return([]map[string]interface{}{{
+				"id":                "e12345",
+				"topic":             "SITE_MESSAGE_TOPIC",
+				"payload":           "{\"site_id\":\"s12345\"}",
+				"attempts":          int64(2),
+				"created_time":      createdTime,
+				"next_attempt_time": createdTime,
+			}}, nil, nil)
+		pubSubClient.On("Publish", mock.Anything, "SITE_MESSAGE_TOPIC", mock.Anything).Return(nil).Once()
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, "e12345").Return(true, nil).Once()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/outbox:relay", nil)
+		relayOutboxHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"1 events delivered\"}", string(bytes))
+	})
+}
@@ -1,12 +1,11 @@
 package retailers
 
 import (
-	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
 	"github.com/TakeoffTech/site-info-svc/common/response"
@@ -26,14 +25,8 @@ func init() {
 	functions.HTTP("GetRetailer", getRetailer)
 }
 
-func getRetailer(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_retailer.getRetailer"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getRetailerHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getRetailer = authorization.Authorize("get_retailer.getRetailer",
+	authorization.OnPathRetailer(common.RoleReader, getRetailerPath), getRetailerHandler)
 
 func getRetailerHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -75,6 +68,15 @@ func getRetailerHandler(responseWriter http.ResponseWriter,
 
 		return
 	}
+	updatedTime := utils.GetUpdatedTime(data)
+	responseHeaders := response.GetCommonResponseHeaders(request).
+		WithHeader(common.HeaderEtag, etag).
+		WithHeader(common.HeaderLastModified, utils.GetLastModified(updatedTime))
+	if utils.IsNotModified(request, etag, updatedTime) {
+		response.RespondWithNotModified(responseWriter, responseHeaders)
+
+		return
+	}
 
 	var retailer models.Retailer
 	err = utils.ConvertToObject(data, &retailer)
@@ -85,10 +87,7 @@ func getRetailerHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	response.Respond(responseWriter, http.StatusOK,
-		retailer,
-		response.GetCommonResponseHeaders(request).
-			WithHeader(common.HeaderEtag, etag))
+	response.Respond(responseWriter, http.StatusOK, retailer, responseHeaders)
 
 	logger.Debugf("Retailer id %s successfully fetched retailer : %v", retailerID, retailer)
 }
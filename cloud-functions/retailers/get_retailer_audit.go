@@ -1,13 +1,12 @@
 package retailers
 
 import (
-	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
 	"github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
 	"github.com/TakeoffTech/site-info-svc/common/response"
@@ -15,7 +14,6 @@ import (
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
 	"net/http"
-	"time"
 )
 
 // This file has the function and handler to get audit logs for a retailer from DB
@@ -25,14 +23,8 @@ func init() {
 	functions.HTTP("GetRetailerAudit", getRetailerAudit)
 }
 
-func getRetailerAudit(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_retailer_audit.getRetailerAudit"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getRetailerAuditHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getRetailerAudit = authorization.Authorize("get_retailer_audit.getRetailerAudit",
+	authorization.OnPathRetailer(common.RoleReader, getRetailerAuditPath), getRetailerAuditHandler)
 
 func getRetailerAuditHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -67,28 +59,23 @@ func getRetailerAuditHandler(responseWriter http.ResponseWriter,
 	}
 
 	var data []map[string]interface{}
-	var startAfterID, nextPageToken string
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
 	pageSize := utils.GetPageSizeFromHeader(request, logger)
 
-	if request.Header.Get(common.HeaderPageToken) != "" {
-		startAfterID, err = utils.DecodeNextPageToken(request.Header.Get(common.HeaderPageToken),
-			common.RetailersEncryptionKey)
-		logger.Debugf("ID got after decoding the next page token : %s", startAfterID)
-		if err != nil {
-			logger.Errorf("Error occurred while decoding the next page token : %v", err)
-			response.RespondWithInternalServerError(responseWriter, request)
+	startAfter, err = cloud.DecodeNextPageToken(request, common.RetailersEncryptionKey)
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
 
-			return
-		}
+		return
 	}
-	parsedTime, _ := time.Parse(common.TimeParseFormat, startAfterID)
 
-	data, startAfterID, err = dbClient.GetAll(ctx, audit.GetRetailerAuditPath(retailerID),
+	data, startAfter, err = dbClient.GetAll(ctx, audit.GetRetailerAuditPath(retailerID),
 		cloud.Page{
-			StartAfterID: parsedTime,
-			PageSize:     pageSize,
-			OrderBy:      common.ChangedAt,
-			Sort:         common.SortDescending,
+			StartAfter: startAfter,
+			PageSize:   pageSize,
+			OrderBy:    []cloud.Order{{Field: common.ChangedAt, Sort: common.SortDescending}},
 		}, nil)
 
 	if err != nil {
@@ -98,10 +85,9 @@ func getRetailerAuditHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	if startAfterID != "" && len(data) == pageSize {
-		logger.Debugf("Received startAfterID : %s", startAfterID)
-		nextPageToken, err = utils.GetNextPageToken(startAfterID, common.RetailersEncryptionKey)
-		logger.Debugf("Received nextPageToken : %s", nextPageToken)
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		logger.Debugf("Received nextPageToken : %s", nextPage.Token)
 		if err != nil {
 			logger.Errorf("Error occurred while creating the next page token : %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
@@ -111,5 +97,5 @@ func getRetailerAuditHandler(responseWriter http.ResponseWriter,
 	}
 
 	auditLog := &models.AuditLog{}
-	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPageToken, auditLog)
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPage, auditLog)
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/stretchr/testify/assert"
@@ -106,7 +107,7 @@ func Test_getRetailerAuditHandler(t *testing.T) {
 		r := getRequest(http.MethodGet, "/retailers/r12345/auditLogs", "", common.HeaderXCorrelationID)
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "400")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		getRetailerAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -121,7 +122,7 @@ func Test_getRetailerAuditHandler(t *testing.T) {
 		r := getRequest(http.MethodGet, "/retailers/r12345/auditLogs", "", common.HeaderXCorrelationID)
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		fireStoreClient.On("Exists", mock.Anything, common.RetailersCollection, common.ID, "r12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, "", errors.New("connection timeout"))
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil, errors.New("connection timeout"))
 		getRetailerAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -135,7 +136,7 @@ func Test_getRetailerAuditHandler(t *testing.T) {
 		r := getRequest(http.MethodGet, "/retailers/r12345/auditLogs", "", common.HeaderXCorrelationID)
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		fireStoreClient.On("Exists", mock.Anything, common.RetailersCollection, common.ID, "r12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getRetailerAuditList(1), "", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getRetailerAuditList(1), nil, nil)
 		getRetailerAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -156,7 +157,7 @@ func Test_getRetailerAuditHandler(t *testing.T) {
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "2")
 		fireStoreClient.On("Exists", mock.Anything, common.RetailersCollection, common.ID, "r12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getRetailerAuditList(2), "12345-12345", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getRetailerAuditList(2), &cloud.Cursor{ID: "12345-12345"}, nil)
 		getRetailerAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -177,10 +178,10 @@ func Test_getRetailerAuditHandler(t *testing.T) {
 		r := getRequest(http.MethodGet, "/retailers/r12345/auditLogs", "", common.HeaderXCorrelationID)
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "2")
-		token, _ := utils.GetNextPageToken("1234-12334", common.RetailersEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		fireStoreClient.On("Exists", mock.Anything, common.RetailersCollection, common.ID, "r12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getRetailerAuditList(2), "123-1234", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getRetailerAuditList(2), &cloud.Cursor{ID: "123-1234"}, nil)
 		getRetailerAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -201,7 +202,7 @@ func Test_getRetailerAuditHandler(t *testing.T) {
 		r := getRequest(http.MethodGet, "/retailers/r12345/auditLogs", "", common.HeaderXCorrelationID)
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		fireStoreClient.On("Exists", mock.Anything, common.RetailersCollection, common.ID, "r12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getBadRetailerAudit(), "", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getBadRetailerAudit(), nil, nil)
 		getRetailerAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -1,11 +1,11 @@
 package retailers
 
 import (
-	"context"
+	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
 	"github.com/TakeoffTech/site-info-svc/common/response"
@@ -19,21 +19,29 @@ import (
 // This file has the function and handler to get list retailers from the DB
 var getRetailersPath = urit.MustCreateTemplate("/retailers")
 
+// getRetailersQuery are the filters and the sort fields accepted as query params by the list of retailers
+var getRetailersQuery = cloud.ListQuery{
+	Filters: append([]cloud.ListFilter{
+		{QueryParam: common.QueryParamNamePrefix, Field: common.Name, Operator: common.OperatorPrefix},
+	}, cloud.GetTimeFilters()...),
+	SortFields: []string{common.ID, common.Name, common.CreatedTime, common.UpdatedTime},
+}
+
 func init() {
 	functions.HTTP("GetRetailers", getRetailers)
 }
 
-func getRetailers(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_retailers.getRetailers"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getRetailersHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getRetailers = authorization.Authorize("get_retailers.getRetailers",
+	authorization.OnAnyRetailer(common.RoleReader),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		getRetailersHandler(responseWriter, request, dbClient,
+			authorization.GetGrantedRetailers(request, common.RoleReader))
+	})
 
+// getRetailersHandler lists the retailers, only the retailers of the retailerIDs unless they are nil, and none when
+// they are empty
 func getRetailersHandler(responseWriter http.ResponseWriter,
-	request *http.Request, client cloud.DB) {
+	request *http.Request, client cloud.DB, retailerIDs []string) {
 	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("get_retailers.getRetailersHandler"))
 	defer span.End()
 	logger := logging.GetLoggerFromContext(ctx)
@@ -50,21 +58,35 @@ func getRetailersHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
+	listWhere, orderBy, errs := cloud.ParseListQuery(request, getRetailersQuery)
+	if errs != nil {
+		logger.Debugf("Request query params validation failed. errors : %v", errs)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, "Request validation failed", errs),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	if retailerIDs != nil && len(retailerIDs) == 0 {
+		// the caller has the role on no retailer
+		utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, nil, utils.NextPage{}, &models.Retailer{})
+
+		return
+	}
+
 	var data []map[string]interface{}
 	var err error
-	var startAfterID, nextPageToken string
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
 	pageSize := utils.GetPageSizeFromHeader(request, logger)
 
-	if request.Header.Get(common.HeaderPageToken) != "" {
-		startAfterID, err = utils.DecodeNextPageToken(request.Header.Get(common.HeaderPageToken),
-			common.RetailersEncryptionKey)
-		logger.Debugf("ID got after decoding the next page token : %s", startAfterID)
-		if err != nil {
-			logger.Errorf("Error occurred while decoding the next page token : %v", err)
-			response.RespondWithInternalServerError(responseWriter, request)
+	startAfter, err = cloud.DecodeNextPageToken(request, common.RetailersEncryptionKey)
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
 
-			return
-		}
+		return
 	}
 
 	where := []cloud.Where{{
@@ -75,13 +97,17 @@ func getRetailersHandler(responseWriter http.ResponseWriter,
 	if strings.ToLower(request.URL.Query().Get(common.QueryParamDeactivated)) == common.True {
 		where = nil
 	}
+	where = append(where, listWhere...)
+	// the retailers of a caller granted roles on more retailers than an in filter accepts are filtered from the page
+	if retailerIDs != nil && len(retailerIDs) <= common.MaxInValues {
+		where = append(where, cloud.Where{Field: common.ID, Operator: common.OperatorIn, Value: retailerIDs})
+	}
 
-	data, startAfterID, err = client.GetAll(ctx, common.RetailersCollection,
+	data, startAfter, err = client.GetAll(ctx, common.RetailersCollection,
 		cloud.Page{
-			StartAfterID: startAfterID,
-			PageSize:     pageSize,
-			OrderBy:      common.ID,
-			Sort:         common.SortAscending,
+			StartAfter: startAfter,
+			PageSize:   pageSize,
+			OrderBy:    orderBy,
 		}, where)
 
 	if err != nil {
@@ -91,10 +117,9 @@ func getRetailersHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	if startAfterID != "" && len(data) == pageSize {
-		logger.Debugf("Received startAfterID : %s", startAfterID)
-		nextPageToken, err = utils.GetNextPageToken(startAfterID, common.RetailersEncryptionKey)
-		logger.Debugf("Received nextPageToken : %s", nextPageToken)
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		logger.Debugf("Received nextPageToken : %s", nextPage.Token)
 		if err != nil {
 			logger.Errorf("Error occurred while creating the next page token : %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
@@ -102,6 +127,22 @@ func getRetailersHandler(responseWriter http.ResponseWriter,
 			return
 		}
 	}
+	if len(retailerIDs) > common.MaxInValues {
+		data = filterRetailers(data, retailerIDs)
+	}
 	retailer := &models.Retailer{}
-	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPageToken, retailer)
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPage, retailer)
+}
+
+// filterRetailers returns the retailers of the retailerIDs, a page can then have fewer retailers than the page size
+// and still have a next page
+func filterRetailers(data []map[string]interface{}, retailerIDs []string) []map[string]interface{} {
+	filtered := make([]map[string]interface{}, 0, len(data))
+	for _, retailer := range data {
+		if utils.Contains(retailerIDs, fmt.Sprint(retailer[common.ID])) {
+			filtered = append(filtered, retailer)
+		}
+	}
+
+	return filtered
 }
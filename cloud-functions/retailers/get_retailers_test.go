@@ -21,6 +21,14 @@ func init() {
 	if err != nil {
 		return
 	}
+	err = os.Setenv(common.EnvPageTokenKeys, "test:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		return
+	}
+	err = os.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+	if err != nil {
+		return
+	}
 }
 
 func Test_getRetailers(t *testing.T) {
@@ -53,7 +61,7 @@ func Test_getRetailersHandler(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -72,9 +80,9 @@ func Test_getRetailersHandler(t *testing.T) {
 		r := httptest.NewRequest(http.MethodPost, "/retailers", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -88,7 +96,7 @@ func Test_getRetailersHandler(t *testing.T) {
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
 		r.Header.Set(common.HeaderPageToken, "r12345")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -107,9 +115,9 @@ func Test_getRetailersHandler(t *testing.T) {
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "300")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -123,13 +131,13 @@ func Test_getRetailersHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getRetailerList(10), "r12345", nil) //returning list equal to page size
+			Return(getRetailerList(10), &cloud.Cursor{ID: "r12345"}, nil) //returning list equal to page size
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
 		r.Header.Set(common.HeaderPageSize, "10")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		assert.NotEmpty(t, response.Header.Get("next_page_token"))
@@ -144,13 +152,13 @@ func Test_getRetailersHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getRetailerList(5), "r12345", nil) //returning list less than page size
+			Return(getRetailerList(5), &cloud.Cursor{ID: "r12345"}, nil) //returning list less than page size
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
 		r.Header.Set(common.HeaderPageSize, "10")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		assert.Empty(t, response.Header.Get("next_page_token"))
@@ -165,13 +173,13 @@ func Test_getRetailersHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getRetailerList(0), "r12345", nil)
+			Return(getRetailerList(0), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
 		r.Header.Set(common.HeaderPageSize, "10")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -185,13 +193,13 @@ func Test_getRetailersHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getRetailerList(5), "r12345", nil)
+			Return(getRetailerList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
 		r.Header.Set(common.HeaderPageSize, "10")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -205,7 +213,7 @@ func Test_getRetailersHandler(t *testing.T) {
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
 		r.Header.Set(common.HeaderPageSize, "invalid")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -214,20 +222,20 @@ func Test_getRetailersHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.RetailersEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where{{
 			Field:    common.DeactivatedTime,
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getRetailerList(5), "r12345", nil)
+			Return(getRetailerList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -235,16 +243,16 @@ func Test_getRetailersHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token with deleted=true", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.RetailersEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where(nil)).
-			Return(getRetailerList(5), "r12345", nil)
+			Return(getRetailerList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers?deactivated=true", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -254,13 +262,13 @@ func Test_getRetailersHandler(t *testing.T) {
 	t.Run("DB returned wrong entities", func(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where(nil)).
-			Return(getBadRetailer(), "r12345", nil)
+			Return(getBadRetailer(), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers?deactivated=true", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
 		r.Header.Set(common.HeaderPageSize, "10")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
@@ -274,15 +282,81 @@ func Test_getRetailersHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(nil, "", errors.New("connection timeout"))
+			Return(nil, nil, errors.New("connection timeout"))
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
-		getRetailersHandler(w, r, fireStoreClient)
+		getRetailersHandler(w, r, fireStoreClient, nil)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
 	})
+
+	t.Run("Name prefix filter ordered by name", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetAll", mock.Anything, common.RetailersCollection,
+			mock.MatchedBy(func(page cloud.Page) bool {
+				return len(page.OrderBy) == 1 && page.OrderBy[0] == cloud.Order{Field: common.Name, Sort: common.SortAscending}
+			}), []cloud.Where{
+				{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil},
+				{Field: common.Name, Operator: common.OperatorGreaterThanOrEquals, Value: "acme"},
+				{Field: common.Name, Operator: common.OperatorLessThan, Value: "acme" + common.PrefixRangeEnd},
+			}).
+			Return(getRetailerList(2), nil, nil)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/retailers?name_prefix=acme", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getRetailersHandler(w, r, fireStoreClient, nil)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("Unsupported sort query param", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/retailers?sort=random", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getRetailersHandler(w, r, mocks.NewDB(t), nil)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("List the granted retailers", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetAll", mock.Anything, common.RetailersCollection, mock.Anything, []cloud.Where{
+			{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil},
+			{Field: common.ID, Operator: common.OperatorIn, Value: []string{"r1", "r2"}},
+		}).Return([]map[string]interface{}{{"id": "r1", "name": "retailer 1"}}, &cloud.Cursor{ID: "r1"}, nil)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getRetailersHandler(w, r, fireStoreClient, []string{"r1", "r2"})
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("List the granted retailers of more retailers than an in filter accepts", func(t *testing.T) {
+		retailerIDs := []string{"r1"}
+		for i := 0; i < common.MaxInValues; i++ {
+			retailerIDs = append(retailerIDs, fmt.Sprintf("g%d", i))
+		}
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetAll", mock.Anything, common.RetailersCollection, mock.Anything, []cloud.Where{
+			{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil},
+		}).Return([]map[string]interface{}{{"id": "r1", "name": "retailer 1"}, {"id": "r2", "name": "retailer 2"}},
+			&cloud.Cursor{ID: "r2"}, nil)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/retailers", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		r.Header.Set(common.HeaderPageSize, "2")
+		getRetailersHandler(w, r, fireStoreClient, retailerIDs)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.NotEmpty(t, response.Header.Get(common.HeaderNextPageToken))
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "retailer 1")
+		assert.NotContains(t, string(bytes), "retailer 2")
+	})
 }
 
 func getRetailerList(length int) []map[string]interface{} {
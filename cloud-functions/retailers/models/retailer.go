@@ -14,7 +14,10 @@ type Retailer struct {
 	CreatedTime     *time.Time `json:"created_time" validate:"disallowed" firestore:"created_time" structs:"created_time"`
 	UpdatedTime     *time.Time `json:"updated_time" validate:"disallowed" firestore:"updated_time" structs:"updated_time"`
 	DeactivatedTime *time.Time `json:"deactivated_time,omitempty" validate:"disallowed" firestore:"deactivated_time" structs:"deactivated_time"`
+	Revision        int64      `json:"revision,omitempty" validate:"disallowed" firestore:"revision,omitempty" structs:"revision,omitempty"`
 	ETag            string     `json:"etag,omitempty" validate:"disallowed" firestore:"-" structs:"-"`
+	// AttributeSchema is the JSON Schema of the attributes of the sites and the spokes of the retailer
+	AttributeSchema map[string]interface{} `json:"attribute_schema,omitempty" firestore:"attribute_schema,omitempty" structs:"attribute_schema,omitempty"`
 }
 
 type PubSubRetailerMessage struct {
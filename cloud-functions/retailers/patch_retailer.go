@@ -3,14 +3,18 @@ package retailers
 import (
 	"cloud.google.com/go/firestore"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/fatih/structs"
@@ -29,15 +33,11 @@ func init() {
 	functions.HTTP("PatchRetailer", patchRetailer)
 }
 
-func patchRetailer(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("patch_retailer.patchRetailer"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	patchRetailerHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()), cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var patchRetailer = authorization.Authorize("patch_retailer.patchRetailer",
+	authorization.OnPathRetailer(common.RoleEditor, patchRetailerPath),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		patchRetailerHandler(responseWriter, request, dbClient, cloud.NewPubSubRepository(request.Context()))
+	})
 
 func patchRetailerHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -94,16 +94,53 @@ func patchRetailerHandler(responseWriter http.ResponseWriter, request *http.Requ
 		return
 	}
 
-	//Check if Retailer name already exists
-	exists, err := dbClient.Exists(ctx, common.RetailersCollection, common.Name, retailer.Name)
+	if len(retailer.AttributeSchema) > 0 &&
+		!attributes.ValidateSchemaForRequest(responseWriter, request, retailer.AttributeSchema) {
+		return
+	}
+
+	if retailer.Name != "" && !isRetailerNameAvailable(responseWriter, request, dbClient, retailer.Name) {
+		return
+	}
+	var writeOptions cloud.WriteOptions
+	if retailer.Name != "" && retailer.Name != retailerData.Name {
+		writeOptions = cloud.WriteOptions{
+			ReserveKeys: []cloud.UniqueKey{cloud.NewUniqueKey(common.RetailersCollection, common.Name,
+				retailer.Name)},
+			ReleaseKeys: []cloud.UniqueKey{cloud.NewUniqueKey(common.RetailersCollection, common.Name,
+				retailerData.Name)},
+		}
+		retailerData.Name = retailer.Name
+	}
+	if retailer.AttributeSchema != nil {
+		// an empty schema removes the attribute schema
+		retailerData.AttributeSchema = retailer.AttributeSchema
+		if len(retailer.AttributeSchema) == 0 {
+			retailerData.AttributeSchema = nil
+		}
+	}
+	updatedTime := time.Now().UTC().Round(time.Second)
+	retailerData.UpdatedBy = identity.GetPrincipalFromContext(ctx)
+	retailerData.UpdatedTime = &updatedTime
+	// the retailer is not updated if it changed since it was read
+	revision := retailerData.Revision
+	writeOptions.Revision = &revision
+	retailerData.Revision++
+
+	docForUpdate := createDocForUpdate(retailerData)
+	events, err := getPatchRetailerEvents(request, oldRetailerData, retailerData)
 	if err != nil {
-		logger.Errorf("Error occurred while checking existence of retailer in DB: %v", err)
+		logger.Errorf("Error while creating the retailer events : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
 
 		return
 	}
-	if exists {
-		logger.Debugf("Retailer with same name already exists")
+	writeOptions.Creates = outbox.Documents(events)
+	updateTime, err := dbClient.UpdateInTransaction(ctx, common.RetailersCollection, retailerID, docForUpdate,
+		writeOptions)
+	var uniqueKeyError *cloud.UniqueKeyError
+	if errors.As(err, &uniqueKeyError) {
+		logger.Debugf("Retailer name %s reserved by another retailer", retailer.Name)
 		response.RespondWithResponseObject(responseWriter,
 			response.NewResponse(http.StatusUnprocessableEntity,
 				fmt.Sprintf("Retailer with name : %s already exists", retailer.Name), nil),
@@ -111,14 +148,12 @@ func patchRetailerHandler(responseWriter http.ResponseWriter, request *http.Requ
 
 		return
 	}
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Retailer %s changed since it was read : %v", retailerID, err)
+		response.RespondWithPreconditionFailed(responseWriter, request)
 
-	updatedTime := time.Now().UTC().Round(time.Second)
-	retailerData.Name = retailer.Name
-	retailerData.UpdatedBy = common.User
-	retailerData.UpdatedTime = &updatedTime
-
-	docForUpdate := createDocForUpdate(retailerData)
-	updateTime, err := dbClient.Update(ctx, common.RetailersCollection, retailerID, docForUpdate)
+		return
+	}
 	if err != nil {
 		logger.Errorf("Error while deleting retailer from DB : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
@@ -127,27 +162,68 @@ func patchRetailerHandler(responseWriter http.ResponseWriter, request *http.Requ
 	}
 
 	sendPatchResponse(ctx, responseWriter, request, retailerData, updateTime)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
+}
 
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvAuditLogTopic),
-		audit.GetPubSubAuditMessage(audit.GetRetailerAuditPath(retailerData.ID),
-			request.Header.Get(common.HeaderXCorrelationID), retailerData.UpdatedBy,
-			common.AuditTypeUpdate,
-			common.EntityRetailer,
-			retailerData.UpdatedTime,
-			oldRetailerData,
-			structs.Map(retailerData),
-		))
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvRetailerMessageTopic),
-		models.GetPubSubRetailerMessage(retailer.ID, common.ChangeTypeUpdate))
+// getPatchRetailerEvents returns the audit and retailer change events to be written along with the retailer update
+func getPatchRetailerEvents(request *http.Request, oldRetailerData map[string]interface{},
+	retailerData models.Retailer) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetRetailerAuditPath(retailerData.ID),
+				request.Header.Get(common.HeaderXCorrelationID), retailerData.UpdatedBy,
+				common.AuditTypeUpdate,
+				common.EntityRetailer,
+				retailerData.UpdatedTime,
+				oldRetailerData,
+				structs.Map(retailerData),
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvRetailerMessageTopic),
+			Data:  models.GetPubSubRetailerMessage(retailerData.ID, common.ChangeTypeUpdate),
+		})
+}
+
+// isRetailerNameAvailable checks that no other retailer has the name. When the name is taken it responds with an
+// unprocessable entity, returning false
+func isRetailerNameAvailable(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB,
+	name string) bool {
+	logger := logging.GetLoggerFromContext(request.Context())
+	exists, err := dbClient.Exists(request.Context(), common.RetailersCollection, common.Name, name)
+	if err != nil {
+		logger.Errorf("Error occurred while checking existence of retailer in DB: %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return false
+	}
+	if exists {
+		logger.Debugf("Retailer with same name already exists")
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Retailer with name : %s already exists", name), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return false
+	}
+
+	return true
 }
 
 func createDocForUpdate(retailer models.Retailer) []firestore.Update {
 	var docForUpdate []firestore.Update
 
 	docForUpdate = append(docForUpdate, firestore.Update{Path: "name", Value: retailer.Name})
+	if retailer.AttributeSchema == nil {
+		docForUpdate = append(docForUpdate, firestore.Update{Path: common.AttributeSchema, Value: firestore.Delete})
+	} else {
+		docForUpdate = append(docForUpdate, firestore.Update{Path: common.AttributeSchema,
+			Value: retailer.AttributeSchema})
+	}
 	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_time", Value: retailer.UpdatedTime})
 	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_by", Value: retailer.UpdatedBy})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: common.Revision, Value: retailer.Revision})
 
 	return docForUpdate
 }
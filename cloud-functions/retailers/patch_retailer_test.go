@@ -1,9 +1,12 @@
 package retailers
 
 import (
+	"cloud.google.com/go/firestore"
 	"errors"
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -13,6 +16,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -271,7 +275,7 @@ func Test_patchRetailerHandler(t *testing.T) {
 		}
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", "name", "retailerName").Return(false, nil)
-		fireStoreClient.On("Update", mock.Anything, common.RetailersCollection, "r12345", mock.Anything).Return(time.Now(), errors.New("connection timeout"))
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345", mock.Anything, mock.Anything).Return(time.Now(), errors.New("connection timeout"))
 		patchRetailerHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -298,9 +302,10 @@ func Test_patchRetailerHandler(t *testing.T) {
 		//updatedTime := time.Now().UTC().Round(time.Second)
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", "name", "retailerName").Return(false, nil)
-		fireStoreClient.On("Update", mock.Anything, common.RetailersCollection, "r12345", mock.Anything).Return(time.Now(), nil)
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345", mock.Anything, mock.Anything).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchRetailerHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		//Etag := response.Header.Get(common.HeaderEtag)
@@ -308,6 +313,104 @@ func Test_patchRetailerHandler(t *testing.T) {
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Equal(t, fmt.Sprintf("{\"id\":\"RetailerID\",\"name\":\"retailerName\",\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\"}", updateTimeStr), string(bytes))
+		assert.Equal(t, fmt.Sprintf("{\"id\":\"RetailerID\",\"name\":\"retailerName\",\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
+	})
+
+	t.Run("Name reserved by a concurrent request", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPatch, "/retailers/r12345", "{\"name\":\"newRetailerName\"}", common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderIfMatch)
+		r.Header.Set("If-Match", "323190fa75e7aed77f65eca7ae2c5a07a6c466d7bf78865ede5c57f9ae8ffca8")
+		retailer := map[string]interface{}{
+			"id":               "RetailerID",
+			"name":             "retailerName",
+			"created_by":       "API",
+			"updated_by":       "",
+			"deactivated_by":   "",
+			"created_time":     "2022-10-28T07:33:05Z",
+			"updated_time":     nil,
+			"deactivated_time": nil,
+		}
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
+		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", "name", "newRetailerName").Return(false, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return assert.ObjectsAreEqual([]cloud.UniqueKey{cloud.NewUniqueKey(common.RetailersCollection, common.Name, "newRetailerName")}, options.ReserveKeys) &&
+					assert.ObjectsAreEqual([]cloud.UniqueKey{cloud.NewUniqueKey(common.RetailersCollection, common.Name, "retailerName")}, options.ReleaseKeys)
+			})).Return(time.Time{}, &cloud.UniqueKeyError{Key: cloud.NewUniqueKey(common.RetailersCollection, common.Name, "newRetailerName")})
+		patchRetailerHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":422,\"message\":\"Retailer with name : newRetailerName already exists\"}", string(bytes))
+	})
+
+	t.Run("Retailer changed since it was read", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPatch, "/retailers/r12345", "{\"name\":\"newRetailerName\"}", common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderIfMatch)
+		retailer := map[string]interface{}{
+			"id":           "RetailerID",
+			"name":         "retailerName",
+			"created_by":   "API",
+			"created_time": "2022-10-28T07:33:05Z",
+			"revision":     int64(3),
+		}
+		etag, _ := utils.GetETag(retailer)
+		assert.True(t, strings.HasPrefix(etag, "3-"))
+		r.Header.Set("If-Match", etag)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
+		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", "name", "newRetailerName").Return(false, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345",
+			mock.MatchedBy(func(updates []firestore.Update) bool {
+				return assert.ObjectsAreEqual(firestore.Update{Path: common.Revision, Value: int64(4)}, updates[len(updates)-1])
+			}),
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return options.Revision != nil && *options.Revision == 3
+			})).Return(time.Time{}, status.Error(codes.FailedPrecondition, "revision changed"))
+		patchRetailerHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusPreconditionFailed, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":412,\"message\":\"If-Match header value incorrect, please get the latest and try again\"}", string(bytes))
+	})
+
+	t.Run("Attribute schema updated without the name", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPatch, "/retailers/r12345", "{\"attribute_schema\":{\"type\":\"object\","+
+			"\"properties\":{\"brand\":{\"type\":\"string\"}}}}", common.HeaderXCorrelationID,
+			common.HeaderAcceptVersion, common.HeaderIfMatch)
+		r.Header.Set("If-Match", "323190fa75e7aed77f65eca7ae2c5a07a6c466d7bf78865ede5c57f9ae8ffca8")
+		retailer := map[string]interface{}{
+			"id":               "RetailerID",
+			"name":             "retailerName",
+			"created_by":       "API",
+			"updated_by":       "",
+			"deactivated_by":   "",
+			"created_time":     "2022-10-28T07:33:05Z",
+			"updated_time":     nil,
+			"deactivated_time": nil,
+		}
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345",
+			mock.MatchedBy(func(updates []firestore.Update) bool {
+				return updates[1].Path == common.AttributeSchema && updates[1].Value != firestore.Delete
+			}),
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return options.ReserveKeys == nil && options.ReleaseKeys == nil
+			})).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).
+			Return(true, nil)
+		patchRetailerHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "\"attribute_schema\":{\"properties\":{\"brand\":{\"type\":\"string\"}},"+
+			"\"type\":\"object\"}")
 	})
 }
@@ -0,0 +1,201 @@
+package retailers
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/fatih/structs"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+	"time"
+)
+
+// This file has the function and handler to undelete a deactivated retailer
+
+var PatchRetailerUndeletePath = urit.MustCreateTemplate(fmt.Sprintf("/retailers/{%s}:%s",
+	common.PathParamRetailerID, common.PathParamUndelete))
+
+func init() {
+	functions.HTTP("PatchRetailerUndelete", patchRetailerUndelete)
+}
+
+var patchRetailerUndeletePermission = authorization.OnPathRetailer(common.RoleAdmin, PatchRetailerUndeletePath)
+
+var patchRetailerUndelete = authorization.Authorize("patch_retailer_undelete.patchRetailerUndelete",
+	patchRetailerUndeletePermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, patchRetailerUndeletePermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				patchRetailerUndeleteHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
+
+func patchRetailerUndeleteHandler(responseWriter http.ResponseWriter, request *http.Request,
+	firestoreClient cloud.DB, pubSubClient cloud.Queue) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("patch_retailer_undelete.patchRetailerUndeleteHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(common.GetMandatoryHeaders(), common.HeaderIfMatch),
+		RequiredPath:    PatchRetailerUndeletePath,
+		RequestMethod:   http.MethodPatch,
+	})
+
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+	retailerID := pathParams[common.PathParamRetailerID]
+	data, err := firestoreClient.GetByID(ctx, common.RetailersCollection, retailerID, false)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			response.RespondWithNotFoundErrorMessage(responseWriter, request,
+				fmt.Sprintf("Retailer ID %s does not exist", retailerID), err)
+		} else {
+			logger.Errorf("Error occurred while fetching the retailer from DB for undelete : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+		}
+
+		return
+	}
+
+	if !utils.IsValidEtagPresentInHeader(responseWriter, request, data, logger) {
+		return
+	}
+
+	var retailer models.Retailer
+	err = utils.ConvertToObject(data, &retailer)
+	if err != nil {
+		logger.Errorf("Error while converting data got from DB to retailer struct : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	if retailer.DeactivatedTime == nil {
+		logger.Debugf("Retailer id %s is not deleted", retailerID)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, fmt.Sprintf("Retailer ID %s is not deleted", retailerID), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	updatedTime := time.Now().UTC().Round(time.Second)
+	oldRetailer := retailer
+	retailer.DeactivatedTime = nil
+	retailer.DeactivatedBy = ""
+	retailer.UpdatedTime = &updatedTime
+	retailer.UpdatedBy = identity.GetPrincipalFromContext(ctx)
+	retailer.Revision++
+
+	events, err := getUndeleteRetailerEvents(request, oldRetailer, retailer)
+	if err != nil {
+		logger.Errorf("Error while creating the retailer events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	// the name could have been taken by another retailer while this one was deleted
+	updateTime, err := firestoreClient.UpdateInTransaction(ctx, common.RetailersCollection, retailerID,
+		createUpdatesForUndelete(retailer),
+		cloud.WriteOptions{
+			ReserveKeys: []cloud.UniqueKey{cloud.NewUniqueKey(common.RetailersCollection, common.Name, retailer.Name)},
+			Creates:     outbox.Documents(events),
+			Revision:    &oldRetailer.Revision,
+		})
+	var uniqueKeyError *cloud.UniqueKeyError
+	if errors.As(err, &uniqueKeyError) {
+		logger.Debugf("Retailer name %s reserved by another retailer", retailer.Name)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Retailer with name : %s already exists", retailer.Name), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Retailer %s changed since it was read : %v", retailerID, err)
+		response.RespondWithPreconditionFailed(responseWriter, request)
+
+		return
+	}
+	if err != nil {
+		logger.Errorf("Error while undeleting retailer in DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	etag, err := utils.GetETag(retailer)
+	if err != nil {
+		logger.Errorf("Error while getting etag for retailer struct object : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	response.Respond(responseWriter, http.StatusOK, retailer,
+		response.GetCommonResponseHeaders(request).
+			WithHeader(common.HeaderLastModified, updateTime.Format(time.RFC3339)).
+			WithHeader(common.HeaderEtag, etag))
+
+	logger.Debugf("Retailer id %s successfully undeleted", retailerID)
+	outbox.Dispatch(ctx, firestoreClient, pubSubClient, events)
+}
+
+// getUndeleteRetailerEvents returns the audit and retailer re-create events to be written along with the undelete
+func getUndeleteRetailerEvents(request *http.Request, oldRetailer models.Retailer,
+	retailer models.Retailer) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetRetailerAuditPath(retailer.ID),
+				request.Header.Get(common.HeaderXCorrelationID), retailer.UpdatedBy,
+				common.AuditTypeUndelete,
+				common.EntityRetailer,
+				retailer.UpdatedTime,
+				structs.Map(oldRetailer),
+				structs.Map(retailer),
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvRetailerMessageTopic),
+			Data:  models.GetPubSubRetailerMessage(retailer.ID, common.ChangeTypeCreate),
+		})
+}
+
+func createUpdatesForUndelete(retailer models.Retailer) []firestore.Update {
+	var updatesForUndelete []firestore.Update
+
+	// deactivated_time is set to null rather than deleted as the active documents are queried on null
+	updatesForUndelete = append(updatesForUndelete, firestore.Update{Path: "deactivated_time", Value: nil})
+	updatesForUndelete = append(updatesForUndelete, firestore.Update{Path: "updated_time", Value: retailer.UpdatedTime})
+	updatesForUndelete = append(updatesForUndelete,
+		firestore.Update{Path: "deactivated_by", Value: retailer.DeactivatedBy})
+	updatesForUndelete = append(updatesForUndelete, firestore.Update{Path: "updated_by", Value: retailer.UpdatedBy})
+	updatesForUndelete = append(updatesForUndelete, firestore.Update{Path: common.Revision, Value: retailer.Revision})
+
+	return updatesForUndelete
+}
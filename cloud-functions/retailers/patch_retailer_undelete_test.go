@@ -0,0 +1,161 @@
+package retailers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getDeletedRetailer() map[string]interface{} {
+	return map[string]interface{}{
+		"id":               "r12345",
+		"name":             "RetailerName",
+		"created_by":       common.User,
+		"updated_by":       common.User,
+		"deactivated_by":   common.User,
+		"created_time":     "2022-10-28T07:33:05Z",
+		"updated_time":     "2022-10-28T07:33:05Z",
+		"deactivated_time": "2022-10-28T07:33:05Z",
+	}
+}
+
+func getUndeleteRetailerRequest(retailerData map[string]interface{}) *http.Request {
+	r := getRequest(http.MethodPatch, "/retailers/r12345:undelete", "",
+		common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+	etag, _ := utils.GetETag(retailerData)
+	r.Header.Set(common.HeaderIfMatch, etag)
+
+	return r
+}
+
+func Test_patchRetailerUndeleteHandler(t *testing.T) {
+	t.Run("Invalid Request Method", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/retailers/r12345:undelete", "",
+			common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderIfMatch)
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("RetailerID does not exist", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteRetailerRequest(getDeletedRetailer())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", false).
+			Return(nil, status.Error(codes.NotFound, "not found"))
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":404,\"message\":\"Retailer ID r12345 does not exist\"}", string(bytes))
+	})
+
+	t.Run("Failed while getting data from DB", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteRetailerRequest(getDeletedRetailer())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", false).
+			Return(nil, errors.New("connection timeout"))
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("ETag Mismatch", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteRetailerRequest(getDeletedRetailer())
+		r.Header.Set(common.HeaderIfMatch, "outdated etag")
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", false).
+			Return(getDeletedRetailer(), nil)
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	})
+
+	t.Run("Retailer is not deleted", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		activeRetailer := getDeletedRetailer()
+		activeRetailer["deactivated_by"] = ""
+		activeRetailer["deactivated_time"] = nil
+		r := getUndeleteRetailerRequest(activeRetailer)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", false).
+			Return(activeRetailer, nil)
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Retailer ID r12345 is not deleted\"}", string(bytes))
+	})
+
+	t.Run("Name taken by another retailer while deleted", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteRetailerRequest(getDeletedRetailer())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", false).
+			Return(getDeletedRetailer(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345",
+			mock.Anything, mock.Anything).
+			Return(time.Time{}, &cloud.UniqueKeyError{
+				Key: cloud.NewUniqueKey(common.RetailersCollection, common.Name, "RetailerName"),
+			})
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":422,\"message\":\"Retailer with name : RetailerName already exists\"}", string(bytes))
+	})
+
+	t.Run("Error while updating the DB", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteRetailerRequest(getDeletedRetailer())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", false).
+			Return(getDeletedRetailer(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345",
+			mock.Anything, mock.Anything).Return(time.Time{}, errors.New("update failed"))
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Successful undelete", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteRetailerRequest(getDeletedRetailer())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", false).
+			Return(getDeletedRetailer(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return len(options.ReserveKeys) == 1 && len(options.Creates) == 2
+			})).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		updateTimeStr := time.Now().UTC().Round(time.Second).Format(time.RFC3339)
+		patchRetailerUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, fmt.Sprintf(
+			"{\"id\":\"r12345\",\"name\":\"RetailerName\",\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
+	})
+}
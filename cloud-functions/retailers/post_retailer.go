@@ -2,19 +2,26 @@ package retailers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/fatih/structs"
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net/http"
 	"os"
 	"time"
@@ -27,15 +34,17 @@ func init() {
 	functions.HTTP("PostRetailer", postRetailer)
 }
 
-func postRetailer(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("post_retailer.postRetailer"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	postRetailerHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()), cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var postRetailerPermission = authorization.OnAllRetailers(common.RoleAdmin)
+
+var postRetailer = authorization.Authorize("post_retailer.postRetailer",
+	postRetailerPermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, postRetailerPermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				postRetailerHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
 
 func postRetailerHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -59,6 +68,11 @@ func postRetailerHandler(responseWriter http.ResponseWriter, request *http.Reque
 
 		return
 	}
+	if len(retailer.AttributeSchema) == 0 {
+		retailer.AttributeSchema = nil
+	} else if !attributes.ValidateSchemaForRequest(responseWriter, request, retailer.AttributeSchema) {
+		return
+	}
 
 	exists, err := dbClient.Exists(ctx, common.RetailersCollection, common.Name, retailer.Name)
 	if err != nil {
@@ -79,16 +93,18 @@ func postRetailerHandler(responseWriter http.ResponseWriter, request *http.Reque
 
 	var updateTime time.Time
 	var retryCount int
+	var events []outbox.Event
 	for retryCount = 0; retryCount < common.MaxRetryCount; retryCount++ {
 		logger.Debugf("Creating unique ID for retailer for %d time", retryCount)
 		retailer.ID = fmt.Sprintf("%s%s", common.RetailerIDPrefix, utils.GetRandomID(common.RandomIDLength))
 
-		retailer.CreatedBy = common.User
-		retailer.UpdatedBy = common.User
+		retailer.CreatedBy = identity.GetPrincipalFromContext(ctx)
+		retailer.UpdatedBy = identity.GetPrincipalFromContext(ctx)
 
 		currentTime := time.Now().UTC().Round(time.Second)
 		retailer.CreatedTime = &currentTime
 		retailer.UpdatedTime = &currentTime
+		retailer.Revision = 1
 
 		idExists, err := dbClient.Exists(ctx, common.RetailersCollection, common.ID, retailer.ID)
 		if err != nil {
@@ -99,7 +115,34 @@ func postRetailerHandler(responseWriter http.ResponseWriter, request *http.Reque
 		}
 		if !idExists {
 			logger.Debugf("Created unique ID for retailer does not exist proceed with save")
-			updateTime, err = dbClient.Save(ctx, common.RetailersCollection, retailer.ID, retailer)
+			events, err = getPostRetailerEvents(request, retailer)
+			if err != nil {
+				logger.Errorf("Error while creating the retailer events : %v", err)
+				response.RespondWithInternalServerError(responseWriter, request)
+
+				return
+			}
+			updateTime, err = dbClient.SaveInTransaction(ctx, common.RetailersCollection, retailer.ID, retailer,
+				cloud.WriteOptions{
+					ReserveKeys: []cloud.UniqueKey{
+						cloud.NewUniqueKey(common.RetailersCollection, common.Name, retailer.Name)},
+					Creates: outbox.Documents(events),
+				})
+			var uniqueKeyError *cloud.UniqueKeyError
+			if errors.As(err, &uniqueKeyError) {
+				logger.Debugf("Retailer with name %s created concurrently", retailer.Name)
+				response.RespondWithResponseObject(responseWriter,
+					response.NewResponse(http.StatusBadRequest,
+						fmt.Sprintf("Retailer with name : %s already exists", retailer.Name), nil),
+					response.GetCommonResponseHeaders(request))
+
+				return
+			}
+			if status.Code(err) == codes.AlreadyExists {
+				logger.Debugf("Retailer id %s was taken concurrently, generating a new one", retailer.ID)
+
+				continue
+			}
 			if err != nil {
 				logger.Errorf("Unable to create retailer : %v", err)
 				response.RespondWithInternalServerError(responseWriter, request)
@@ -119,11 +162,32 @@ func postRetailerHandler(responseWriter http.ResponseWriter, request *http.Reque
 		return
 	}
 
-	sendPostResponse(ctx, responseWriter, request, pubsubClient, retailer, updateTime)
+	sendPostResponse(ctx, responseWriter, request, retailer, updateTime)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
+}
+
+// getPostRetailerEvents returns the audit and retailer change events to be written along with the new retailer
+func getPostRetailerEvents(request *http.Request, retailer models.Retailer) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetRetailerAuditPath(retailer.ID),
+				request.Header.Get(common.HeaderXCorrelationID), retailer.CreatedBy,
+				common.AuditTypeCreate,
+				common.EntityRetailer,
+				retailer.CreatedTime,
+				nil,
+				structs.Map(retailer),
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvRetailerMessageTopic),
+			Data:  models.GetPubSubRetailerMessage(retailer.ID, common.ChangeTypeCreate),
+		})
 }
 
 func sendPostResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	pubsubClient cloud.Queue, retailer models.Retailer, updateTime time.Time) {
+	retailer models.Retailer, updateTime time.Time) {
 	logger := logging.GetLoggerFromContext(ctx)
 	etag, err := utils.GetETag(retailer)
 	if err != nil {
@@ -139,17 +203,4 @@ func sendPostResponse(ctx context.Context, responseWriter http.ResponseWriter, r
 		WithHeader(common.HeaderEtag, etag))
 
 	logger.Debugf("Retailer successfully created with id : %s", retailer.ID)
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvAuditLogTopic),
-		audit.GetPubSubAuditMessage(audit.GetRetailerAuditPath(retailer.ID),
-			request.Header.Get(common.HeaderXCorrelationID), retailer.CreatedBy,
-			common.AuditTypeCreate,
-			common.EntityRetailer,
-			retailer.CreatedTime,
-			nil,
-			structs.Map(retailer),
-		))
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvRetailerMessageTopic),
-		models.GetPubSubRetailerMessage(retailer.ID, common.ChangeTypeCreate))
 }
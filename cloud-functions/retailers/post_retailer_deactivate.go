@@ -2,15 +2,17 @@ package retailers
 
 import (
 	"cloud.google.com/go/firestore"
-	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/fatih/structs"
@@ -32,15 +34,17 @@ func init() {
 	functions.HTTP("PostRetailerDeactivate", postRetailerDeactivate)
 }
 
-func postRetailerDeactivate(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("deactivate_retailer.postRetailerDeactivate"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	postRetailerDeactivateHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()), cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var postRetailerDeactivatePermission = authorization.OnPathRetailer(common.RoleAdmin, PostRetailerDeactivatePath)
+
+var postRetailerDeactivate = authorization.Authorize("deactivate_retailer.postRetailerDeactivate",
+	postRetailerDeactivatePermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, postRetailerDeactivatePermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				postRetailerDeactivateHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
 
 func postRetailerDeactivateHandler(responseWriter http.ResponseWriter, request *http.Request,
 	firestoreClient cloud.DB, pubSubClient cloud.Queue) {
@@ -110,11 +114,27 @@ func postRetailerDeactivateHandler(responseWriter http.ResponseWriter, request *
 	oldRetailer := retailer
 	retailer.DeactivatedTime = &deactivatedTime
 	retailer.UpdatedTime = &deactivatedTime
-	retailer.UpdatedBy = common.User
-	retailer.DeactivatedBy = common.User
+	retailer.UpdatedBy = identity.GetPrincipalFromContext(ctx)
+	retailer.DeactivatedBy = identity.GetPrincipalFromContext(ctx)
+	retailer.Revision++
 
 	updatesForDelete := createUpdatesForDelete(retailer)
-	updateTime, err := firestoreClient.Update(ctx, common.RetailersCollection, retailerID, updatesForDelete)
+	events, err := getDeactivateRetailerEvents(request, oldRetailer, retailer)
+	if err != nil {
+		logger.Errorf("Error while creating the retailer events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	// the retailer is not deactivated if it changed since it was read
+	updateTime, err := firestoreClient.UpdateInTransaction(ctx, common.RetailersCollection, retailerID, updatesForDelete,
+		cloud.WriteOptions{Creates: outbox.Documents(events), Revision: &oldRetailer.Revision})
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Retailer %s changed since it was read : %v", retailerID, err)
+		response.RespondWithPreconditionFailed(responseWriter, request)
+
+		return
+	}
 	if err != nil {
 		logger.Errorf("Error while deleting retailer from DB : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
@@ -138,19 +158,28 @@ func postRetailerDeactivateHandler(responseWriter http.ResponseWriter, request *
 			WithHeader(common.HeaderEtag, etag))
 
 	logger.Debugf("Retailer id %s successfully deactivated", retailerID)
+	outbox.Dispatch(ctx, firestoreClient, pubSubClient, events)
+}
 
-	pubSubClient.Publish(ctx, os.Getenv(common.EnvAuditLogTopic),
-		audit.GetPubSubAuditMessage(audit.GetRetailerAuditPath(oldRetailer.ID),
-			request.Header.Get(common.HeaderXCorrelationID), retailer.DeactivatedBy,
-			common.AuditTypeDeactivate,
-			common.EntityRetailer,
-			retailer.DeactivatedTime,
-			structs.Map(oldRetailer),
-			nil,
-		))
-
-	pubSubClient.Publish(ctx, os.Getenv(common.EnvRetailerMessageTopic),
-		models.GetPubSubRetailerMessage(retailer.ID, common.AuditTypeDeactivate))
+// getDeactivateRetailerEvents returns the audit and retailer change events to be written along with the deactivation
+func getDeactivateRetailerEvents(request *http.Request, oldRetailer models.Retailer,
+	retailer models.Retailer) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetRetailerAuditPath(oldRetailer.ID),
+				request.Header.Get(common.HeaderXCorrelationID), retailer.DeactivatedBy,
+				common.AuditTypeDeactivate,
+				common.EntityRetailer,
+				retailer.DeactivatedTime,
+				structs.Map(oldRetailer),
+				nil,
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvRetailerMessageTopic),
+			Data:  models.GetPubSubRetailerMessage(retailer.ID, common.AuditTypeDeactivate),
+		})
 }
 
 func createUpdatesForDelete(retailer models.Retailer) []firestore.Update {
@@ -161,6 +190,7 @@ func createUpdatesForDelete(retailer models.Retailer) []firestore.Update {
 	updatesForDelete = append(updatesForDelete, firestore.Update{Path: "updated_time", Value: retailer.UpdatedTime})
 	updatesForDelete = append(updatesForDelete, firestore.Update{Path: "deactivated_by", Value: retailer.DeactivatedBy})
 	updatesForDelete = append(updatesForDelete, firestore.Update{Path: "updated_by", Value: retailer.UpdatedBy})
+	updatesForDelete = append(updatesForDelete, firestore.Update{Path: common.Revision, Value: retailer.Revision})
 
 	return updatesForDelete
 }
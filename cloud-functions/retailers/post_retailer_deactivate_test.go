@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -46,6 +47,36 @@ func Test_deactivateRetailer(t *testing.T) {
 			assert.Equal(t, tt.args.w.Result().StatusCode, http.StatusBadRequest)
 		})
 	}
+
+	t.Run("Request of an editor of the retailer", func(t *testing.T) {
+		grantsFile := filepath.Join(t.TempDir(), "grants.json")
+		assert.Nil(t, os.WriteFile(grantsFile,
+			[]byte("{\"jo@example.com\":[{\"retailer_id\":\"r12345\",\"role\":\"editor\"}]}"), 0o600))
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeGateway)
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile, grantsFile)
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/retailers/r12345:deactivate", "",
+			common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderIfMatch)
+		r.Header.Set(common.DefaultIdentityGatewayHeader, "jo@example.com")
+		postRetailerDeactivate(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":403,\"message\":\"Request not authorized\",\"errors\":[\"jo@example.com"+
+			" does not have the admin role on the retailer r12345\"]}", string(bytes))
+	})
+
+	t.Run("Request without an identity", func(t *testing.T) {
+		grantsFile := filepath.Join(t.TempDir(), "grants.json")
+		assert.Nil(t, os.WriteFile(grantsFile,
+			[]byte("{\""+common.User+"\":[{\"retailer_id\":\"*\",\"role\":\"admin\"}]}"), 0o600))
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile, grantsFile)
+		w := httptest.NewRecorder()
+		postRetailerDeactivate(w, getRequest(http.MethodPost, "/retailers/r12345:deactivate", "",
+			common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderIfMatch))
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
 }
 
 func Test_postRetailerDeactivate(t *testing.T) {
@@ -228,7 +259,7 @@ func Test_postRetailerDeactivate(t *testing.T) {
 		}
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("CheckSubDocuments", mock.Anything, mock.Anything, "r12345").Return(true, nil)
-		fireStoreClient.On("Update", mock.Anything, common.RetailersCollection, "r12345", mock.Anything).Return(time.Now(), errors.New("connection timeout"))
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345", mock.Anything, mock.Anything).Return(time.Now(), errors.New("connection timeout"))
 		postRetailerDeactivateHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -255,9 +286,10 @@ func Test_postRetailerDeactivate(t *testing.T) {
 		}
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("CheckSubDocuments", mock.Anything, mock.Anything, "r12345").Return(true, nil)
-		fireStoreClient.On("Update", mock.Anything, common.RetailersCollection, "r12345", mock.Anything).Return(time.Now(), nil)
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, common.RetailersCollection, "r12345", mock.Anything, mock.Anything).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		postRetailerDeactivateHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
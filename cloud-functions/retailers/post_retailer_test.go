@@ -112,6 +112,20 @@ func Test_postRetailerHandler(t *testing.T) {
 			"\"Key: 'Retailer.Name' Error:Field validation for 'Name' failed on the 'required' tag\"]}", string(bytes))
 	})
 
+	t.Run("Invalid attribute schema", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/retailers", "{\"name\":\"retailerName\",\"attribute_schema\":"+
+			"{\"type\":\"object\",\"properties\":{\"floors\":{\"type\":\"int\"}}}}", common.HeaderXCorrelationID)
+		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
+		postRetailerHandler(w, r, mocks.NewDB(t), mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\",\"errors\":[\"Invalid "+
+			"attribute_schema : properties.floors.type must be one of [array boolean integer number object string]\"]}",
+			string(bytes))
+	})
+
 	t.Run("Retailer name already exists", func(t *testing.T) {
 		pubSubClient := mocks.NewQueue(t)
 		fireStoreClient := mocks.NewDB(t)
@@ -146,7 +160,7 @@ func Test_postRetailerHandler(t *testing.T) {
 		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything).Return(false, nil).Once()
 		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything).Return(true, nil).Once()
 		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything).Return(false, nil).Once()
-		fireStoreClient.On("Save", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything).
+		fireStoreClient.On("SaveInTransaction", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything, mock.Anything).
 			Return(time.Now(), errors.New("conflict ID already exists"))
 		w := httptest.NewRecorder()
 		r := getRequest(http.MethodPost, "/retailers", "{\"name\":\"retailerName\"}", common.HeaderXCorrelationID)
@@ -177,19 +191,20 @@ func Test_postRetailerHandler(t *testing.T) {
 		pubSubClient := mocks.NewQueue(t)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("Exists", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything).Return(false, nil)
-		fireStoreClient.On("Save", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything).Return(time.Now(), nil)
+		fireStoreClient.On("SaveInTransaction", mock.Anything, "site-info-retailers", mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), nil)
 		w := httptest.NewRecorder()
 		r := getRequest(http.MethodPost, "/retailers", "{\"name\":\"retailerName\"}", common.HeaderXCorrelationID)
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		postRetailerHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusCreated, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Contains(t, string(bytes), fmt.Sprintf("\"name\":\"retailerName\",\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"%s\",\"updated_time\":\"%s\"}", updateTimeStr, updateTimeStr))
+		assert.Contains(t, string(bytes), fmt.Sprintf("\"name\":\"retailerName\",\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"%s\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr, updateTimeStr))
 	})
 
 	t.Run("Unable to create a unique id for retailer", func(t *testing.T) {
@@ -16,7 +16,8 @@ func main() {
 	os.Setenv("OPENCENSUSX_PROJECT_ID", "PROJECT_ID")
 	os.Setenv("AUDIT_LOG_TOPIC", "AUDIT_LOG_TOPIC")
 	os.Setenv("SITE_MESSAGE_TOPIC", "SITE_MESSAGE_TOPIC")
-	// Only for POST AND PATCH Site
+	// Only for POST AND PATCH Site, the timezones are resolved offline unless TIMEZONE_RESOLVER is google
+	os.Setenv("TIMEZONE_RESOLVER", "offline")
 	os.Setenv("GOOGLE_MAPS_API_KEY", "GOOGLE_MAPS_API_KEY")
 
 	// Use PORT environment variable, or default to 8080.
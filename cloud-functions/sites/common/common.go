@@ -1,13 +1,18 @@
 package common
 
 import (
+	"context"
 	"fmt"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"math"
 	"net/http"
 )
 
@@ -29,3 +34,49 @@ func GetSiteFromDB(responseWriter http.ResponseWriter, request *http.Request, lo
 
 	return oldSiteDataMap
 }
+
+// GetServiceAreaOverlaps returns the IDs of the other active sites of the retailer with a service area overlapping
+// the service area of the site, sharing a postal code or with overlapping polygons.
+// The service areas can't be queried on overlaps, so every active site of the retailer is read
+func GetServiceAreaOverlaps(ctx context.Context, dbClient cloud.DB, site models.Site) ([]string, error) {
+	if site.ServiceArea == nil {
+		return nil, nil
+	}
+	data, _, err := dbClient.GetAll(ctx, utils.GetSitePath(site.RetailerID), cloud.Page{PageSize: math.MaxInt},
+		[]cloud.Where{{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil}})
+	if err != nil {
+		return nil, err
+	}
+	var sites []models.Site
+	if err = utils.ConvertToObject(data, &sites); err != nil {
+		return nil, err
+	}
+
+	postalCodes := make(map[string]bool, len(site.ServiceArea.PostalCodes))
+	for _, postalCode := range site.ServiceArea.PostalCodes {
+		postalCodes[postalCode] = true
+	}
+	var overlaps []string
+	for _, other := range sites {
+		if other.ID != site.ID && other.ServiceArea != nil &&
+			serviceAreasOverlap(*site.ServiceArea, postalCodes, *other.ServiceArea) {
+			overlaps = append(overlaps, other.ID)
+		}
+	}
+
+	return overlaps, nil
+}
+
+// serviceAreasOverlap returns whether the other service area has one of the postal codes of the service area
+// or a polygon overlapping its polygon
+func serviceAreasOverlap(serviceArea commonModels.ServiceArea, postalCodes map[string]bool,
+	other commonModels.ServiceArea) bool {
+	for _, postalCode := range other.PostalCodes {
+		if postalCodes[postalCode] {
+			return true
+		}
+	}
+
+	return serviceArea.Polygon != nil && other.Polygon != nil &&
+		utils.PolygonsOverlap(*serviceArea.Polygon, *other.Polygon)
+}
@@ -0,0 +1,195 @@
+package sites
+
+import (
+	"context"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	model "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	commonModel "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"math"
+	"net/http"
+	"sort"
+)
+
+// This file has the function and handler to look up the sites and the spokes of a retailer serving a location
+var getServiceAreaLookupPath = urit.MustCreateTemplate("/serviceAreas:lookup")
+
+// serviceAreaLookup is the response of the lookup, the sites and the spokes serving the location ordered by distance
+type serviceAreaLookup struct {
+	Sites  []models.Site `json:"sites"`
+	Spokes []model.Spoke `json:"spokes"`
+}
+
+// servingDocument is a document with a service area serving the location along with its distance
+type servingDocument struct {
+	data       map[string]interface{}
+	id         string
+	distanceKm float64
+}
+
+func init() {
+	functions.HTTP("GetServiceAreaLookup", getServiceAreaLookup)
+}
+
+var getServiceAreaLookup = authorization.Authorize("get_service_area_lookup.getServiceAreaLookup",
+	authorization.OnHeaderRetailer(common.RoleReader), getServiceAreaLookupHandler)
+
+func getServiceAreaLookupHandler(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("get_service_area_lookup.getServiceAreaLookupHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	_, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders:     models.GetRequiredHeaders(),
+		RequiredQueryParams: []string{common.QueryParamLocation},
+		RequiredPath:        getServiceAreaLookupPath,
+		RequestMethod:       http.MethodGet,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	latitude, longitude, valid := utils.ParseLatLong(request.URL.Query().Get(common.QueryParamLocation))
+	if !valid {
+		logger.Debugf("Invalid location query param : %s", request.URL.Query().Get(common.QueryParamLocation))
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, "Request validation failed",
+				[]string{fmt.Sprintf("Invalid value for query param %s, it must be lat,long with -90 < lat < 90 "+
+					"and -180 < long < 180", common.QueryParamLocation)}),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+		return
+	}
+
+	postalCode := request.URL.Query().Get(common.QueryParamPostalCode)
+	var lookup serviceAreaLookup
+	sites, err := getServingDocuments(ctx, dbClient, utils.GetSitePath(retailerID), latitude, longitude, postalCode)
+	if err == nil {
+		err = utils.ConvertToObject(sites, &lookup.Sites)
+	}
+	if err != nil {
+		logger.Errorf("Error while looking up the sites serving the location : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	spokes, err := getServingDocuments(ctx, dbClient, utils.GetSpokePath(retailerID), latitude, longitude, postalCode)
+	if err == nil {
+		err = utils.ConvertToObject(spokes, &lookup.Spokes)
+	}
+	if err != nil {
+		logger.Errorf("Error while looking up the spokes serving the location : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	logger.Debugf("%d sites and %d spokes serve the location %f,%f", len(lookup.Sites), len(lookup.Spokes),
+		latitude, longitude)
+	response.Respond(responseWriter, http.StatusOK, lookup, response.GetCommonResponseHeaders(request))
+}
+
+// getServingDocuments returns the active documents under the collectionPath with a service area serving the location,
+// a polygon containing it or the postal code, ordered by distance and then by ID with the distance in the
+// common.DistanceKm field. The candidate polygons are the ones with a geohash cell which is a prefix of
+// the geohash of the location
+func getServingDocuments(ctx context.Context, dbClient cloud.DB, collectionPath string, latitude float64,
+	longitude float64, postalCode string) ([]map[string]interface{}, error) {
+	active := cloud.Where{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil}
+	geohash := utils.GetGeohash(latitude, longitude, common.GeohashPrecision)
+	candidates, _, err := dbClient.GetAll(ctx, collectionPath, cloud.Page{PageSize: math.MaxInt}, []cloud.Where{
+		active,
+		{Field: common.ServiceAreaGeohashes, Operator: common.OperatorArrayContainsAny,
+			Value: utils.GetGeohashAncestors(geohash)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var documents []servingDocument
+	for _, candidate := range candidates {
+		var serviceArea commonModel.ServiceArea
+		if err = utils.ConvertToObject(candidate[common.ServiceArea], &serviceArea); err != nil {
+			return nil, err
+		}
+		if serviceArea.Polygon != nil && utils.IsPointInPolygon(*serviceArea.Polygon, latitude, longitude) {
+			documents = append(documents, servingDocument{data: candidate, id: fmt.Sprint(candidate[common.ID])})
+		}
+	}
+	if postalCode != "" {
+		candidates, _, err = dbClient.GetAll(ctx, collectionPath, cloud.Page{PageSize: math.MaxInt}, []cloud.Where{
+			active,
+			{Field: common.ServiceAreaPostalCodes, Operator: common.OperatorArrayContains, Value: postalCode},
+		})
+		if err != nil {
+			return nil, err
+		}
+		documents = appendServingDocuments(documents, candidates)
+	}
+
+	return sortByDistance(documents, latitude, longitude)
+}
+
+// appendServingDocuments appends the documents which are not served already
+func appendServingDocuments(documents []servingDocument, data []map[string]interface{}) []servingDocument {
+	for _, document := range data {
+		id := fmt.Sprint(document[common.ID])
+		served := false
+		for _, other := range documents {
+			served = served || other.id == id
+		}
+		if !served {
+			documents = append(documents, servingDocument{data: document, id: id})
+		}
+	}
+
+	return documents
+}
+
+// sortByDistance returns the data of the documents ordered by the distance of their location from the point
+// and then by ID, with the distance in the common.DistanceKm field
+func sortByDistance(documents []servingDocument, latitude float64,
+	longitude float64) ([]map[string]interface{}, error) {
+	for index, document := range documents {
+		var location commonModel.Location
+		if err := utils.ConvertToObject(document.data[common.Location], &location); err != nil {
+			return nil, err
+		}
+		if location.Latitude != nil && location.Longitude != nil {
+			documents[index].distanceKm = utils.GetDistanceKm(latitude, longitude, *location.Latitude,
+				*location.Longitude)
+		}
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		if documents[i].distanceKm != documents[j].distanceKm {
+			return documents[i].distanceKm < documents[j].distanceKm
+		}
+
+		return documents[i].id < documents[j].id
+	})
+
+	data := make([]map[string]interface{}, 0, len(documents))
+	for _, document := range documents {
+		document.data[common.DistanceKm] = math.Round(document.distanceKm*1000) / 1000
+		data = append(data, document.data)
+	}
+
+	return data, nil
+}
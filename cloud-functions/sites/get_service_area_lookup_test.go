@@ -0,0 +1,137 @@
+package sites
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_getServiceAreaLookup(t *testing.T) {
+	w := httptest.NewRecorder()
+	getServiceAreaLookup(w, getRequest(http.MethodGet, "/serviceAreas:lookup", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func Test_getServiceAreaLookupHandler(t *testing.T) {
+	t.Run("Location not passed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/serviceAreas:lookup")
+		getServiceAreaLookupHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Invalid location", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/serviceAreas:lookup?location=91,0")
+		getServiceAreaLookupHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request validation failed\",\"errors\":[\"Invalid value for "+
+			"query param location, it must be lat,long with -90 \\u003c lat \\u003c 90 and "+
+			"-180 \\u003c long \\u003c 180\"]}", string(bytes))
+	})
+
+	t.Run("Sites and spokes serving the location ordered by distance", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/serviceAreas:lookup?location=37.7749,-122.4194&postal_code=94103")
+		getServiceAreaLookupHandler(w, r, getServiceAreasRepository(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var lookup serviceAreaLookup
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &lookup))
+		assert.Equal(t, 3, len(lookup.Sites))
+		assert.Equal(t, []string{"s1", "s3", "s5"}, []string{lookup.Sites[0].ID, lookup.Sites[1].ID,
+			lookup.Sites[2].ID})
+		assert.Equal(t, 0.0, *lookup.Sites[0].DistanceKm)
+		assert.Equal(t, 1, len(lookup.Spokes))
+		assert.Equal(t, "p1", lookup.Spokes[0].ID)
+		assert.Equal(t, "Polygon", lookup.Spokes[0].ServiceArea.Polygon.Type)
+	})
+
+	t.Run("No service area serving the location", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/serviceAreas:lookup?location=51.5074,-0.1278")
+		getServiceAreaLookupHandler(w, r, getServiceAreasRepository(t))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"sites\":[],\"spokes\":[]}", string(bytes))
+	})
+
+	t.Run("Error while reading the service areas", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{}, nil)
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSitePath("r12345"), mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout"))
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/serviceAreas:lookup?location=37.7749,-122.4194")
+		getServiceAreaLookupHandler(w, r, fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
+
+func getLookupRequest(url string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, url, nil)
+	request.Header.Set(common.HeaderXCorrelationID, "1234")
+	request.Header.Set(common.HeaderRetailerID, "r12345")
+	request.Header.Set(common.HeaderAcceptVersion, "v1")
+
+	return request
+}
+
+// getServiceAreasRepository returns a repository of r12345 with sites located 0.02 degrees of latitude apart north of
+// 37.7749,-122.4194: s1, s2 (deactivated) and s5 with a service area around it, s3 serving the postal code 94103 and
+// s4 with a service area elsewhere, along with a spoke p1 with a service area around it
+func getServiceAreasRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := cloud.NewMemoryRepository()
+	_, err := repository.Save(ctx, common.RetailersCollection, "r12345", map[string]interface{}{
+		"id": "r12345", "deactivated_time": nil})
+	assert.Nil(t, err)
+	deactivatedTime := time.Date(2022, 1, 13, 0, 0, 0, 0, time.UTC)
+	for index, site := range []models.Site{
+		{ID: "s1", ServiceArea: getTestServiceArea(-122.5, 37.7, 0.2)},
+		{ID: "s2", ServiceArea: getTestServiceArea(-122.5, 37.7, 0.2), DeactivatedTime: &deactivatedTime},
+		{ID: "s3", ServiceArea: &commonModels.ServiceArea{PostalCodes: []string{"94103"}}},
+		{ID: "s4", ServiceArea: getTestServiceArea(-74.1, 40.6, 0.2)},
+		{ID: "s5", ServiceArea: getTestServiceArea(-122.5, 37.7, 0.2)},
+	} {
+		latitude, longitude := 37.7749+float64(index)*0.02, -122.4194
+		site.Location = &commonModels.Location{Latitude: &latitude, Longitude: &longitude}
+		_, err = repository.Save(ctx, utils.GetSitePath("r12345"), site.ID, site)
+		assert.Nil(t, err)
+	}
+	spoke := spokes.Spoke{ID: "p1", ServiceArea: getTestServiceArea(-123, 37, 2)}
+	_, err = repository.Save(ctx, utils.GetSpokePath("r12345"), spoke.ID, spoke)
+	assert.Nil(t, err)
+
+	return repository
+}
+
+// getTestServiceArea returns a service area with a square polygon of the size in degrees from the south-west corner
+func getTestServiceArea(longitude float64, latitude float64, size float64) *commonModels.ServiceArea {
+	ring := commonModels.LinearRing{Points: []commonModels.Point{
+		{Longitude: longitude, Latitude: latitude}, {Longitude: longitude + size, Latitude: latitude},
+		{Longitude: longitude + size, Latitude: latitude + size}, {Longitude: longitude, Latitude: latitude + size},
+		{Longitude: longitude, Latitude: latitude},
+	}}
+
+	return utils.NewServiceArea(&commonModels.ServiceArea{
+		Polygon: &commonModels.Polygon{Type: common.GeoJSONPolygon, Rings: []commonModels.LinearRing{ring}},
+	})
+}
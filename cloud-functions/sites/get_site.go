@@ -1,13 +1,12 @@
 package sites
 
 import (
-	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
@@ -25,14 +24,8 @@ func init() {
 	functions.HTTP("GetSite", getSite)
 }
 
-func getSite(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_site.getSite"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getSiteHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getSite = authorization.Authorize("get_site.getSite",
+	authorization.OnHeaderRetailer(common.RoleReader), getSiteHandler)
 
 func getSiteHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -77,6 +70,15 @@ func getSiteHandler(responseWriter http.ResponseWriter,
 
 		return
 	}
+	updatedTime := utils.GetUpdatedTime(data)
+	responseHeaders := response.GetCommonResponseHeaders(request).
+		WithHeader(common.HeaderEtag, etag).
+		WithHeader(common.HeaderLastModified, utils.GetLastModified(updatedTime))
+	if utils.IsNotModified(request, etag, updatedTime) {
+		response.RespondWithNotModified(responseWriter, responseHeaders)
+
+		return
+	}
 
 	var site models.Site
 	err = utils.ConvertToObject(data, &site)
@@ -87,10 +89,7 @@ func getSiteHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	response.Respond(responseWriter, http.StatusOK,
-		site,
-		response.GetCommonResponseHeaders(request).
-			WithHeader(common.HeaderEtag, etag))
+	response.Respond(responseWriter, http.StatusOK, site, responseHeaders)
 
 	logger.Debugf("Site id %s successfully fetched retailer : %v", siteID, site)
 }
@@ -1,14 +1,13 @@
 package sites
 
 import (
-	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
 	auditModels "github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
 	"github.com/TakeoffTech/site-info-svc/common/response"
@@ -16,7 +15,6 @@ import (
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
 	"net/http"
-	"time"
 )
 
 // This file has the function and handler to get audit logs for a site from DB
@@ -26,14 +24,8 @@ func init() {
 	functions.HTTP("GetSiteAudit", getSiteAudit)
 }
 
-func getSiteAudit(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_site_audit.getSiteAudit"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getSiteAuditHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getSiteAudit = authorization.Authorize("get_site_audit.getSiteAudit",
+	authorization.OnHeaderRetailer(common.RoleReader), getSiteAuditHandler)
 
 func getSiteAuditHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -71,27 +63,22 @@ func getSiteAuditHandler(responseWriter http.ResponseWriter,
 	}
 
 	var data []map[string]interface{}
-	var startAfterID, nextPageToken string
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
 	pageSize := utils.GetPageSizeFromHeader(request, logger)
 
-	if request.Header.Get(common.HeaderPageToken) != "" {
-		startAfterID, err = utils.DecodeNextPageToken(request.Header.Get(common.HeaderPageToken),
-			common.SitesEncryptionKey)
-		logger.Debugf("ID got after decoding the next page token : %s", startAfterID)
-		if err != nil {
-			logger.Errorf("Error occurred while decoding the next page token : %v", err)
-			response.RespondWithInternalServerError(responseWriter, request)
+	startAfter, err = cloud.DecodeNextPageToken(request, common.SitesEncryptionKey)
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
 
-			return
-		}
+		return
 	}
-	parsedTime, _ := time.Parse(common.TimeParseFormat, startAfterID)
-	data, startAfterID, err = dbClient.GetAll(ctx, audit.GetSiteAuditPath(retailerID, siteID),
+	data, startAfter, err = dbClient.GetAll(ctx, audit.GetSiteAuditPath(retailerID, siteID),
 		cloud.Page{
-			StartAfterID: parsedTime,
-			PageSize:     pageSize,
-			OrderBy:      common.ChangedAt,
-			Sort:         common.SortDescending,
+			StartAfter: startAfter,
+			PageSize:   pageSize,
+			OrderBy:    []cloud.Order{{Field: common.ChangedAt, Sort: common.SortDescending}},
 		}, nil)
 
 	if err != nil {
@@ -101,10 +88,9 @@ func getSiteAuditHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	if startAfterID != "" && len(data) == pageSize {
-		logger.Debugf("Received startAfterID : %s", startAfterID)
-		nextPageToken, err = utils.GetNextPageToken(startAfterID, common.SitesEncryptionKey)
-		logger.Debugf("Received nextPageToken : %s", nextPageToken)
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		logger.Debugf("Received nextPageToken : %s", nextPage.Token)
 		if err != nil {
 			logger.Errorf("Error occurred while creating the next page token : %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
@@ -113,5 +99,5 @@ func getSiteAuditHandler(responseWriter http.ResponseWriter,
 		}
 	}
 	auditLog := &auditModels.AuditLog{}
-	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPageToken, auditLog)
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPage, auditLog)
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/stretchr/testify/assert"
@@ -108,7 +109,7 @@ func Test_getSiteAuditHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), common.ID, "s12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, "", errors.New("connection timeout"))
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil, errors.New("connection timeout"))
 		getSiteAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -123,7 +124,7 @@ func Test_getSiteAuditHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), common.ID, "s12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getSiteAuditList(1), "", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getSiteAuditList(1), nil, nil)
 		getSiteAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -145,7 +146,7 @@ func Test_getSiteAuditHandler(t *testing.T) {
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "2")
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), common.ID, "s12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getSiteAuditList(2), "12345-12345", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getSiteAuditList(2), &cloud.Cursor{ID: "12345-12345"}, nil)
 		getSiteAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -167,10 +168,10 @@ func Test_getSiteAuditHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "2")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), common.ID, "s12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getSiteAuditList(2), "123-1234", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getSiteAuditList(2), &cloud.Cursor{ID: "123-1234"}, nil)
 		getSiteAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -192,7 +193,7 @@ func Test_getSiteAuditHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "500")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSiteAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -208,7 +209,7 @@ func Test_getSiteAuditHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), common.ID, "s12345").Return(true, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getBadSiteAudit(), "", nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getBadSiteAudit(), nil, nil)
 		getSiteAuditHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -0,0 +1,95 @@
+package sites
+
+import (
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+)
+
+// This file has the function and handler to evaluate whether a site is open at a time with its operating hours
+var getSiteOpenStatusPath = urit.MustCreateTemplate(fmt.Sprintf("/sites/{%s}/openStatus", common.PathParamSiteID))
+
+func init() {
+	functions.HTTP("GetSiteOpenStatus", getSiteOpenStatus)
+}
+
+var getSiteOpenStatus = authorization.Authorize("get_site_open_status.getSiteOpenStatus",
+	authorization.OnHeaderRetailer(common.RoleReader), getSiteOpenStatusHandler)
+
+func getSiteOpenStatusHandler(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("get_site_open_status.getSiteOpenStatusHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: models.GetRequiredHeaders(),
+		RequiredPath:    getSiteOpenStatusPath,
+		RequestMethod:   http.MethodGet,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	at, validationResponse := utils.ParseAtQueryParam(request)
+	if validationResponse != nil {
+		logger.Debugf("Invalid at query param : %s", request.URL.Query().Get(common.QueryParamAt))
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	siteID := pathParams[common.PathParamSiteID]
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+		return
+	}
+
+	data := siteCommon.GetSiteFromDB(responseWriter, request, logger, dbClient, retailerID, siteID, true)
+	if data == nil {
+		return
+	}
+
+	var site models.Site
+	err := utils.ConvertToObject(data, &site)
+	if err != nil {
+		logger.Errorf("Error while converting data from DB to struct object : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	if site.OperatingHours == nil || site.Timezone == "" {
+		logger.Debugf("Site ID %s has no operating hours or no timezone", siteID)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Site ID %s has no operating hours or no timezone", siteID), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	openStatus, err := utils.GetOpenStatus(*site.OperatingHours, site.Timezone, at)
+	if err != nil {
+		logger.Errorf("Error while evaluating the operating hours of the site in timezone %s : %v", site.Timezone, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	response.Respond(responseWriter, http.StatusOK, openStatus, response.GetCommonResponseHeaders(request))
+	logger.Debugf("Site ID %s open at %v : %t", siteID, at, openStatus.Open)
+}
@@ -0,0 +1,100 @@
+package sites
+
+import (
+	"context"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_getSiteOpenStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	getSiteOpenStatus(w, getRequest(http.MethodGet, "/sites/s1/openStatus", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func Test_getSiteOpenStatusHandler(t *testing.T) {
+	t.Run("Invalid at query param", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/sites/s1/openStatus?at=tomorrow")
+		getSiteOpenStatusHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request validation failed\",\"errors\":[\"Invalid value for "+
+			"query param at, it must be an RFC3339 time\"]}", string(bytes))
+	})
+
+	t.Run("Site not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/sites/s9/openStatus")
+		getSiteOpenStatusHandler(w, r, getOpenStatusRepository(t))
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("Site without operating hours", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/sites/s2/openStatus")
+		getSiteOpenStatusHandler(w, r, getOpenStatusRepository(t))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":422,\"message\":\"Site ID s2 has no operating hours or no timezone\"}",
+			string(bytes))
+	})
+
+	t.Run("Site open at the time", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/sites/s1/openStatus?at=2023-03-06T15:00:00Z")
+		getSiteOpenStatusHandler(w, r, getOpenStatusRepository(t))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"at\":\"2023-03-06T16:00:00+01:00\",\"timezone\":\"Europe/Berlin\",\"open\":true,"+
+			"\"current_window\":{\"open\":\"2023-03-06T09:00:00+01:00\",\"close\":\"2023-03-06T17:00:00+01:00\"},"+
+			"\"next_window\":{\"open\":\"2023-03-07T09:00:00+01:00\",\"close\":\"2023-03-07T17:00:00+01:00\"}}",
+			string(bytes))
+	})
+
+	t.Run("Site closed on a holiday", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getLookupRequest("/sites/s1/openStatus?at=2023-03-08T10:00:00%2B01:00")
+		getSiteOpenStatusHandler(w, r, getOpenStatusRepository(t))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"at\":\"2023-03-08T10:00:00+01:00\",\"timezone\":\"Europe/Berlin\",\"open\":false,"+
+			"\"next_window\":{\"open\":\"2023-03-09T09:00:00+01:00\",\"close\":\"2023-03-09T17:00:00+01:00\"}}",
+			string(bytes))
+	})
+}
+
+// getOpenStatusRepository returns a repository of r12345 with a site s1 in Europe/Berlin open on weekdays from 09:00
+// to 17:00 and closed on 2023-03-08, and a site s2 without operating hours
+func getOpenStatusRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := cloud.NewMemoryRepository()
+	_, err := repository.Save(ctx, common.RetailersCollection, "r12345", map[string]interface{}{
+		"id": "r12345", "deactivated_time": nil})
+	assert.Nil(t, err)
+	operatingHours := &commonModels.OperatingHours{
+		Exceptions: []commonModels.HoursException{{Date: "2023-03-08", Closed: true, Reason: "Holiday"}},
+	}
+	for _, day := range []string{"monday", "tuesday", "wednesday", "thursday", "friday"} {
+		operatingHours.Weekly = append(operatingHours.Weekly,
+			commonModels.DayHours{Day: day, Open: "09:00", Close: "17:00"})
+	}
+	for _, site := range []models.Site{
+		{ID: "s1", Timezone: "Europe/Berlin", OperatingHours: operatingHours},
+		{ID: "s2", Timezone: "Europe/Berlin"},
+	} {
+		_, err = repository.Save(ctx, utils.GetSitePath("r12345"), site.ID, site)
+		assert.Nil(t, err)
+	}
+
+	return repository
+}
@@ -1,13 +1,12 @@
 package sites
 
 import (
-	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	model "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
@@ -15,7 +14,6 @@ import (
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
-	"math"
 	"net/http"
 	"strings"
 )
@@ -26,14 +24,8 @@ func init() {
 	functions.HTTP("GetSiteSpokes", getSiteSpokes)
 }
 
-func getSiteSpokes(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_site_spokes.getSiteSpokes"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getSiteSpokesHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getSiteSpokes = authorization.Authorize("get_site_spokes.getSiteSpokes",
+	authorization.OnHeaderRetailer(common.RoleReader), getSiteSpokesHandler)
 
 func getSiteSpokesHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -66,83 +58,49 @@ func getSiteSpokesHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	//Get Spoke IDs from collection
-	siteSpokeData, _, err := dbClient.GetAll(ctx, utils.GetSiteSpokePath(retailerID),
-		cloud.Page{
-			StartAfterID: "",
-			PageSize:     math.MaxInt,
-			OrderBy:      common.ID,
-			Sort:         common.SortAscending,
-		}, []cloud.Where{{
-			Field:    common.SiteID,
-			Operator: common.OperatorEquals,
-			Value:    siteID,
-		}})
+	var err error
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
+	pageSize := utils.GetPageSizeFromHeader(request, logger)
 
+	startAfter, err = cloud.DecodeNextPageToken(request, common.SpokesEncryptionKey)
 	if err != nil {
-		logger.Errorf("Internal server error while fetching the site spokes mapping from DB : %v", err)
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
 
 		return
 	}
 
-	var siteSpokeMappingData []model.SiteSpoke
-	err = utils.ConvertToObject(siteSpokeData, &siteSpokeMappingData)
+	// the spokes are listed from the copies kept on the site spoke mappings, so a single query pages through them
+	data, startAfter, err := dbClient.GetAll(ctx, utils.GetSiteSpokePath(retailerID),
+		cloud.Page{
+			StartAfter: startAfter,
+			PageSize:   pageSize,
+			OrderBy:    []cloud.Order{{Field: common.SiteSpokeSpoke + "." + common.Name, Sort: common.SortAscending}},
+		}, populateWhereClause(request, siteID))
+
 	if err != nil {
-		logger.Errorf("Internal server error while converting Site Spoke Mpping data to struct : %v", err)
+		logger.Errorf("Internal server error while fetching the site spokes from DB : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
 
 		return
 	}
 
-	var spokeIDs []string
-	for _, siteSpokeMapping := range siteSpokeMappingData {
-		spokeIDs = append(spokeIDs, siteSpokeMapping.SpokeID)
-	}
-
-	var startAfterID, nextPageToken string
-	pageSize := utils.GetPageSizeFromHeader(request, logger)
-
-	if request.Header.Get(common.HeaderPageToken) != "" {
-		startAfterID, err = utils.DecodeNextPageToken(request.Header.Get(common.HeaderPageToken),
-			common.SpokesEncryptionKey)
-		logger.Debugf("ID got after decoding the next page token : %s", startAfterID)
-		if err != nil {
-			logger.Errorf("Error occurred while decoding the next page token : %v", err)
+	spokes := make([]map[string]interface{}, 0, len(data))
+	for _, siteSpoke := range data {
+		spoke, ok := siteSpoke[common.SiteSpokeSpoke].(map[string]interface{})
+		if !ok {
+			logger.Errorf("Site spoke mapping %v does not have the spoke", siteSpoke[common.ID])
 			response.RespondWithInternalServerError(responseWriter, request)
 
 			return
 		}
+		spokes = append(spokes, spoke)
 	}
 
-	var data []map[string]interface{}
-
-	if len(spokeIDs) == 0 {
-		utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, "", model.Spoke{})
-
-		return
-	}
-	where := populateWhereClause(request, spokeIDs)
-
-	data, startAfterID, err = dbClient.GetAll(ctx, utils.GetSpokePath(retailerID),
-		cloud.Page{
-			StartAfterID: startAfterID,
-			PageSize:     pageSize,
-			OrderBy:      common.Name,
-			Sort:         common.SortAscending,
-		}, where)
-
-	if err != nil {
-		logger.Errorf("Internal server error while fetching the sites from DB : %v", err)
-		response.RespondWithInternalServerError(responseWriter, request)
-
-		return
-	}
-
-	if startAfterID != "" && len(data) == pageSize {
-		logger.Debugf("Received startAfterID : %s", startAfterID)
-		nextPageToken, err = utils.GetNextPageToken(startAfterID, common.SpokesEncryptionKey)
-		logger.Debugf("Received nextPageToken : %s", nextPageToken)
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		logger.Debugf("Received nextPageToken : %s", nextPage.Token)
 		if err != nil {
 			logger.Errorf("Error occurred while creating the next page token : %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
@@ -152,24 +110,22 @@ func getSiteSpokesHandler(responseWriter http.ResponseWriter,
 	}
 
 	spoke := model.Spoke{}
-	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPageToken, spoke)
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, spokes, nextPage, spoke)
 }
 
-func populateWhereClause(request *http.Request, spokeIDs []string) []cloud.Where {
+func populateWhereClause(request *http.Request, siteID string) []cloud.Where {
 	where := []cloud.Where{{
-		Field:    common.DeactivatedTime,
+		Field:    common.SiteID,
 		Operator: common.OperatorEquals,
-		Value:    nil,
+		Value:    siteID,
 	}}
-	if strings.ToLower(request.URL.Query().Get(common.QueryParamDeactivated)) == common.True {
-		where = nil
+	if strings.ToLower(request.URL.Query().Get(common.QueryParamDeactivated)) != common.True {
+		where = append(where, cloud.Where{
+			Field:    common.SiteSpokeSpoke + "." + common.DeactivatedTime,
+			Operator: common.OperatorEquals,
+			Value:    nil,
+		})
 	}
 
-	where = append(where, cloud.Where{
-		Field:    common.ID,
-		Operator: common.OperatorIn,
-		Value:    spokeIDs,
-	})
-
 	return where
 }
@@ -1,9 +1,14 @@
 package sites
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	model "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/stretchr/testify/assert"
@@ -15,6 +20,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -106,10 +112,8 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 	t.Run("Required headers passed with page size header passed", func(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSiteSpokeList(10), "p12345", nil).Once()
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSpokesList(10), "p12345", nil).Once()
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSiteSpokePath("r12345"), mock.Anything, mock.Anything).
+			Return(getSiteSpokeList(10), &cloud.Cursor{ID: "p12345"}, nil).Once()
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -127,10 +131,8 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 	t.Run("Required headers passed with page size header passed", func(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSiteSpokeList(10), "p12345", nil).Once()
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSpokesList(5), "p12345", nil).Once()
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSiteSpokePath("r12345"), mock.Anything, mock.Anything).
+			Return(getSiteSpokeList(5), &cloud.Cursor{ID: "p12345"}, nil).Once()
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -149,7 +151,7 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSiteSpokeList(0), "p12345", nil).Once()
+			Return(getSiteSpokeList(0), &cloud.Cursor{ID: "p12345"}, nil).Once()
 
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
@@ -198,18 +200,16 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("p12345", common.SpokesEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSiteSpokeList(10), "p12345", nil).Once()
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSpokesList(10), "p12345", nil).Once()
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSiteSpokePath("r12345"), mock.Anything, mock.Anything).
+			Return(getSiteSpokeList(10), &cloud.Cursor{ID: "p12345"}, nil).Once()
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "p12345")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSiteSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -259,7 +259,7 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "200")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SpokesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSiteSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -272,7 +272,7 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getBadSite(), "r12345", nil)
+			Return(getBadSite(), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -287,18 +287,16 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token with deactivated=true", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.SitesEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSiteSpokeList(1), "p12345", nil).Once()
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSpokesList(1), "p12345", nil).Once()
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSiteSpokePath("r12345"), mock.Anything, mock.Anything).
+			Return(getSiteSpokeList(1), &cloud.Cursor{ID: "p12345"}, nil).Once()
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes?deactivated=true", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSiteSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -308,16 +306,16 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 	})
 
 	t.Run("DB error while fetching site spoke mapping", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.SitesEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(nil, "", errors.New("connection timeout")).Once()
+			Return(nil, nil, errors.New("connection timeout")).Once()
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSiteSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -326,57 +324,119 @@ func Test_getSiteSpokeHandler(t *testing.T) {
 		assert.NotEmpty(t, bytes)
 	})
 
-	t.Run("DB error while fetching spokes for the site", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.SitesEncryptionKey)
+	t.Run("Site spoke mapping without the spoke", func(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(getSiteSpokeList(10), "p12345", nil).Once()
-		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-			Return(nil, "", errors.New("connection timeout")).Once()
+			Return([]map[string]interface{}{{"id": "s12345_p12345", "site_id": "s12345", "spoke_id": "p12345"}},
+				nil, nil).Once()
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
-		r.Header.Set(common.HeaderPageToken, token)
 		getSiteSpokesHandler(w, r, fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Spokes of the site listed by name from the site spoke mappings", func(t *testing.T) {
+		repository := getSiteSpokesRepository(t)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		r.Header.Set(common.HeaderPageSize, "2")
+		getSiteSpokesHandler(w, r, repository)
 		response := w.Result()
-		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var spokes []model.Spoke
 		bytes, _ := io.ReadAll(response.Body)
-		assert.NotEmpty(t, bytes)
+		assert.Nil(t, json.Unmarshal(bytes, &spokes))
+		assert.Equal(t, []string{"p3", "p1"}, []string{spokes[0].ID, spokes[1].ID})
+		assert.NotEmpty(t, response.Header.Get(common.HeaderNextPageToken))
+
+		w = httptest.NewRecorder()
+		r.Header.Set(common.HeaderPageToken, response.Header.Get(common.HeaderNextPageToken))
+		getSiteSpokesHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &spokes))
+		assert.Equal(t, 1, len(spokes))
+		assert.Equal(t, "p4", spokes[0].ID)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+	})
+
+	t.Run("Deactivated spokes of the site listed with deactivated=true", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites/s12345/spokes?deactivated=true", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSiteSpokesHandler(w, r, getSiteSpokesRepository(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var spokes []model.Spoke
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &spokes))
+		assert.Equal(t, 4, len(spokes))
 	})
 }
 
-func getSiteSpokeList(length int) []map[string]interface{} {
-	var list []map[string]interface{}
-	for i := 0; i < length; i++ {
-		data := map[string]interface{}{
-			"site_id":  utils.GetRandomID(5),
-			"spoke_id": utils.GetRandomID(5),
+// getSiteSpokesRepository returns a repository with four spokes attached to the site s12345,
+// one of them deactivated, and a spoke attached to another site
+func getSiteSpokesRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := cloud.NewMemoryRepository()
+	_, err := repository.Save(ctx, common.RetailersCollection, "r12345", map[string]interface{}{
+		"id": "r12345", "deactivated_time": nil})
+	assert.Nil(t, err)
+	_, err = repository.Save(ctx, utils.GetSitePath("r12345"), "s12345", map[string]interface{}{
+		"id": "s12345", "deactivated_time": nil})
+	assert.Nil(t, err)
+	deactivatedTime := time.Now().UTC()
+	for siteID, spokes := range map[string][]model.Spoke{
+		"s12345": {
+			{ID: "p1", Name: "b spoke", RetailerID: "r12345"},
+			{ID: "p2", Name: "a spoke", RetailerID: "r12345", DeactivatedTime: &deactivatedTime},
+			{ID: "p3", Name: "a spoke", RetailerID: "r12345"},
+			{ID: "p4", Name: "c spoke", RetailerID: "r12345"},
+		},
+		"s67890": {{ID: "p5", Name: "a spoke", RetailerID: "r12345"}},
+	} {
+		for _, spoke := range spokes {
+			siteSpoke := model.NewSiteSpoke(models.Site{ID: siteID, RetailerID: "r12345"}, spoke, common.User)
+			_, err = repository.Save(ctx, utils.GetSiteSpokePath("r12345"), siteSpoke.ID, siteSpoke)
+			assert.Nil(t, err)
 		}
-		list = append(list, data)
 	}
 
-	return list
+	return repository
 }
 
-func getSpokesList(length int) []map[string]interface{} {
+func getSiteSpokeList(length int) []map[string]interface{} {
 	var list []map[string]interface{}
 	for i := 0; i < length; i++ {
+		spokeID := utils.GetRandomID(5)
 		data := map[string]interface{}{
-			"id":          "p12345",
-			"name":        "spoke 8",
-			"retailer_id": "r485sh",
-			"timezone":    "Europe/Bucharest",
-			"location": map[string]interface{}{
-				"lat":  45.394,
-				"long": 23.844,
+			"id":       "s12345_" + spokeID,
+			"site_id":  "s12345",
+			"spoke_id": spokeID,
+			"spoke": map[string]interface{}{
+				"id":          spokeID,
+				"name":        "spoke 8",
+				"retailer_id": "r485sh",
+				"timezone":    "Europe/Bucharest",
+				"location": map[string]interface{}{
+					"lat":  45.394,
+					"long": 23.844,
+				},
+				"created_by":   common.User,
+				"updated_by":   common.User,
+				"created_time": "2022-11-24T05:41:47Z",
+				"updated_time": "2022-11-24T05:41:47Z",
 			},
-			"created_by":   common.User,
-			"updated_by":   common.User,
-			"created_time": "2022-11-24T05:41:47Z",
-			"updated_time": "2022-11-24T05:41:47Z",
 		}
 		list = append(list, data)
 	}
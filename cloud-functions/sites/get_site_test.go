@@ -305,4 +305,36 @@ func Test_getSiteHandler(t *testing.T) {
 		bytes, _ := io.ReadAll(response.Body)
 		assert.Equal(t, "{\"id\":\"sd4d9r\",\"name\":\"site 8\",\"retailer_site_id\":\"ABS141\",\"retailer_id\":\"r485sh\",\"status\":\"DRAFT\",\"timezone\":\"Europe/Bucharest\",\"location\":{\"lat\":45.394,\"long\":23.844},\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-11-24T05:41:47Z\",\"updated_time\":\"2022-11-24T05:41:47Z\"}", string(bytes))
 	})
+
+	t.Run("Site not modified since the ETag or the Last-Modified", func(t *testing.T) {
+		repository := getSitesRepository(t)
+		getSiteWithHeaders := func(headers map[string]string) *http.Response {
+			w := httptest.NewRecorder()
+			r := getRequest(http.MethodGet, "/sites/s1", "", common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+			r.Header.Set(common.HeaderRetailerID, "r12345")
+			for name, value := range headers {
+				r.Header.Set(name, value)
+			}
+			getSiteHandler(w, r, repository)
+
+			return w.Result()
+		}
+		fetched := getSiteWithHeaders(nil)
+		assert.Equal(t, http.StatusOK, fetched.StatusCode)
+		etag := fetched.Header.Get(common.HeaderEtag)
+		assert.Equal(t, "2022-01-13T00:00:00Z", fetched.Header.Get(common.HeaderLastModified))
+
+		notModified := getSiteWithHeaders(map[string]string{common.HeaderIfNoneMatch: "W/\"other\", \"" + etag + "\""})
+		assert.Equal(t, http.StatusNotModified, notModified.StatusCode)
+		assert.Equal(t, etag, notModified.Header.Get(common.HeaderEtag))
+		assert.Empty(t, notModified.Header.Get(common.HeaderContentType))
+		bytes, _ := io.ReadAll(notModified.Body)
+		assert.Empty(t, bytes)
+		assert.Equal(t, http.StatusNotModified, getSiteWithHeaders(map[string]string{
+			common.HeaderIfModifiedSince: fetched.Header.Get(common.HeaderLastModified)}).StatusCode)
+		assert.Equal(t, http.StatusOK, getSiteWithHeaders(map[string]string{
+			common.HeaderIfModifiedSince: "Wed, 12 Jan 2022 23:59:59 GMT"}).StatusCode)
+		assert.Equal(t, http.StatusOK, getSiteWithHeaders(map[string]string{common.HeaderIfNoneMatch: "other",
+			common.HeaderIfModifiedSince: fetched.Header.Get(common.HeaderLastModified)}).StatusCode)
+	})
 }
@@ -1,11 +1,11 @@
 package sites
 
 import (
-	"context"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
@@ -20,18 +20,23 @@ import (
 // This file has the function and handler to get list sites from the DB
 var getSitesPath = urit.MustCreateTemplate("/sites")
 
+// getSitesQuery are the filters and the sort fields accepted as query params by the list of sites
+var getSitesQuery = cloud.ListQuery{
+	Filters: append([]cloud.ListFilter{
+		{QueryParam: common.Status, Field: common.Status, Operator: common.OperatorEquals},
+		{QueryParam: common.Timezone, Field: common.Timezone, Operator: common.OperatorEquals},
+		{QueryParam: common.RetailersSiteID, Field: common.RetailersSiteID, Operator: common.OperatorEquals},
+		{QueryParam: common.QueryParamNamePrefix, Field: common.Name, Operator: common.OperatorPrefix},
+	}, cloud.GetTimeFilters()...),
+	SortFields: []string{common.ID, common.Name, common.Status, common.CreatedTime, common.UpdatedTime},
+}
+
 func init() {
 	functions.HTTP("GetSites", getSites)
 }
 
-func getSites(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_sites.getSites"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getSitesHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getSites = authorization.Authorize("get_sites.getSites",
+	authorization.OnHeaderRetailer(common.RoleReader), getSitesHandler)
 
 func getSitesHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -49,29 +54,45 @@ func getSitesHandler(responseWriter http.ResponseWriter,
 
 		return
 	}
+
+	listWhere, orderBy, errs := cloud.ParseListQuery(request, getSitesQuery)
+	near, nearErrs := cloud.ParseNearQuery(request, listWhere)
+	errs = append(errs, nearErrs...)
+	if errs != nil {
+		logger.Debugf("Request query params validation failed. errors : %v", errs)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, "Request validation failed", errs),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
 	//By default, deleted records are omitted from response.
 	skipDeactivated := true
 	//Gets Retailer ID from header Params
 	retailerID := request.Header.Get(common.HeaderRetailerID)
 
-	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, skipDeactivated) {
+	retailerData, found := dbutil.GetRetailerFromDB(responseWriter, request, dbClient, retailerID, logger,
+		skipDeactivated)
+	if !found {
+		return
+	}
+	attributesWhere, valid := attributes.GetFiltersForRequest(responseWriter, request, retailerData)
+	if !valid {
 		return
 	}
 
-	var startAfterID, nextPageToken string
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
 	var err error
 	pageSize := utils.GetPageSizeFromHeader(request, logger)
 
-	if request.Header.Get(common.HeaderPageToken) != "" {
-		startAfterID, err = utils.DecodeNextPageToken(request.Header.Get(common.HeaderPageToken),
-			common.SitesEncryptionKey)
-		logger.Debugf("ID got after decoding the next page token : %s", startAfterID)
-		if err != nil {
-			logger.Errorf("Error occurred while decoding the next page token : %v", err)
-			response.RespondWithInternalServerError(responseWriter, request)
+	startAfter, err = cloud.DecodeNextPageToken(request, common.SitesEncryptionKey)
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
 
-			return
-		}
+		return
 	}
 	var data []map[string]interface{}
 	where := []cloud.Where{{
@@ -82,13 +103,13 @@ func getSitesHandler(responseWriter http.ResponseWriter,
 	if strings.ToLower(request.URL.Query().Get(common.QueryParamDeactivated)) == common.True {
 		where = nil
 	}
-	data, startAfterID, err = dbClient.GetAll(ctx, utils.GetSitePath(retailerID),
-		cloud.Page{
-			StartAfterID: startAfterID,
-			PageSize:     pageSize,
-			OrderBy:      common.ID,
-			Sort:         common.SortAscending,
-		}, where)
+	where = append(append(where, listWhere...), attributesWhere...)
+	page := cloud.Page{StartAfter: startAfter, PageSize: pageSize, OrderBy: orderBy}
+	if near != nil {
+		data, startAfter, err = cloud.GetAllNear(ctx, dbClient, utils.GetSitePath(retailerID), *near, page, where)
+	} else {
+		data, startAfter, err = dbClient.GetAll(ctx, utils.GetSitePath(retailerID), page, where)
+	}
 
 	if err != nil {
 		logger.Errorf("Internal server error while fetching the sites from DB : %v", err)
@@ -97,10 +118,9 @@ func getSitesHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	if startAfterID != "" && len(data) == pageSize {
-		logger.Debugf("Received startAfterID : %s", startAfterID)
-		nextPageToken, err = utils.GetNextPageToken(startAfterID, common.SitesEncryptionKey)
-		logger.Debugf("Received nextPageToken : %s", nextPageToken)
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		logger.Debugf("Received nextPageToken : %s", nextPage.Token)
 		if err != nil {
 			logger.Errorf("Error occurred while creating the next page token : %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
@@ -109,5 +129,5 @@ func getSitesHandler(responseWriter http.ResponseWriter,
 		}
 	}
 	site := models.Site{}
-	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPageToken, site)
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPage, site)
 }
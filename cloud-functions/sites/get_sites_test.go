@@ -1,10 +1,15 @@
 package sites
 
 import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +21,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -23,6 +29,14 @@ func init() {
 	if err != nil {
 		return
 	}
+	err = os.Setenv(common.EnvPageTokenKeys, "test:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		return
+	}
+	err = os.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+	if err != nil {
+		return
+	}
 }
 
 func Test_getSites(t *testing.T) {
@@ -110,7 +124,7 @@ func Test_getSitesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSiteList(10), "r12345", nil) //returning list equal to page size
+			Return(getSiteList(10), &cloud.Cursor{ID: "r12345"}, nil) //returning list equal to page size
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -133,7 +147,7 @@ func Test_getSitesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSiteList(5), "r12345", nil) //returning list less than page size
+			Return(getSiteList(5), &cloud.Cursor{ID: "r12345"}, nil) //returning list less than page size
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -156,7 +170,7 @@ func Test_getSitesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSiteList(0), "r12345", nil)
+			Return(getSiteList(0), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -178,7 +192,7 @@ func Test_getSitesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSiteList(5), "r12345", nil)
+			Return(getSiteList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -210,19 +224,19 @@ func Test_getSitesHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.SitesEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where{{
 			Field:    common.DeactivatedTime,
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSiteList(5), "r12345", nil)
+			Return(getSiteList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		getSitesHandler(w, r, fireStoreClient)
@@ -264,15 +278,15 @@ func Test_getSitesHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token with deactivated=true", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.SitesEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where(nil)).
-			Return(getSiteList(5), "r12345", nil)
+			Return(getSiteList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites?deactivated=true", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		getSitesHandler(w, r, fireStoreClient)
@@ -290,7 +304,7 @@ func Test_getSitesHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "200")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSitesHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -302,7 +316,7 @@ func Test_getSitesHandler(t *testing.T) {
 	t.Run("DB returned wrong entities", func(t *testing.T) {
 		fireStoreClient := mocks.NewDB(t)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where(nil)).
-			Return(getBadSite(), "r12345", nil)
+			Return(getBadSite(), &cloud.Cursor{ID: "r12345"}, nil)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites?deactivated=true", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -324,7 +338,7 @@ func Test_getSitesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(nil, "", errors.New("connection timeout"))
+			Return(nil, nil, errors.New("connection timeout"))
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/sites", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
@@ -335,6 +349,261 @@ func Test_getSitesHandler(t *testing.T) {
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
 	})
+
+	t.Run("Unsupported order_by query param", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?order_by=location", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSitesHandler(w, r, mocks.NewDB(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request validation failed\",\"errors\":"+
+			"[\"Unsupported value for query param order_by, supported values are "+
+			"[id name status created_time updated_time]\"]}", string(bytes))
+	})
+
+	t.Run("Sites filtered on status and name prefix ordered by name descending", func(t *testing.T) {
+		repository := getSitesRepository(t)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?status=draft&name_prefix=north&sort=desc", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		r.Header.Set(common.HeaderPageSize, "2")
+		getSitesHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var sites []models.Site
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &sites))
+		assert.Equal(t, []string{"s4", "s3"}, []string{sites[0].ID, sites[1].ID})
+		assert.NotEmpty(t, response.Header.Get(common.HeaderNextPageToken))
+
+		w = httptest.NewRecorder()
+		r.Header.Set(common.HeaderPageToken, response.Header.Get(common.HeaderNextPageToken))
+		getSitesHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &sites))
+		assert.Equal(t, []string{"s1"}, []string{sites[0].ID})
+	})
+
+	t.Run("Sites filtered on the created time", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?created_after=2022-01-14T12:00:00Z", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSitesHandler(w, r, getSitesRepository(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var sites []models.Site
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &sites))
+		assert.Equal(t, []string{"s3", "s4", "s5"}, []string{sites[0].ID, sites[1].ID, sites[2].ID})
+	})
+
+	t.Run("Sites not modified since the ETag of the list", func(t *testing.T) {
+		repository := getSitesRepository(t)
+		getSitesWithHeaders := func(headers map[string]string) *http.Response {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/sites?status=draft", nil)
+			r.Header.Set(common.HeaderXCorrelationID, "1234")
+			r.Header.Set(common.HeaderRetailerID, "r12345")
+			r.Header.Set(common.HeaderAcceptVersion, "v1")
+			for name, value := range headers {
+				r.Header.Set(name, value)
+			}
+			getSitesHandler(w, r, repository)
+
+			return w.Result()
+		}
+		listed := getSitesWithHeaders(nil)
+		assert.Equal(t, http.StatusOK, listed.StatusCode)
+		assert.NotEmpty(t, listed.Header.Get(common.HeaderEtag))
+		assert.Equal(t, "2022-01-17T00:00:00Z", listed.Header.Get(common.HeaderLastModified))
+
+		notModified := getSitesWithHeaders(map[string]string{
+			common.HeaderIfNoneMatch: "\"" + listed.Header.Get(common.HeaderEtag) + "\""})
+		assert.Equal(t, http.StatusNotModified, notModified.StatusCode)
+		assert.Equal(t, listed.Header.Get(common.HeaderEtag), notModified.Header.Get(common.HeaderEtag))
+		bytes, _ := io.ReadAll(notModified.Body)
+		assert.Empty(t, bytes)
+		// a list is not modified only when it has the ETag, a site leaving the list does not change its Last-Modified
+		assert.Equal(t, http.StatusOK, getSitesWithHeaders(map[string]string{
+			common.HeaderIfModifiedSince: "Mon, 17 Jan 2022 00:00:00 GMT"}).StatusCode)
+
+		_, err := repository.Update(context.Background(), utils.GetSitePath("r12345"), "s3",
+			[]firestore.Update{{Path: common.DeactivatedTime, Value: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)}})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, getSitesWithHeaders(map[string]string{
+			common.HeaderIfNoneMatch: listed.Header.Get(common.HeaderEtag)}).StatusCode)
+		assert.Equal(t, "2022-01-17T00:00:00Z", getSitesWithHeaders(nil).Header.Get(common.HeaderLastModified))
+	})
+
+	t.Run("Page of the sites with a next page not modified since its ETag", func(t *testing.T) {
+		repository := getSitesRepository(t)
+		getFirstPage := func(ifNoneMatch string) *http.Response {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/sites?status=draft", nil)
+			r.Header.Set(common.HeaderXCorrelationID, "1234")
+			r.Header.Set(common.HeaderRetailerID, "r12345")
+			r.Header.Set(common.HeaderAcceptVersion, "v1")
+			r.Header.Set(common.HeaderPageSize, "2")
+			r.Header.Set(common.HeaderIfNoneMatch, ifNoneMatch)
+			getSitesHandler(w, r, repository)
+
+			return w.Result()
+		}
+		listed := getFirstPage("")
+		assert.Equal(t, http.StatusOK, listed.StatusCode)
+		assert.NotEmpty(t, listed.Header.Get(common.HeaderNextPageToken))
+
+		notModified := getFirstPage(listed.Header.Get(common.HeaderEtag))
+		assert.Equal(t, http.StatusNotModified, notModified.StatusCode)
+		assert.Equal(t, listed.Header.Get(common.HeaderEtag), notModified.Header.Get(common.HeaderEtag))
+		assert.NotEmpty(t, notModified.Header.Get(common.HeaderNextPageToken))
+
+		_, err := repository.Update(context.Background(), utils.GetSitePath("r12345"), "s3",
+			[]firestore.Update{{Path: common.DeactivatedTime, Value: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)}})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, getFirstPage(listed.Header.Get(common.HeaderEtag)).StatusCode)
+	})
+
+	t.Run("Page token of another query rejected", func(t *testing.T) {
+		repository := getSitesRepository(t)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?status=draft", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		r.Header.Set(common.HeaderPageSize, "2")
+		getSitesHandler(w, r, repository)
+		pageToken := w.Result().Header.Get(common.HeaderNextPageToken)
+		assert.NotEmpty(t, pageToken)
+
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/sites?status=active", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		r.Header.Set(common.HeaderPageToken, pageToken)
+		getSitesHandler(w, r, repository)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Sites near a point ordered by distance", func(t *testing.T) {
+		repository := getSitesRepository(t)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?near=37.7749,-122.4194&radius_km=7&status=draft", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		r.Header.Set(common.HeaderPageSize, "2")
+		getSitesHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var sites []models.Site
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &sites))
+		assert.Equal(t, []string{"s1", "s3"}, []string{sites[0].ID, sites[1].ID})
+		assert.Equal(t, []float64{0, 4.448}, []float64{*sites[0].DistanceKm, *sites[1].DistanceKm})
+		assert.NotEmpty(t, response.Header.Get(common.HeaderNextPageToken))
+
+		w = httptest.NewRecorder()
+		r.Header.Set(common.HeaderPageToken, response.Header.Get(common.HeaderNextPageToken))
+		getSitesHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &sites))
+		assert.Equal(t, 1, len(sites))
+		assert.Equal(t, "s4", sites[0].ID)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+	})
+
+	t.Run("Sites filtered on attributes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?attributes.brand=Acme&attributes.tags=mall", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSitesHandler(w, r, getSitesRepository(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var sites []models.Site
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &sites))
+		assert.Equal(t, []string{"s3", "s5"}, []string{sites[0].ID, sites[1].ID})
+		assert.Len(t, sites, 2)
+	})
+
+	t.Run("Filter on an undeclared attribute rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?attributes.color=red", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSitesHandler(w, r, getSitesRepository(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request validation failed\",\"errors\":[\"Unsupported query "+
+			"param attributes.color, color is not a declared attribute\"]}", string(bytes))
+	})
+
+	t.Run("Near query with order_by rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/sites?near=37.7749,-122.4194&radius_km=4&order_by=name", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSitesHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
+
+// getSitesRepository returns a repository with five sites of r12345 created a day apart from 2022-01-13,
+// three of them in draft and named "north...", located 0.02 degrees of latitude (about 2.2 km) apart
+// north of 37.7749,-122.4194. The odd sites have the brand Acme, and the sites from s3 the mall tag
+func getSitesRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := cloud.NewMemoryRepository()
+	_, err := repository.Save(ctx, common.RetailersCollection, "r12345", map[string]interface{}{
+		"id": "r12345", "deactivated_time": nil, common.AttributeSchema: map[string]interface{}{
+			"type": "object", "properties": map[string]interface{}{
+				"brand": map[string]interface{}{"type": "string"},
+				"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			}}})
+	assert.Nil(t, err)
+	for index, site := range []models.Site{
+		{ID: "s1", Name: "north a", Status: common.StatusDraft},
+		{ID: "s2", Name: "north b", Status: "active"},
+		{ID: "s3", Name: "north c", Status: common.StatusDraft},
+		{ID: "s4", Name: "north d", Status: common.StatusDraft},
+		{ID: "s5", Name: "south a", Status: common.StatusDraft},
+	} {
+		createdTime := time.Date(2022, 1, 13+index, 0, 0, 0, 0, time.UTC)
+		site.RetailerID = "r12345"
+		site.CreatedTime = &createdTime
+		site.UpdatedTime = &createdTime
+		latitude, longitude := 37.7749+float64(index)*0.02, -122.4194
+		site.Location = &commonModels.Location{Latitude: &latitude, Longitude: &longitude}
+		site.Geohash = utils.GetGeohash(latitude, longitude, common.GeohashPrecision)
+		site.Attributes = map[string]interface{}{"brand": []string{"Acme", "Globex"}[index%2],
+			"tags": []interface{}{"street"}}
+		if index >= 2 {
+			site.Attributes["tags"] = []interface{}{"street", "mall"}
+		}
+		_, err = repository.Save(ctx, utils.GetSitePath("r12345"), site.ID, site)
+		assert.Nil(t, err)
+	}
+
+	return repository
 }
 
 func getSiteList(length int) []map[string]interface{} {
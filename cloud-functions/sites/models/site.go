@@ -8,20 +8,27 @@ import (
 
 //nolint:lll
 type Site struct {
-	ID              string           `json:"id" validate:"disallowed" firestore:"id" structs:"id"`
-	Name            string           `json:"name" validate:"required,name" firestore:"name" structs:"name"`
-	RetailerSiteID  string           `json:"retailer_site_id" validate:"required" firestore:"retailer_site_id" structs:"retailer_site_id"`
-	RetailerID      string           `json:"retailer_id" validate:"disallowed" firestore:"retailer_id" structs:"retailer_id"`
-	Status          string           `json:"status" validate:"disallowed" firestore:"status" structs:"status"`
-	Timezone        string           `json:"timezone" validate:"disallowed" firestore:"timezone" structs:"timezone"`
-	Location        *models.Location `json:"location" validate:"required" firestore:"location" structs:"location"`
-	CreatedBy       string           `json:"created_by" validate:"disallowed" firestore:"created_by" structs:"created_by"`
-	UpdatedBy       string           `json:"updated_by" validate:"disallowed" firestore:"updated_by" structs:"updated_by"`
-	DeactivatedBy   string           `json:"deactivated_by,omitempty" validate:"disallowed" firestore:"deactivated_by" structs:"deactivated_by"`
-	CreatedTime     *time.Time       `json:"created_time" validate:"disallowed" firestore:"created_time" structs:"created_time"`
-	UpdatedTime     *time.Time       `json:"updated_time" validate:"disallowed" firestore:"updated_time" structs:"updated_time"`
-	DeactivatedTime *time.Time       `json:"deactivated_time,omitempty" validate:"disallowed" firestore:"deactivated_time" structs:"deactivated_time"`
-	ETag            string           `json:"etag,omitempty" validate:"disallowed" firestore:"-" structs:"-"`
+	ID              string                 `json:"id" validate:"disallowed" firestore:"id" structs:"id"`
+	Name            string                 `json:"name" validate:"required,name" firestore:"name" structs:"name"`
+	RetailerSiteID  string                 `json:"retailer_site_id" validate:"required" firestore:"retailer_site_id" structs:"retailer_site_id"`
+	RetailerID      string                 `json:"retailer_id" validate:"disallowed" firestore:"retailer_id" structs:"retailer_id"`
+	Status          string                 `json:"status" validate:"disallowed" firestore:"status" structs:"status"`
+	Timezone        string                 `json:"timezone" validate:"disallowed" firestore:"timezone" structs:"timezone"`
+	Location        *models.Location       `json:"location" validate:"required_without=Address" firestore:"location" structs:"location"`
+	Address         *models.Address        `json:"address,omitempty" firestore:"address,omitempty" structs:"address,omitempty"`
+	Geohash         string                 `json:"geohash,omitempty" validate:"disallowed" firestore:"geohash,omitempty" structs:"geohash,omitempty"`
+	DistanceKm      *float64               `json:"distance_km,omitempty" validate:"disallowed" firestore:"-" structs:"-"`
+	ServiceArea     *models.ServiceArea    `json:"service_area,omitempty" firestore:"service_area,omitempty" structs:"service_area,omitempty"`
+	OperatingHours  *models.OperatingHours `json:"operating_hours,omitempty" firestore:"operating_hours,omitempty" structs:"operating_hours,omitempty"`
+	Attributes      map[string]interface{} `json:"attributes,omitempty" firestore:"attributes,omitempty" structs:"attributes,omitempty"`
+	CreatedBy       string                 `json:"created_by" validate:"disallowed" firestore:"created_by" structs:"created_by"`
+	UpdatedBy       string                 `json:"updated_by" validate:"disallowed" firestore:"updated_by" structs:"updated_by"`
+	DeactivatedBy   string                 `json:"deactivated_by,omitempty" validate:"disallowed" firestore:"deactivated_by" structs:"deactivated_by"`
+	CreatedTime     *time.Time             `json:"created_time" validate:"disallowed" firestore:"created_time" structs:"created_time"`
+	UpdatedTime     *time.Time             `json:"updated_time" validate:"disallowed" firestore:"updated_time" structs:"updated_time"`
+	DeactivatedTime *time.Time             `json:"deactivated_time,omitempty" validate:"disallowed" firestore:"deactivated_time" structs:"deactivated_time"`
+	Revision        int64                  `json:"revision,omitempty" validate:"disallowed" firestore:"revision,omitempty" structs:"revision,omitempty"`
+	ETag            string                 `json:"etag,omitempty" validate:"disallowed" firestore:"-" structs:"-"`
 }
 
 type SiteStatuses struct {
@@ -6,23 +6,31 @@ import (
 	"errors"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/geocoding"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
-	commonModel "github.com/TakeoffTech/site-info-svc/common/models"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/timezone"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/fatih/structs"
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -33,16 +41,11 @@ func init() {
 	functions.HTTP("PatchSite", patchSite)
 }
 
-func patchSite(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("patch_site.patchSite"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	patchSiteHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()),
-		cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var patchSite = authorization.Authorize("patch_site.patchSite",
+	authorization.OnHeaderRetailer(common.RoleEditor),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		patchSiteHandler(responseWriter, request, dbClient, cloud.NewPubSubRepository(request.Context()))
+	})
 
 func patchSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -50,6 +53,7 @@ func patchSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 	defer span.End()
 	logger := logging.GetLoggerFromContext(ctx)
 	var site models.Site
+	var mergePatch map[string]interface{}
 
 	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
 		RequiredHeaders: append(models.GetRequiredHeaders(), common.HeaderIfMatch),
@@ -58,6 +62,7 @@ func patchSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 		RequestBodyValidation: &utils.RequestBodyValidation{
 			Entity:             &site,
 			CompleteValidation: false,
+			MergePatch:         &mergePatch,
 		},
 	})
 	if validationResponse != nil {
@@ -72,7 +77,8 @@ func patchSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 	//Gets Site ID from Query Params
 	siteID := pathParams[common.PathParamSiteID]
 
-	oldSiteDataMap, err := validateRequestData(ctx, responseWriter, request, retailerID, siteID, dbClient)
+	retailerData, oldSiteDataMap, err := validateRequestData(ctx, responseWriter, request, retailerID, siteID,
+		dbClient)
 	if err != nil {
 		return
 	}
@@ -86,45 +92,71 @@ func patchSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 		return
 	}
 
-	newSiteData, err := checkNameForUpdate(ctx, responseWriter, request, site, oldSiteData, retailerID, dbClient)
-	if err != nil {
-		return
+	if mergePatch != nil {
+		site, err = getMergePatchSite(ctx, responseWriter, request, oldSiteData, mergePatch)
+		if err != nil {
+			return
+		}
 	}
 
-	isTimezoneChanged := false
-	// Location can't be 0,0 or empty.
-
-	newSiteData, isTimezoneChanged, err = checkLocationForUpdate(ctx, responseWriter, request, site, newSiteData)
+	newSiteData, isTimezoneChanged, err := getUpdatedSite(ctx, responseWriter, request, retailerData, site,
+		oldSiteData, dbClient)
 	if err != nil {
 		return
 	}
 
 	if !reflect.DeepEqual(newSiteData, oldSiteData) {
 		updatedTime := time.Now().UTC().Round(time.Second)
-		newSiteData.UpdatedBy = common.User
+		newSiteData.UpdatedBy = identity.GetPrincipalFromContext(ctx)
 		newSiteData.UpdatedTime = &updatedTime
+		newSiteData.Revision++
 		docForUpdate := createDocForUpdate(newSiteData, oldSiteData)
-		updateTime, err := dbClient.Update(ctx, utils.GetSitePath(newSiteData.RetailerID), newSiteData.ID, docForUpdate)
+		events, err := getPatchSiteEvents(request, oldSiteData, newSiteData)
 		if err != nil {
-			logger.Errorf("Error while deleting site from DB : %v", err)
+			logger.Errorf("Error while creating the site events : %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
 
 			return
 		}
+		writeOptions := getUniqueKeyWriteOptions(newSiteData, oldSiteData)
+		writeOptions.Creates = outbox.Documents(events)
+		// the site is not updated if it changed since it was read
+		writeOptions.Revision = &oldSiteData.Revision
+		updateTime, err := dbClient.UpdateInTransaction(ctx, utils.GetSitePath(newSiteData.RetailerID),
+			newSiteData.ID, docForUpdate, writeOptions)
+		var uniqueKeyError *cloud.UniqueKeyError
+		if errors.As(err, &uniqueKeyError) {
+			logger.Debugf("Unique key reserved by another site : %v", uniqueKeyError.Key)
+			message := fmt.Sprintf("Site with name : %s already exists", uniqueKeyError.Key.Value)
+			if uniqueKeyError.Key.Field == common.RetailersSiteID {
+				message = fmt.Sprintf("Retailer's site id %s already exists", uniqueKeyError.Key.Value)
+			}
+			response.RespondWithResponseObject(responseWriter,
+				response.NewResponse(http.StatusUnprocessableEntity, message, nil),
+				response.GetCommonResponseHeaders(request))
 
-		sendPatchResponse(ctx, responseWriter, request, newSiteData, updateTime, isTimezoneChanged)
-		pubsubClient.Publish(ctx, os.Getenv(common.EnvAuditLogTopic),
-			audit.GetPubSubAuditMessage(audit.GetSiteAuditPath(newSiteData.RetailerID, newSiteData.ID),
-				request.Header.Get(common.HeaderXCorrelationID), newSiteData.UpdatedBy,
-				common.AuditTypeUpdate,
-				common.EntitySite,
-				newSiteData.UpdatedTime,
-				structs.Map(oldSiteData),
-				structs.Map(newSiteData),
-			))
+			return
+		}
+		if status.Code(err) == codes.FailedPrecondition {
+			logger.Debugf("Site %s changed since it was read : %v", siteID, err)
+			response.RespondWithPreconditionFailed(responseWriter, request)
 
-		pubsubClient.Publish(ctx, os.Getenv(common.EnvSiteMessageTopic),
-			models.GetPubSubSiteMessage(newSiteData.RetailerID, newSiteData.ID, common.ChangeTypeUpdate))
+			return
+		}
+		if err != nil {
+			logger.Errorf("Error while deleting site from DB : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+
+		var serviceAreaOverlaps []string
+		if !reflect.DeepEqual(newSiteData.ServiceArea, oldSiteData.ServiceArea) {
+			serviceAreaOverlaps = getServiceAreaOverlaps(ctx, dbClient, newSiteData)
+		}
+		sendPatchResponse(ctx, responseWriter, request, newSiteData, updateTime, isTimezoneChanged,
+			serviceAreaOverlaps)
+		outbox.Dispatch(ctx, dbClient, pubsubClient, events)
 	} else {
 		// valid json and site object is same as in db.
 		logger.Errorf("site object not changed \nold object: %v \nnew object: %v", oldSiteData, newSiteData)
@@ -135,69 +167,223 @@ func patchSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 	}
 }
 
-func checkGoogleTimeZone(googleTimeZone *commonModel.GoogleTimeZone) bool {
-	if googleTimeZone != nil && googleTimeZone.TimezoneID != "" {
+func checkSiteLocation(site, newSiteData models.Site) bool {
+	if site.IsValidLocationData() && !reflect.DeepEqual(newSiteData.Location, site.Location) {
 		return true
 	}
 
 	return false
 }
 
-func checkSiteLocation(site, newSiteData models.Site) bool {
-	if site.IsValidLocationData() && !reflect.DeepEqual(newSiteData.Location, site.Location) {
-		return true
+// getUpdatedSite returns the stored site with the changes of the requested site, checking the uniqueness of the
+// name and retailer's site id and resolving the location and timezone, along with whether the timezone changed
+func getUpdatedSite(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	retailerData map[string]interface{}, site, oldSiteData models.Site, dbClient cloud.DB) (models.Site, bool, error) {
+	newSiteData, err := checkNameForUpdate(ctx, responseWriter, request, site, oldSiteData, oldSiteData.RetailerID,
+		dbClient)
+	if err != nil {
+		return newSiteData, false, err
+	}
+	newSiteData, err = checkRetailerSiteIDForUpdate(ctx, responseWriter, request, site, newSiteData, dbClient)
+	if err != nil {
+		return newSiteData, false, err
+	}
+	// Location can't be 0,0 or empty.
+	newSiteData, isTimezoneChanged, err := checkLocationForUpdate(ctx, responseWriter, request, site, newSiteData)
+	if err != nil {
+		return newSiteData, false, err
+	}
+	if site.ServiceArea != nil {
+		// an empty service area removes the service area of the site
+		newSiteData.ServiceArea = utils.NewServiceArea(site.ServiceArea)
+	}
+	if site.OperatingHours != nil {
+		// empty operating hours remove the operating hours of the site
+		newSiteData.OperatingHours = utils.NewOperatingHours(site.OperatingHours)
 	}
+	newSiteData, err = checkAttributesForUpdate(responseWriter, request, retailerData, site, newSiteData)
 
-	return false
+	return newSiteData, isTimezoneChanged, err
+}
+
+// getMergePatchSite returns the requested site equivalent to the JSON merge patch of the stored site, with the removed
+// address, service area and operating hours as empty values and the removed attributes as null attributes.
+// A site always has a location, so the location can't be removed
+func getMergePatchSite(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	oldSiteData models.Site, patch map[string]interface{}) (models.Site, error) {
+	logger := logging.GetLoggerFromContext(ctx)
+	patchedSite := oldSiteData
+	validationResponse := utils.ApplyMergePatch(ctx, &patchedSite, patch)
+	if validationResponse == nil && patchedSite.Location == nil {
+		validationResponse = response.NewResponse(http.StatusBadRequest, "Request body validation failed",
+			[]string{"location can not be removed, a site always has a location"})
+	}
+	if validationResponse != nil {
+		logger.Debugf("Merge patch validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse,
+			response.GetCommonResponseHeaders(request))
+
+		return models.Site{}, errors.New("invalid merge patch")
+	}
+
+	var site models.Site
+	if patchedSite.Name != oldSiteData.Name {
+		site.Name = patchedSite.Name
+	}
+	if patchedSite.RetailerSiteID != oldSiteData.RetailerSiteID {
+		site.RetailerSiteID = patchedSite.RetailerSiteID
+	}
+	if _, ok := patch[common.Location]; ok {
+		site.Location = patchedSite.Location
+	}
+	if _, ok := patch[common.Address]; ok {
+		site.Address = &commonModels.Address{}
+		if patchedSite.Address != nil {
+			site.Address = patchedSite.Address
+		}
+	}
+	if _, ok := patch[common.ServiceArea]; ok {
+		site.ServiceArea = &commonModels.ServiceArea{}
+		if patchedSite.ServiceArea != nil {
+			site.ServiceArea = patchedSite.ServiceArea
+		}
+	}
+	if _, ok := patch[common.OperatingHours]; ok {
+		site.OperatingHours = &commonModels.OperatingHours{}
+		if patchedSite.OperatingHours != nil {
+			site.OperatingHours = patchedSite.OperatingHours
+		}
+	}
+	site.Attributes = getMergePatchAttributes(oldSiteData.Attributes, patchedSite.Attributes, patch)
+
+	return site, nil
+}
+
+// getMergePatchAttributes returns the attributes to be merged into the stored attributes for the JSON merge patch,
+// the patched attributes and null for the removed ones, so the attributes which are not patched keep their values
+func getMergePatchAttributes(oldAttributes, patchedAttributes map[string]interface{},
+	patch map[string]interface{}) map[string]interface{} {
+	patchAttributes, ok := patch[common.Attributes]
+	if !ok {
+		return nil
+	}
+	attributesUpdate := map[string]interface{}{}
+	if patchAttributes == nil {
+		for name := range oldAttributes {
+			attributesUpdate[name] = nil
+		}
+
+		return attributesUpdate
+	}
+	for name := range patchAttributes.(map[string]interface{}) {
+		attributesUpdate[name] = patchedAttributes[name]
+	}
+
+	return attributesUpdate
 }
 
 func validateRequestData(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	retailerID, siteID string, dbClient cloud.DB) (map[string]interface{}, error) {
+	retailerID, siteID string, dbClient cloud.DB) (map[string]interface{}, map[string]interface{}, error) {
 	logger := logging.GetLoggerFromContext(ctx)
-	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
-		return nil, errors.New("retailer not found")
+	retailerData, found := dbutil.GetRetailerFromDB(responseWriter, request, dbClient, retailerID, logger, true)
+	if !found {
+		return nil, nil, errors.New("retailer not found")
 	}
 
 	oldSiteDataMap := siteCommon.GetSiteFromDB(responseWriter, request, logger, dbClient, retailerID, siteID, true)
 	if oldSiteDataMap == nil {
-		return nil, errors.New("site not found")
+		return nil, nil, errors.New("site not found")
 	}
 
 	if !utils.IsValidEtagPresentInHeader(responseWriter, request, oldSiteDataMap, logger) {
-		return nil, errors.New("invalid etag")
+		return nil, nil, errors.New("invalid etag")
 	}
 
-	return oldSiteDataMap, nil
+	return retailerData, oldSiteDataMap, nil
 }
 
 func checkLocationForUpdate(ctx context.Context, responseWriter http.ResponseWriter,
 	request *http.Request, site, newSiteData models.Site) (models.Site, bool, error) {
 	newSite := newSiteData
 	isTimezoneChanged := false
+	if site.Address != nil {
+		// an empty address removes the address of the site
+		newSite.Address = utils.NewAddress(site.Address)
+	}
+	if site.Address != nil || site.Location != nil {
+		// the requested location is checked against the address, or else geocoded from the new address
+		location, resolved := geocoding.GetLocationForRequest(ctx, responseWriter, request, newSite.Address,
+			site.Location)
+		if !resolved {
+			return newSite, false, errors.New("location not resolved")
+		}
+		site.Location = location
+	}
 	if checkSiteLocation(site, newSite) {
 		newSite.Location = site.Location
-		googleTimeZone, err := utils.GetTimeZone(ctx, *site.Location.Latitude, *site.Location.Longitude)
-		if err != nil {
-			logging.GetLoggerFromContext(ctx).Errorf("Error occurred while retrieving timezone : %v", err)
-			response.RespondWithResponseObject(responseWriter,
-				response.NewResponse(http.StatusBadRequest,
-					fmt.Sprintf("Error occurred while retrieving location with latitude %f and longitude %f. "+
-						"Timezone API returned with status: %s. "+
-						"Please provide valid location details", *site.Location.Latitude, *site.Location.Longitude,
-						googleTimeZone.Status), nil),
-				response.GetCommonResponseHeaders(request))
-
-			return newSite, false, err
-		}
-		if checkGoogleTimeZone(googleTimeZone) {
-			newSite.Timezone = googleTimeZone.TimezoneID
-			isTimezoneChanged = true
+		newSite.Geohash = utils.GetGeohash(*site.Location.Latitude, *site.Location.Longitude,
+			common.GeohashPrecision)
+		siteTimezone, resolved := timezone.GetTimezoneForRequest(ctx, responseWriter, request,
+			*site.Location.Latitude, *site.Location.Longitude)
+		if !resolved {
+			return newSite, false, errors.New("timezone not resolved")
 		}
+		newSite.Timezone = siteTimezone
+		isTimezoneChanged = true
 	}
 
 	return newSite, isTimezoneChanged, nil
 }
 
+// checkAttributesForUpdate returns the site with the requested attributes merged into its attributes, a null
+// attribute removing the attribute, after validating them against the attribute schema of the retailer
+func checkAttributesForUpdate(responseWriter http.ResponseWriter, request *http.Request,
+	retailerData map[string]interface{}, site, newSiteData models.Site) (models.Site, error) {
+	if site.Attributes == nil {
+		return newSiteData, nil
+	}
+	newSite := newSiteData
+	var valid bool
+	newSite.Attributes, valid = attributes.GetAttributesForRequest(responseWriter, request, retailerData,
+		attributes.Merge(newSiteData.Attributes, site.Attributes))
+	if !valid {
+		return newSiteData, errors.New("invalid attributes")
+	}
+
+	return newSite, nil
+}
+
+// checkRetailerSiteIDForUpdate returns the site with the requested retailer's site id,
+// after checking that no other site of the retailer has it
+func checkRetailerSiteIDForUpdate(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	site, newSiteData models.Site, dbClient cloud.DB) (models.Site, error) {
+	if site.RetailerSiteID == "" || site.RetailerSiteID == newSiteData.RetailerSiteID {
+		return newSiteData, nil
+	}
+	logger := logging.GetLoggerFromContext(ctx)
+	exists, err := dbClient.Exists(ctx, utils.GetSitePath(newSiteData.RetailerID), common.RetailersSiteID,
+		site.RetailerSiteID)
+	if err != nil {
+		logger.Errorf("Error occurred while checking existence of retailer's site id in DB: %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return newSiteData, err
+	}
+	if exists {
+		logger.Debugf("Site with same retailer's site id already exists")
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Retailer's site id %s already exists", site.RetailerSiteID), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return newSiteData, errors.New("site with retailer's site id already exists")
+	}
+	newSite := newSiteData
+	newSite.RetailerSiteID = site.RetailerSiteID
+
+	return newSite, nil
+}
+
 func checkNameForUpdate(ctx context.Context, responseWriter http.ResponseWriter,
 	request *http.Request, site, oldSiteData models.Site, retailerID string, dbClient cloud.DB) (models.Site, error) {
 	logger := logging.GetLoggerFromContext(ctx)
@@ -230,23 +416,55 @@ func checkNameForUpdate(ctx context.Context, responseWriter http.ResponseWriter,
 	return newSiteData, nil
 }
 
-func createDocForUpdate(site models.Site, oldData models.Site) []firestore.Update {
-	var docForUpdate []firestore.Update
+// getPatchSiteEvents returns the audit, site change and site spoke copy events to be written along with the site update
+func getPatchSiteEvents(request *http.Request,
+	oldSiteData models.Site, newSiteData models.Site) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetSiteAuditPath(newSiteData.RetailerID, newSiteData.ID),
+				request.Header.Get(common.HeaderXCorrelationID), newSiteData.UpdatedBy,
+				common.AuditTypeUpdate,
+				common.EntitySite,
+				newSiteData.UpdatedTime,
+				structs.Map(oldSiteData),
+				structs.Map(newSiteData),
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvSiteMessageTopic),
+			Data:  models.GetPubSubSiteMessage(newSiteData.RetailerID, newSiteData.ID, common.ChangeTypeUpdate),
+		},
+		dbutil.GetSiteSpokeCopyMessage(newSiteData.RetailerID, common.EntitySite, newSiteData.ID))
+}
+
+// getUniqueKeyWriteOptions reserves the new name and retailer's site id of the site and releases the old ones
+// when they are changed
+func getUniqueKeyWriteOptions(site models.Site, oldData models.Site) cloud.WriteOptions {
+	var writeOptions cloud.WriteOptions
 	if site.Name != oldData.Name {
-		docForUpdate = append(docForUpdate, firestore.Update{Path: "name", Value: site.Name})
+		writeOptions.ReserveKeys = append(writeOptions.ReserveKeys,
+			cloud.NewUniqueKey(utils.GetSitePath(site.RetailerID), common.Name, site.Name))
+		writeOptions.ReleaseKeys = append(writeOptions.ReleaseKeys,
+			cloud.NewUniqueKey(utils.GetSitePath(site.RetailerID), common.Name, oldData.Name))
 	}
-	if site.Location != nil {
-		docForUpdate = append(docForUpdate, firestore.Update{Path: "location", Value: site.Location})
-		docForUpdate = append(docForUpdate, firestore.Update{Path: "timezone", Value: site.Timezone})
+	if site.RetailerSiteID != oldData.RetailerSiteID {
+		writeOptions.ReserveKeys = append(writeOptions.ReserveKeys,
+			cloud.NewUniqueKey(utils.GetSitePath(site.RetailerID), common.RetailersSiteID, site.RetailerSiteID))
+		writeOptions.ReleaseKeys = append(writeOptions.ReleaseKeys,
+			cloud.NewUniqueKey(utils.GetSitePath(site.RetailerID), common.RetailersSiteID, oldData.RetailerSiteID))
 	}
-	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_time", Value: site.UpdatedTime})
-	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_by", Value: site.UpdatedBy})
 
-	return docForUpdate
+	return writeOptions
+}
+
+// createDocForUpdate returns the updates of the fields of the site which changed
+func createDocForUpdate(site models.Site, oldData models.Site) []firestore.Update {
+	return dbutil.GetUpdates(site, oldData)
 }
 
 func sendPatchResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	siteData models.Site, updateTime time.Time, isTimezoneChanged bool) {
+	siteData models.Site, updateTime time.Time, isTimezoneChanged bool, serviceAreaOverlaps []string) {
 	logger := logging.GetLoggerFromContext(ctx)
 	etag, err := utils.GetETag(siteData)
 	if err != nil {
@@ -259,6 +477,7 @@ func sendPatchResponse(ctx context.Context, responseWriter http.ResponseWriter,
 	if isTimezoneChanged {
 		responseHeaders.WithHeader(common.HeaderTimezone, siteData.Timezone)
 	}
+	responseHeaders.WithHeader(common.HeaderServiceAreaOverlaps, strings.Join(serviceAreaOverlaps, ","))
 	response.Respond(responseWriter, http.StatusOK, siteData,
 		responseHeaders.WithHeader(common.HeaderLastModified, updateTime.Format(time.RFC3339)).
 			WithHeader(common.HeaderEtag, etag))
@@ -5,17 +5,23 @@ import (
 	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net/http"
 	"os"
 	"strings"
@@ -29,16 +35,17 @@ func init() {
 	functions.HTTP("PatchSiteStatus", patchSiteStatus)
 }
 
-func patchSiteStatus(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("patch_site_status.patchSiteStatus"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	patchSiteStatusHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()),
-		cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var patchSiteStatusPermission = authorization.OnHeaderRetailer(common.RoleEditor)
+
+var patchSiteStatus = authorization.Authorize("patch_site_status.patchSiteStatus",
+	patchSiteStatusPermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, patchSiteStatusPermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				patchSiteStatusHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
 
 func patchSiteStatusHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -116,10 +123,26 @@ func patchSiteStatusHandler(responseWriter http.ResponseWriter, request *http.Re
 		return
 	}
 
-	newSiteData := createNewSiteData(oldSiteData, siteStatus)
+	newSiteData := createNewSiteData(oldSiteData, siteStatus, identity.GetPrincipalFromContext(ctx))
 	docForUpdate := createDocForStatusUpdate(newSiteData)
 
-	updateTime, err := dbClient.Update(ctx, utils.GetSitePath(retailerID), siteID, docForUpdate)
+	events, err := getPatchSiteStatusEvents(request, oldSiteData, newSiteData)
+	if err != nil {
+		logger.Errorf("Error while creating the site status events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	// the status is not changed if the site changed since it was read
+	updateTime, err := dbClient.UpdateInTransaction(ctx, utils.GetSitePath(retailerID), siteID, docForUpdate,
+		cloud.WriteOptions{Creates: outbox.Documents(events), Revision: &oldSiteData.Revision})
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Site %s changed since it was read : %v", siteID, err)
+		response.RespondWithPreconditionFailed(responseWriter, request)
+
+		return
+	}
 	if err != nil {
 		logger.Errorf("Error while deleting site from DB : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
@@ -128,18 +151,19 @@ func patchSiteStatusHandler(responseWriter http.ResponseWriter, request *http.Re
 	}
 
 	sendPatchStatusResponse(ctx, responseWriter, request, newSiteData, updateTime)
-	publicToPubSubTopic(ctx, request, oldSiteData, newSiteData, pubsubClient)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
 }
 
-func createNewSiteData(oldSiteData models.Site, newStatus string) models.Site {
+func createNewSiteData(oldSiteData models.Site, newStatus string, principal string) models.Site {
 	newSiteData := oldSiteData
 	updatedTime := time.Now().UTC().Round(time.Second)
-	newSiteData.UpdatedBy = common.User
+	newSiteData.UpdatedBy = principal
 	newSiteData.UpdatedTime = &updatedTime
 	newSiteData.Status = newStatus
+	newSiteData.Revision++
 	if newStatus == common.StatusDeprecated {
 		newSiteData.DeactivatedTime = &updatedTime
-		newSiteData.DeactivatedBy = common.User
+		newSiteData.DeactivatedBy = principal
 	}
 
 	return newSiteData
@@ -150,6 +174,7 @@ func createDocForStatusUpdate(site models.Site) []firestore.Update {
 	docForUpdate = append(docForUpdate, firestore.Update{Path: "status", Value: site.Status})
 	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_time", Value: site.UpdatedTime})
 	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_by", Value: site.UpdatedBy})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: common.Revision, Value: site.Revision})
 	if site.Status == common.StatusDeprecated {
 		docForUpdate = append(docForUpdate, firestore.Update{Path: "deactivated_by", Value: site.DeactivatedBy})
 		docForUpdate = append(docForUpdate, firestore.Update{Path: "deactivated_time", Value: site.DeactivatedTime})
@@ -177,24 +202,32 @@ func sendPatchStatusResponse(ctx context.Context, responseWriter http.ResponseWr
 	logger.Debugf("Site status : %s updated successfully.", site.Status)
 }
 
-func publicToPubSubTopic(ctx context.Context, request *http.Request,
-	oldSiteData models.Site, newSiteData models.Site, pubsubClient cloud.Queue) {
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvAuditLogTopic),
-		audit.GetPubSubAuditMessage(audit.GetSiteAuditPath(newSiteData.RetailerID, newSiteData.ID),
-			request.Header.Get(common.HeaderXCorrelationID), newSiteData.UpdatedBy,
-			common.AuditTypeUpdate,
-			common.Status,
-			newSiteData.UpdatedTime,
-			map[string]interface{}{common.Status: oldSiteData.Status},
-			map[string]interface{}{common.Status: newSiteData.Status},
-		))
-
+// getPatchSiteStatusEvents returns the audit, site change and site spoke copy events to be written along with the
+// status change
+func getPatchSiteStatusEvents(request *http.Request,
+	oldSiteData models.Site, newSiteData models.Site) ([]outbox.Event, error) {
 	changeType := common.ChangeTypeUpdate
 	if newSiteData.Status == common.StatusDeprecated {
 		changeType = common.ChangeTypeDelete
 	}
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvSiteMessageTopic),
-		models.GetPubSubSiteMessage(newSiteData.RetailerID, newSiteData.ID, changeType))
+
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetSiteAuditPath(newSiteData.RetailerID, newSiteData.ID),
+				request.Header.Get(common.HeaderXCorrelationID), newSiteData.UpdatedBy,
+				common.AuditTypeUpdate,
+				common.Status,
+				newSiteData.UpdatedTime,
+				map[string]interface{}{common.Status: oldSiteData.Status},
+				map[string]interface{}{common.Status: newSiteData.Status},
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvSiteMessageTopic),
+			Data:  models.GetPubSubSiteMessage(newSiteData.RetailerID, newSiteData.ID, changeType),
+		},
+		dbutil.GetSiteSpokeCopyMessage(newSiteData.RetailerID, common.EntitySite, newSiteData.ID))
 }
 
 func populateSiteStatusTransitions(ctx context.Context, dbClient cloud.DB) error {
@@ -332,7 +332,7 @@ func Test_patchSiteStatusHandler(t *testing.T) {
 		}
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything,
 			"s12345", true).Return(siteInActive, nil).Once()
-		fireStoreClient.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), errors.New("update failed")).Once()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), errors.New("update failed")).Once()
 		patchSiteStatusHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -355,17 +355,18 @@ func Test_patchSiteStatusHandler(t *testing.T) {
 		siteInDeprovisioning["status"] = "inactive"
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything,
 			"s12345", true).Return(siteInDeprovisioning, nil).Once()
-		fireStoreClient.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), nil).Once()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), nil).Once()
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSiteStatusHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		assert.Equal(t, fmt.Sprintf(
-			"{\"id\":\"s12345\",\"name\":\"site name\",\"retailer_site_id\":\"r site id\",\"retailer_id\":\"r12345\",\"status\":\"active\",\"timezone\":\"UTC\",\"location\":{\"lat\":10.12,\"long\":10.12},\"created_by\":\"user\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\"}", updateTimeStr), string(bytes))
+			"{\"id\":\"s12345\",\"name\":\"site name\",\"retailer_site_id\":\"r site id\",\"retailer_id\":\"r12345\",\"status\":\"active\",\"timezone\":\"UTC\",\"location\":{\"lat\":10.12,\"long\":10.12},\"created_by\":\"user\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
 	})
 
 	t.Run("Successful update for deprecated with cached site statuses", func(t *testing.T) {
@@ -381,16 +382,17 @@ func Test_patchSiteStatusHandler(t *testing.T) {
 		siteInDeprovisioning["status"] = "deprovisioning"
 		fireStoreClient.On("GetByID", mock.Anything, mock.Anything,
 			"s12345", true).Return(siteInDeprovisioning, nil).Once()
-		fireStoreClient.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), nil).Once()
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), nil).Once()
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSiteStatusHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		assert.Equal(t, fmt.Sprintf(
-			"{\"id\":\"s12345\",\"name\":\"site name\",\"retailer_site_id\":\"r site id\",\"retailer_id\":\"r12345\",\"status\":\"deprecated\",\"timezone\":\"UTC\",\"location\":{\"lat\":10.12,\"long\":10.12},\"created_by\":\"user\",\"updated_by\":\"api@takeoff.com\",\"deactivated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"deactivated_time\":\"%s\"}", updateTimeStr, updateTimeStr), string(bytes))
+			"{\"id\":\"s12345\",\"name\":\"site name\",\"retailer_site_id\":\"r site id\",\"retailer_id\":\"r12345\",\"status\":\"deprecated\",\"timezone\":\"UTC\",\"location\":{\"lat\":10.12,\"long\":10.12},\"created_by\":\"user\",\"updated_by\":\"api@takeoff.com\",\"deactivated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"deactivated_time\":\"%s\",\"revision\":1}", updateTimeStr, updateTimeStr), string(bytes))
 	})
 }
 
@@ -1,8 +1,10 @@
 package sites
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/h2non/gock"
@@ -14,6 +16,7 @@ import (
 	"time"
 
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -63,6 +66,14 @@ func Test_patchSite(t *testing.T) {
 			assert.Equal(t, tt.args.w.Result().StatusCode, http.StatusBadRequest)
 		})
 	}
+
+	t.Run("Request without the identity of the gateway", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeGateway)
+		w := httptest.NewRecorder()
+		patchSite(w, getRequest(http.MethodPatch, "/sites/s12345", "{\"name\":\"siteName\"}",
+			common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderRetailerID))
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
 }
 func Test_patchSiteHandler(t *testing.T) {
 	t.Run("SitesID not passed in path param", func(t *testing.T) {
@@ -405,7 +416,7 @@ func Test_patchSiteHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath("r12345"), "s12345", true).Return(site, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), "name", "newSiteName").Return(false, nil)
-		fireStoreClient.On("Update", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything).Return(time.Now(), errors.New("connection timeout"))
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything, mock.Anything).Return(time.Now(), errors.New("connection timeout"))
 		patchSiteHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -446,16 +457,21 @@ func Test_patchSiteHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath("r12345"), "s12345", true).Return(site, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), "name", "siteName").Return(false, nil)
-		fireStoreClient.On("Update", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything).Return(time.Now(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				// the site is copied onto its site spokes by the site spoke copy event
+				return len(options.Creates) == 3 && len(options.Updates) == 0
+			})).Return(time.Now(), nil)
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSiteHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"siteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"\",\"location\":null,\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\"}", updateTimeStr), string(bytes))
+		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"siteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"\",\"location\":null,\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
 	})
 
 	t.Run("Update location successful", func(t *testing.T) {
@@ -491,16 +507,17 @@ func Test_patchSiteHandler(t *testing.T) {
 
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath("r12345"), "s12345", true).Return(site, nil)
-		fireStoreClient.On("Update", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything).Return(time.Now(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything, mock.Anything).Return(time.Now(), nil)
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSiteHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"oldSiteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":40.73061,\"long\":-73.935242},\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\"}", updateTimeStr), string(bytes))
+		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"oldSiteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":40.73061,\"long\":-73.935242},\"geohash\":\"dr5rtwccpb\",\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
 	})
 
 	t.Run("Update same location failed", func(t *testing.T) {
@@ -536,16 +553,17 @@ func Test_patchSiteHandler(t *testing.T) {
 
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath("r12345"), "s12345", true).Return(site, nil)
-		fireStoreClient.On("Update", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything).Return(time.Now(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything, mock.Anything).Return(time.Now(), nil)
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSiteHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"oldSiteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":40.73061,\"long\":-73.935242},\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\"}", updateTimeStr), string(bytes))
+		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"oldSiteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":40.73061,\"long\":-73.935242},\"geohash\":\"dr5rtwccpb\",\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
 	})
 
 	t.Run("Update same location failed", func(t *testing.T) {
@@ -630,18 +648,19 @@ func Test_patchSiteHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath("r12345"), "s12345", true).Return(site, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath("r12345"), "name", "New SiteName").Return(false, nil)
-		fireStoreClient.On("Update", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything).Return(time.Now(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSitePath("r12345"), "s12345", mock.Anything, mock.Anything).Return(time.Now(), nil)
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSiteHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"New SiteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"\",\"location\":{\"lat\":40.73061,\"long\":-73.935242},\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\"}", updateTimeStr), string(bytes))
+		assert.Equal(t, fmt.Sprintf("{\"id\":\"s12345\",\"name\":\"New SiteName\",\"retailer_site_id\":\"ABC123\",\"retailer_id\":\"r12345\",\"status\":\"\",\"timezone\":\"\",\"location\":{\"lat\":40.73061,\"long\":-73.935242},\"created_by\":\"API\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
 	})
 
 	t.Run("Error while retrieving timezone", func(t *testing.T) {
@@ -691,6 +710,95 @@ func Test_patchSiteHandler(t *testing.T) {
 		defer response.Body.Close()
 		bytes, _ := io.ReadAll(response.Body)
 		assert.Equal(t, string(bytes), "{\"code\":400,\"message\":\"Error occurred while retrieving location with"+
-			" latitude 54.250000 and longitude 13.134000. Timezone API returned with status: INVALID_REQUEST. Please provide valid location details\"}")
+			" latitude 54.250000 and longitude 13.134000. No timezone found for the location. Please provide valid location details\"}")
+	})
+}
+
+func Test_patchSiteHandlerMergePatch(t *testing.T) {
+	latitude, longitude := 54.25, 13.134
+	getMergePatchRepository := func(retailerID string) cloud.DB {
+		dbClient := cloud.NewMemoryRepository()
+		_, _ = dbClient.Save(context.Background(), common.RetailersCollection, retailerID,
+			map[string]interface{}{common.ID: retailerID, common.DeactivatedTime: nil})
+		for id, retailerSiteID := range map[string]string{"s1merge": "MRG001", "s2merge": "MRG002"} {
+			_, _ = dbClient.Save(context.Background(), utils.GetSitePath(retailerID), id, models.Site{ID: id,
+				Name: "site " + id, RetailerSiteID: retailerSiteID, RetailerID: retailerID, Timezone: "Europe/Berlin",
+				Location: &commonModels.Location{Latitude: &latitude, Longitude: &longitude},
+				Address:  &commonModels.Address{Lines: []string{"1 Main St"}, City: "Berlin", PostalCode: "10115", Country: "DE"}})
+		}
+
+		return dbClient
+	}
+	getMergePatchRequest := func(dbClient cloud.DB, retailerID string, body string) *http.Request {
+		site, _ := dbClient.GetByID(context.Background(), utils.GetSitePath(retailerID), "s1merge", true)
+		etag, _ := utils.GetETag(site)
+		r := getRequest(http.MethodPatch, "/sites/s1merge", body, common.HeaderXCorrelationID,
+			common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		r.Header.Set(common.HeaderIfMatch, etag)
+		r.Header.Set(common.HeaderContentType, common.ContentTypeMergePatchJSON)
+
+		return r
+	}
+
+	t.Run("Merge patch removes the address and updates the retailer's site id", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		dbClient := getMergePatchRepository("rmerge1")
+		w := httptest.NewRecorder()
+		patchSiteHandler(w, getMergePatchRequest(dbClient, "rmerge1",
+			"{\"address\":null,\"retailer_site_id\":\"MRG003\"}"), dbClient, pubSubClient)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		site, err := dbClient.GetByID(context.Background(), utils.GetSitePath("rmerge1"), "s1merge", true)
+		assert.Nil(t, err)
+		assert.Nil(t, site[common.Address])
+		assert.Equal(t, "MRG003", site[common.RetailersSiteID])
+		assert.Equal(t, "site s1merge", site[common.Name])
+		assert.NotNil(t, site[common.Location])
+	})
+
+	t.Run("Merge patch with the retailer's site id of another site", func(t *testing.T) {
+		dbClient := getMergePatchRepository("rmerge2")
+		w := httptest.NewRecorder()
+		patchSiteHandler(w, getMergePatchRequest(dbClient, "rmerge2", "{\"retailer_site_id\":\"MRG002\"}"),
+			dbClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":422,\"message\":\"Retailer's site id MRG002 already exists\"}", string(bytes))
+	})
+
+	t.Run("Merge patch removing the location", func(t *testing.T) {
+		dbClient := getMergePatchRepository("rmerge3")
+		w := httptest.NewRecorder()
+		patchSiteHandler(w, getMergePatchRequest(dbClient, "rmerge3", "{\"location\":null}"), dbClient,
+			mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\","+
+			"\"errors\":[\"location can not be removed, a site always has a location\"]}", string(bytes))
+	})
+
+	t.Run("Merge patch removing a required field", func(t *testing.T) {
+		dbClient := getMergePatchRepository("rmerge4")
+		w := httptest.NewRecorder()
+		patchSiteHandler(w, getMergePatchRequest(dbClient, "rmerge4", "{\"name\":null}"), dbClient,
+			mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Merge patch with an unknown field", func(t *testing.T) {
+		dbClient := getMergePatchRepository("rmerge5")
+		w := httptest.NewRecorder()
+		patchSiteHandler(w, getMergePatchRequest(dbClient, "rmerge5", "{\"color\":null}"), dbClient,
+			mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Merge patch with a disallowed field", func(t *testing.T) {
+		dbClient := getMergePatchRepository("rmerge6")
+		w := httptest.NewRecorder()
+		patchSiteHandler(w, getMergePatchRequest(dbClient, "rmerge6", "{\"status\":\"active\"}"), dbClient,
+			mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
 	})
 }
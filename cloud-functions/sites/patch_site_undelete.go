@@ -0,0 +1,188 @@
+package sites
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/fatih/structs"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+	"time"
+)
+
+// This file has the function and handler to undelete a deprecated site
+var patchSiteUndeletePath = urit.MustCreateTemplate(fmt.Sprintf("/sites/{%s}:%s",
+	common.PathParamSiteID, common.PathParamUndelete))
+
+func init() {
+	functions.HTTP("PatchSiteUndelete", patchSiteUndelete)
+}
+
+var patchSiteUndeletePermission = authorization.OnHeaderRetailer(common.RoleAdmin)
+
+var patchSiteUndelete = authorization.Authorize("patch_site_undelete.patchSiteUndelete",
+	patchSiteUndeletePermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, patchSiteUndeletePermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				patchSiteUndeleteHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
+
+func patchSiteUndeleteHandler(responseWriter http.ResponseWriter, request *http.Request,
+	dbClient cloud.DB, pubsubClient cloud.Queue) {
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("patch_site_undelete.patchSiteUndeleteHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(models.GetRequiredHeaders(), common.HeaderIfMatch),
+		RequiredPath:    patchSiteUndeletePath,
+		RequestMethod:   http.MethodPatch,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	siteID := pathParams[common.PathParamSiteID]
+
+	// a site cannot be restored under a deactivated retailer
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+		return
+	}
+
+	oldSiteDataMap := siteCommon.GetSiteFromDB(responseWriter, request, logger, dbClient, retailerID, siteID, false)
+	if oldSiteDataMap == nil {
+		return
+	}
+
+	if !utils.IsValidEtagPresentInHeader(responseWriter, request, oldSiteDataMap, logger) {
+		return
+	}
+
+	var oldSiteData models.Site
+	err := utils.ConvertToObject(oldSiteDataMap, &oldSiteData)
+	if err != nil {
+		logger.Errorf("Error while unmarshalling data from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	if oldSiteData.DeactivatedTime == nil {
+		logger.Debugf("Site id %s of retailer id %s is not deleted", siteID, retailerID)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, fmt.Sprintf("Site ID %s is not deleted", siteID), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	newSiteData := createUndeletedSiteData(oldSiteData, identity.GetPrincipalFromContext(ctx))
+	events, err := getPatchSiteUndeleteEvents(request, oldSiteData, newSiteData)
+	if err != nil {
+		logger.Errorf("Error while creating the site undelete events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	docForUpdate := createDocForUndelete(newSiteData)
+	// the name and retailer's site id could have been taken by another site while this one was deleted
+	updateTime, err := dbClient.UpdateInTransaction(ctx, utils.GetSitePath(retailerID), siteID, docForUpdate,
+		cloud.WriteOptions{ReserveKeys: getSiteUniqueKeys(newSiteData), Creates: outbox.Documents(events),
+			Revision: &oldSiteData.Revision})
+	var uniqueKeyError *cloud.UniqueKeyError
+	if errors.As(err, &uniqueKeyError) {
+		respondWithSiteUniqueKeyError(ctx, responseWriter, request, uniqueKeyError.Key)
+
+		return
+	}
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Site %s changed since it was read : %v", siteID, err)
+		response.RespondWithPreconditionFailed(responseWriter, request)
+
+		return
+	}
+	if err != nil {
+		logger.Errorf("Error while undeleting site in DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	sendPatchStatusResponse(ctx, responseWriter, request, newSiteData, updateTime)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
+}
+
+// createUndeletedSiteData returns the site with the deactivation cleared, undeleted by the principal.
+// The site is moved back to draft as its resources were released while deprovisioning it
+func createUndeletedSiteData(oldSiteData models.Site, principal string) models.Site {
+	newSiteData := oldSiteData
+	updatedTime := time.Now().UTC().Round(time.Second)
+	newSiteData.UpdatedBy = principal
+	newSiteData.UpdatedTime = &updatedTime
+	newSiteData.Status = common.StatusDraft
+	newSiteData.DeactivatedTime = nil
+	newSiteData.DeactivatedBy = ""
+	newSiteData.Revision++
+
+	return newSiteData
+}
+
+func createDocForUndelete(site models.Site) []firestore.Update {
+	var docForUpdate []firestore.Update
+	docForUpdate = append(docForUpdate, firestore.Update{Path: "status", Value: site.Status})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_time", Value: site.UpdatedTime})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_by", Value: site.UpdatedBy})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: "deactivated_by", Value: site.DeactivatedBy})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: common.Revision, Value: site.Revision})
+	// deactivated_time is set to null rather than deleted as the active documents are queried on null
+	docForUpdate = append(docForUpdate, firestore.Update{Path: "deactivated_time", Value: nil})
+
+	return docForUpdate
+}
+
+// getPatchSiteUndeleteEvents returns the audit, site re-create and site spoke copy events to be written along with
+// the undelete
+func getPatchSiteUndeleteEvents(request *http.Request,
+	oldSiteData models.Site, newSiteData models.Site) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetSiteAuditPath(newSiteData.RetailerID, newSiteData.ID),
+				request.Header.Get(common.HeaderXCorrelationID), newSiteData.UpdatedBy,
+				common.AuditTypeUndelete,
+				common.EntitySite,
+				newSiteData.UpdatedTime,
+				structs.Map(oldSiteData),
+				structs.Map(newSiteData),
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvSiteMessageTopic),
+			Data:  models.GetPubSubSiteMessage(newSiteData.RetailerID, newSiteData.ID, common.ChangeTypeCreate),
+		},
+		dbutil.GetSiteSpokeCopyMessage(newSiteData.RetailerID, common.EntitySite, newSiteData.ID))
+}
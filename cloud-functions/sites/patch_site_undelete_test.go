@@ -0,0 +1,199 @@
+package sites
+
+import (
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getDeletedSite() map[string]interface{} {
+	return map[string]interface{}{
+		"id":               "s12345",
+		"name":             "site name",
+		"retailer_site_id": "r site id",
+		"retailer_id":      "r12345",
+		"status":           "deprecated",
+		"timezone":         "UTC",
+		"location": map[string]interface{}{
+			"lat":  10.12,
+			"long": 10.12,
+		},
+		"created_by":       "user",
+		"updated_by":       "user",
+		"deactivated_by":   "user",
+		"created_time":     "2022-10-28T07:33:05Z",
+		"updated_time":     "2022-10-28T07:33:05Z",
+		"deactivated_time": "2022-10-28T07:33:05Z",
+	}
+}
+
+func getUndeleteSiteRequest(siteData map[string]interface{}) *http.Request {
+	r := getRequest(http.MethodPatch, fmt.Sprintf("/sites/%s:%s", "s12345", common.PathParamUndelete), "",
+		common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderRetailerID, common.HeaderIfMatch)
+	etag, _ := utils.GetETag(siteData)
+	r.Header.Set(common.HeaderIfMatch, etag)
+
+	return r
+}
+
+func Test_patchSiteUndeleteHandler(t *testing.T) {
+	t.Run("Invalid method request", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, fmt.Sprintf("/sites/%s:%s", "s12345", common.PathParamUndelete), "", common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderIfMatch, common.HeaderRetailerID)
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request validation failed\",\"errors\":[\"Invalid request method, send request with correct method\"]}", string(bytes))
+	})
+
+	t.Run("Missing if-match header", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPatch, fmt.Sprintf("/sites/%s:%s", "s12345", common.PathParamUndelete), "", common.HeaderXCorrelationID, common.HeaderAcceptVersion, common.HeaderRetailerID)
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t,
+			fmt.Sprintf("{\"code\":400,"+
+				"\"message\":\"Request validation failed\","+
+				"\"errors\":["+
+				"\"Request does not have the required headers : [%s]\"]}",
+				common.HeaderIfMatch),
+			string(bytes))
+	})
+
+	t.Run("Retailer not found in the DB", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteSiteRequest(getDeletedSite())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(nil, status.Error(codes.NotFound, "not found")).Once()
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("Site ID not found in the DB", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteSiteRequest(getDeletedSite())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{"id": "r12345"}, nil).Once()
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, "s12345", false).
+			Return(nil, status.Error(codes.NotFound, "not found")).Once()
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":404,\"message\":\"Site ID s12345 not found\"}", string(bytes))
+	})
+
+	t.Run("Etag Mismatch", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteSiteRequest(getDeletedSite())
+		r.Header.Set(common.HeaderIfMatch, "outdated etag")
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{"id": "r12345"}, nil).Once()
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, "s12345", false).
+			Return(getDeletedSite(), nil).Once()
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	})
+
+	t.Run("Site is not deleted", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		activeSite := getDeletedSite()
+		activeSite["status"] = "active"
+		activeSite["deactivated_by"] = ""
+		activeSite["deactivated_time"] = nil
+		r := getUndeleteSiteRequest(activeSite)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{"id": "r12345"}, nil).Once()
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, "s12345", false).
+			Return(activeSite, nil).Once()
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Site ID s12345 is not deleted\"}", string(bytes))
+	})
+
+	t.Run("Name taken by another site while deleted", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteSiteRequest(getDeletedSite())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{"id": "r12345"}, nil).Once()
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, "s12345", false).
+			Return(getDeletedSite(), nil).Once()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, mock.Anything, "s12345", mock.Anything, mock.Anything).
+			Return(time.Time{}, &cloud.UniqueKeyError{Key: cloud.NewUniqueKey("sites", common.Name, "site name")}).Once()
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Site with name : site name already exists\"}", string(bytes))
+	})
+
+	t.Run("Error while doing the update", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteSiteRequest(getDeletedSite())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{"id": "r12345"}, nil).Once()
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, "s12345", false).
+			Return(getDeletedSite(), nil).Once()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, mock.Anything, "s12345", mock.Anything, mock.Anything).
+			Return(time.Time{}, errors.New("update failed")).Once()
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Successful undelete", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getUndeleteSiteRequest(getDeletedSite())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{"id": "r12345"}, nil).Once()
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, "s12345", false).
+			Return(getDeletedSite(), nil).Once()
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, mock.Anything, "s12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return len(options.ReserveKeys) == 3 && len(options.Creates) == 3
+			})).Return(time.Now(), nil).Once()
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		updateTimeStr := time.Now().UTC().Round(time.Second).Format(time.RFC3339)
+		patchSiteUndeleteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, fmt.Sprintf(
+			"{\"id\":\"s12345\",\"name\":\"site name\",\"retailer_site_id\":\"r site id\",\"retailer_id\":\"r12345\",\"status\":\"draft\",\"timezone\":\"UTC\",\"location\":{\"lat\":10.12,\"long\":10.12},\"created_by\":\"user\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"2022-10-28T07:33:05Z\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr), string(bytes))
+	})
+}
@@ -2,23 +2,33 @@ package sites
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
+	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
 	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/geocoding"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
-	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/timezone"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/fatih/structs"
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -29,16 +39,17 @@ func init() {
 	functions.HTTP("PostSite", postSite)
 }
 
-func postSite(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("post_site.postSite"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	postSiteHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()),
-		cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var postSitePermission = authorization.OnHeaderRetailer(common.RoleEditor)
+
+var postSite = authorization.Authorize("post_site.postSite",
+	postSitePermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, postSitePermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				postSiteHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
 
 func postSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -65,7 +76,14 @@ func postSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 	retailerID := request.Header.Get(common.HeaderRetailerID)
 	var err error
 
-	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+	retailerData, found := dbutil.GetRetailerFromDB(responseWriter, request, dbClient, retailerID, logger, true)
+	if !found {
+		return
+	}
+	var valid bool
+	site.Attributes, valid = attributes.GetAttributesForRequest(responseWriter, request, retailerData,
+		site.Attributes)
+	if !valid {
 		return
 	}
 
@@ -105,27 +123,25 @@ func postSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 		return
 	}
 
-	var googleTimeZone *commonModels.GoogleTimeZone
-	googleTimeZone, err = utils.GetTimeZone(ctx, *site.Location.Latitude, *site.Location.Longitude)
-
-	if err != nil {
-		logger.Errorf("Error occurred while retrieving timezone : %v", err)
-		response.RespondWithResponseObject(responseWriter,
-			response.NewResponse(http.StatusBadRequest,
-				fmt.Sprintf("Error occurred while retrieving location with latitude %f and longitude %f. "+
-					"Timezone API returned with status: %s. "+
-					"Please provide valid location details", *site.Location.Latitude,
-					*site.Location.Longitude, googleTimeZone.Status), nil),
-			response.GetCommonResponseHeaders(request))
-
+	var resolved bool
+	site.Address = utils.NewAddress(site.Address)
+	site.Location, resolved = geocoding.GetRequiredLocationForRequest(ctx, responseWriter, request,
+		site.Address, site.Location)
+	if !resolved {
 		return
 	}
-	if googleTimeZone != nil && googleTimeZone.TimezoneID != "" {
-		site.Timezone = googleTimeZone.TimezoneID
+	site.Timezone, resolved = timezone.GetTimezoneForRequest(ctx, responseWriter, request,
+		*site.Location.Latitude, *site.Location.Longitude)
+	if !resolved {
+		return
 	}
+	site.Geohash = utils.GetGeohash(*site.Location.Latitude, *site.Location.Longitude, common.GeohashPrecision)
+	site.ServiceArea = utils.NewServiceArea(site.ServiceArea)
+	site.OperatingHours = utils.NewOperatingHours(site.OperatingHours)
 	var updateTime time.Time
 	var retryCount int
-	site, updateTime, retryCount, err = performPostSite(ctx, responseWriter, request, site, retailerID, dbClient)
+	var events []outbox.Event
+	site, events, updateTime, retryCount, err = performPostSite(ctx, responseWriter, request, site, retailerID, dbClient)
 	if err != nil {
 		return
 	}
@@ -138,44 +154,66 @@ func postSiteHandler(responseWriter http.ResponseWriter, request *http.Request,
 		return
 	}
 
-	sendPostResponse(ctx, responseWriter, request, pubsubClient, site, updateTime)
+	sendPostResponse(ctx, responseWriter, request, site, updateTime, getServiceAreaOverlaps(ctx, dbClient, site))
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
 }
 
 func performPostSite(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	site models.Site, retailerID string, dbClient cloud.DB) (models.Site, time.Time, int, error) {
+	site models.Site, retailerID string, dbClient cloud.DB) (models.Site, []outbox.Event, time.Time, int, error) {
 	logger := logging.GetLoggerFromContext(ctx)
 	var retryCount int
 	var updateTime time.Time
+	var events []outbox.Event
 	newSite := site
 	for retryCount = 0; retryCount < common.MaxRetryCount; retryCount++ {
 		logger.Debugf("Creating unique ID for site for %d time", retryCount)
 		newSite.ID = fmt.Sprintf("%s%s", common.SiteIDPrefix, utils.GetRandomID(common.RandomIDLength))
 		newSite.RetailerID = retailerID
 
-		newSite.CreatedBy = common.User
-		newSite.UpdatedBy = common.User
+		newSite.CreatedBy = identity.GetPrincipalFromContext(ctx)
+		newSite.UpdatedBy = identity.GetPrincipalFromContext(ctx)
 
 		currentTime := time.Now().UTC().Round(time.Second)
 		newSite.CreatedTime = &currentTime
 		newSite.UpdatedTime = &currentTime
 
 		newSite.Status = common.StatusDraft
+		newSite.Revision = 1
 
 		idExists, err := dbClient.ExistsInCollectionGroup(ctx, common.SitesCollection, common.ID, newSite.ID)
 		if err != nil {
 			logger.Errorf("Error occurred while checking existence of site in DB: %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
 
-			return newSite, time.Time{}, retryCount, err
+			return newSite, nil, time.Time{}, retryCount, err
 		}
 		if !idExists {
 			logger.Debugf("Proceed with save")
-			updateTime, err = dbClient.Save(ctx, utils.GetSitePath(retailerID), newSite.ID, newSite)
+			events, err = getPostSiteEvents(request, newSite)
+			if err != nil {
+				logger.Errorf("Error while creating the site events : %v", err)
+				response.RespondWithInternalServerError(responseWriter, request)
+
+				return newSite, nil, time.Time{}, retryCount, err
+			}
+			updateTime, err = dbClient.SaveInTransaction(ctx, utils.GetSitePath(retailerID), newSite.ID, newSite,
+				cloud.WriteOptions{ReserveKeys: getSiteUniqueKeys(newSite), Creates: outbox.Documents(events)})
+			var uniqueKeyError *cloud.UniqueKeyError
+			if errors.As(err, &uniqueKeyError) && uniqueKeyError.Key.Field != common.ID {
+				respondWithSiteUniqueKeyError(ctx, responseWriter, request, uniqueKeyError.Key)
+
+				return newSite, nil, time.Time{}, retryCount, err
+			}
+			if uniqueKeyError != nil || status.Code(err) == codes.AlreadyExists {
+				logger.Debugf("Site id %s was taken concurrently, generating a new one", newSite.ID)
+
+				continue
+			}
 			if err != nil {
 				logger.Errorf("Unable to create site : %v", err)
 				response.RespondWithInternalServerError(responseWriter, request)
 
-				return newSite, time.Time{}, retryCount, err
+				return newSite, nil, time.Time{}, retryCount, err
 			}
 			logger.Debugf("Created unique ID for site")
 
@@ -183,11 +221,71 @@ func performPostSite(ctx context.Context, responseWriter http.ResponseWriter, re
 		}
 	}
 
-	return newSite, updateTime, retryCount, nil
+	return newSite, events, updateTime, retryCount, nil
+}
+
+// getPostSiteEvents returns the audit and site change events to be written along with the new site
+func getPostSiteEvents(request *http.Request, site models.Site) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetSiteAuditPath(site.RetailerID, site.ID),
+				request.Header.Get(common.HeaderXCorrelationID), site.CreatedBy,
+				common.AuditTypeCreate,
+				common.EntitySite,
+				site.CreatedTime,
+				nil,
+				structs.Map(site),
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvSiteMessageTopic),
+			Data:  models.GetPubSubSiteMessage(site.RetailerID, site.ID, common.ChangeTypeCreate),
+		})
+}
+
+// getServiceAreaOverlaps returns the IDs of the other sites of the retailer with a service area overlapping the
+// service area of the site. The site is saved already, so an error is only logged
+func getServiceAreaOverlaps(ctx context.Context, dbClient cloud.DB, site models.Site) []string {
+	logger := logging.GetLoggerFromContext(ctx)
+	overlaps, err := siteCommon.GetServiceAreaOverlaps(ctx, dbClient, site)
+	if err != nil {
+		logger.Errorf("Error while checking the service area overlaps of site %s : %v", site.ID, err)
+	}
+	if overlaps != nil {
+		logger.Warnf("Service area of site %s overlaps the service areas of the sites %v", site.ID, overlaps)
+	}
+
+	return overlaps
+}
+
+// getSiteUniqueKeys returns the keys which must be held only by the site,
+// the name and retailer's site id within the retailer and the id across all retailers
+func getSiteUniqueKeys(site models.Site) []cloud.UniqueKey {
+	return []cloud.UniqueKey{
+		cloud.NewUniqueKey(common.SitesCollection, common.ID, site.ID),
+		cloud.NewUniqueKey(utils.GetSitePath(site.RetailerID), common.Name, site.Name),
+		cloud.NewUniqueKey(utils.GetSitePath(site.RetailerID), common.RetailersSiteID, site.RetailerSiteID),
+	}
+}
+
+// respondWithSiteUniqueKeyError responds with the same message as the existence checks
+// when the key was reserved by a concurrent request
+func respondWithSiteUniqueKeyError(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	key cloud.UniqueKey) {
+	logger := logging.GetLoggerFromContext(ctx)
+	logger.Debugf("Unique key reserved by another site : %v", key)
+	message := fmt.Sprintf("Site with name : %s already exists", key.Value)
+	if key.Field == common.RetailersSiteID {
+		message = fmt.Sprintf("Retailer's site id %s already exists", key.Value)
+	}
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusBadRequest, message, nil),
+		response.GetCommonResponseHeaders(request))
 }
 
 func sendPostResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	pubsubClient cloud.Queue, site models.Site, updateTime time.Time) {
+	site models.Site, updateTime time.Time, serviceAreaOverlaps []string) {
 	logger := logging.GetLoggerFromContext(ctx)
 	etag, err := utils.GetETag(site)
 	if err != nil {
@@ -201,20 +299,8 @@ func sendPostResponse(ctx context.Context, responseWriter http.ResponseWriter, r
 		response.GetCommonResponseHeaders(request).
 			WithHeader(common.HeaderLastModified, updateTime.Format(time.RFC3339)).
 			WithHeader(common.HeaderLocation, fmt.Sprintf("%s%s", common.SitePath, site.ID)).
-			WithHeader(common.HeaderEtag, etag))
+			WithHeader(common.HeaderEtag, etag).
+			WithHeader(common.HeaderServiceAreaOverlaps, strings.Join(serviceAreaOverlaps, ",")))
 
 	logger.Debugf("Site successfully created with id : %s", site.ID)
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvAuditLogTopic),
-		audit.GetPubSubAuditMessage(audit.GetSiteAuditPath(site.RetailerID, site.ID),
-			request.Header.Get(common.HeaderXCorrelationID), site.CreatedBy,
-			common.AuditTypeCreate,
-			common.EntitySite,
-			site.CreatedTime,
-			nil,
-			structs.Map(site),
-		))
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvSiteMessageTopic),
-		models.GetPubSubSiteMessage(site.RetailerID, site.ID, common.ChangeTypeCreate))
 }
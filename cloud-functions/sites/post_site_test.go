@@ -1,9 +1,14 @@
 package sites
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
@@ -26,6 +31,15 @@ func init() {
 	if err != nil {
 		return
 	}
+	// the timezones are resolved with the timezone API mocked by gock, without the cache
+	err = os.Setenv(common.EnvTimezoneResolver, common.TimezoneResolverGoogle)
+	if err != nil {
+		return
+	}
+	err = os.Setenv(common.EnvTimezoneCacheSize, "0")
+	if err != nil {
+		return
+	}
 }
 
 func getRequest(method string, url string, body string, headers ...string) *http.Request {
@@ -173,7 +187,7 @@ func Test_postSiteHandler(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\","+
-			"\"errors\":[\"Key: 'Site.Location' Error:Field validation for 'Location' failed on the 'required' tag\"]}", string(bytes))
+			"\"errors\":[\"Key: 'Site.Location' Error:Field validation for 'Location' failed on the 'required_without' tag\"]}", string(bytes))
 	})
 
 	t.Run("Missing Longitude in JSON POST Entity", func(t *testing.T) {
@@ -406,7 +420,7 @@ func Test_postSiteHandler(t *testing.T) {
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything).Return(false, nil).Once()
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything).Return(false, nil).Once()
 		fireStoreClient.On("ExistsInCollectionGroup", mock.Anything, common.SitesCollection, mock.Anything, mock.Anything).Return(false, nil).Once()
-		fireStoreClient.On("Save", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything).Return(time.Now(), errors.New(mock.Anything)).Once()
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), errors.New(mock.Anything)).Once()
 		postSiteHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -447,7 +461,7 @@ func Test_postSiteHandler(t *testing.T) {
 		response := w.Result()
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
-		assert.Contains(t, string(bytes), "{\"code\":400,\"message\":\"Error occurred while retrieving location with latitude 54.250000 and longitude 13.134000. Timezone API returned with status: INVALID_REQUEST.")
+		assert.Contains(t, string(bytes), "{\"code\":400,\"message\":\"Error occurred while retrieving location with latitude 54.250000 and longitude 13.134000. No timezone found for the location.")
 	})
 
 	t.Run("Site saved successfully", func(t *testing.T) {
@@ -480,19 +494,20 @@ func Test_postSiteHandler(t *testing.T) {
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything).Return(false, nil).Once()
 
 		fireStoreClient.On("ExistsInCollectionGroup", mock.Anything, common.SitesCollection, mock.Anything, mock.Anything).Return(false, nil).Once()
-		fireStoreClient.On("Save", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything).Return(time.Now(), nil)
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), nil)
 
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
 		pubSubClient.On("Publish", mock.Anything,
-			mock.Anything, mock.Anything).Return()
+			mock.Anything, mock.Anything).Return(nil)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		postSiteHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusCreated, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Contains(t, string(bytes), fmt.Sprintf("\"name\":\"siteID1\",\"retailer_site_id\":\"ABS111\",\"retailer_id\":\"rcfsk\",\"status\":\"draft\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":54.25,\"long\":13.134},\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"%s\",\"updated_time\":\"%s\"}", updateTimeStr, updateTimeStr))
+		assert.Contains(t, string(bytes), fmt.Sprintf("\"name\":\"siteID1\",\"retailer_site_id\":\"ABS111\",\"retailer_id\":\"rcfsk\",\"status\":\"draft\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":54.25,\"long\":13.134},\"geohash\":\"u39kdggvhk\",\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"%s\",\"updated_time\":\"%s\",\"revision\":1}", updateTimeStr, updateTimeStr))
 	})
 
 	t.Run("Error while creating unique site id across retailers", func(t *testing.T) {
@@ -533,4 +548,308 @@ func Test_postSiteHandler(t *testing.T) {
 		bytes, _ := io.ReadAll(response.Body)
 		assert.Equal(t, string(bytes), "{\"code\":500,\"message\":\"Internal server error occurred. Please check logs for more details.\"}")
 	})
+	t.Run("Site saved and duplicate name rejected with in-memory DB", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		dbClient := cloud.NewMemoryRepository()
+		retailerID := "rmemdb"
+		_, _ = dbClient.Save(context.Background(), common.RetailersCollection, retailerID,
+			map[string]interface{}{common.ID: retailerID, common.DeactivatedTime: nil})
+		body := "{\"name\":\"siteMemory\",\"retailer_site_id\" : \"MEM111\"," +
+			"\"location\" : {\"lat\" : 54.25,\"long\" : 13.134}}"
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", body, common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		postSiteHandler(w, r, dbClient, pubSubClient)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		siteID := strings.TrimPrefix(w.Result().Header.Get(common.HeaderLocation), common.SitePath)
+		site, err := dbClient.GetByID(context.Background(), utils.GetSitePath(retailerID), siteID, true)
+		assert.Nil(t, err)
+		assert.Equal(t, "Europe/Berlin", site["timezone"])
+
+		w = httptest.NewRecorder()
+		r = getRequest(http.MethodPost, "/sites", body, common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		postSiteHandler(w, r, dbClient, pubSubClient)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Site with name : siteMemory already exists\"}", string(bytes))
+	})
+
+	t.Run("Site creation retried with an Idempotency-Key", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		dbClient := cloud.NewMemoryRepository()
+		retailerID := "ridem1"
+		_, _ = dbClient.Save(context.Background(), common.RetailersCollection, retailerID,
+			map[string]interface{}{common.ID: retailerID, common.DeactivatedTime: nil})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+		postSiteWithKey := func(body string) *http.Response {
+			w := httptest.NewRecorder()
+			r := getRequest(http.MethodPost, "/sites", body, common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+			r.Header.Set(common.HeaderRetailerID, retailerID)
+			r.Header.Set(common.HeaderIdempotencyKey, "create-site-1")
+			idempotency.HandleRequest(w, r, dbClient, retailerID, func(w http.ResponseWriter, r *http.Request) {
+				postSiteHandler(w, r, dbClient, pubSubClient)
+			})
+
+			return w.Result()
+		}
+		body := "{\"name\":\"siteIdem\",\"retailer_site_id\" : \"IDEM111\"," +
+			"\"location\" : {\"lat\" : 54.25,\"long\" : 13.134}}"
+
+		created := postSiteWithKey(body)
+		assert.Equal(t, http.StatusCreated, created.StatusCode)
+		replayed := postSiteWithKey(body)
+		assert.Equal(t, http.StatusCreated, replayed.StatusCode)
+		assert.Equal(t, created.Header.Get(common.HeaderLocation), replayed.Header.Get(common.HeaderLocation))
+		assert.Equal(t, created.Header.Get(common.HeaderEtag), replayed.Header.Get(common.HeaderEtag))
+		assert.Equal(t, "true", replayed.Header.Get(common.HeaderIdempotentReplayed))
+		sites, _, err := dbClient.GetAll(context.Background(), utils.GetSitePath(retailerID),
+			cloud.Page{PageSize: common.DefaultPageSize}, nil)
+		assert.Nil(t, err)
+		assert.Len(t, sites, 1)
+
+		conflicting := postSiteWithKey(strings.Replace(body, "siteIdem", "otherSite", 1))
+		assert.Equal(t, http.StatusUnprocessableEntity, conflicting.StatusCode)
+	})
+
+	t.Run("Site with a service area overlapping the service area of another site", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		dbClient := cloud.NewMemoryRepository()
+		retailerID := "rarea1"
+		_, _ = dbClient.Save(context.Background(), common.RetailersCollection, retailerID,
+			map[string]interface{}{common.ID: retailerID, common.DeactivatedTime: nil})
+		for id, postalCode := range map[string]string{"s0area": "10001", "s1area": "10002"} {
+			_, _ = dbClient.Save(context.Background(), utils.GetSitePath(retailerID), id, models.Site{ID: id,
+				ServiceArea: &commonModels.ServiceArea{PostalCodes: []string{postalCode}}})
+		}
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"siteArea\",\"retailer_site_id\" : \"AREA111\","+
+			"\"location\" : {\"lat\" : 54.25,\"long\" : 13.134},\"service_area\" : {\"postal_codes\" : [\"10001\"],"+
+			"\"polygon\" : {\"type\" : \"Polygon\",\"coordinates\" : [[[13,54],[14,54],[14,55],[13,55],[13,54]]]}}}",
+			common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		postSiteHandler(w, r, dbClient, pubSubClient)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		assert.Equal(t, "s0area", w.Result().Header.Get(common.HeaderServiceAreaOverlaps))
+		siteID := strings.TrimPrefix(w.Result().Header.Get(common.HeaderLocation), common.SitePath)
+		site, err := dbClient.GetByID(context.Background(), utils.GetSitePath(retailerID), siteID, true)
+		assert.Nil(t, err)
+		serviceArea, _ := site[common.ServiceArea].(map[string]interface{})
+		assert.NotEmpty(t, serviceArea["geohashes"])
+	})
+
+	t.Run("Site with an address and the location geocoded from it", func(t *testing.T) {
+		t.Setenv(common.EnvGeocoder, common.GeocoderGoogle)
+		pubSubClient := mocks.NewQueue(t)
+		dbClient := cloud.NewMemoryRepository()
+		retailerID := "raddr1"
+		_, _ = dbClient.Save(context.Background(), common.RetailersCollection, retailerID,
+			map[string]interface{}{common.ID: retailerID, common.DeactivatedTime: nil})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			Reply(200).
+			JSON(commonModels.GoogleGeocoding{Status: "OK", Results: []commonModels.GoogleGeocodingResult{
+				{Geometry: commonModels.GoogleGeocodingGeometry{
+					Location: commonModels.GoogleGeocodingLocation{Latitude: 52.5163, Longitude: 13.3777}}},
+			}})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			MatchParam("location", "52.516300,13.377700").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"siteAddress\",\"retailer_site_id\" : \"ADDR111\","+
+			"\"address\" : {\"lines\" : [\"Pariser Platz 1\"],\"city\" : \"Berlin\",\"postal_code\" : \"10117\","+
+			"\"country\" : \"DE\"}}", common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		postSiteHandler(w, r, dbClient, pubSubClient)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		assert.True(t, gock.IsDone())
+		siteID := strings.TrimPrefix(w.Result().Header.Get(common.HeaderLocation), common.SitePath)
+		data, err := dbClient.GetByID(context.Background(), utils.GetSitePath(retailerID), siteID, true)
+		assert.Nil(t, err)
+		var site models.Site
+		assert.Nil(t, utils.ConvertToObject(data, &site))
+		assert.Equal(t, 52.5163, *site.Location.Latitude)
+		assert.Equal(t, "Berlin", site.Address.City)
+	})
+
+	t.Run("Site with an address and no location without a geocoder", func(t *testing.T) {
+		t.Setenv(common.EnvGeocoder, "")
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{common.ID: "raddr2"}, nil)
+		fireStoreClient.On("Exists", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"siteAddress\",\"retailer_site_id\" : \"ADDR111\","+
+			"\"address\" : {\"lines\" : [\"Pariser Platz 1\"],\"city\" : \"Berlin\",\"postal_code\" : \"10117\","+
+			"\"country\" : \"DE\"}}", common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, "raddr2")
+		postSiteHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Location is required when no geocoder is configured for "+
+			"the address\"}", string(bytes))
+	})
+
+	t.Run("Site with attributes of the attribute schema of the retailer", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		dbClient := cloud.NewMemoryRepository()
+		retailerID := "rattr1"
+		_, _ = dbClient.Save(context.Background(), common.RetailersCollection, retailerID,
+			map[string]interface{}{common.ID: retailerID, common.DeactivatedTime: nil,
+				common.AttributeSchema: map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+					"brand":  map[string]interface{}{"type": "string"},
+					"floors": map[string]interface{}{"type": "integer"},
+				}}})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"siteAttributes\",\"retailer_site_id\" : \"ATTR111\","+
+			"\"location\" : {\"lat\" : 54.25,\"long\" : 13.134},"+
+			"\"attributes\" : {\"brand\" : \"Acme\",\"floors\" : 2}}", common.HeaderXCorrelationID,
+			common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		postSiteHandler(w, r, dbClient, pubSubClient)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		siteID := strings.TrimPrefix(w.Result().Header.Get(common.HeaderLocation), common.SitePath)
+		data, err := dbClient.GetByID(context.Background(), utils.GetSitePath(retailerID), siteID, true)
+		assert.Nil(t, err)
+		var site models.Site
+		assert.Nil(t, utils.ConvertToObject(data, &site))
+		assert.Equal(t, map[string]interface{}{"brand": "Acme", "floors": 2.0}, site.Attributes)
+	})
+
+	t.Run("Site created by the principal of the request", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		dbClient := cloud.NewMemoryRepository()
+		retailerID := "rprin1"
+		_, _ = dbClient.Save(context.Background(), common.RetailersCollection, retailerID,
+			map[string]interface{}{common.ID: retailerID, common.DeactivatedTime: nil})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"sitePrincipal\",\"retailer_site_id\" : \"PRIN111\","+
+			"\"location\" : {\"lat\" : 54.25,\"long\" : 13.134}}", common.HeaderXCorrelationID,
+			common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		r = r.WithContext(context.WithValue(r.Context(), identity.CtxPrincipal{}, "jo@example.com"))
+		postSiteHandler(w, r, dbClient, pubSubClient)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		siteID := strings.TrimPrefix(w.Result().Header.Get(common.HeaderLocation), common.SitePath)
+		site, err := dbClient.GetByID(context.Background(), utils.GetSitePath(retailerID), siteID, true)
+		assert.Nil(t, err)
+		assert.Equal(t, "jo@example.com", site["created_by"])
+		assert.Equal(t, "jo@example.com", site["updated_by"])
+	})
+
+	t.Run("Site with attributes not matching the attribute schema of the retailer", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mock.Anything, true).
+			Return(map[string]interface{}{common.ID: "rattr2", common.AttributeSchema: map[string]interface{}{
+				"type": "object", "additionalProperties": false, "properties": map[string]interface{}{
+					"floors": map[string]interface{}{"type": "integer"},
+				}}}, nil)
+
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"siteAttributes\",\"retailer_site_id\" : \"ATTR111\","+
+			"\"location\" : {\"lat\" : 54.25,\"long\" : 13.134},"+
+			"\"attributes\" : {\"brand\" : \"Acme\",\"floors\" : \"two\"}}", common.HeaderXCorrelationID,
+			common.HeaderAcceptVersion)
+		r.Header.Set(common.HeaderRetailerID, "rattr2")
+		postSiteHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\",\"errors\":["+
+			"\"attributes.brand is not a declared attribute\",\"attributes.floors must be of type integer\"]}",
+			string(bytes))
+	})
+
+	t.Run("Retailer site id reserved by a concurrent request", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"siteID1\","+
+			"\"retailer_site_id\" : \"ABS111\","+
+			"\"location\" : {"+
+			"\"lat\" : 54.25,"+
+			"\"long\" : 13.134"+
+			"}"+
+			"}", common.HeaderXCorrelationID)
+		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
+		retailerID := "r" + utils.GetRandomID(4)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, retailerID, true).Return(map[string]interface{}{}, nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything).Return(false, nil).Twice()
+		fireStoreClient.On("ExistsInCollectionGroup", mock.Anything, common.SitesCollection, common.ID, mock.Anything).Return(false, nil).Once()
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything, mock.Anything).
+			Return(time.Time{}, &cloud.UniqueKeyError{Key: cloud.NewUniqueKey(utils.GetSitePath(retailerID), common.RetailersSiteID, "ABS111")}).Once()
+		postSiteHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Retailer's site id ABS111 already exists\"}", string(bytes))
+	})
+
+	t.Run("Site id reserved by a concurrent request is regenerated", func(t *testing.T) {
+		pubSubClient := mocks.NewQueue(t)
+		fireStoreClient := mocks.NewDB(t)
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		w := httptest.NewRecorder()
+		r := getRequest(http.MethodPost, "/sites", "{\"name\":\"siteID1\","+
+			"\"retailer_site_id\" : \"ABS111\","+
+			"\"location\" : {"+
+			"\"lat\" : 54.25,"+
+			"\"long\" : 13.134"+
+			"}"+
+			"}", common.HeaderXCorrelationID)
+		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
+		retailerID := "r" + utils.GetRandomID(4)
+		r.Header.Set(common.HeaderRetailerID, retailerID)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, retailerID, true).Return(map[string]interface{}{}, nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything).Return(false, nil).Twice()
+		fireStoreClient.On("ExistsInCollectionGroup", mock.Anything, common.SitesCollection, common.ID, mock.Anything).Return(false, nil).Twice()
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything, mock.Anything).
+			Return(time.Time{}, &cloud.UniqueKeyError{Key: cloud.NewUniqueKey(common.SitesCollection, common.ID, "s12345")}).Once()
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSitePath(retailerID), mock.Anything, mock.Anything, mock.Anything).
+			Return(time.Now(), nil).Once()
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		postSiteHandler(w, r, fireStoreClient, pubSubClient)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	})
 }
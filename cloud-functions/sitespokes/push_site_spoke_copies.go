@@ -0,0 +1,177 @@
+package sitespokes
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// This file has the function copying an updated site or spoke onto its site spoke mappings,
+// so the spokes of a site and the sites of a spoke are listed with their current data
+func init() {
+	functions.CloudEvent("PushSiteSpokeCopies", PushSiteSpokeCopies)
+}
+
+type MessagePublishedData struct {
+	Message PubSubMessage
+}
+
+type PubSubMessage struct {
+	Data []byte `json:"data"`
+}
+
+func PushSiteSpokeCopies(ctx context.Context, event event.Event) error {
+	logger := logging.GetLoggerFromContext(ctx)
+	var msg MessagePublishedData
+
+	if err := event.DataAs(&msg); err != nil {
+		logger.Errorf("Error occurred while converting event data to MessagePublishedData struct: %v", err)
+
+		return err
+	}
+
+	return pushSiteSpokeCopies(ctx, msg.Message.Data, cloud.NewDBRepository(ctx))
+}
+
+// pushSiteSpokeCopies copies the site or the spoke of the message onto its site spoke mappings.
+// An error is returned for the message to be redelivered, e.g. when the site or the spoke changed while copying it,
+// and a message which can never be copied is acknowledged
+func pushSiteSpokeCopies(ctx context.Context, data []byte, dbClient cloud.DB) error {
+	logger := logging.GetLoggerFromContext(ctx)
+	var message dbutil.SiteSpokeCopyMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		logger.Errorf("Invalid site spoke copy message received : %v", err)
+
+		return nil
+	}
+	copier, err := newSiteSpokeCopier(ctx, dbClient, message)
+	if status.Code(err) == codes.NotFound || status.Code(err) == codes.InvalidArgument {
+		logger.Errorf("The %s %s of retailer %s is not copied : %v", message.Entity, message.ID,
+			message.RetailerID, err)
+
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("Error while fetching the %s %s from DB : %v", message.Entity, message.ID, err)
+
+		return err
+	}
+	if err = copier.run(ctx); err != nil {
+		logger.Errorf("Error while copying the %s %s after %d site spokes : %v", message.Entity, message.ID,
+			copier.copied, err)
+
+		return err
+	}
+	logger.Infof("The %s %s at revision %d copied onto %d site spokes", message.Entity, message.ID,
+		copier.revision, copier.copied)
+
+	return nil
+}
+
+// siteSpokeCopier writes the copy of the site or the spoke at the revision read under the field of the site spoke
+// mappings whose idField is the ID of the site or the spoke
+type siteSpokeCopier struct {
+	dbClient       cloud.DB
+	retailerID     string
+	collectionPath string
+	id             string
+	idField        string
+	field          string
+	copy           any
+	revision       int64
+	copied         int
+}
+
+// newSiteSpokeCopier reads the site or the spoke of the message, including a deactivated one
+func newSiteSpokeCopier(ctx context.Context, dbClient cloud.DB,
+	message dbutil.SiteSpokeCopyMessage) (*siteSpokeCopier, error) {
+	copier := &siteSpokeCopier{dbClient: dbClient, retailerID: message.RetailerID, id: message.ID}
+	switch message.Entity {
+	case common.EntitySite:
+		copier.collectionPath = utils.GetSitePath(message.RetailerID)
+		copier.idField = common.SiteID
+		copier.field = common.SiteSpokeSite
+		copier.copy = &sites.Site{}
+	case common.EntitySpoke:
+		copier.collectionPath = utils.GetSpokePath(message.RetailerID)
+		copier.idField = common.SpokeID
+		copier.field = common.SiteSpokeSpoke
+		copier.copy = &spokes.Spoke{}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid entity '%s'", message.Entity)
+	}
+	data, err := dbClient.GetByID(ctx, copier.collectionPath, message.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err = utils.ConvertToObject(data, copier.copy); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s : %v", message.Entity, err)
+	}
+	copier.revision = utils.GetRevision(data)
+
+	return copier, nil
+}
+
+// run copies onto the mappings a batch at a time, skipping the mappings which have the revision already
+func (c *siteSpokeCopier) run(ctx context.Context) error {
+	var startAfter *cloud.Cursor
+	for {
+		siteSpokes, cursor, err := c.dbClient.GetAll(ctx, utils.GetSiteSpokePath(c.retailerID),
+			cloud.Page{StartAfter: startAfter, PageSize: common.SiteSpokeCopyBatchSize},
+			[]cloud.Where{{Field: c.idField, Operator: common.OperatorEquals, Value: c.id}})
+		if err != nil {
+			return err
+		}
+		var documentIDs []string
+		for _, siteSpoke := range siteSpokes {
+			copied, _ := siteSpoke[c.field].(map[string]interface{})
+			if copied == nil || utils.GetRevision(copied) < c.revision {
+				documentIDs = append(documentIDs, fmt.Sprint(siteSpoke[common.ID]))
+			}
+		}
+		if err = c.write(ctx, documentIDs); err != nil {
+			return err
+		}
+		if len(siteSpokes) < common.SiteSpokeCopyBatchSize {
+			return nil
+		}
+		startAfter = cursor
+	}
+}
+
+// write copies onto the mappings in a transaction which checks that the site or the spoke still has the revision
+// read, so a copy never overwrites the copy of a newer revision written by the message of a later update
+func (c *siteSpokeCopier) write(ctx context.Context, documentIDs []string) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	copyUpdates := []firestore.Update{{Path: c.field, Value: c.copy}}
+	updates := []cloud.DocumentUpdate{{CollectionPath: c.collectionPath, DocumentID: c.id, Revision: &c.revision}}
+	for _, documentID := range documentIDs[1:] {
+		updates = append(updates, cloud.DocumentUpdate{
+			CollectionPath: utils.GetSiteSpokePath(c.retailerID),
+			DocumentID:     documentID,
+			Updates:        copyUpdates,
+		})
+	}
+	_, err := c.dbClient.UpdateInTransaction(ctx, utils.GetSiteSpokePath(c.retailerID), documentIDs[0], copyUpdates,
+		cloud.WriteOptions{Updates: updates})
+	if err != nil {
+		return err
+	}
+	c.copied += len(documentIDs)
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package sitespokes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokes "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func getCopyMessage(t *testing.T, entity string, id string) []byte {
+	data, err := json.Marshal(dbutil.SiteSpokeCopyMessage{RetailerID: "r12345", Entity: entity, ID: id})
+	assert.Nil(t, err)
+
+	return data
+}
+
+// getTestSiteSpokes returns a repository with the site s1 at the revision 2 attached to the spokes, with a copy of
+// the site at the revision 1
+func getTestSiteSpokes(t *testing.T, spokeCount int) *cloud.MemoryRepository {
+	repository := cloud.NewMemoryRepository()
+	ctx := context.Background()
+	_, err := repository.Save(ctx, utils.GetSitePath("r12345"), "s1",
+		sites.Site{ID: "s1", RetailerID: "r12345", Name: "renamed", Revision: 2})
+	assert.Nil(t, err)
+	for i := 0; i < spokeCount; i++ {
+		spoke := spokes.Spoke{ID: fmt.Sprintf("p%d", i), RetailerID: "r12345", Name: "spoke"}
+		siteSpoke := spokes.NewSiteSpoke(sites.Site{ID: "s1", RetailerID: "r12345", Name: "site", Revision: 1},
+			spoke, common.User)
+		_, err = repository.Save(ctx, utils.GetSiteSpokePath("r12345"), siteSpoke.ID, siteSpoke)
+		assert.Nil(t, err)
+	}
+
+	return repository
+}
+
+func Test_pushSiteSpokeCopies(t *testing.T) {
+	t.Run("Copy the site onto all its site spokes in batches", func(t *testing.T) {
+		repository := getTestSiteSpokes(t, common.SiteSpokeCopyBatchSize+1)
+		err := pushSiteSpokeCopies(context.Background(), getCopyMessage(t, common.EntitySite, "s1"), repository)
+		assert.Nil(t, err)
+		siteSpokes, _, err := repository.GetAll(context.Background(), utils.GetSiteSpokePath("r12345"),
+			cloud.Page{PageSize: common.SiteSpokeCopyBatchSize * 2}, nil)
+		assert.Nil(t, err)
+		assert.Len(t, siteSpokes, common.SiteSpokeCopyBatchSize+1)
+		for _, siteSpoke := range siteSpokes {
+			site := siteSpoke[common.SiteSpokeSite].(map[string]interface{})
+			assert.Equal(t, "renamed", site[common.Name])
+			assert.Equal(t, int64(2), site[common.Revision])
+		}
+	})
+
+	t.Run("Site spokes with the copy of the revision are not written again", func(t *testing.T) {
+		repository := getTestSiteSpokes(t, 1)
+		message := dbutil.SiteSpokeCopyMessage{RetailerID: "r12345", Entity: common.EntitySite, ID: "s1"}
+		copier, err := newSiteSpokeCopier(context.Background(), repository, message)
+		assert.Nil(t, err)
+		assert.Nil(t, copier.run(context.Background()))
+		assert.Equal(t, 1, copier.copied)
+		copier.copied = 0
+		assert.Nil(t, copier.run(context.Background()))
+		assert.Equal(t, 0, copier.copied)
+	})
+
+	t.Run("Site changed while copying it is redelivered", func(t *testing.T) {
+		dbClient := mocks.NewDB(t)
+		dbClient.On("GetByID", mock.Anything, utils.GetSitePath("r12345"), "s1", false).
+			Return(map[string]interface{}{common.ID: "s1", common.Revision: int64(2)}, nil)
+		dbClient.On("GetAll", mock.Anything, utils.GetSiteSpokePath("r12345"), mock.Anything, mock.Anything).
+			Return([]map[string]interface{}{{common.ID: "s1_p1"}}, &cloud.Cursor{ID: "s1_p1"}, nil)
+		dbClient.On("UpdateInTransaction", mock.Anything, utils.GetSiteSpokePath("r12345"), "s1_p1", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return len(options.Updates) == 1 && *options.Updates[0].Revision == 2 &&
+					len(options.Updates[0].Updates) == 0
+			})).Return(time.Time{}, status.Error(codes.FailedPrecondition, "revision mismatch"))
+		err := pushSiteSpokeCopies(context.Background(), getCopyMessage(t, common.EntitySite, "s1"), dbClient)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("Error while fetching the spoke is redelivered", func(t *testing.T) {
+		dbClient := mocks.NewDB(t)
+		dbClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p1", false).
+			Return(nil, status.Error(codes.Unavailable, "connection timeout"))
+		err := pushSiteSpokeCopies(context.Background(), getCopyMessage(t, common.EntitySpoke, "p1"), dbClient)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Message of a missing spoke is acknowledged", func(t *testing.T) {
+		err := pushSiteSpokeCopies(context.Background(), getCopyMessage(t, common.EntitySpoke, "p1"),
+			cloud.NewMemoryRepository())
+		assert.Nil(t, err)
+	})
+
+	t.Run("Invalid message is acknowledged", func(t *testing.T) {
+		err := pushSiteSpokeCopies(context.Background(), []byte("invalid"), mocks.NewDB(t))
+		assert.Nil(t, err)
+		err = pushSiteSpokeCopies(context.Background(), getCopyMessage(t, "retailer", "r1"), mocks.NewDB(t))
+		assert.Nil(t, err)
+	})
+}
@@ -3,13 +3,19 @@ package common
 import (
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/fatih/structs"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"net/http"
+	"os"
+	"time"
 )
 
 func GetSpokeFromDB(responseWriter http.ResponseWriter, request *http.Request, logger *zap.SugaredLogger,
@@ -51,3 +57,28 @@ func IsSpokeAttachedToSite(responseWriter http.ResponseWriter, request *http.Req
 
 	return true
 }
+
+// GetSiteSpokeAuditMessages returns the audit messages recording the attach or detach of the spoke to the site
+// in the audit logs of both the site and the spoke
+func GetSiteSpokeAuditMessages(request *http.Request, changeType string, siteSpoke models.SiteSpoke,
+	changedBy string, changedAt *time.Time) []outbox.Message {
+	var oldEntity, newEntity map[string]interface{}
+	if changeType == common.AuditTypeDetach {
+		oldEntity = structs.Map(siteSpoke)
+	} else {
+		newEntity = structs.Map(siteSpoke)
+	}
+	var messages []outbox.Message
+	for _, path := range []string{
+		audit.GetSiteAuditPath(siteSpoke.RetailerID, siteSpoke.SiteID),
+		audit.GetSpokeAuditPath(siteSpoke.RetailerID, siteSpoke.SpokeID),
+	} {
+		messages = append(messages, outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(path, request.Header.Get(common.HeaderXCorrelationID), changedBy,
+				changeType, common.EntitySiteSpoke, changedAt, oldEntity, newEntity),
+		})
+	}
+
+	return messages
+}
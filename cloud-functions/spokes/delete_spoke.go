@@ -0,0 +1,188 @@
+package spokes
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	spokeCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/common"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/fatih/structs"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+	"time"
+)
+
+// This file has the function and handler to deactivate a spoke which is not attached to any site
+var deleteSpokePath = urit.MustCreateTemplate(fmt.Sprintf("/spokes/{%s}", common.PathParamSpokeID))
+
+func init() {
+	functions.HTTP("DeleteSpoke", deleteSpoke)
+}
+
+var deleteSpoke = authorization.Authorize("delete_spoke.deleteSpoke",
+	authorization.OnHeaderRetailer(common.RoleEditor),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		deleteSpokeHandler(responseWriter, request, dbClient, cloud.NewPubSubRepository(request.Context()))
+	})
+
+func deleteSpokeHandler(responseWriter http.ResponseWriter, request *http.Request,
+	dbClient cloud.DB, pubsubClient cloud.Queue) {
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("delete_spoke.deleteSpokeHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(models.GetRequiredHeaders(), common.HeaderIfMatch),
+		RequiredPath:    deleteSpokePath,
+		RequestMethod:   http.MethodDelete,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	spokeID := pathParams[common.PathParamSpokeID]
+
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+		return
+	}
+
+	data := spokeCommon.GetSpokeFromDB(responseWriter, request, logger, dbClient, retailerID, spokeID, true)
+	if data == nil {
+		return
+	}
+
+	attached, err := dbClient.Exists(ctx, utils.GetSiteSpokePath(retailerID), common.SpokeID, spokeID)
+	if err != nil {
+		logger.Errorf("Error occurred while checking the sites attached to the spoke in DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	if attached {
+		logger.Debugf("Spoke %s cannot be deleted as it is attached to sites", spokeID)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusConflict,
+				"Delete request cannot be processed, the spoke is attached to sites. "+
+					"Please detach the spoke from the sites and try again", nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	if !utils.IsValidEtagPresentInHeader(responseWriter, request, data, logger) {
+		return
+	}
+
+	var spoke models.Spoke
+	err = utils.ConvertToObject(data, &spoke)
+	if err != nil {
+		logger.Errorf("Error while converting data got from DB to spoke struct : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	deactivatedTime := time.Now().UTC().Round(time.Second)
+	oldSpoke := spoke
+	spoke.DeactivatedTime = &deactivatedTime
+	spoke.UpdatedTime = &deactivatedTime
+	spoke.UpdatedBy = identity.GetPrincipalFromContext(ctx)
+	spoke.DeactivatedBy = identity.GetPrincipalFromContext(ctx)
+	spoke.Revision++
+
+	events, err := getDeleteSpokeEvents(request, oldSpoke, spoke)
+	if err != nil {
+		logger.Errorf("Error while creating the spoke events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	// an attach bumps the revision of the spoke, so the spoke is not deleted if it was attached since it was read.
+	// The name of the deleted spoke is released for a new spoke
+	updateTime, err := dbClient.UpdateInTransaction(ctx, utils.GetSpokePath(retailerID), spokeID,
+		createSpokeUpdatesForDelete(spoke),
+		cloud.WriteOptions{
+			ReleaseKeys: []cloud.UniqueKey{cloud.NewUniqueKey(utils.GetSpokePath(retailerID), common.Name, spoke.Name)},
+			Creates:     outbox.Documents(events),
+			Revision:    &oldSpoke.Revision,
+		})
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Spoke %s changed since it was read : %v", spokeID, err)
+		response.RespondWithPreconditionFailed(responseWriter, request)
+
+		return
+	}
+	if err != nil {
+		logger.Errorf("Error while deleting spoke from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	etag, err := utils.GetETag(spoke)
+	if err != nil {
+		logger.Errorf("Error while getting etag for spoke struct object : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusOK, fmt.Sprintf("Spoke %s deleted successfully", spokeID), nil),
+		response.GetCommonResponseHeaders(request).
+			WithHeader(common.HeaderLastModified, updateTime.Format(time.RFC3339)).
+			WithHeader(common.HeaderEtag, etag))
+
+	logger.Debugf("Spoke id %s successfully deleted", spokeID)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
+}
+
+// getDeleteSpokeEvents returns the audit and spoke change events to be written along with the deactivation
+func getDeleteSpokeEvents(request *http.Request, oldSpoke models.Spoke, spoke models.Spoke) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetSpokeAuditPath(spoke.RetailerID, spoke.ID),
+				request.Header.Get(common.HeaderXCorrelationID), spoke.DeactivatedBy,
+				common.AuditTypeDeactivate,
+				common.EntitySpoke,
+				spoke.DeactivatedTime,
+				structs.Map(oldSpoke),
+				nil,
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvSpokeMessageTopic),
+			Data:  models.GetPubSubSpokeMessage(spoke.RetailerID, "", spoke.ID, "", common.ChangeTypeDelete),
+		})
+}
+
+func createSpokeUpdatesForDelete(spoke models.Spoke) []firestore.Update {
+	var updatesForDelete []firestore.Update
+
+	updatesForDelete = append(updatesForDelete, firestore.Update{Path: "deactivated_time", Value: spoke.DeactivatedTime})
+	updatesForDelete = append(updatesForDelete, firestore.Update{Path: "updated_time", Value: spoke.UpdatedTime})
+	updatesForDelete = append(updatesForDelete, firestore.Update{Path: "deactivated_by", Value: spoke.DeactivatedBy})
+	updatesForDelete = append(updatesForDelete, firestore.Update{Path: "updated_by", Value: spoke.UpdatedBy})
+	updatesForDelete = append(updatesForDelete, firestore.Update{Path: common.Revision, Value: spoke.Revision})
+
+	return updatesForDelete
+}
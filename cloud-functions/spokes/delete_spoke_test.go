@@ -0,0 +1,129 @@
+package spokes
+
+import (
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_deleteSpokeHandler(t *testing.T) {
+	t.Run("Missing If-Match header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodDelete, "", getStoredSpoke())
+		r.Header.Del(common.HeaderIfMatch)
+		deleteSpokeHandler(w, r, mocks.NewDB(t), mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Spoke ID does not exist", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodDelete, "", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(nil, status.Error(codes.NotFound, "not found"))
+		deleteSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+
+	t.Run("Spoke attached to sites", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodDelete, "", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath("r12345"), common.SpokeID, "p12345").
+			Return(true, nil)
+		deleteSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusConflict, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":409,\"message\":\"Delete request cannot be processed, the spoke is attached to sites. "+
+			"Please detach the spoke from the sites and try again\"}", string(bytes))
+	})
+
+	t.Run("Error while checking the attached sites", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodDelete, "", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath("r12345"), common.SpokeID, "p12345").
+			Return(false, errors.New("connection timeout"))
+		deleteSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("ETag mismatch", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodDelete, "", getStoredSpoke())
+		r.Header.Set(common.HeaderIfMatch, "outdated etag")
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath("r12345"), common.SpokeID, "p12345").
+			Return(false, nil)
+		deleteSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	})
+
+	t.Run("Spoke changed since it was read", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodDelete, "", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath("r12345"), common.SpokeID, "p12345").
+			Return(false, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool { return options.Revision != nil })).
+			Return(time.Time{}, status.Error(codes.FailedPrecondition, "revision mismatch"))
+		deleteSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	})
+
+	t.Run("Successful delete", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodDelete, "", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath("r12345"), common.SpokeID, "p12345").
+			Return(false, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return len(options.ReleaseKeys) == 1 && len(options.Creates) == 2 &&
+					options.ReleaseKeys[0] == cloud.NewUniqueKey(utils.GetSpokePath("r12345"), common.Name, "SpokeName")
+			})).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		deleteSpokeHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":200,\"message\":\"Spoke p12345 deleted successfully\"}", string(bytes))
+	})
+}
@@ -1,13 +1,12 @@
 package spokes
 
 import (
-	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	spokesCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
@@ -25,14 +24,8 @@ func init() {
 	functions.HTTP("GetSpoke", getSpoke)
 }
 
-func getSpoke(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_spoke.getSpoke"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getSpokeHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getSpoke = authorization.Authorize("get_spoke.getSpoke",
+	authorization.OnHeaderRetailer(common.RoleReader), getSpokeHandler)
 
 func getSpokeHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -77,6 +70,15 @@ func getSpokeHandler(responseWriter http.ResponseWriter,
 
 		return
 	}
+	updatedTime := utils.GetUpdatedTime(data)
+	responseHeaders := response.GetCommonResponseHeaders(request).
+		WithHeader(common.HeaderEtag, etag).
+		WithHeader(common.HeaderLastModified, utils.GetLastModified(updatedTime))
+	if utils.IsNotModified(request, etag, updatedTime) {
+		response.RespondWithNotModified(responseWriter, responseHeaders)
+
+		return
+	}
 
 	var spoke models.Spoke
 	err = utils.ConvertToObject(data, &spoke)
@@ -87,10 +89,7 @@ func getSpokeHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	response.Respond(responseWriter, http.StatusOK,
-		spoke,
-		response.GetCommonResponseHeaders(request).
-			WithHeader(common.HeaderEtag, etag))
+	response.Respond(responseWriter, http.StatusOK, spoke, responseHeaders)
 
 	logger.Debugf("Spoke id %s successfully fetched for retailer : %v", spokeID, spoke)
 }
@@ -0,0 +1,103 @@
+package spokes
+
+import (
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	auditModels "github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+)
+
+// This file has the function and handler to get audit logs for a spoke from DB
+var getSpokeAuditPath = urit.MustCreateTemplate(fmt.Sprintf("/spokes/{%s}/auditLogs", common.PathParamSpokeID))
+
+func init() {
+	functions.HTTP("GetSpokeAudit", getSpokeAudit)
+}
+
+var getSpokeAudit = authorization.Authorize("get_spoke_audit.getSpokeAudit",
+	authorization.OnHeaderRetailer(common.RoleReader), getSpokeAuditHandler)
+
+func getSpokeAuditHandler(responseWriter http.ResponseWriter,
+	request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("get_spoke_audit.getSpokeAuditHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(models.GetRequiredHeaders(), utils.AddPaginationHeaderIfNotAdded(request)...),
+		RequiredPath:    getSpokeAuditPath,
+		RequestMethod:   http.MethodGet,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	spokeID := pathParams[common.PathParamSpokeID]
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+
+	exists, err := dbClient.Exists(ctx, utils.GetSpokePath(retailerID), common.ID, spokeID)
+	if err != nil {
+		logger.Errorf("Error occurred while checking existence of spoke for a retailer in DB: %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	if !exists {
+		logger.Debugf("Spoke with id %s under Retailer with id %s does not exist", spokeID, retailerID)
+		response.RespondWithNotFoundErrorMessage(responseWriter, request,
+			fmt.Sprintf("Spoke with id %s does not exist for Retailer with id %s", spokeID, retailerID), err)
+
+		return
+	}
+
+	var data []map[string]interface{}
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
+	pageSize := utils.GetPageSizeFromHeader(request, logger)
+
+	startAfter, err = cloud.DecodeNextPageToken(request, common.SpokesEncryptionKey)
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	data, startAfter, err = dbClient.GetAll(ctx, audit.GetSpokeAuditPath(retailerID, spokeID),
+		cloud.Page{
+			StartAfter: startAfter,
+			PageSize:   pageSize,
+			OrderBy:    []cloud.Order{{Field: common.ChangedAt, Sort: common.SortDescending}},
+		}, nil)
+
+	if err != nil {
+		logger.Errorf("Internal server error while fetching the spoke audit logs from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		logger.Debugf("Received nextPageToken : %s", nextPage.Token)
+		if err != nil {
+			logger.Errorf("Error occurred while creating the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+	}
+	auditLog := &auditModels.AuditLog{}
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPage, auditLog)
+}
@@ -0,0 +1,95 @@
+package spokes
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getSpokeAuditRequest() *http.Request {
+	r := getRequest(http.MethodGet, "/spokes/p12345/auditLogs", "", common.HeaderXCorrelationID,
+		common.HeaderAcceptVersion)
+	r.Header.Set(common.HeaderRetailerID, "r12345")
+
+	return r
+}
+
+func Test_getSpokeAuditHandler(t *testing.T) {
+	t.Run("Invalid method request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getSpokeAuditRequest()
+		r.Method = http.MethodPost
+		getSpokeAuditHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("RetailerID and Spoke ID combination does not exist", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath("r12345"), common.ID, "p12345").Return(false, nil)
+		getSpokeAuditHandler(w, getSpokeAuditRequest(), fireStoreClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":404,\"message\":\"Spoke with id p12345 does not exist for Retailer with id r12345\"}", string(bytes))
+	})
+
+	t.Run("Failed while getting audit logs from DB", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath("r12345"), common.ID, "p12345").Return(true, nil)
+		fireStoreClient.On("GetAll", mock.Anything, audit.GetSpokeAuditPath("r12345", "p12345"), mock.Anything,
+			mock.Anything).Return(nil, nil, errors.New("connection timeout"))
+		getSpokeAuditHandler(w, getSpokeAuditRequest(), fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Successful get audit logs from DB with page token in response", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeAuditRequest()
+		r.Header.Set(common.HeaderPageSize, "2")
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath("r12345"), common.ID, "p12345").Return(true, nil)
+		fireStoreClient.On("GetAll", mock.Anything, audit.GetSpokeAuditPath("r12345", "p12345"), mock.Anything,
+			mock.Anything).Return(getSpokeAuditList(2), &cloud.Cursor{ID: "12345-12345"}, nil)
+		getSpokeAuditHandler(w, r, fireStoreClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		var auditEntities []models.AuditLog
+		_ = json.Unmarshal(bytes, &auditEntities)
+		assert.Equal(t, 2, len(auditEntities))
+		assert.Equal(t, common.AuditTypeAttach, auditEntities[0].ChangeType)
+		assert.NotEmpty(t, auditEntities[0].ChangeDetails)
+		assert.NotEmpty(t, response.Header.Get(common.HeaderNextPageToken))
+	})
+}
+
+func getSpokeAuditList(length int) []map[string]interface{} {
+	var list []map[string]interface{}
+	for i := 0; i < length; i++ {
+		list = append(list, map[string]interface{}{
+			"changed_by":  common.User,
+			"change_type": common.AuditTypeAttach,
+			"change_details": []map[string]interface{}{
+				{"field": common.SiteID, "new_value": "s12345"},
+				{"field": common.SpokeID, "new_value": "p12345"},
+			},
+			"changed_at": time.Now().UTC().Round(time.Second),
+		})
+	}
+
+	return list
+}
@@ -0,0 +1,95 @@
+package spokes
+
+import (
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	spokesCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/common"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+)
+
+// This file has the function and handler to evaluate whether a spoke is open at a time with its operating hours
+var getSpokeOpenStatusPath = urit.MustCreateTemplate(fmt.Sprintf("/spokes/{%s}/openStatus", common.PathParamSpokeID))
+
+func init() {
+	functions.HTTP("GetSpokeOpenStatus", getSpokeOpenStatus)
+}
+
+var getSpokeOpenStatus = authorization.Authorize("get_spoke_open_status.getSpokeOpenStatus",
+	authorization.OnHeaderRetailer(common.RoleReader), getSpokeOpenStatusHandler)
+
+func getSpokeOpenStatusHandler(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(),
+		utils.GetSpanName("get_spoke_open_status.getSpokeOpenStatusHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: models.GetRequiredHeaders(),
+		RequiredPath:    getSpokeOpenStatusPath,
+		RequestMethod:   http.MethodGet,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	at, validationResponse := utils.ParseAtQueryParam(request)
+	if validationResponse != nil {
+		logger.Debugf("Invalid at query param : %s", request.URL.Query().Get(common.QueryParamAt))
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	spokeID := pathParams[common.PathParamSpokeID]
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+		return
+	}
+
+	data := spokesCommon.GetSpokeFromDB(responseWriter, request, logger, dbClient, retailerID, spokeID, true)
+	if data == nil {
+		return
+	}
+
+	var spoke models.Spoke
+	err := utils.ConvertToObject(data, &spoke)
+	if err != nil {
+		logger.Errorf("Error while converting data from DB to struct object : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	if spoke.OperatingHours == nil || spoke.Timezone == "" {
+		logger.Debugf("Spoke ID %s has no operating hours or no timezone", spokeID)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Spoke ID %s has no operating hours or no timezone", spokeID), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	openStatus, err := utils.GetOpenStatus(*spoke.OperatingHours, spoke.Timezone, at)
+	if err != nil {
+		logger.Errorf("Error while evaluating the operating hours of the spoke in timezone %s : %v", spoke.Timezone, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	response.Respond(responseWriter, http.StatusOK, openStatus, response.GetCommonResponseHeaders(request))
+	logger.Debugf("Spoke ID %s open at %v : %t", spokeID, at, openStatus.Open)
+}
@@ -0,0 +1,75 @@
+package spokes
+
+import (
+	"context"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_getSpokeOpenStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	getSpokeOpenStatus(w, getRequest(http.MethodGet, "/spokes/p1/openStatus", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func Test_getSpokeOpenStatusHandler(t *testing.T) {
+	ctx := context.Background()
+	repository := cloud.NewMemoryRepository()
+	_, err := repository.Save(ctx, common.RetailersCollection, "r12345", map[string]interface{}{
+		"id": "r12345", "deactivated_time": nil})
+	assert.Nil(t, err)
+	for _, spoke := range []models.Spoke{
+		{ID: "p1", Timezone: "America/Los_Angeles", OperatingHours: &commonModels.OperatingHours{
+			Weekly: []commonModels.DayHours{{Day: "saturday", Open: "22:00", Close: "06:00"}},
+		}},
+		{ID: "p2", OperatingHours: &commonModels.OperatingHours{
+			Weekly: []commonModels.DayHours{{Day: "saturday", Open: "22:00", Close: "06:00"}},
+		}},
+	} {
+		_, err = repository.Save(ctx, utils.GetSpokePath("r12345"), spoke.ID, spoke)
+		assert.Nil(t, err)
+	}
+
+	t.Run("Spoke open overnight", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getOpenStatusRequest("/spokes/p1/openStatus?at=2023-03-05T10:00:00Z")
+		getSpokeOpenStatusHandler(w, r, repository)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"at\":\"2023-03-05T02:00:00-08:00\",\"timezone\":\"America/Los_Angeles\",\"open\":true,"+
+			"\"current_window\":{\"open\":\"2023-03-04T22:00:00-08:00\",\"close\":\"2023-03-05T06:00:00-08:00\"},"+
+			"\"next_window\":{\"open\":\"2023-03-11T22:00:00-08:00\",\"close\":\"2023-03-12T06:00:00-07:00\"}}",
+			string(bytes))
+	})
+
+	t.Run("Spoke without timezone", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getOpenStatusRequest("/spokes/p2/openStatus")
+		getSpokeOpenStatusHandler(w, r, repository)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	})
+
+	t.Run("Spoke not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getOpenStatusRequest("/spokes/p3/openStatus")
+		getSpokeOpenStatusHandler(w, r, repository)
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	})
+}
+
+func getOpenStatusRequest(url string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, url, nil)
+	request.Header.Set(common.HeaderXCorrelationID, "1234")
+	request.Header.Set(common.HeaderRetailerID, "r12345")
+	request.Header.Set(common.HeaderAcceptVersion, "v1")
+
+	return request
+}
@@ -0,0 +1,128 @@
+package spokes
+
+import (
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"net/http"
+	"strings"
+)
+
+// This file has the function and handler to list the sites a spoke is attached to
+var getSpokeSitesPath = urit.MustCreateTemplate(fmt.Sprintf("/spokes/{%s}/sites", common.PathParamSpokeID))
+
+func init() {
+	functions.HTTP("GetSpokeSites", getSpokeSites)
+}
+
+var getSpokeSites = authorization.Authorize("get_spoke_sites.getSpokeSites",
+	authorization.OnHeaderRetailer(common.RoleReader), getSpokeSitesHandler)
+
+func getSpokeSitesHandler(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("get_spoke_sites.getSpokeSitesHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(models.GetRequiredHeaders(), utils.AddPaginationHeaderIfNotAdded(request)...),
+		RequiredPath:    getSpokeSitesPath,
+		RequestMethod:   http.MethodGet,
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	spokeID := pathParams[common.PathParamSpokeID]
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+
+	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+		return
+	}
+
+	if !dbutil.IsSpokeIDPresentInDB(responseWriter, request, dbClient, retailerID, spokeID, logger, true) {
+		return
+	}
+
+	var err error
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
+	pageSize := utils.GetPageSizeFromHeader(request, logger)
+
+	startAfter, err = cloud.DecodeNextPageToken(request, "")
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	// the sites are listed from the copies kept on the site spoke mappings, so a single query pages through them
+	data, startAfter, err := dbClient.GetAll(ctx, utils.GetSiteSpokePath(retailerID),
+		cloud.Page{
+			StartAfter: startAfter,
+			PageSize:   pageSize,
+			OrderBy:    []cloud.Order{{Field: common.SiteSpokeSite + "." + common.Name, Sort: common.SortAscending}},
+		}, getSpokeSitesWhereClause(request, spokeID))
+	if err != nil {
+		logger.Errorf("Internal server error while fetching the spoke sites from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	spokeSites := make([]map[string]interface{}, 0, len(data))
+	for _, siteSpoke := range data {
+		site, ok := siteSpoke[common.SiteSpokeSite].(map[string]interface{})
+		if !ok {
+			logger.Errorf("Site spoke mapping %v does not have the site", siteSpoke[common.ID])
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+		spokeSites = append(spokeSites, site)
+	}
+
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		if err != nil {
+			logger.Errorf("Error occurred while creating the next page token : %v", err)
+			response.RespondWithInternalServerError(responseWriter, request)
+
+			return
+		}
+	}
+
+	site := sites.Site{}
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, spokeSites, nextPage, site)
+}
+
+// getSpokeSitesWhereClause returns the mappings of the spoke, only those of the active sites
+// unless the deactivated sites are requested
+func getSpokeSitesWhereClause(request *http.Request, spokeID string) []cloud.Where {
+	where := []cloud.Where{{
+		Field:    common.SpokeID,
+		Operator: common.OperatorEquals,
+		Value:    spokeID,
+	}}
+	if strings.ToLower(request.URL.Query().Get(common.QueryParamDeactivated)) != common.True {
+		where = append(where, cloud.Where{
+			Field:    common.SiteSpokeSite + "." + common.DeactivatedTime,
+			Operator: common.OperatorEquals,
+			Value:    nil,
+		})
+	}
+
+	return where
+}
@@ -0,0 +1,156 @@
+package spokes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getSpokeSitesRequest(url string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	r.Header.Set(common.HeaderXCorrelationID, "1234")
+	r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
+	r.Header.Set(common.HeaderRetailerID, "r12345")
+
+	return r
+}
+
+func Test_getSpokeSitesHandler(t *testing.T) {
+	t.Run("Invalid method request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getSpokeSitesRequest("/spokes/p12345/sites")
+		r.Method = http.MethodPost
+		getSpokeSitesHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Passed invalid next page token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getSpokeSitesRequest("/spokes/p12345/sites")
+		r.Header.Set(common.HeaderPageToken, "invalid")
+		getSpokeSitesHandler(w, r, mocks.NewDB(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request validation failed\",\"errors\":[\"Invalid header value, unable to decrypt header : page_token\"]}", string(bytes))
+	})
+
+	t.Run("SpokeID does not exist", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(retailer, nil).Once()
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(nil, status.Error(codes.NotFound, "Spoke ID not found")).Once()
+		w := httptest.NewRecorder()
+		getSpokeSitesHandler(w, getSpokeSitesRequest("/spokes/p12345/sites"), fireStoreClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":404,\"message\":\"Spoke ID p12345 not found\"}", string(bytes))
+	})
+
+	t.Run("DB error while fetching site spoke mapping", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSiteSpokePath("r12345"), mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout")).Once()
+		w := httptest.NewRecorder()
+		getSpokeSitesHandler(w, getSpokeSitesRequest("/spokes/p12345/sites"), fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Site spoke mapping without the site", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		fireStoreClient.On("GetByID", mock.Anything, mock.Anything, mock.Anything, true).Return(retailer, nil)
+		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return([]map[string]interface{}{{"id": "s12345_p12345", "site_id": "s12345", "spoke_id": "p12345"}},
+				nil, nil).Once()
+		w := httptest.NewRecorder()
+		getSpokeSitesHandler(w, getSpokeSitesRequest("/spokes/p12345/sites"), fireStoreClient)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Sites of the spoke listed by name from the site spoke mappings", func(t *testing.T) {
+		repository := getSpokeSitesRepository(t)
+		w := httptest.NewRecorder()
+		r := getSpokeSitesRequest("/spokes/p12345/sites")
+		r.Header.Set(common.HeaderPageSize, "2")
+		getSpokeSitesHandler(w, r, repository)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var spokeSites []sites.Site
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &spokeSites))
+		assert.Equal(t, []string{"s3", "s1"}, []string{spokeSites[0].ID, spokeSites[1].ID})
+		assert.NotEmpty(t, response.Header.Get(common.HeaderNextPageToken))
+
+		w = httptest.NewRecorder()
+		r.Header.Set(common.HeaderPageToken, response.Header.Get(common.HeaderNextPageToken))
+		getSpokeSitesHandler(w, r, repository)
+		response = w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ = io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &spokeSites))
+		assert.Equal(t, 1, len(spokeSites))
+		assert.Equal(t, "s4", spokeSites[0].ID)
+		assert.Empty(t, response.Header.Get(common.HeaderNextPageToken))
+	})
+
+	t.Run("Deactivated sites of the spoke listed with deactivated=true", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		getSpokeSitesHandler(w, getSpokeSitesRequest("/spokes/p12345/sites?deactivated=true"),
+			getSpokeSitesRepository(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		var spokeSites []sites.Site
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Nil(t, json.Unmarshal(bytes, &spokeSites))
+		assert.Equal(t, 4, len(spokeSites))
+	})
+}
+
+// getSpokeSitesRepository returns a repository with the spoke p12345 attached to four sites,
+// one of them deactivated, and another spoke attached to one of the sites
+func getSpokeSitesRepository(t *testing.T) *cloud.MemoryRepository {
+	ctx := context.Background()
+	repository := cloud.NewMemoryRepository()
+	_, err := repository.Save(ctx, common.RetailersCollection, "r12345", map[string]interface{}{
+		"id": "r12345", "deactivated_time": nil})
+	assert.Nil(t, err)
+	_, err = repository.Save(ctx, utils.GetSpokePath("r12345"), "p12345", map[string]interface{}{
+		"id": "p12345", "deactivated_time": nil})
+	assert.Nil(t, err)
+	deactivatedTime := time.Now().UTC()
+	for spokeID, spokeSites := range map[string][]sites.Site{
+		"p12345": {
+			{ID: "s1", Name: "b site", RetailerID: "r12345"},
+			{ID: "s2", Name: "a site", RetailerID: "r12345", DeactivatedTime: &deactivatedTime},
+			{ID: "s3", Name: "a site", RetailerID: "r12345"},
+			{ID: "s4", Name: "c site", RetailerID: "r12345"},
+		},
+		"p67890": {{ID: "s1", Name: "b site", RetailerID: "r12345"}},
+	} {
+		for _, site := range spokeSites {
+			siteSpoke := models.NewSiteSpoke(site, models.Spoke{ID: spokeID, RetailerID: "r12345"}, common.User)
+			_, err = repository.Save(ctx, utils.GetSiteSpokePath("r12345"), siteSpoke.ID, siteSpoke)
+			assert.Nil(t, err)
+		}
+	}
+
+	return repository
+}
@@ -1,11 +1,11 @@
 package spokes
 
 import (
-	"context"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
@@ -20,18 +20,21 @@ import (
 // This file has the function and handler to get list spokes from the DB
 var getSpokesPath = urit.MustCreateTemplate("/spokes")
 
+// getSpokesQuery are the filters and the sort fields accepted as query params by the list of spokes
+var getSpokesQuery = cloud.ListQuery{
+	Filters: append([]cloud.ListFilter{
+		{QueryParam: common.Timezone, Field: common.Timezone, Operator: common.OperatorEquals},
+		{QueryParam: common.QueryParamNamePrefix, Field: common.Name, Operator: common.OperatorPrefix},
+	}, cloud.GetTimeFilters()...),
+	SortFields: []string{common.ID, common.Name, common.CreatedTime, common.UpdatedTime},
+}
+
 func init() {
 	functions.HTTP("GetSpokes", getSpokes)
 }
 
-func getSpokes(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("get_spokes.getSpokes"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	getSpokesHandler(responseWriter, requestWithContext, cloud.NewFirestoreRepository(requestWithContext.Context()))
-}
+var getSpokes = authorization.Authorize("get_spokes.getSpokes",
+	authorization.OnHeaderRetailer(common.RoleReader), getSpokesHandler)
 
 func getSpokesHandler(responseWriter http.ResponseWriter,
 	request *http.Request, dbClient cloud.DB) {
@@ -49,29 +52,45 @@ func getSpokesHandler(responseWriter http.ResponseWriter,
 
 		return
 	}
+
+	listWhere, orderBy, errs := cloud.ParseListQuery(request, getSpokesQuery)
+	near, nearErrs := cloud.ParseNearQuery(request, listWhere)
+	errs = append(errs, nearErrs...)
+	if errs != nil {
+		logger.Debugf("Request query params validation failed. errors : %v", errs)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, "Request validation failed", errs),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
 	//Gets Retailer ID from header Params
 	retailerID := request.Header.Get(common.HeaderRetailerID)
 	//By default, deleted records are omitted from response.
 	skipDeactivated := true
 
-	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, skipDeactivated) {
+	retailerData, found := dbutil.GetRetailerFromDB(responseWriter, request, dbClient, retailerID, logger,
+		skipDeactivated)
+	if !found {
+		return
+	}
+	attributesWhere, valid := attributes.GetFiltersForRequest(responseWriter, request, retailerData)
+	if !valid {
 		return
 	}
 
 	var err error
-	var nextPageToken, startAfterID string
+	var startAfter *cloud.Cursor
+	var nextPage utils.NextPage
 	pageSize := utils.GetPageSizeFromHeader(request, logger)
 
-	if request.Header.Get(common.HeaderPageToken) != "" {
-		startAfterID, err = utils.DecodeNextPageToken(request.Header.Get(common.HeaderPageToken),
-			common.SpokesEncryptionKey)
-		logger.Debugf("ID got after decoding the next page token : %s", startAfterID)
-		if err != nil {
-			logger.Errorf("Error occurred while decoding the next page token : %v", err)
-			response.RespondWithInternalServerError(responseWriter, request)
+	startAfter, err = cloud.DecodeNextPageToken(request, common.SpokesEncryptionKey)
+	if err != nil {
+		logger.Errorf("Error occurred while decoding the next page token : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
 
-			return
-		}
+		return
 	}
 
 	var data []map[string]interface{}
@@ -83,13 +102,13 @@ func getSpokesHandler(responseWriter http.ResponseWriter,
 	if strings.ToLower(request.URL.Query().Get(common.QueryParamDeactivated)) == common.True {
 		where = nil
 	}
-	data, startAfterID, err = dbClient.GetAll(ctx, utils.GetSpokePath(retailerID),
-		cloud.Page{
-			StartAfterID: startAfterID,
-			PageSize:     pageSize,
-			OrderBy:      common.ID,
-			Sort:         common.SortAscending,
-		}, where)
+	where = append(append(where, listWhere...), attributesWhere...)
+	page := cloud.Page{StartAfter: startAfter, PageSize: pageSize, OrderBy: orderBy}
+	if near != nil {
+		data, startAfter, err = cloud.GetAllNear(ctx, dbClient, utils.GetSpokePath(retailerID), *near, page, where)
+	} else {
+		data, startAfter, err = dbClient.GetAll(ctx, utils.GetSpokePath(retailerID), page, where)
+	}
 
 	if err != nil {
 		logger.Errorf("Internal server error while fetching the spokes from DB : %v", err)
@@ -98,10 +117,9 @@ func getSpokesHandler(responseWriter http.ResponseWriter,
 		return
 	}
 
-	if startAfterID != "" && len(data) == pageSize {
-		logger.Debugf("Received startAfterID : %s", startAfterID)
-		nextPageToken, err = utils.GetNextPageToken(startAfterID, common.SpokesEncryptionKey)
-		logger.Debugf("Received nextPageToken : %s", nextPageToken)
+	if startAfter != nil && len(data) == pageSize {
+		nextPage, err = cloud.GetNextPage(request, startAfter)
+		logger.Debugf("Received nextPageToken : %s", nextPage.Token)
 		if err != nil {
 			logger.Errorf("Error occurred while creating the next page token : %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
@@ -111,5 +129,5 @@ func getSpokesHandler(responseWriter http.ResponseWriter,
 	}
 
 	spoke := models.Spoke{}
-	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPageToken, spoke)
+	utils.CreateResponseForGetAllByModel(ctx, responseWriter, request, data, nextPage, spoke)
 }
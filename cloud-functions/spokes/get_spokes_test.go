@@ -25,6 +25,14 @@ func init() {
 	if err != nil {
 		return
 	}
+	err = os.Setenv(common.EnvPageTokenKeys, "test:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		return
+	}
+	err = os.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+	if err != nil {
+		return
+	}
 }
 
 func Test_getSpokes(t *testing.T) {
@@ -79,7 +87,7 @@ func Test_getSpokesHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "2")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -132,7 +140,7 @@ func Test_getSpokesHandler(t *testing.T) {
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderPageSize, "200")
-		token, _ := utils.GetNextPageToken("1234-12334", common.SitesEncryptionKey)
+		token, _ := utils.GetNextPageToken(r, "1234-12334")
 		r.Header.Set(common.HeaderPageToken, token)
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
@@ -185,7 +193,7 @@ func Test_getSpokesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSpokeList(10), "r12345", nil) //returning list equal to page size
+			Return(getSpokeList(10), &cloud.Cursor{ID: "r12345"}, nil) //returning list equal to page size
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -208,7 +216,7 @@ func Test_getSpokesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSpokeList(5), "r12345", nil) //returning list less than page size
+			Return(getSpokeList(5), &cloud.Cursor{ID: "r12345"}, nil) //returning list less than page size
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -231,7 +239,7 @@ func Test_getSpokesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSpokeList(0), "r12345", nil)
+			Return(getSpokeList(0), &cloud.Cursor{ID: "r12345"}, nil)
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -253,7 +261,7 @@ func Test_getSpokesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSpokeList(5), "r12345", nil)
+			Return(getSpokeList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -262,13 +270,13 @@ func Test_getSpokesHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.SitesEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/spokes", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where{{
@@ -276,7 +284,7 @@ func Test_getSpokesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(getSpokeList(5), "r12345", nil)
+			Return(getSpokeList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -285,17 +293,17 @@ func Test_getSpokesHandler(t *testing.T) {
 	})
 
 	t.Run("Passed valid next page token with deactivated=true", func(t *testing.T) {
-		token, _ := utils.GetNextPageToken("r12345", common.SitesEncryptionKey)
 		fireStoreClient := mocks.NewDB(t)
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/spokes?deactivated=true", nil)
 		r.Header.Set(common.HeaderXCorrelationID, "1234")
 		r.Header.Set(common.HeaderRetailerID, "r12345")
 		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		token, _ := utils.GetNextPageToken(r, "r12345")
 		r.Header.Set(common.HeaderPageToken, token)
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where(nil)).
-			Return(getSpokeList(5), "r12345", nil)
+			Return(getSpokeList(5), &cloud.Cursor{ID: "r12345"}, nil)
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -313,7 +321,7 @@ func Test_getSpokesHandler(t *testing.T) {
 		r.Header.Set(common.HeaderPageSize, "10")
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
 		fireStoreClient.On("GetAll", mock.Anything, mock.Anything, mock.Anything, []cloud.Where(nil)).
-			Return(getBadSpoke(), "r12345", nil)
+			Return(getBadSpoke(), &cloud.Cursor{ID: "r12345"}, nil)
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -334,11 +342,77 @@ func Test_getSpokesHandler(t *testing.T) {
 			Operator: common.OperatorEquals,
 			Value:    nil,
 		}}).
-			Return(nil, "", errors.New("connection timeout"))
+			Return(nil, nil, errors.New("connection timeout"))
 		getSpokesHandler(w, r, fireStoreClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
 	})
+
+	t.Run("Filters and sort passed to the DB", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/spokes?timezone=Europe/Berlin&order_by=name&sort=desc", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSpokePath("r12345"),
+			mock.MatchedBy(func(page cloud.Page) bool {
+				return len(page.OrderBy) == 1 && page.OrderBy[0] == cloud.Order{Field: common.Name, Sort: common.SortDescending}
+			}), []cloud.Where{
+				{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil},
+				{Field: common.Timezone, Operator: common.OperatorEquals, Value: "Europe/Berlin"},
+			}).
+			Return(getSpokeList(2), nil, nil)
+		getSpokesHandler(w, r, fireStoreClient)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	})
+
+	t.Run("Near query on the geohash ranges around the point", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/spokes?near=37.7749,-122.4194&radius_km=2", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).Return(retailer, nil)
+		prefixes := utils.GetGeohashPrefixes(37.7749, -122.4194, 2)
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSpokePath("r12345"), mock.Anything,
+			mock.MatchedBy(func(where []cloud.Where) bool {
+				return len(where) == 3 && where[0].Field == common.Geohash && where[2].Field == common.DeactivatedTime
+			})).
+			Return([]map[string]interface{}{{
+				"id":       "p12345",
+				"location": map[string]interface{}{"lat": 37.7849, "long": -122.4194},
+			}}, nil, nil).Once()
+		fireStoreClient.On("GetAll", mock.Anything, utils.GetSpokePath("r12345"), mock.Anything, mock.Anything).
+			Return(nil, nil, nil).Times(len(prefixes) - 1)
+		getSpokesHandler(w, r, fireStoreClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "\"distance_km\":1.112")
+	})
+
+	t.Run("Radius without near rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/spokes?radius_km=2", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSpokesHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Invalid created_before query param", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/spokes?created_before=2022-01-01", nil)
+		r.Header.Set(common.HeaderXCorrelationID, "1234")
+		r.Header.Set(common.HeaderRetailerID, "r12345")
+		r.Header.Set(common.HeaderAcceptVersion, "v1")
+		getSpokesHandler(w, r, mocks.NewDB(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
 }
 
 func getSpokeList(length int) []map[string]interface{} {
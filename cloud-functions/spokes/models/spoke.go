@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/models"
 	"time"
@@ -9,42 +10,55 @@ import (
 
 //nolint:lll
 type Spoke struct {
-	ID              string           `json:"id" validate:"disallowed" firestore:"id" structs:"id"`
-	Name            string           `json:"name" validate:"required,name" firestore:"name" structs:"name"`
-	RetailerID      string           `json:"retailer_id" validate:"disallowed" firestore:"retailer_id" structs:"retailer_id"`
-	Timezone        string           `json:"timezone" validate:"disallowed" firestore:"timezone" structs:"timezone"`
-	Location        *models.Location `json:"location" validate:"required" firestore:"location" structs:"location"`
-	CreatedBy       string           `json:"created_by" validate:"disallowed" firestore:"created_by" structs:"created_by"`
-	UpdatedBy       string           `json:"updated_by" validate:"disallowed" firestore:"updated_by" structs:"updated_by"`
-	DeactivatedBy   string           `json:"deactivated_by,omitempty" validate:"disallowed" firestore:"deactivated_by" structs:"deactivated_by"`
-	CreatedTime     *time.Time       `json:"created_time" validate:"disallowed" firestore:"created_time" structs:"created_time"`
-	UpdatedTime     *time.Time       `json:"updated_time" validate:"disallowed" firestore:"updated_time" structs:"updated_time"`
-	DeactivatedTime *time.Time       `json:"deactivated_time,omitempty" validate:"disallowed" firestore:"deactivated_time" structs:"deactivated_time"`
-	ETag            string           `json:"etag,omitempty" validate:"disallowed" firestore:"-" structs:"-"`
+	ID              string                 `json:"id" validate:"disallowed" firestore:"id" structs:"id"`
+	Name            string                 `json:"name" validate:"required,name" firestore:"name" structs:"name"`
+	RetailerID      string                 `json:"retailer_id" validate:"disallowed" firestore:"retailer_id" structs:"retailer_id"`
+	Timezone        string                 `json:"timezone" validate:"disallowed" firestore:"timezone" structs:"timezone"`
+	Location        *models.Location       `json:"location" validate:"required_without=Address" firestore:"location" structs:"location"`
+	Address         *models.Address        `json:"address,omitempty" firestore:"address,omitempty" structs:"address,omitempty"`
+	Geohash         string                 `json:"geohash,omitempty" validate:"disallowed" firestore:"geohash,omitempty" structs:"geohash,omitempty"`
+	DistanceKm      *float64               `json:"distance_km,omitempty" validate:"disallowed" firestore:"-" structs:"-"`
+	ServiceArea     *models.ServiceArea    `json:"service_area,omitempty" firestore:"service_area,omitempty" structs:"service_area,omitempty"`
+	OperatingHours  *models.OperatingHours `json:"operating_hours,omitempty" firestore:"operating_hours,omitempty" structs:"operating_hours,omitempty"`
+	Attributes      map[string]interface{} `json:"attributes,omitempty" firestore:"attributes,omitempty" structs:"attributes,omitempty"`
+	CreatedBy       string                 `json:"created_by" validate:"disallowed" firestore:"created_by" structs:"created_by"`
+	UpdatedBy       string                 `json:"updated_by" validate:"disallowed" firestore:"updated_by" structs:"updated_by"`
+	DeactivatedBy   string                 `json:"deactivated_by,omitempty" validate:"disallowed" firestore:"deactivated_by" structs:"deactivated_by"`
+	CreatedTime     *time.Time             `json:"created_time" validate:"disallowed" firestore:"created_time" structs:"created_time"`
+	UpdatedTime     *time.Time             `json:"updated_time" validate:"disallowed" firestore:"updated_time" structs:"updated_time"`
+	DeactivatedTime *time.Time             `json:"deactivated_time,omitempty" validate:"disallowed" firestore:"deactivated_time" structs:"deactivated_time"`
+	Revision        int64                  `json:"revision,omitempty" validate:"disallowed" firestore:"revision,omitempty" structs:"revision,omitempty"`
+	ETag            string                 `json:"etag,omitempty" validate:"disallowed" firestore:"-" structs:"-"`
 }
 
 // SiteSpoke site spoke struct
+// Site and Spoke are copies of the site and the spoke kept in sync on their updates,
+// so the spokes of a site and the sites of a spoke are listed with a single query
 //
 //nolint:lll
 type SiteSpoke struct {
-	ID          string     `json:"id" validate:"disallowed" firestore:"id" structs:"id"`
-	SiteID      string     `json:"site_id" validate:"disallowed" firestore:"site_id" structs:"site_id"`
-	SpokeID     string     `json:"spoke_id" validate:"disallowed" firestore:"spoke_id" structs:"spoke_id"`
-	RetailerID  string     `json:"retailer_id" validate:"disallowed" firestore:"retailer_id" structs:"retailer_id"`
-	CreatedBy   string     `json:"created_by" validate:"disallowed" firestore:"created_by" structs:"created_by"`
-	CreatedTime *time.Time `json:"created_time" validate:"disallowed" firestore:"created_time" structs:"created_time"`
+	ID          string      `json:"id" validate:"disallowed" firestore:"id" structs:"id"`
+	SiteID      string      `json:"site_id" validate:"disallowed" firestore:"site_id" structs:"site_id"`
+	SpokeID     string      `json:"spoke_id" validate:"disallowed" firestore:"spoke_id" structs:"spoke_id"`
+	RetailerID  string      `json:"retailer_id" validate:"disallowed" firestore:"retailer_id" structs:"retailer_id"`
+	CreatedBy   string      `json:"created_by" validate:"disallowed" firestore:"created_by" structs:"created_by"`
+	CreatedTime *time.Time  `json:"created_time" validate:"disallowed" firestore:"created_time" structs:"created_time"`
+	Site        *sites.Site `json:"site,omitempty" validate:"disallowed" firestore:"site,omitempty" structs:"-"`
+	Spoke       *Spoke      `json:"spoke,omitempty" validate:"disallowed" firestore:"spoke,omitempty" structs:"-"`
 }
 
-func NewSiteSpoke(siteID string, spokeID string, retailerID string, createdBy string) SiteSpoke {
+func NewSiteSpoke(site sites.Site, spoke Spoke, createdBy string) SiteSpoke {
 	currentTime := time.Now().UTC().Round(time.Second)
 
 	return SiteSpoke{
-		ID:          GetSiteSpokeID(siteID, spokeID),
-		SiteID:      siteID,
-		SpokeID:     spokeID,
-		RetailerID:  retailerID,
+		ID:          GetSiteSpokeID(site.ID, spoke.ID),
+		SiteID:      site.ID,
+		SpokeID:     spoke.ID,
+		RetailerID:  spoke.RetailerID,
 		CreatedBy:   createdBy,
 		CreatedTime: &currentTime,
+		Site:        &site,
+		Spoke:       &spoke,
 	}
 }
 
@@ -0,0 +1,340 @@
+package spokes
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	spokeCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/common"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/geocoding"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/timezone"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/fatih/structs"
+	"github.com/go-andiamo/urit"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+)
+
+// This file has the function and handler to update the name and location of a spoke
+var patchSpokePath = urit.MustCreateTemplate(fmt.Sprintf("/spokes/{%s}", common.PathParamSpokeID))
+
+func init() {
+	functions.HTTP("PatchSpoke", patchSpoke)
+}
+
+var patchSpoke = authorization.Authorize("patch_spoke.patchSpoke",
+	authorization.OnHeaderRetailer(common.RoleEditor),
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		patchSpokeHandler(responseWriter, request, dbClient, cloud.NewPubSubRepository(request.Context()))
+	})
+
+func patchSpokeHandler(responseWriter http.ResponseWriter, request *http.Request,
+	dbClient cloud.DB, pubsubClient cloud.Queue) {
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("patch_spoke.patchSpokeHandler"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	var spoke models.Spoke
+
+	pathParams, validationResponse := utils.ValidateRequest(request, utils.RequestValidation{
+		RequiredHeaders: append(models.GetRequiredHeaders(), common.HeaderIfMatch),
+		RequiredPath:    patchSpokePath,
+		RequestMethod:   http.MethodPatch,
+		RequestBodyValidation: &utils.RequestBodyValidation{
+			Entity:             &spoke,
+			CompleteValidation: false,
+		},
+	})
+	if validationResponse != nil {
+		logger.Debugf("Request validation failed. validationResponse : %v", validationResponse)
+		response.RespondWithResponseObject(responseWriter, validationResponse, response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	retailerID := request.Header.Get(common.HeaderRetailerID)
+	spokeID := pathParams[common.PathParamSpokeID]
+
+	retailerData, found := dbutil.GetRetailerFromDB(responseWriter, request, dbClient, retailerID, logger, true)
+	if !found {
+		return
+	}
+
+	oldSpokeDataMap := spokeCommon.GetSpokeFromDB(responseWriter, request, logger, dbClient, retailerID, spokeID, true)
+	if oldSpokeDataMap == nil {
+		return
+	}
+
+	if !utils.IsValidEtagPresentInHeader(responseWriter, request, oldSpokeDataMap, logger) {
+		return
+	}
+
+	var oldSpokeData models.Spoke
+	err := utils.ConvertToObject(oldSpokeDataMap, &oldSpokeData)
+	if err != nil {
+		logger.Errorf("Error while unmarshalling data from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	newSpokeData, err := checkSpokeNameForUpdate(ctx, responseWriter, request, spoke, oldSpokeData, dbClient)
+	if err != nil {
+		return
+	}
+
+	newSpokeData, isTimezoneChanged, err := checkSpokeLocationForUpdate(ctx, responseWriter, request, spoke,
+		newSpokeData)
+	if err != nil {
+		return
+	}
+	if spoke.ServiceArea != nil {
+		// an empty service area removes the service area of the spoke
+		newSpokeData.ServiceArea = utils.NewServiceArea(spoke.ServiceArea)
+	}
+	if spoke.OperatingHours != nil {
+		// empty operating hours remove the operating hours of the spoke
+		newSpokeData.OperatingHours = utils.NewOperatingHours(spoke.OperatingHours)
+	}
+	newSpokeData, err = checkSpokeAttributesForUpdate(responseWriter, request, retailerData, spoke, newSpokeData)
+	if err != nil {
+		return
+	}
+
+	if reflect.DeepEqual(newSpokeData, oldSpokeData) {
+		logger.Debugf("spoke object not changed \nold object: %v \nnew object: %v", oldSpokeData, newSpokeData)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity, "No changes detected", nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	updatedTime := time.Now().UTC().Round(time.Second)
+	newSpokeData.UpdatedBy = identity.GetPrincipalFromContext(ctx)
+	newSpokeData.UpdatedTime = &updatedTime
+	newSpokeData.Revision++
+	events, err := getPatchSpokeEvents(request, oldSpokeData, newSpokeData)
+	if err != nil {
+		logger.Errorf("Error while creating the spoke events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	spokeUpdates := createSpokeDocForUpdate(newSpokeData, oldSpokeData)
+	writeOptions := getSpokeNameWriteOptions(newSpokeData, oldSpokeData)
+	writeOptions.Creates = outbox.Documents(events)
+	// the spoke is not updated if it changed since it was read
+	writeOptions.Revision = &oldSpokeData.Revision
+	updateTime, err := dbClient.UpdateInTransaction(ctx, utils.GetSpokePath(retailerID), spokeID, spokeUpdates,
+		writeOptions)
+	var uniqueKeyError *cloud.UniqueKeyError
+	if errors.As(err, &uniqueKeyError) {
+		logger.Debugf("Spoke name %s reserved by another spoke", newSpokeData.Name)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Spoke with name : %s already exists", newSpokeData.Name), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Spoke %s changed since it was read : %v", spokeID, err)
+		response.RespondWithPreconditionFailed(responseWriter, request)
+
+		return
+	}
+	if err != nil {
+		logger.Errorf("Error while updating spoke in DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	sendPatchSpokeResponse(ctx, responseWriter, request, newSpokeData, updateTime, isTimezoneChanged)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
+}
+
+// checkSpokeNameForUpdate returns the spoke with the requested name if it is not taken by another spoke
+func checkSpokeNameForUpdate(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	spoke, oldSpokeData models.Spoke, dbClient cloud.DB) (models.Spoke, error) {
+	logger := logging.GetLoggerFromContext(ctx)
+	newSpokeData := oldSpokeData
+	if spoke.Name == "" || spoke.Name == oldSpokeData.Name {
+		return newSpokeData, nil
+	}
+	spokeNameExists, err := dbClient.Exists(ctx, utils.GetSpokePath(oldSpokeData.RetailerID), common.Name, spoke.Name)
+	if err != nil {
+		logger.Errorf("Error occurred while checking existence of spoke in DB: %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return newSpokeData, errors.New("error occurred while checking existence of spoke in DB")
+	}
+	if spokeNameExists {
+		logger.Debugf("Spoke with name %s already exists", spoke.Name)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Spoke with name : %s already exists", spoke.Name), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return newSpokeData, errors.New("spoke with name already exists")
+	}
+	newSpokeData.Name = spoke.Name
+
+	return newSpokeData, nil
+}
+
+// checkSpokeAttributesForUpdate returns the spoke with the requested attributes merged into its attributes, a null
+// attribute removing the attribute, after validating them against the attribute schema of the retailer
+func checkSpokeAttributesForUpdate(responseWriter http.ResponseWriter, request *http.Request,
+	retailerData map[string]interface{}, spoke, newSpokeData models.Spoke) (models.Spoke, error) {
+	if spoke.Attributes == nil {
+		return newSpokeData, nil
+	}
+	newSpoke := newSpokeData
+	var valid bool
+	newSpoke.Attributes, valid = attributes.GetAttributesForRequest(responseWriter, request, retailerData,
+		attributes.Merge(newSpokeData.Attributes, spoke.Attributes))
+	if !valid {
+		return newSpokeData, errors.New("invalid attributes")
+	}
+
+	return newSpoke, nil
+}
+
+// checkSpokeLocationForUpdate returns the spoke with the requested address, the requested location or the one
+// geocoded from the address, its geohash and the timezone resolved for it, along with whether the timezone was resolved
+func checkSpokeLocationForUpdate(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	spoke, newSpokeData models.Spoke) (models.Spoke, bool, error) {
+	newSpoke := newSpokeData
+	if spoke.Address != nil {
+		// an empty address removes the address of the spoke
+		newSpoke.Address = utils.NewAddress(spoke.Address)
+	}
+	if spoke.Address != nil || spoke.Location != nil {
+		// the requested location is checked against the address, or else geocoded from the new address
+		location, resolved := geocoding.GetLocationForRequest(ctx, responseWriter, request, newSpoke.Address,
+			spoke.Location)
+		if !resolved {
+			return newSpoke, false, errors.New("location not resolved")
+		}
+		spoke.Location = location
+	}
+	if !spoke.IsValidLocationData() || reflect.DeepEqual(newSpoke.Location, spoke.Location) {
+		return newSpoke, false, nil
+	}
+	newSpoke.Location = spoke.Location
+	newSpoke.Geohash = utils.GetGeohash(*spoke.Location.Latitude, *spoke.Location.Longitude, common.GeohashPrecision)
+	spokeTimezone, resolved := timezone.GetTimezoneForRequest(ctx, responseWriter, request,
+		*spoke.Location.Latitude, *spoke.Location.Longitude)
+	if !resolved {
+		return newSpoke, false, errors.New("timezone not resolved")
+	}
+	newSpoke.Timezone = spokeTimezone
+
+	return newSpoke, true, nil
+}
+
+// getPatchSpokeEvents returns the audit, spoke change and site spoke copy events to be written along with the
+// spoke update
+func getPatchSpokeEvents(request *http.Request,
+	oldSpokeData models.Spoke, newSpokeData models.Spoke) ([]outbox.Event, error) {
+	return outbox.NewEvents(
+		outbox.Message{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetSpokeAuditPath(newSpokeData.RetailerID, newSpokeData.ID),
+				request.Header.Get(common.HeaderXCorrelationID), newSpokeData.UpdatedBy,
+				common.AuditTypeUpdate,
+				common.EntitySpoke,
+				newSpokeData.UpdatedTime,
+				structs.Map(oldSpokeData),
+				structs.Map(newSpokeData),
+			),
+		},
+		outbox.Message{
+			Topic: os.Getenv(common.EnvSpokeMessageTopic),
+			Data: models.GetPubSubSpokeMessage(newSpokeData.RetailerID, "", newSpokeData.ID, "",
+				common.ChangeTypeUpdate),
+		},
+		dbutil.GetSiteSpokeCopyMessage(newSpokeData.RetailerID, common.EntitySpoke, newSpokeData.ID))
+}
+
+// getSpokeNameWriteOptions reserves the new name of the spoke and releases the old one when the name is changed
+func getSpokeNameWriteOptions(spoke models.Spoke, oldData models.Spoke) cloud.WriteOptions {
+	if spoke.Name == oldData.Name {
+		return cloud.WriteOptions{}
+	}
+
+	return cloud.WriteOptions{
+		ReserveKeys: []cloud.UniqueKey{cloud.NewUniqueKey(utils.GetSpokePath(spoke.RetailerID), common.Name, spoke.Name)},
+		ReleaseKeys: []cloud.UniqueKey{
+			cloud.NewUniqueKey(utils.GetSpokePath(spoke.RetailerID), common.Name, oldData.Name),
+		},
+	}
+}
+
+func createSpokeDocForUpdate(spoke models.Spoke, oldData models.Spoke) []firestore.Update {
+	var docForUpdate []firestore.Update
+	if spoke.Name != oldData.Name {
+		docForUpdate = append(docForUpdate, firestore.Update{Path: "name", Value: spoke.Name})
+	}
+	if !reflect.DeepEqual(spoke.Location, oldData.Location) {
+		docForUpdate = append(docForUpdate, firestore.Update{Path: "location", Value: spoke.Location})
+		docForUpdate = append(docForUpdate, firestore.Update{Path: "timezone", Value: spoke.Timezone})
+		docForUpdate = append(docForUpdate, firestore.Update{Path: common.Geohash, Value: spoke.Geohash})
+	}
+	if !reflect.DeepEqual(spoke.Address, oldData.Address) {
+		docForUpdate = append(docForUpdate, dbutil.GetAddressUpdate(spoke.Address))
+	}
+	if !reflect.DeepEqual(spoke.ServiceArea, oldData.ServiceArea) {
+		docForUpdate = append(docForUpdate, dbutil.GetServiceAreaUpdate(spoke.ServiceArea))
+	}
+	if !reflect.DeepEqual(spoke.OperatingHours, oldData.OperatingHours) {
+		docForUpdate = append(docForUpdate, dbutil.GetOperatingHoursUpdate(spoke.OperatingHours))
+	}
+	if !reflect.DeepEqual(spoke.Attributes, oldData.Attributes) {
+		docForUpdate = append(docForUpdate, dbutil.GetAttributesUpdate(spoke.Attributes))
+	}
+	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_time", Value: spoke.UpdatedTime})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: "updated_by", Value: spoke.UpdatedBy})
+	docForUpdate = append(docForUpdate, firestore.Update{Path: common.Revision, Value: spoke.Revision})
+
+	return docForUpdate
+}
+
+func sendPatchSpokeResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	spokeData models.Spoke, updateTime time.Time, isTimezoneChanged bool) {
+	logger := logging.GetLoggerFromContext(ctx)
+	etag, err := utils.GetETag(spokeData)
+	if err != nil {
+		logger.Errorf("Error while getting etag for spoke struct object : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	responseHeaders := response.GetCommonResponseHeaders(request)
+	if isTimezoneChanged {
+		responseHeaders.WithHeader(common.HeaderTimezone, spokeData.Timezone)
+	}
+	response.Respond(responseWriter, http.StatusOK, spokeData,
+		responseHeaders.WithHeader(common.HeaderLastModified, updateTime.Format(time.RFC3339)).
+			WithHeader(common.HeaderEtag, etag))
+
+	logger.Debugf("Spoke ID : %s updated successfully.", spokeData.ID)
+}
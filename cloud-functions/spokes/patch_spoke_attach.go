@@ -1,19 +1,28 @@
 package spokes
 
 import (
+	"cloud.google.com/go/firestore"
 	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
+	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokeCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net/http"
 	"os"
 	"time"
@@ -26,16 +35,17 @@ func init() {
 	functions.HTTP("PatchSpokeAttach", patchSpokeAttach)
 }
 
-func patchSpokeAttach(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("patch_spoke_attach.patchSpokeAttach"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	patchSpokeAttachHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()),
-		cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var patchSpokeAttachPermission = authorization.OnHeaderRetailer(common.RoleEditor)
+
+var patchSpokeAttach = authorization.Authorize("patch_spoke_attach.patchSpokeAttach",
+	patchSpokeAttachPermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, patchSpokeAttachPermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				patchSpokeAttachHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
 
 func patchSpokeAttachHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -63,11 +73,29 @@ func patchSpokeAttachHandler(responseWriter http.ResponseWriter, request *http.R
 		return
 	}
 
-	if !dbutil.IsSiteIDPresentInDB(responseWriter, request, dbClient, retailerID, siteID, logger, true) {
+	siteData := siteCommon.GetSiteFromDB(responseWriter, request, logger, dbClient, retailerID, siteID, true)
+	if siteData == nil {
+		return
+	}
+	var site sites.Site
+	err = utils.ConvertToObject(siteData, &site)
+	if err != nil {
+		logger.Errorf("Error while unmarshalling data from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	spokeData := spokeCommon.GetSpokeFromDB(responseWriter, request, logger, dbClient, retailerID, spokeID, true)
+	if spokeData == nil {
 		return
 	}
+	var spoke models.Spoke
+	err = utils.ConvertToObject(spokeData, &spoke)
+	if err != nil {
+		logger.Errorf("Error while unmarshalling data from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
 
-	if !dbutil.IsSpokeIDPresentInDB(responseWriter, request, dbClient, retailerID, spokeID, logger, true) {
 		return
 	}
 
@@ -88,9 +116,52 @@ func patchSpokeAttachHandler(responseWriter http.ResponseWriter, request *http.R
 		return
 	}
 
-	siteSpoke := models.NewSiteSpoke(siteID, spokeID, retailerID, common.User)
+	revision := spoke.Revision
+	attachedTime := time.Now().UTC().Round(time.Second)
+	spoke.UpdatedTime = &attachedTime
+	spoke.Revision++
+	siteSpoke := models.NewSiteSpoke(site, spoke, identity.GetPrincipalFromContext(ctx))
+
+	events, err := outbox.NewEvents(append(spokeCommon.GetSiteSpokeAuditMessages(request, common.AuditTypeAttach,
+		siteSpoke, siteSpoke.CreatedBy, siteSpoke.CreatedTime), outbox.Message{
+		Topic: os.Getenv(common.EnvSpokeMessageTopic),
+		Data: models.GetPubSubSpokeMessage(siteSpoke.RetailerID, siteSpoke.SiteID, siteSpoke.SpokeID,
+			siteSpoke.ID, common.ChangeTypeUpdate),
+	})...)
+	if err != nil {
+		logger.Errorf("Error while creating the spoke events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	// the attach bumps the revision of the spoke along with its updated time, so a concurrent delete of the spoke
+	// or the attach fails. The site is only checked, so the mapping is not created with a copy of the site older
+	// than the one copied onto its other mappings
+	updateTime, err := dbClient.SaveInTransaction(ctx, utils.GetSiteSpokePath(retailerID), siteSpoke.ID, siteSpoke,
+		cloud.WriteOptions{Creates: outbox.Documents(events), Updates: []cloud.DocumentUpdate{{
+			CollectionPath: utils.GetSpokePath(retailerID),
+			DocumentID:     spokeID,
+			Updates: []firestore.Update{
+				{Path: "updated_time", Value: spoke.UpdatedTime},
+				{Path: common.Revision, Value: spoke.Revision},
+			},
+			Revision: &revision,
+		}, {
+			CollectionPath: utils.GetSitePath(retailerID),
+			DocumentID:     siteID,
+			Revision:       &site.Revision,
+		}}})
+	if status.Code(err) == codes.FailedPrecondition {
+		logger.Debugf("Site %s or spoke %s changed while attaching them : %v", siteID, spokeID, err)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusConflict,
+				fmt.Sprintf("Spoke %s or site %s changed while attaching them, please try again", spokeID, siteID),
+				nil),
+			response.GetCommonResponseHeaders(request))
 
-	updateTime, err := dbClient.Save(ctx, utils.GetSiteSpokePath(retailerID), siteSpoke.ID, siteSpoke)
+		return
+	}
 	if err != nil {
 		logger.Errorf("Error while attaching site and spoke from DB : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
@@ -98,11 +169,12 @@ func patchSpokeAttachHandler(responseWriter http.ResponseWriter, request *http.R
 		return
 	}
 
-	sendPatchSpokeAttachResponse(ctx, responseWriter, request, pubsubClient, siteSpoke, updateTime)
+	sendPatchSpokeAttachResponse(ctx, responseWriter, request, siteSpoke, updateTime)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
 }
 
 func sendPatchSpokeAttachResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	pubsubClient cloud.Queue, siteSpoke models.SiteSpoke, updateTime time.Time) {
+	siteSpoke models.SiteSpoke, updateTime time.Time) {
 	logger := logging.GetLoggerFromContext(ctx)
 
 	response.Respond(responseWriter, http.StatusOK,
@@ -112,8 +184,4 @@ func sendPatchSpokeAttachResponse(ctx context.Context, responseWriter http.Respo
 			WithHeader(common.HeaderLastModified, updateTime.Format(time.RFC3339)))
 
 	logger.Debugf("Spoke %s attached successfully to site %s", siteSpoke.SpokeID, siteSpoke.SiteID)
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvSpokeMessageTopic),
-		models.GetPubSubSpokeMessage(siteSpoke.RetailerID, siteSpoke.SiteID, siteSpoke.SpokeID,
-			models.GetSiteSpokeID(siteSpoke.SiteID, siteSpoke.SpokeID), common.ChangeTypeUpdate))
 }
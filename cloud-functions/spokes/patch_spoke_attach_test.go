@@ -3,7 +3,9 @@ package spokes
 import (
 	"errors"
 	"fmt"
+	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
 	"github.com/stretchr/testify/assert"
@@ -206,7 +208,7 @@ func Test_patchSpokeAttachHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mock.Anything, true).Return(site, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, true).Return(spoke, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(false, nil)
-		fireStoreClient.On("Save", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(time.Time{}, status.Error(codes.NotFound, fmt.Sprintf("Spoke ID %s is not attached to site %s", mockedSpokeID, mockedSiteID)))
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything, mock.Anything).Return(time.Time{}, status.Error(codes.NotFound, fmt.Sprintf("Spoke ID %s is not attached to site %s", mockedSpokeID, mockedSiteID)))
 		patchSpokeAttachHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -214,6 +216,25 @@ func Test_patchSpokeAttachHandler(t *testing.T) {
 		assert.Equal(t, "{\"code\":500,\"message\":\"Internal server error occurred. Please check logs for more details.\"}", string(bytes))
 	})
 
+	t.Run("Spoke changed while attaching it", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		mockedRetailerID := "r" + utils.GetRandomID(4)
+		mockedSiteID := "s" + utils.GetRandomID(4)
+		mockedSpokeID := "p" + utils.GetRandomID(4)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/sites/%s/spokes/%s:attach", mockedSiteID, mockedSpokeID), nil)
+		r.Header.Set(common.HeaderXCorrelationID, "123")
+		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
+		r.Header.Set(common.HeaderRetailerID, mockedRetailerID)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mockedRetailerID, true).Return(map[string]interface{}{}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mock.Anything, true).Return(map[string]interface{}{}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, true).Return(map[string]interface{}{}, nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(false, nil)
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything, mock.Anything).Return(time.Time{}, status.Error(codes.FailedPrecondition, "revision mismatch"))
+		patchSpokeAttachHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	})
+
 	t.Run("Site Spoke attached successful", func(t *testing.T) {
 		pubSubClient := mocks.NewQueue(t)
 		fireStoreClient := mocks.NewDB(t)
@@ -226,14 +247,26 @@ func Test_patchSpokeAttachHandler(t *testing.T) {
 		r.Header.Set(common.HeaderAcceptVersion, common.APIVersionV1)
 		r.Header.Set(common.HeaderRetailerID, mockedRetailerID)
 		retailer := map[string]interface{}{}
-		site := map[string]interface{}{}
-		spoke := map[string]interface{}{}
+		site := map[string]interface{}{"id": mockedSiteID, "name": "site 1", "retailer_id": mockedRetailerID}
+		spoke := map[string]interface{}{"id": mockedSpokeID, "name": "spoke 1", "retailer_id": mockedRetailerID}
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mockedRetailerID, true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mock.Anything, true).Return(site, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, true).Return(spoke, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(false, nil)
-		fireStoreClient.On("Save", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(time.Now(), nil)
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
+		// the attach is audited for both the site and the spoke along with the spoke message
+		// the site spoke mapping keeps a copy of the site and the spoke
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything,
+			mock.MatchedBy(func(siteSpoke models.SiteSpoke) bool {
+				return siteSpoke.Site != nil && siteSpoke.Site.Name == "site 1" &&
+					siteSpoke.Spoke != nil && siteSpoke.Spoke.Name == "spoke 1"
+			}),
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return len(options.Creates) == 3 && len(options.Updates) == 2 && options.Updates[1].Revision != nil &&
+					len(options.Updates[0].Updates) == 2 && options.Updates[0].Updates[0].Path == "updated_time" &&
+					options.Updates[0].Updates[1].Value == int64(1)
+			})).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSpokeAttachHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
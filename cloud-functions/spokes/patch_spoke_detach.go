@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
 	spokesCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/go-andiamo/urit"
@@ -27,16 +30,17 @@ func init() {
 	functions.HTTP("PatchSpokeDetach", patchSpokeDetach)
 }
 
-func patchSpokeDetach(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("patch_spoke_detach.patchSpokeDetach"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	patchSpokeDetachHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()),
-		cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var patchSpokeDetachPermission = authorization.OnHeaderRetailer(common.RoleEditor)
+
+var patchSpokeDetach = authorization.Authorize("patch_spoke_detach.patchSpokeDetach",
+	patchSpokeDetachPermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, patchSpokeDetachPermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				patchSpokeDetachHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
 
 func patchSpokeDetachHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -72,7 +76,27 @@ func patchSpokeDetachHandler(responseWriter http.ResponseWriter, request *http.R
 		return
 	}
 
-	_, err = dbClient.Delete(ctx, utils.GetSiteSpokePath(retailerID), models.GetSiteSpokeID(siteID, spokeID))
+	detachedTime := time.Now().UTC().Round(time.Second)
+	siteSpoke := models.SiteSpoke{
+		ID:         models.GetSiteSpokeID(siteID, spokeID),
+		SiteID:     siteID,
+		SpokeID:    spokeID,
+		RetailerID: retailerID,
+	}
+	events, err := outbox.NewEvents(append(spokesCommon.GetSiteSpokeAuditMessages(request, common.AuditTypeDetach,
+		siteSpoke, identity.GetPrincipalFromContext(ctx), &detachedTime), outbox.Message{
+		Topic: os.Getenv(common.EnvSpokeMessageTopic),
+		Data:  models.GetPubSubSpokeMessage(retailerID, siteID, spokeID, siteSpoke.ID, common.ChangeTypeUpdate),
+	})...)
+	if err != nil {
+		logger.Errorf("Error while creating the spoke events : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+
+	_, err = dbClient.DeleteInTransaction(ctx, utils.GetSiteSpokePath(retailerID), siteSpoke.ID,
+		cloud.WriteOptions{Creates: outbox.Documents(events)})
 	if err != nil {
 		logger.Errorf("Error while detaching site and spoke from DB : %v", err)
 		response.RespondWithInternalServerError(responseWriter, request)
@@ -80,11 +104,12 @@ func patchSpokeDetachHandler(responseWriter http.ResponseWriter, request *http.R
 		return
 	}
 
-	sendPatchSpokeDetachResponse(ctx, responseWriter, request, pubsubClient, spokeID, siteID, retailerID)
+	sendPatchSpokeDetachResponse(ctx, responseWriter, request, spokeID, siteID)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
 }
 
 func sendPatchSpokeDetachResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	pubsubClient cloud.Queue, spokeID string, siteID string, retailerID string) {
+	spokeID string, siteID string) {
 	logger := logging.GetLoggerFromContext(ctx)
 
 	response.Respond(responseWriter, http.StatusOK,
@@ -94,8 +119,4 @@ func sendPatchSpokeDetachResponse(ctx context.Context, responseWriter http.Respo
 			WithHeader(common.HeaderLastModified, time.Now().Format(time.RFC3339)))
 
 	logger.Debugf("Spoke %s detached successfully from site %s", spokeID, siteID)
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvSpokeMessageTopic),
-		models.GetPubSubSpokeMessage(retailerID, siteID, spokeID,
-			models.GetSiteSpokeID(siteID, spokeID), common.ChangeTypeUpdate))
 }
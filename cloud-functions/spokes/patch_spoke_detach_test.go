@@ -4,6 +4,7 @@ import (
 	_ "errors"
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	_ "github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
@@ -17,7 +18,6 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
-	_ "time"
 )
 
 func init() {
@@ -154,7 +154,7 @@ func Test_patchSpokeDetachHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mockedRetailerID, true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mock.Anything, true).Return(site, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, false).Return(map[string]interface{}{}, nil)
-		fireStoreClient.On("Delete", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything).Return(false, status.Error(codes.NotFound, fmt.Sprintf("Spoke ID %s is not attached to site %s", mockedSpokeID, mockedSiteID)))
+		fireStoreClient.On("DeleteInTransaction", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(false, status.Error(codes.NotFound, fmt.Sprintf("Spoke ID %s is not attached to site %s", mockedSpokeID, mockedSiteID)))
 		patchSpokeDetachHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -178,8 +178,11 @@ func Test_patchSpokeDetachHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, mockedRetailerID, true).Return(retailer, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mock.Anything, true).Return(site, nil)
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, false).Return(map[string]interface{}{}, nil)
-		fireStoreClient.On("Delete", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything).Return(true, nil)
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
+		// the detach is audited for both the site and the spoke along with the spoke message
+		fireStoreClient.On("DeleteInTransaction", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool { return len(options.Creates) == 3 })).Return(true, nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		patchSpokeDetachHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusOK, response.StatusCode)
@@ -0,0 +1,380 @@
+package spokes
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getStoredSpoke() map[string]interface{} {
+	return map[string]interface{}{
+		"id":          "p12345",
+		"name":        "SpokeName",
+		"retailer_id": "r12345",
+		"timezone":    "Asia/Kolkata",
+		"location": map[string]interface{}{
+			"lat":  18.5204,
+			"long": 73.8567,
+		},
+		"created_by":   common.User,
+		"updated_by":   common.User,
+		"created_time": "2022-10-28T07:33:05Z",
+		"updated_time": "2022-10-28T07:33:05Z",
+	}
+}
+
+func getSpokeRequest(method string, body string, spokeData map[string]interface{}) *http.Request {
+	r := getRequest(method, "/spokes/p12345", body, common.HeaderXCorrelationID, common.HeaderAcceptVersion)
+	r.Header.Set(common.HeaderRetailerID, "r12345")
+	etag, _ := utils.GetETag(spokeData)
+	r.Header.Set(common.HeaderIfMatch, etag)
+
+	return r
+}
+
+func Test_patchSpokeHandler(t *testing.T) {
+	t.Run("Invalid request method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPut, "{\"name\":\"NewSpokeName\"}", getStoredSpoke())
+		patchSpokeHandler(w, r, mocks.NewDB(t), mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Disallowed field in the request body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"timezone\":\"Europe/Berlin\"}", getStoredSpoke())
+		patchSpokeHandler(w, r, mocks.NewDB(t), mocks.NewQueue(t))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("Spoke ID does not exist", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"name\":\"NewSpokeName\"}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(nil, status.Error(codes.NotFound, "not found"))
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":404,\"message\":\"Spoke ID p12345 not found\"}", string(bytes))
+	})
+
+	t.Run("ETag mismatch", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"name\":\"NewSpokeName\"}", getStoredSpoke())
+		r.Header.Set(common.HeaderIfMatch, "outdated etag")
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	})
+
+	t.Run("Spoke name already exists", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"name\":\"NewSpokeName\"}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath("r12345"), common.Name, "NewSpokeName").
+			Return(true, nil)
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":422,\"message\":\"Spoke with name : NewSpokeName already exists\"}", string(bytes))
+	})
+
+	t.Run("Spoke name taken concurrently", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"name\":\"NewSpokeName\"}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath("r12345"), common.Name, "NewSpokeName").
+			Return(false, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345",
+			mock.Anything, mock.Anything).
+			Return(time.Time{}, &cloud.UniqueKeyError{
+				Key: cloud.NewUniqueKey(utils.GetSpokePath("r12345"), common.Name, "NewSpokeName"),
+			})
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+	})
+
+	t.Run("No changes detected", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"name\":\"SpokeName\"}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":422,\"message\":\"No changes detected\"}", string(bytes))
+	})
+
+	t.Run("Error while updating the DB", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"name\":\"NewSpokeName\"}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath("r12345"), common.Name, "NewSpokeName").
+			Return(false, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345",
+			mock.Anything, mock.Anything).Return(time.Time{}, errors.New("update failed"))
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Successful rename", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"name\":\"NewSpokeName\"}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath("r12345"), common.Name, "NewSpokeName").
+			Return(false, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				// the spoke is copied onto its site spokes by the site spoke copy event
+				return len(options.ReserveKeys) == 1 && len(options.ReleaseKeys) == 1 && len(options.Creates) == 3 &&
+					len(options.Updates) == 0
+			})).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		patchSpokeHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.NotEmpty(t, response.Header.Get(common.HeaderEtag))
+		assert.Empty(t, response.Header.Get(common.HeaderTimezone))
+	})
+
+	t.Run("Successful relocation with timezone re-resolved", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{
+				DstOffset:    0,
+				RawOffset:    3600,
+				Status:       "OK",
+				TimezoneID:   "Europe/Berlin",
+				TimezoneName: "Central European Standard Time",
+			})
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"location\":{\"lat\":52.52,\"long\":13.405}}",
+			getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345", mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return len(options.ReserveKeys) == 0 && len(options.Creates) == 3
+			})).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		patchSpokeHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "Europe/Berlin", response.Header.Get(common.HeaderTimezone))
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), fmt.Sprintf("\"timezone\":\"%s\"", "Europe/Berlin"))
+	})
+	t.Run("Successful removal of the service area", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		storedSpoke := getStoredSpoke()
+		storedSpoke[common.ServiceArea] = map[string]interface{}{"postal_codes": []interface{}{"10001"}}
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"service_area\":{}}", storedSpoke)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(storedSpoke, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345",
+			mock.MatchedBy(func(updates []firestore.Update) bool {
+				return updates[0].Path == common.ServiceArea && updates[0].Value == firestore.Delete
+			}), mock.Anything).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		patchSpokeHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.NotContains(t, string(bytes), common.ServiceArea)
+	})
+	t.Run("Successful update of the operating hours", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"operating_hours\":{\"weekly\":[{\"day\":\"monday\","+
+			"\"open\":\"09:00\",\"close\":\"17:00\"}]}}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345",
+			mock.MatchedBy(func(updates []firestore.Update) bool {
+				return updates[0].Path == common.OperatingHours && reflect.DeepEqual(updates[0].Value,
+					&commonModels.OperatingHours{Weekly: []commonModels.DayHours{
+						{Day: "monday", Open: "09:00", Close: "17:00"}}})
+			}), mock.Anything).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
+		patchSpokeHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "\"operating_hours\":{\"weekly\":[{\"day\":\"monday\"")
+	})
+
+	t.Run("Successful update of the address with the location geocoded from it", func(t *testing.T) {
+		t.Setenv(common.EnvGeocoder, common.GeocoderGoogle)
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"address\":{\"lines\":[\"1 FC Road\"],\"city\":\"Pune\","+
+			"\"region\":\"MH\",\"postal_code\":\"411004\",\"country\":\"IN\"}}", getStoredSpoke())
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			MatchParam("components", "country:IN\\|postal_code:411004").
+			Reply(200).
+			JSON(commonModels.GoogleGeocoding{Status: "OK", Results: []commonModels.GoogleGeocodingResult{
+				{Geometry: commonModels.GoogleGeocodingGeometry{
+					Location: commonModels.GoogleGeocodingLocation{Latitude: 18.5236, Longitude: 73.8478}}},
+			}})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(commonModels.GoogleTimeZone{Status: "OK", TimezoneID: "Asia/Kolkata"})
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345",
+			mock.MatchedBy(func(updates []firestore.Update) bool {
+				return updates[0].Path == common.Location && updates[3].Path == common.Address
+			}), mock.Anything).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).
+			Return(true, nil)
+		patchSpokeHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "\"location\":{\"lat\":18.5236,\"long\":73.8478}")
+	})
+
+	t.Run("Successful update of the attributes merged into the attributes of the spoke", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		pubSubClient := mocks.NewQueue(t)
+		storedSpoke := getStoredSpoke()
+		storedSpoke[common.Attributes] = map[string]interface{}{"brand": "Acme", "floors": 2.0}
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"attributes\":{\"floors\":null,\"parking\":true}}", storedSpoke)
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345", common.AttributeSchema: map[string]interface{}{
+				"type": "object", "properties": map[string]interface{}{
+					"brand":   map[string]interface{}{"type": "string"},
+					"floors":  map[string]interface{}{"type": "integer"},
+					"parking": map[string]interface{}{"type": "boolean"},
+				}}}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(storedSpoke, nil)
+		fireStoreClient.On("UpdateInTransaction", mock.Anything, utils.GetSpokePath("r12345"), "p12345",
+			mock.MatchedBy(func(updates []firestore.Update) bool {
+				return updates[0].Path == common.Attributes && reflect.DeepEqual(updates[0].Value,
+					map[string]interface{}{"brand": "Acme", "parking": true})
+			}), mock.Anything).Return(time.Now(), nil)
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(3)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).
+			Return(true, nil)
+		patchSpokeHandler(w, r, fireStoreClient, pubSubClient)
+		response := w.Result()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "\"attributes\":{\"brand\":\"Acme\",\"parking\":true}")
+	})
+
+	t.Run("Attributes of a retailer without an attribute schema", func(t *testing.T) {
+		fireStoreClient := mocks.NewDB(t)
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"attributes\":{\"brand\":\"Acme\"}}", getStoredSpoke())
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(getStoredSpoke(), nil)
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\",\"errors\":["+
+			"\"attributes are not allowed as the retailer has no attribute_schema\"]}", string(bytes))
+	})
+
+	t.Run("Location far from the address of the spoke", func(t *testing.T) {
+		t.Setenv(common.EnvGeocoder, common.GeocoderGoogle)
+		fireStoreClient := mocks.NewDB(t)
+		storedSpoke := getStoredSpoke()
+		storedSpoke[common.Address] = map[string]interface{}{"lines": []interface{}{"1 FC Road"}, "city": "Pune",
+			"region": "MH", "postal_code": "411004", "country": "IN"}
+		w := httptest.NewRecorder()
+		r := getSpokeRequest(http.MethodPatch, "{\"location\":{\"lat\":19.076,\"long\":72.8777}}", storedSpoke)
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			Reply(200).
+			JSON(commonModels.GoogleGeocoding{Status: "OK", Results: []commonModels.GoogleGeocodingResult{
+				{Geometry: commonModels.GoogleGeocodingGeometry{
+					Location: commonModels.GoogleGeocodingLocation{Latitude: 18.5236, Longitude: 73.8478}}},
+			}})
+		fireStoreClient.On("GetByID", mock.Anything, common.RetailersCollection, "r12345", true).
+			Return(map[string]interface{}{"id": "r12345"}, nil)
+		fireStoreClient.On("GetByID", mock.Anything, utils.GetSpokePath("r12345"), "p12345", true).
+			Return(storedSpoke, nil)
+		patchSpokeHandler(w, r, fireStoreClient, mocks.NewQueue(t))
+		response := w.Result()
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "km from the address, it must be within 1 km")
+	})
+}
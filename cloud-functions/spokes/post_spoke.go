@@ -2,19 +2,32 @@ package spokes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
-	"github.com/TakeoffTech/go-telemetry/sdpropagation"
+	siteCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/common"
+	sites "github.com/TakeoffTech/site-info-svc/cloud-functions/sites/models"
+	spokeCommon "github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/common"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/spokes/models"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/attributes"
+	"github.com/TakeoffTech/site-info-svc/common/audit"
+	"github.com/TakeoffTech/site-info-svc/common/authorization"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	"github.com/TakeoffTech/site-info-svc/common/dbutil"
+	"github.com/TakeoffTech/site-info-svc/common/geocoding"
+	"github.com/TakeoffTech/site-info-svc/common/idempotency"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
-	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/timezone"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/fatih/structs"
 	"github.com/go-andiamo/urit"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net/http"
 	"os"
 	"time"
@@ -26,16 +39,17 @@ func init() {
 	functions.HTTP("PostSpoke", postSpoke)
 }
 
-func postSpoke(responseWriter http.ResponseWriter, request *http.Request) {
-	ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request,
-		utils.GetSpanName("post_spoke.postSpoke"))
-	defer span.End()
-	key, logger := logging.GetContextWithLogger(request)
-	requestWithContext := request.WithContext(context.WithValue(ctx, key, logger))
-	postSpokeHandler(responseWriter, requestWithContext,
-		cloud.NewFirestoreRepository(requestWithContext.Context()),
-		cloud.NewPubSubRepository(requestWithContext.Context()))
-}
+var postSpokePermission = authorization.OnHeaderRetailer(common.RoleEditor)
+
+var postSpoke = authorization.Authorize("post_spoke.postSpoke",
+	postSpokePermission,
+	func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+		idempotency.HandleRequest(responseWriter, request, dbClient, postSpokePermission.Retailer(request),
+			func(writer http.ResponseWriter, idempotentRequest *http.Request) {
+				postSpokeHandler(writer, idempotentRequest, dbClient,
+					cloud.NewPubSubRepository(idempotentRequest.Context()))
+			})
+	})
 
 func postSpokeHandler(responseWriter http.ResponseWriter, request *http.Request,
 	dbClient cloud.DB, pubsubClient cloud.Queue) {
@@ -64,11 +78,27 @@ func postSpokeHandler(responseWriter http.ResponseWriter, request *http.Request,
 	retailerID := request.Header.Get(common.HeaderRetailerID)
 	var err error
 
-	if !dbutil.IsRetailerIDPresentInDB(responseWriter, request, dbClient, retailerID, logger, true) {
+	retailerData, found := dbutil.GetRetailerFromDB(responseWriter, request, dbClient, retailerID, logger, true)
+	if !found {
+		return
+	}
+	var valid bool
+	spoke.Attributes, valid = attributes.GetAttributesForRequest(responseWriter, request, retailerData,
+		spoke.Attributes)
+	if !valid {
+		return
+	}
+
+	siteData := siteCommon.GetSiteFromDB(responseWriter, request, logger, dbClient, retailerID, siteID, true)
+	if siteData == nil {
 		return
 	}
+	var site sites.Site
+	err = utils.ConvertToObject(siteData, &site)
+	if err != nil {
+		logger.Errorf("Error while unmarshalling data from DB : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
 
-	if !dbutil.IsSiteIDPresentInDB(responseWriter, request, dbClient, retailerID, siteID, logger, true) {
 		return
 	}
 
@@ -89,28 +119,26 @@ func postSpokeHandler(responseWriter http.ResponseWriter, request *http.Request,
 		return
 	}
 
-	var googleTimeZone *commonModels.GoogleTimeZone
-	googleTimeZone, err = utils.GetTimeZone(ctx, *spoke.Location.Latitude, *spoke.Location.Longitude)
-
-	if err != nil {
-		logger.Errorf("Error occurred while retrieving timezone : %v", err)
-		response.RespondWithResponseObject(responseWriter,
-			response.NewResponse(http.StatusBadRequest,
-				fmt.Sprintf("Error occurred while retrieving location with latitude %f and longitude %f. "+
-					"Timezone API returned with status: %s. "+
-					"Please provide valid location details", *spoke.Location.Latitude,
-					*spoke.Location.Longitude, googleTimeZone.Status), nil),
-			response.GetCommonResponseHeaders(request))
-
+	var resolved bool
+	spoke.Address = utils.NewAddress(spoke.Address)
+	spoke.Location, resolved = geocoding.GetRequiredLocationForRequest(ctx, responseWriter, request,
+		spoke.Address, spoke.Location)
+	if !resolved {
 		return
 	}
-	if googleTimeZone != nil && googleTimeZone.TimezoneID != "" {
-		spoke.Timezone = googleTimeZone.TimezoneID
+	spoke.Timezone, resolved = timezone.GetTimezoneForRequest(ctx, responseWriter, request,
+		*spoke.Location.Latitude, *spoke.Location.Longitude)
+	if !resolved {
+		return
 	}
+	spoke.Geohash = utils.GetGeohash(*spoke.Location.Latitude, *spoke.Location.Longitude, common.GeohashPrecision)
+	spoke.ServiceArea = utils.NewServiceArea(spoke.ServiceArea)
+	spoke.OperatingHours = utils.NewOperatingHours(spoke.OperatingHours)
 	var updateTime time.Time
 	var retryCount int
-	spoke, updateTime, retryCount, err = performPostSpoke(responseWriter,
-		request, spoke, siteSpoke, retailerID, siteID, dbClient)
+	var events []outbox.Event
+	spoke, events, updateTime, retryCount, err = performPostSpoke(responseWriter,
+		request, spoke, siteSpoke, retailerID, site, dbClient)
 	if err != nil {
 		return
 	}
@@ -123,16 +151,18 @@ func postSpokeHandler(responseWriter http.ResponseWriter, request *http.Request,
 		return
 	}
 
-	sendPostResponse(ctx, responseWriter, request, pubsubClient, spoke, siteSpoke, updateTime)
+	sendPostResponse(ctx, responseWriter, request, spoke, updateTime)
+	outbox.Dispatch(ctx, dbClient, pubsubClient, events)
 }
 
 func performPostSpoke(responseWriter http.ResponseWriter, request *http.Request,
 	spoke models.Spoke, siteSpoke models.SiteSpoke, retailerID string,
-	siteID string, dbClient cloud.DB) (models.Spoke, time.Time, int, error) {
+	site sites.Site, dbClient cloud.DB) (models.Spoke, []outbox.Event, time.Time, int, error) {
 	ctx := request.Context()
 	logger := logging.GetLoggerFromContext(ctx)
 	var retryCount int
 	var updateTime time.Time
+	var events []outbox.Event
 
 	for retryCount = 0; retryCount < common.MaxRetryCount; retryCount++ {
 		logger.Debugf("Creating unique ID for Spoke for %d time", retryCount)
@@ -140,51 +170,100 @@ func performPostSpoke(responseWriter http.ResponseWriter, request *http.Request,
 
 		spoke.RetailerID = retailerID
 
-		spoke.CreatedBy = common.User
-		spoke.UpdatedBy = common.User
+		spoke.CreatedBy = identity.GetPrincipalFromContext(ctx)
+		spoke.UpdatedBy = identity.GetPrincipalFromContext(ctx)
 
 		currentTime := time.Now().UTC().Round(time.Second)
 		spoke.CreatedTime = &currentTime
 		spoke.UpdatedTime = &currentTime
+		spoke.Revision = 1
 
-		siteSpoke = models.NewSiteSpoke(siteID, spoke.ID, retailerID, common.User)
+		siteSpoke = models.NewSiteSpoke(site, spoke, identity.GetPrincipalFromContext(ctx))
 
 		idExists, err := dbClient.ExistsInCollectionGroup(ctx, common.SpokesCollection, common.ID, spoke.ID)
 		if err != nil {
 			logger.Errorf("Error occurred while checking existence of spoke in DB: %v", err)
 			response.RespondWithInternalServerError(responseWriter, request)
 
-			return spoke, time.Time{}, retryCount, err
+			return spoke, nil, time.Time{}, retryCount, err
 		}
 		if !idExists {
 			logger.Debugf("Proceed with save")
-			_, err = dbClient.Save(ctx, utils.GetSpokePath(retailerID), spoke.ID, spoke)
+			events, err = getPostSpokeEvents(request, spoke, siteSpoke)
 			if err != nil {
-				logger.Errorf("Unable to create spoke : %v", err)
+				logger.Errorf("Error while creating the spoke events : %v", err)
 				response.RespondWithInternalServerError(responseWriter, request)
 
-				return spoke, time.Time{}, retryCount, err
+				return spoke, nil, time.Time{}, retryCount, err
 			}
-			logger.Debugf("Created unique ID for site")
+			updateTime, err = dbClient.SaveInTransaction(ctx, utils.GetSpokePath(retailerID), spoke.ID, spoke,
+				cloud.WriteOptions{
+					ReserveKeys: []cloud.UniqueKey{
+						cloud.NewUniqueKey(common.SpokesCollection, common.ID, spoke.ID),
+						cloud.NewUniqueKey(utils.GetSpokePath(retailerID), common.Name, spoke.Name),
+					},
+					Creates: append(outbox.Documents(events), cloud.Document{
+						CollectionPath: utils.GetSiteSpokePath(retailerID), DocumentID: siteSpoke.ID, Data: siteSpoke}),
+				})
+			var uniqueKeyError *cloud.UniqueKeyError
+			if errors.As(err, &uniqueKeyError) && uniqueKeyError.Key.Field == common.Name {
+				logger.Debugf("Spoke with name %s created concurrently", spoke.Name)
+				response.RespondWithResponseObject(responseWriter,
+					response.NewResponse(http.StatusBadRequest,
+						fmt.Sprintf("Spoke with name : %s already exists", spoke.Name), nil),
+					response.GetCommonResponseHeaders(request))
+
+				return spoke, nil, time.Time{}, retryCount, err
+			}
+			if uniqueKeyError != nil || status.Code(err) == codes.AlreadyExists {
+				logger.Debugf("Spoke id %s was taken concurrently, generating a new one", spoke.ID)
 
-			logger.Debugf("Proceed with site-spoke association")
-			updateTime, err = dbClient.Save(ctx, utils.GetSiteSpokePath(retailerID), siteSpoke.ID, siteSpoke)
+				continue
+			}
 			if err != nil {
-				logger.Errorf("Unable to create site spoke association : %v", err)
+				logger.Errorf("Unable to create spoke with site spoke association : %v", err)
 				response.RespondWithInternalServerError(responseWriter, request)
 
-				return spoke, time.Time{}, retryCount, err
+				return spoke, nil, time.Time{}, retryCount, err
 			}
+			logger.Debugf("Created unique ID for spoke along with site-spoke association")
 
 			break
 		}
 	}
 
-	return spoke, updateTime, retryCount, nil
+	return spoke, events, updateTime, retryCount, nil
+}
+
+// getPostSpokeEvents returns the audit and spoke change events to be written along with the spoke creation,
+// auditing both the creation of the spoke and its attachment to the site
+func getPostSpokeEvents(request *http.Request, spoke models.Spoke, siteSpoke models.SiteSpoke) ([]outbox.Event, error) {
+	messages := []outbox.Message{
+		{
+			Topic: os.Getenv(common.EnvAuditLogTopic),
+			Data: audit.GetPubSubAuditMessage(audit.GetSpokeAuditPath(spoke.RetailerID, spoke.ID),
+				request.Header.Get(common.HeaderXCorrelationID), spoke.CreatedBy,
+				common.AuditTypeCreate,
+				common.EntitySpoke,
+				spoke.CreatedTime,
+				nil,
+				structs.Map(spoke),
+			),
+		},
+	}
+	messages = append(messages, spokeCommon.GetSiteSpokeAuditMessages(request, common.AuditTypeAttach, siteSpoke,
+		siteSpoke.CreatedBy, siteSpoke.CreatedTime)...)
+	messages = append(messages, outbox.Message{
+		Topic: os.Getenv(common.EnvSpokeMessageTopic),
+		Data: models.GetPubSubSpokeMessage(spoke.RetailerID, siteSpoke.SiteID, spoke.ID,
+			siteSpoke.ID, common.ChangeTypeCreate),
+	})
+
+	return outbox.NewEvents(messages...)
 }
 
 func sendPostResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
-	pubsubClient cloud.Queue, Spoke models.Spoke, siteSpoke models.SiteSpoke, updateTime time.Time) {
+	Spoke models.Spoke, updateTime time.Time) {
 	logger := logging.GetLoggerFromContext(ctx)
 	etag, err := utils.GetETag(Spoke)
 	if err != nil {
@@ -201,8 +280,4 @@ func sendPostResponse(ctx context.Context, responseWriter http.ResponseWriter, r
 			WithHeader(common.HeaderEtag, etag))
 
 	logger.Debugf("Spoke successfully created with id : %s", Spoke.ID)
-
-	pubsubClient.Publish(ctx, os.Getenv(common.EnvSpokeMessageTopic),
-		models.GetPubSubSpokeMessage(Spoke.RetailerID, siteSpoke.SiteID, Spoke.ID,
-			siteSpoke.ID, common.ChangeTypeCreate))
 }
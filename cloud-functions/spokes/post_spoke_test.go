@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
 	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"github.com/TakeoffTech/site-info-svc/mocks"
@@ -26,6 +27,15 @@ func init() {
 	if err != nil {
 		return
 	}
+	// the timezones are resolved with the timezone API mocked by gock, without the cache
+	err = os.Setenv(common.EnvTimezoneResolver, common.TimezoneResolverGoogle)
+	if err != nil {
+		return
+	}
+	err = os.Setenv(common.EnvTimezoneCacheSize, "0")
+	if err != nil {
+		return
+	}
 }
 
 func getRequest(method string, url string, body string, headers ...string) *http.Request {
@@ -150,7 +160,7 @@ func Test_postSpokeHandler(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\","+
-			"\"errors\":[\"Key: 'Spoke.Location' Error:Field validation for 'Location' failed on the 'required' tag\"]}", string(bytes))
+			"\"errors\":[\"Key: 'Spoke.Location' Error:Field validation for 'Location' failed on the 'required_without' tag\"]}", string(bytes))
 	})
 
 	t.Run("Missing Longitude in JSON POST Entity", func(t *testing.T) {
@@ -325,7 +335,7 @@ func Test_postSpokeHandler(t *testing.T) {
 		response := w.Result()
 		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
-		assert.Contains(t, string(bytes), "{\"code\":400,\"message\":\"Error occurred while retrieving location with latitude 54.250000 and longitude 13.134000. Timezone API returned with status: INVALID_REQUEST.")
+		assert.Contains(t, string(bytes), "{\"code\":400,\"message\":\"Error occurred while retrieving location with latitude 54.250000 and longitude 13.134000. No timezone found for the location.")
 	})
 
 	t.Run("Error while checking existence of Spoke ID", func(t *testing.T) {
@@ -395,7 +405,7 @@ func Test_postSpokeHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mockedSiteID, true).Return(site, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(false, nil).Once()
 		fireStoreClient.On("ExistsInCollectionGroup", mock.Anything, common.SpokesCollection, common.ID, mock.Anything).Return(false, nil).Once()
-		fireStoreClient.On("Save", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(time.Now(), errors.New(mock.Anything)).Once()
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything, mock.Anything).Return(time.Now(), errors.New(mock.Anything)).Once()
 		postSpokeHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -433,8 +443,12 @@ func Test_postSpokeHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mockedSiteID, true).Return(site, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(false, nil).Once()
 		fireStoreClient.On("ExistsInCollectionGroup", mock.Anything, common.SpokesCollection, common.ID, mock.Anything).Return(false, nil).Once()
-		fireStoreClient.On("Save", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(time.Now(), nil).Once()
-		fireStoreClient.On("Save", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(time.Now(), errors.New(mock.Anything)).Once()
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				siteSpoke := options.Creates[len(options.Creates)-1]
+
+				return siteSpoke.CollectionPath == utils.GetSiteSpokePath(mockedRetailerID)
+			})).Return(time.Now(), errors.New(mock.Anything)).Once()
 		postSpokeHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
@@ -511,15 +525,17 @@ func Test_postSpokeHandler(t *testing.T) {
 		fireStoreClient.On("GetByID", mock.Anything, utils.GetSitePath(mockedRetailerID), mockedSiteID, true).Return(site, nil)
 		fireStoreClient.On("Exists", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(false, nil).Once()
 		fireStoreClient.On("ExistsInCollectionGroup", mock.Anything, common.SpokesCollection, common.ID, mock.Anything).Return(false, nil).Once()
-		fireStoreClient.On("Save", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(time.Now(), nil).Once()
-		fireStoreClient.On("Save", mock.Anything, utils.GetSiteSpokePath(mockedRetailerID), mock.Anything, mock.Anything).Return(time.Now(), nil).Once()
-		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return()
+		// the creation and the attach audit events and the spoke message are written along with the site spoke
+		fireStoreClient.On("SaveInTransaction", mock.Anything, utils.GetSpokePath(mockedRetailerID), mock.Anything, mock.Anything,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool { return len(options.Creates) == 5 })).Return(time.Now(), nil).Once()
+		pubSubClient.On("Publish", mock.Anything, mock.Anything, mock.Anything).Return(nil).Times(4)
+		fireStoreClient.On("Delete", mock.Anything, common.OutboxCollection, mock.Anything).Return(true, nil)
 		postSpokeHandler(w, r, fireStoreClient, pubSubClient)
 		response := w.Result()
 		assert.Equal(t, http.StatusCreated, response.StatusCode)
 		bytes, _ := io.ReadAll(response.Body)
 		updateTime := time.Now().UTC().Round(time.Second)
 		updateTimeStr := updateTime.Format(time.RFC3339)
-		assert.Contains(t, string(bytes), fmt.Sprintf("\"name\":\"spokeName\",\"retailer_id\":\"%s\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":54.25,\"long\":13.134},\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"%s\",\"updated_time\":\"%s\"}", mockedRetailerID, updateTimeStr, updateTimeStr))
+		assert.Contains(t, string(bytes), fmt.Sprintf("\"name\":\"spokeName\",\"retailer_id\":\"%s\",\"timezone\":\"Europe/Berlin\",\"location\":{\"lat\":54.25,\"long\":13.134},\"geohash\":\"u39kdggvhk\",\"created_by\":\"api@takeoff.com\",\"updated_by\":\"api@takeoff.com\",\"created_time\":\"%s\",\"updated_time\":\"%s\",\"revision\":1}", mockedRetailerID, updateTimeStr, updateTimeStr))
 	})
 }
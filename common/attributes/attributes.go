@@ -0,0 +1,222 @@
+package attributes
+
+import (
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GetSchema returns the compiled attribute schema of the retailer, or nil when the retailer has no attribute schema
+func GetSchema(retailerData map[string]interface{}) (*Schema, error) {
+	rawSchema, ok := retailerData[common.AttributeSchema].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	return Compile(rawSchema)
+}
+
+// GetSchemaForRequest returns the attribute schema of the retailer as GetSchema. When the stored schema does not
+// compile it responds with an internal server error, returning false
+func GetSchemaForRequest(responseWriter http.ResponseWriter, request *http.Request,
+	retailerData map[string]interface{}) (*Schema, bool) {
+	schema, err := GetSchema(retailerData)
+	if err != nil {
+		logging.GetLoggerFromContext(request.Context()).Errorf("Invalid attribute schema of the retailer : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return nil, false
+	}
+
+	return schema, true
+}
+
+// GetAttributesForRequest returns the attributes of a site or a spoke after validating them against the attribute
+// schema of the retailer as ValidateForRequest, nil when there are no attributes. When the attributes are invalid it
+// responds with a bad request, returning false
+func GetAttributesForRequest(responseWriter http.ResponseWriter, request *http.Request,
+	retailerData map[string]interface{}, attributes map[string]interface{}) (map[string]interface{}, bool) {
+	schema, compiled := GetSchemaForRequest(responseWriter, request, retailerData)
+	if !compiled || !ValidateForRequest(responseWriter, request, schema, attributes) {
+		return nil, false
+	}
+	if len(attributes) == 0 {
+		return nil, true
+	}
+
+	return attributes, true
+}
+
+// ValidateSchemaForRequest validates the attribute schema of a retailer. When the schema does not compile it responds
+// with a bad request, returning false
+func ValidateSchemaForRequest(responseWriter http.ResponseWriter, request *http.Request,
+	rawSchema map[string]interface{}) bool {
+	if _, err := Compile(rawSchema); err != nil {
+		errs := []string{fmt.Sprintf("Invalid %s : %v", common.AttributeSchema, err)}
+		logging.GetLoggerFromContext(request.Context()).Debugf("Attribute schema validation failed. errors : %v", errs)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", errs),
+			response.GetCommonResponseHeaders(request))
+
+		return false
+	}
+
+	return true
+}
+
+// Merge returns the attributes after the update of a PATCH request, the updated attributes replace the attributes
+// with the same name and a null attribute removes the attribute
+func Merge(attributes map[string]interface{}, update map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(attributes)+len(update))
+	for name, value := range attributes {
+		merged[name] = value
+	}
+	for name, value := range update {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = value
+		}
+	}
+
+	return merged
+}
+
+// ValidateForRequest validates the attributes of a site or a spoke against the attribute schema of the retailer.
+// Attributes are not allowed when the retailer has no attribute schema. When the attributes are invalid it responds
+// with a bad request, returning false
+func ValidateForRequest(responseWriter http.ResponseWriter, request *http.Request, schema *Schema,
+	attributes map[string]interface{}) bool {
+	if len(attributes) == 0 {
+		attributes = map[string]interface{}{}
+	}
+	var errs []string
+	if schema == nil {
+		if len(attributes) > 0 {
+			errs = []string{fmt.Sprintf("%s are not allowed as the retailer has no %s", common.Attributes,
+				common.AttributeSchema)}
+		}
+	} else {
+		errs = schema.Validate(attributes, common.Attributes)
+	}
+	if errs != nil {
+		logging.GetLoggerFromContext(request.Context()).Debugf("Attributes validation failed. errors : %v", errs)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", errs),
+			response.GetCommonResponseHeaders(request))
+
+		return false
+	}
+
+	return true
+}
+
+// GetFiltersForRequest returns the where clauses of the attribute filters of the request as ParseFilters, against
+// the attribute schema of the retailer. When a filter is invalid it responds with a bad request, returning false
+func GetFiltersForRequest(responseWriter http.ResponseWriter, request *http.Request,
+	retailerData map[string]interface{}) ([]cloud.Where, bool) {
+	schema, compiled := GetSchemaForRequest(responseWriter, request, retailerData)
+	if !compiled {
+		return nil, false
+	}
+	where, errs := ParseFilters(request, schema)
+	if errs != nil {
+		logging.GetLoggerFromContext(request.Context()).Debugf("Attribute filters validation failed. errors : %v",
+			errs)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest, "Request validation failed", errs),
+			response.GetCommonResponseHeaders(request))
+
+		return nil, false
+	}
+
+	return where, true
+}
+
+// ParseFilters returns the where clauses of the attributes.<name> query params of the request, filtering on the
+// attributes declared by the schema, or the validation errors of the query params. The strings, the numbers and the
+// booleans are filtered on equality, and the arrays of them on containing the value.
+// Firestore allows a single array-contains filter in a query
+func ParseFilters(request *http.Request, schema *Schema) ([]cloud.Where, []string) {
+	query := request.URL.Query()
+	params := make([]string, 0, len(query))
+	for param := range query {
+		if strings.HasPrefix(param, common.QueryParamAttributesPrefix) {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+
+	var where []cloud.Where
+	var errs []string
+	arrayParam := ""
+	for _, param := range params {
+		filter, errMessage := getFilter(param, query.Get(param), schema)
+		switch {
+		case errMessage != "":
+			errs = append(errs, errMessage)
+		case filter.Operator == common.OperatorArrayContains && arrayParam != "":
+			errs = append(errs, fmt.Sprintf("Query param %s can not be combined with a filter on %s", param,
+				arrayParam))
+		default:
+			if filter.Operator == common.OperatorArrayContains {
+				arrayParam = param
+			}
+			where = append(where, filter)
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	return where, nil
+}
+
+// getFilter returns the where clause of the query param, or its validation error
+func getFilter(param string, value string, schema *Schema) (cloud.Where, string) {
+	name := strings.TrimPrefix(param, common.QueryParamAttributesPrefix)
+	var property *Schema
+	if schema != nil {
+		property = schema.Properties[name]
+	}
+	if property == nil {
+		return cloud.Where{}, fmt.Sprintf("Unsupported query param %s, %s is not a declared attribute", param, name)
+	}
+	operator := common.OperatorEquals
+	if property.Type == common.SchemaTypeArray && property.Items != nil {
+		operator = common.OperatorArrayContains
+		property = property.Items
+	}
+	filterValue, err := getFilterValue(value, property.Type)
+	if err != nil {
+		return cloud.Where{}, fmt.Sprintf("Invalid value for query param %s : %v", param, err)
+	}
+
+	return cloud.Where{Field: common.Attributes + "." + name, Operator: operator, Value: filterValue}, ""
+}
+
+// getFilterValue returns the value of the query param as the type of the attribute
+func getFilterValue(value string, schemaType string) (interface{}, error) {
+	switch schemaType {
+	case common.SchemaTypeString:
+		if value == "" {
+			return nil, fmt.Errorf("value is empty")
+		}
+
+		return value, nil
+	case common.SchemaTypeNumber:
+		return strconv.ParseFloat(value, 64)
+	case common.SchemaTypeInteger:
+		return strconv.ParseInt(value, 10, 64)
+	case common.SchemaTypeBoolean:
+		return strconv.ParseBool(value)
+	default:
+		return nil, fmt.Errorf("attributes of type %s can not be filtered on", schemaType)
+	}
+}
@@ -0,0 +1,135 @@
+package attributes
+
+import (
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetSchema(t *testing.T) {
+	t.Run("Retailer without a schema", func(t *testing.T) {
+		schema, err := GetSchema(map[string]interface{}{common.Name: "retailer"})
+		assert.Nil(t, err)
+		assert.Nil(t, schema)
+	})
+
+	t.Run("Retailer with a schema", func(t *testing.T) {
+		schema, err := GetSchema(map[string]interface{}{common.AttributeSchema: getRawSchema(t, testSchema)})
+		assert.Nil(t, err)
+		assert.Equal(t, common.SchemaTypeObject, schema.Type)
+	})
+}
+
+func TestMerge(t *testing.T) {
+	attributes := map[string]interface{}{"brand": "Acme", "floors": 2.0}
+	merged := Merge(attributes, map[string]interface{}{"floors": nil, "parking": true})
+	assert.Equal(t, map[string]interface{}{"brand": "Acme", "parking": true}, merged)
+	assert.Equal(t, map[string]interface{}{"brand": "Acme", "floors": 2.0}, attributes)
+}
+
+func TestGetAttributesForRequest(t *testing.T) {
+	retailerData := map[string]interface{}{common.AttributeSchema: getRawSchema(t, testSchema)}
+
+	t.Run("Valid attributes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		attributes, valid := GetAttributesForRequest(w, getRequest("/sites"), retailerData,
+			map[string]interface{}{"brand": "Acme"})
+		assert.True(t, valid)
+		assert.Equal(t, map[string]interface{}{"brand": "Acme"}, attributes)
+	})
+
+	t.Run("Invalid attributes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		_, valid := GetAttributesForRequest(w, getRequest("/sites"), retailerData,
+			map[string]interface{}{"brand": "Acme", "floors": "two"})
+		assert.False(t, valid)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\","+
+			"\"errors\":[\"attributes.floors must be of type integer\"]}", string(bytes))
+	})
+
+	t.Run("Attributes of a retailer without a schema", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		_, valid := GetAttributesForRequest(w, getRequest("/sites"), nil, map[string]interface{}{"brand": "Acme"})
+		assert.False(t, valid)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\","+
+			"\"errors\":[\"attributes are not allowed as the retailer has no attribute_schema\"]}", string(bytes))
+	})
+
+	t.Run("Required attributes of a site without attributes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		attributes, valid := GetAttributesForRequest(w, getRequest("/sites"), retailerData, nil)
+		assert.False(t, valid)
+		assert.Nil(t, attributes)
+	})
+
+	t.Run("Invalid stored schema", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		_, valid := GetAttributesForRequest(w, getRequest("/sites"),
+			map[string]interface{}{common.AttributeSchema: map[string]interface{}{"type": "string"}}, nil)
+		assert.False(t, valid)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
+
+func TestValidateSchemaForRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	valid := ValidateSchemaForRequest(w, getRequest("/retailers"), map[string]interface{}{"type": "array"})
+	assert.False(t, valid)
+	bytes, _ := io.ReadAll(w.Result().Body)
+	assert.Equal(t, "{\"code\":400,\"message\":\"Request body validation failed\","+
+		"\"errors\":[\"Invalid attribute_schema : type must be object\"]}", string(bytes))
+}
+
+func TestParseFilters(t *testing.T) {
+	schema, err := Compile(getRawSchema(t, testSchema))
+	assert.Nil(t, err)
+	tests := []struct {
+		name  string
+		query string
+		where []cloud.Where
+		errs  []string
+	}{
+		{name: "No attribute filters", query: "status=active"},
+		{name: "Filters on scalar attributes", query: "attributes.brand=Acme&attributes.floors=2&" +
+			"attributes.parking=true&attributes.rating=4.5",
+			where: []cloud.Where{
+				{Field: "attributes.brand", Operator: common.OperatorEquals, Value: "Acme"},
+				{Field: "attributes.floors", Operator: common.OperatorEquals, Value: int64(2)},
+				{Field: "attributes.parking", Operator: common.OperatorEquals, Value: true},
+				{Field: "attributes.rating", Operator: common.OperatorEquals, Value: 4.5},
+			}},
+		{name: "Filter on an array attribute", query: "attributes.tags=a",
+			where: []cloud.Where{{Field: "attributes.tags", Operator: common.OperatorArrayContains, Value: "a"}}},
+		{name: "Undeclared attribute", query: "attributes.color=red",
+			errs: []string{"Unsupported query param attributes.color, color is not a declared attribute"}},
+		{name: "Object attribute", query: "attributes.manager=Jo",
+			errs: []string{"Invalid value for query param attributes.manager : " +
+				"attributes of type object can not be filtered on"}},
+		{name: "Invalid value", query: "attributes.floors=two",
+			errs: []string{"Invalid value for query param attributes.floors : " +
+				"strconv.ParseInt: parsing \"two\": invalid syntax"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, errs := ParseFilters(getRequest("/sites?"+tt.query), schema)
+			assert.Equal(t, tt.where, where)
+			assert.Equal(t, tt.errs, errs)
+		})
+	}
+
+	t.Run("Retailer without a schema", func(t *testing.T) {
+		_, errs := ParseFilters(getRequest("/sites?attributes.brand=Acme"), nil)
+		assert.Equal(t, []string{"Unsupported query param attributes.brand, brand is not a declared attribute"}, errs)
+	})
+}
+
+func getRequest(target string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, target, nil)
+}
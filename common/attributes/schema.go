@@ -0,0 +1,453 @@
+package attributes
+
+import (
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Schema is a compiled JSON Schema of the attributes of the sites and the spokes of a retailer. The subset of JSON
+// Schema supported is the type of every schema, one of string, number, integer, boolean, array or object, along with:
+//   - enum of the strings, the numbers and the booleans
+//   - minLength, maxLength, pattern and format (date or date-time) of the strings
+//   - minimum, maximum, exclusiveMinimum and exclusiveMaximum of the numbers
+//   - items, minItems, maxItems and uniqueItems of the arrays, whose items can't be arrays as Firestore does not store
+//     nested arrays
+//   - properties, required and additionalProperties (a boolean) of the objects
+//
+// The title and the description are allowed, and any other keyword is rejected rather than ignored
+type Schema struct {
+	Type                 string
+	Enum                 []interface{}
+	MinLength            *int
+	MaxLength            *int
+	Pattern              *regexp.Regexp
+	Format               string
+	Minimum              *float64
+	Maximum              *float64
+	ExclusiveMinimum     *float64
+	ExclusiveMaximum     *float64
+	Items                *Schema
+	MinItems             *int
+	MaxItems             *int
+	UniqueItems          bool
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties bool
+}
+
+// typeKeywords are the keywords supported by each type
+var typeKeywords = map[string][]string{
+	common.SchemaTypeString:  {"enum", "minLength", "maxLength", "pattern", "format"},
+	common.SchemaTypeNumber:  {"enum", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum"},
+	common.SchemaTypeInteger: {"enum", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum"},
+	common.SchemaTypeBoolean: {"enum"},
+	common.SchemaTypeArray:   {"items", "minItems", "maxItems", "uniqueItems"},
+	common.SchemaTypeObject:  {"properties", "required", "additionalProperties"},
+}
+
+var attributeNameRegex = regexp.MustCompile(common.AttributeNameRegex)
+
+// Compile returns the schema of the attributes, an object schema, or the first error of the schema
+func Compile(raw map[string]interface{}) (*Schema, error) {
+	schema, err := compileSchema(raw, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Type != common.SchemaTypeObject {
+		return nil, fmt.Errorf("type must be %s", common.SchemaTypeObject)
+	}
+
+	return schema, nil
+}
+
+func compileSchema(raw interface{}, path string, depth int) (*Schema, error) {
+	rawSchema, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a schema object", getPath(path, ""))
+	}
+	if depth > common.MaxAttributeSchemaDepth {
+		return nil, fmt.Errorf("%s is nested deeper than %d levels", getPath(path, ""),
+			common.MaxAttributeSchemaDepth)
+	}
+	schemaType, _ := rawSchema["type"].(string)
+	keywords, ok := typeKeywords[schemaType]
+	if !ok {
+		return nil, fmt.Errorf("%s must be one of %v", getPath(path, "type"), getSchemaTypes())
+	}
+
+	schema := &Schema{Type: schemaType, AdditionalProperties: true}
+	keys := make([]string, 0, len(rawSchema))
+	for key := range rawSchema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch {
+		case key == "type" || key == "title" || key == "description":
+			continue
+		case !containsString(keywords, key):
+			return nil, fmt.Errorf("%s is not supported for type %s", getPath(path, key), schemaType)
+		}
+		if err := schema.setKeyword(key, rawSchema[key], getPath(path, key), depth); err != nil {
+			return nil, err
+		}
+	}
+
+	return schema, nil
+}
+
+// setKeyword sets the keyword of the schema, the keyword is supported by the type of the schema
+func (s *Schema) setKeyword(key string, value interface{}, path string, depth int) error {
+	var err error
+	switch key {
+	case "enum":
+		s.Enum, err = s.getEnum(value, path)
+	case "minLength", "maxLength", "minItems", "maxItems":
+		err = s.setCount(key, value, path)
+	case "pattern":
+		s.Pattern, err = getPattern(value, path)
+	case "format":
+		s.Format, _ = value.(string)
+		if s.Format != common.SchemaFormatDate && s.Format != common.SchemaFormatDateTime {
+			err = fmt.Errorf("%s must be one of %v", path, []string{common.SchemaFormatDate,
+				common.SchemaFormatDateTime})
+		}
+	case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+		err = s.setLimit(key, value, path)
+	case "items":
+		s.Items, err = compileSchema(value, path, depth+1)
+		if err == nil && s.Items.Type == common.SchemaTypeArray {
+			err = fmt.Errorf("%s can't be an array, nested arrays are not supported", path)
+		}
+	case "uniqueItems", "additionalProperties":
+		err = s.setFlag(key, value, path)
+	case "properties":
+		s.Properties, err = getProperties(value, path, depth)
+	case "required":
+		s.Required, err = getRequired(value, path)
+	}
+
+	return err
+}
+
+func (s *Schema) getEnum(value interface{}, path string) ([]interface{}, error) {
+	values, ok := value.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("%s must be a non-empty array", path)
+	}
+	for index, enumValue := range values {
+		if errs := s.validateType(enumValue, fmt.Sprintf("%s[%d]", path, index)); errs != nil {
+			return nil, fmt.Errorf("%s", errs[0])
+		}
+	}
+
+	return values, nil
+}
+
+func (s *Schema) setCount(key string, value interface{}, path string) error {
+	number, ok := toNumber(value)
+	if !ok || number < 0 || number != math.Trunc(number) {
+		return fmt.Errorf("%s must be a non-negative integer", path)
+	}
+	count := int(number)
+	switch key {
+	case "minLength":
+		s.MinLength = &count
+	case "maxLength":
+		s.MaxLength = &count
+	case "minItems":
+		s.MinItems = &count
+	case "maxItems":
+		s.MaxItems = &count
+	}
+
+	return nil
+}
+
+func (s *Schema) setLimit(key string, value interface{}, path string) error {
+	limit, ok := toNumber(value)
+	if !ok {
+		return fmt.Errorf("%s must be a number", path)
+	}
+	switch key {
+	case "minimum":
+		s.Minimum = &limit
+	case "maximum":
+		s.Maximum = &limit
+	case "exclusiveMinimum":
+		s.ExclusiveMinimum = &limit
+	case "exclusiveMaximum":
+		s.ExclusiveMaximum = &limit
+	}
+
+	return nil
+}
+
+func (s *Schema) setFlag(key string, value interface{}, path string) error {
+	flag, ok := value.(bool)
+	if !ok {
+		return fmt.Errorf("%s must be a boolean", path)
+	}
+	if key == "uniqueItems" {
+		s.UniqueItems = flag
+	} else {
+		s.AdditionalProperties = flag
+	}
+
+	return nil
+}
+
+func getPattern(value interface{}, path string) (*regexp.Regexp, error) {
+	pattern, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string", path)
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a valid regular expression : %v", path, err)
+	}
+
+	return compiled, nil
+}
+
+func getProperties(value interface{}, path string, depth int) (map[string]*Schema, error) {
+	rawProperties, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", path)
+	}
+	names := make([]string, 0, len(rawProperties))
+	for name := range rawProperties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	properties := map[string]*Schema{}
+	for _, name := range names {
+		if !attributeNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("%s must match %s", getPath(path, name), common.AttributeNameRegex)
+		}
+		property, err := compileSchema(rawProperties[name], getPath(path, name), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		properties[name] = property
+	}
+
+	return properties, nil
+}
+
+func getRequired(value interface{}, path string) ([]string, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of property names", path)
+	}
+	required := make([]string, 0, len(values))
+	for _, requiredValue := range values {
+		name, ok := requiredValue.(string)
+		if !ok || containsString(required, name) {
+			return nil, fmt.Errorf("%s must be an array of unique property names", path)
+		}
+		required = append(required, name)
+	}
+
+	return required, nil
+}
+
+// Validate returns the errors of the value against the schema, the path is the name of the value in the errors
+func (s *Schema) Validate(value interface{}, path string) []string {
+	if errs := s.validateType(value, path); errs != nil {
+		return errs
+	}
+	if s.Enum != nil && !containsValue(s.Enum, value) {
+		return []string{fmt.Sprintf("%s must be one of %v", path, s.Enum)}
+	}
+	switch s.Type {
+	case common.SchemaTypeString:
+		return s.validateString(value.(string), path)
+	case common.SchemaTypeNumber, common.SchemaTypeInteger:
+		number, _ := toNumber(value)
+
+		return s.validateNumber(number, path)
+	case common.SchemaTypeArray:
+		return s.validateArray(value.([]interface{}), path)
+	case common.SchemaTypeObject:
+		return s.validateObject(value.(map[string]interface{}), path)
+	}
+
+	return nil
+}
+
+func (s *Schema) validateType(value interface{}, path string) []string {
+	valid := false
+	switch s.Type {
+	case common.SchemaTypeString:
+		_, valid = value.(string)
+	case common.SchemaTypeNumber:
+		_, valid = toNumber(value)
+	case common.SchemaTypeInteger:
+		number, ok := toNumber(value)
+		valid = ok && number == math.Trunc(number)
+	case common.SchemaTypeBoolean:
+		_, valid = value.(bool)
+	case common.SchemaTypeArray:
+		_, valid = value.([]interface{})
+	case common.SchemaTypeObject:
+		_, valid = value.(map[string]interface{})
+	}
+	if !valid {
+		return []string{fmt.Sprintf("%s must be of type %s", path, s.Type)}
+	}
+
+	return nil
+}
+
+func (s *Schema) validateString(value string, path string) []string {
+	var errs []string
+	length := len([]rune(value))
+	if s.MinLength != nil && length < *s.MinLength {
+		errs = append(errs, fmt.Sprintf("%s must have at least %d characters", path, *s.MinLength))
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		errs = append(errs, fmt.Sprintf("%s must have at most %d characters", path, *s.MaxLength))
+	}
+	if s.Pattern != nil && !s.Pattern.MatchString(value) {
+		errs = append(errs, fmt.Sprintf("%s must match the pattern %s", path, s.Pattern))
+	}
+	layout := map[string]string{common.SchemaFormatDate: common.HoursDateLayout,
+		common.SchemaFormatDateTime: time.RFC3339}[s.Format]
+	if _, err := time.Parse(layout, value); layout != "" && err != nil {
+		errs = append(errs, fmt.Sprintf("%s must be a %s", path, s.Format))
+	}
+
+	return errs
+}
+
+func (s *Schema) validateNumber(value float64, path string) []string {
+	var errs []string
+	if s.Minimum != nil && value < *s.Minimum {
+		errs = append(errs, fmt.Sprintf("%s must be >= %v", path, *s.Minimum))
+	}
+	if s.Maximum != nil && value > *s.Maximum {
+		errs = append(errs, fmt.Sprintf("%s must be <= %v", path, *s.Maximum))
+	}
+	if s.ExclusiveMinimum != nil && value <= *s.ExclusiveMinimum {
+		errs = append(errs, fmt.Sprintf("%s must be > %v", path, *s.ExclusiveMinimum))
+	}
+	if s.ExclusiveMaximum != nil && value >= *s.ExclusiveMaximum {
+		errs = append(errs, fmt.Sprintf("%s must be < %v", path, *s.ExclusiveMaximum))
+	}
+
+	return errs
+}
+
+func (s *Schema) validateArray(values []interface{}, path string) []string {
+	var errs []string
+	if s.MinItems != nil && len(values) < *s.MinItems {
+		errs = append(errs, fmt.Sprintf("%s must have at least %d items", path, *s.MinItems))
+	}
+	if s.MaxItems != nil && len(values) > *s.MaxItems {
+		errs = append(errs, fmt.Sprintf("%s must have at most %d items", path, *s.MaxItems))
+	}
+	for index, value := range values {
+		if s.UniqueItems && containsValue(values[:index], value) {
+			errs = append(errs, fmt.Sprintf("%s must have unique items", path))
+
+			break
+		}
+	}
+	if s.Items != nil {
+		for index, value := range values {
+			errs = append(errs, s.Items.Validate(value, fmt.Sprintf("%s[%d]", path, index))...)
+		}
+	}
+
+	return errs
+}
+
+func (s *Schema) validateObject(values map[string]interface{}, path string) []string {
+	var errs []string
+	for _, name := range s.Required {
+		if _, ok := values[name]; !ok {
+			errs = append(errs, fmt.Sprintf("%s is required", getPath(path, name)))
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		switch {
+		case ok:
+			errs = append(errs, property.Validate(values[name], getPath(path, name))...)
+		case !s.AdditionalProperties:
+			errs = append(errs, fmt.Sprintf("%s is not a declared attribute", getPath(path, name)))
+		}
+	}
+
+	return errs
+}
+
+// getPath returns the path of the name within the path, separated by a dot
+func getPath(path string, name string) string {
+	switch {
+	case path == "":
+		return name
+	case name == "":
+		return path
+	default:
+		return path + "." + name
+	}
+}
+
+func getSchemaTypes() []string {
+	types := make([]string, 0, len(typeKeywords))
+	for schemaType := range typeKeywords {
+		types = append(types, schemaType)
+	}
+	sort.Strings(types)
+
+	return types
+}
+
+// toNumber returns the number of a JSON or a Firestore number, along with whether the value is a number
+func toNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int64:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	default:
+		return 0, false
+	}
+}
+
+// containsValue returns whether the values have the value, the numbers are compared by their value
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, other := range values {
+		number, isNumber := toNumber(value)
+		otherNumber, isOtherNumber := toNumber(other)
+		if (isNumber && isOtherNumber && number == otherNumber) || reflect.DeepEqual(value, other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, other := range values {
+		if other == value {
+			return true
+		}
+	}
+
+	return false
+}
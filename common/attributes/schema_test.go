@@ -0,0 +1,124 @@
+package attributes
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"additionalProperties": false,
+	"required": ["brand"],
+	"properties": {
+		"brand": {"type": "string", "enum": ["Acme", "Globex"]},
+		"code": {"type": "string", "minLength": 2, "maxLength": 4, "pattern": "^[A-Z]+$"},
+		"opened": {"type": "string", "format": "date"},
+		"floors": {"type": "integer", "minimum": 1, "maximum": 5},
+		"rating": {"type": "number", "exclusiveMinimum": 0, "exclusiveMaximum": 5},
+		"parking": {"type": "boolean"},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+		"manager": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {"name": {"type": "string"}, "phone": {"type": "string"}}
+		}
+	}
+}`
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{name: "Valid schema", schema: testSchema},
+		{name: "Root not an object", schema: `{"type": "string"}`, err: "type must be object"},
+		{name: "Missing type", schema: `{"properties": {"brand": {"enum": ["Acme"]}}, "type": "object"}`,
+			err: "properties.brand.type must be one of [array boolean integer number object string]"},
+		{name: "Unsupported keyword", schema: `{"type": "object", "$ref": "#/definitions/site"}`,
+			err: "$ref is not supported for type object"},
+		{name: "Keyword of another type", schema: `{"type": "object", "properties": {"floors": {"type": "integer",
+			"maxLength": 2}}}`, err: "properties.floors.maxLength is not supported for type integer"},
+		{name: "Invalid attribute name", schema: `{"type": "object", "properties": {"Brand Name": {"type": "string"}}}`,
+			err: "properties.Brand Name must match ^[a-z][a-z0-9_]{0,63}$"},
+		{name: "Nested arrays", schema: `{"type": "object", "properties": {"grid": {"type": "array",
+			"items": {"type": "array"}}}}`, err: "properties.grid.items can't be an array, nested arrays are not supported"},
+		{name: "Enum value of another type", schema: `{"type": "object", "properties": {"floors": {"type": "integer",
+			"enum": [1, "two"]}}}`, err: "properties.floors.enum[1] must be of type integer"},
+		{name: "Invalid pattern", schema: `{"type": "object", "properties": {"code": {"type": "string",
+			"pattern": "["}}}`, err: "properties.code.pattern must be a valid regular expression : " +
+			"error parsing regexp: missing closing ]: `[`"},
+		{name: "Unsupported format", schema: `{"type": "object", "properties": {"email": {"type": "string",
+			"format": "email"}}}`, err: "properties.email.format must be one of [date date-time]"},
+		{name: "Negative count", schema: `{"type": "object", "properties": {"code": {"type": "string",
+			"minLength": -1}}}`, err: "properties.code.minLength must be a non-negative integer"},
+		{name: "Duplicate required property", schema: `{"type": "object", "required": ["brand", "brand"]}`,
+			err: "required must be an array of unique property names"},
+		{name: "Schema too deep", schema: `{"type": "object", "properties": {"a": {"type": "object", "properties":
+			{"b": {"type": "object", "properties": {"c": {"type": "object", "properties": {"d": {"type": "object",
+			"properties": {"e": {"type": "object", "properties": {"f": {"type": "object"}}}}}}}}}}}}}`,
+			err: "properties.a.properties.b.properties.c.properties.d.properties.e.properties.f is nested deeper " +
+				"than 5 levels"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Compile(getRawSchema(t, tt.schema))
+			if tt.err == "" {
+				assert.Nil(t, err)
+				assert.NotNil(t, schema)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := Compile(getRawSchema(t, testSchema))
+	assert.Nil(t, err)
+	tests := []struct {
+		name       string
+		attributes string
+		errs       []string
+	}{
+		{name: "Valid attributes", attributes: `{"brand": "Acme", "code": "NYC", "opened": "2022-10-28",
+			"floors": 3, "rating": 4.5, "parking": true, "tags": ["a", "b"], "manager": {"name": "Jo"}}`},
+		{name: "Missing required attribute", attributes: `{"floors": 2}`,
+			errs: []string{"attributes.brand is required"}},
+		{name: "Undeclared attribute", attributes: `{"brand": "Acme", "color": "red"}`,
+			errs: []string{"attributes.color is not a declared attribute"}},
+		{name: "Value not in the enum", attributes: `{"brand": "Initech"}`,
+			errs: []string{"attributes.brand must be one of [Acme Globex]"}},
+		{name: "Invalid string", attributes: `{"brand": "Acme", "code": "a", "opened": "28/10/2022"}`,
+			errs: []string{"attributes.code must have at least 2 characters",
+				"attributes.code must match the pattern ^[A-Z]+$", "attributes.opened must be a date"}},
+		{name: "Invalid numbers", attributes: `{"brand": "Acme", "floors": 2.5, "rating": 5}`,
+			errs: []string{"attributes.floors must be of type integer", "attributes.rating must be < 5"}},
+		{name: "Number out of range", attributes: `{"brand": "Acme", "floors": 0}`,
+			errs: []string{"attributes.floors must be >= 1"}},
+		{name: "Invalid array", attributes: `{"brand": "Acme", "tags": ["a", "a", 1]}`,
+			errs: []string{"attributes.tags must have at most 2 items", "attributes.tags must have unique items",
+				"attributes.tags[2] must be of type string"}},
+		{name: "Invalid nested object", attributes: `{"brand": "Acme", "manager": {"phone": 5, "email": "x"}}`,
+			errs: []string{"attributes.manager.name is required", "attributes.manager.phone must be of type string"}},
+		{name: "Value of the wrong type", attributes: `{"brand": "Acme", "parking": "yes"}`,
+			errs: []string{"attributes.parking must be of type boolean"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.errs, schema.Validate(getRawSchema(t, tt.attributes), "attributes"))
+		})
+	}
+
+	t.Run("Firestore integers", func(t *testing.T) {
+		assert.Nil(t, schema.Validate(map[string]interface{}{"brand": "Acme", "floors": int64(2)}, "attributes"))
+	})
+}
+
+func getRawSchema(t *testing.T, data string) map[string]interface{} {
+	var raw map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(data), &raw))
+
+	return raw
+}
@@ -1,9 +1,13 @@
 package audit
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/audit/models"
+	"strings"
 	"time"
 )
 
@@ -49,6 +53,44 @@ func NewAuditLog(changedBy string, changeType string,
 	}
 }
 
+// GetAuditLogID returns the ID of the audit log of the message derived from its path, correlation ID,
+// changed_at and a hash of its content, so that a redelivered message maps to the audit log already saved
+func GetAuditLogID(msg *PubSubAuditMessage) (string, error) {
+	content, err := json.Marshal(struct {
+		ChangedBy     string                 `json:"changed_by"`
+		EntityChanged string                 `json:"entity_changed"`
+		ChangeType    string                 `json:"change_type"`
+		OldEntity     map[string]interface{} `json:"old_entity"`
+		NewEntity     map[string]interface{} `json:"new_entity"`
+	}{msg.ChangedBy, msg.EntityChanged, msg.ChangeType, msg.OldEntity, msg.NewEntity})
+	if err != nil {
+		return "", err
+	}
+	var changedAt string
+	if msg.ChangedAt != nil {
+		changedAt = msg.ChangedAt.UTC().Format(time.RFC3339Nano)
+	}
+	contentHash := sha256.Sum256(content)
+	id := sha256.Sum256([]byte(strings.Join(
+		[]string{msg.Path, msg.XCorrelationID, changedAt, hex.EncodeToString(contentHash[:])}, "\n")))
+
+	return hex.EncodeToString(id[:]), nil
+}
+
+// NewDeadLetter returns the dead letter of the payload identified by its hash,
+// so that a redelivered payload is dead lettered only once
+func NewDeadLetter(payload []byte, reason string) *models.DeadLetter {
+	currentTime := time.Now().UTC().Round(time.Second)
+	id := sha256.Sum256(payload)
+
+	return &models.DeadLetter{
+		ID:          hex.EncodeToString(id[:]),
+		Payload:     string(payload),
+		Reason:      reason,
+		CreatedTime: &currentTime,
+	}
+}
+
 // GetRetailerAuditPath will return the firestore path at which the audit for the retailerID should be stored
 func GetRetailerAuditPath(retailerID string) string {
 	return fmt.Sprintf("%s/%s/%s",
@@ -66,3 +108,13 @@ func GetSiteAuditPath(retailerID, siteID string) string {
 		siteID,
 		common.SiteAuditCollection)
 }
+
+// GetSpokeAuditPath will return the firestore path at which the audit for the retailerID,spokeID should be stored
+func GetSpokeAuditPath(retailerID, spokeID string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s",
+		common.RetailersCollection,
+		retailerID,
+		common.SpokesCollection,
+		spokeID,
+		common.SpokeAuditCollection)
+}
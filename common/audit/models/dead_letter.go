@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// DeadLetter is an audit message which can never be pushed as an audit log, kept to be looked into and replayed
+type DeadLetter struct {
+	ID          string     `json:"id" firestore:"id"`
+	Payload     string     `json:"payload" firestore:"payload"`
+	Reason      string     `json:"reason" firestore:"reason"`
+	CreatedTime *time.Time `json:"created_time" firestore:"created_time"`
+}
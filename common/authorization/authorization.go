@@ -0,0 +1,242 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"github.com/TakeoffTech/go-telemetry/sdpropagation"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/go-andiamo/urit"
+	"net/http"
+)
+
+// roleRanks orders the roles, a role having the permissions of the roles of a lower rank
+var roleRanks = map[string]int{common.RoleReader: 1, common.RoleEditor: 2, common.RoleAdmin: 3}
+
+// Permission is the role required by a function on the retailer of its requests
+type Permission struct {
+	Role string
+	// Retailer returns the ID of the retailer of the request, common.AllRetailers for the operations across retailers
+	Retailer func(request *http.Request) string
+	// AnyRetailer is whether the role on any retailer is enough, the handler restricting the request to the retailers
+	// returned by GetGrantedRetailers
+	AnyRetailer bool
+}
+
+// AuthorizedHandler handles a request authorized by Authorize, with the DB client its grants were read with
+type AuthorizedHandler func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB)
+
+// ctxGrants is the context key of the grants of the principal of an authorized request
+type ctxGrants struct{}
+
+// authorizedGrants are the grants of the principal of an authorized request, all the retailers are allowed when
+// the requests are not authorized
+type authorizedGrants struct {
+	grants       []Grant
+	unrestricted bool
+}
+
+// OnHeaderRetailer returns the permission of the role on the retailer of the retailer_id header,
+// the retailer of the sites and the spokes
+func OnHeaderRetailer(role string) Permission {
+	return Permission{Role: role, Retailer: func(request *http.Request) string {
+		return request.Header.Get(common.HeaderRetailerID)
+	}}
+}
+
+// OnPathRetailer returns the permission of the role on the retailer of the retailer_id path param of the path
+func OnPathRetailer(role string, path urit.Template) Permission {
+	return Permission{Role: role, Retailer: func(request *http.Request) string {
+		pathVars, ok := path.Matches(request.URL.Path)
+		if !ok {
+			return ""
+		}
+		for _, pathVar := range pathVars.GetAll() {
+			if value, ok := pathVar.Value.(string); ok && pathVar.Name == common.PathParamRetailerID {
+				return value
+			}
+		}
+
+		return ""
+	}}
+}
+
+// OnAnyRetailer returns the permission of the role on any retailer, e.g. to list the retailers the principal has the
+// role on
+func OnAnyRetailer(role string) Permission {
+	return Permission{Role: role, AnyRetailer: true, Retailer: func(_ *http.Request) string {
+		return ""
+	}}
+}
+
+// OnAllRetailers returns the permission of the role on every retailer, e.g. to create a retailer
+func OnAllRetailers(role string) Permission {
+	return Permission{Role: role, Retailer: func(_ *http.Request) string {
+		return common.AllRetailers
+	}}
+}
+
+// IsAllowed returns whether the grants have the role, or a role of a higher rank, on the retailer.
+// A grant on common.AllRetailers applies to every retailer
+func IsAllowed(grants []Grant, role string, retailerID string) bool {
+	for _, grant := range grants {
+		if ((grant.RetailerID == retailerID && retailerID != "") || grant.RetailerID == common.AllRetailers) &&
+			roleRanks[grant.Role] >= roleRanks[role] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Authorize returns the HTTP function of the handler, which every function registers. It starts the span named
+// spanName, adds the logger and the authenticated principal to the context of the request and calls the handler
+// only when AuthorizeRequest allows the request, so no handler is called for a request which was not authorized
+func Authorize(spanName string, permission Permission, handler AuthorizedHandler) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		ctx, span := sdpropagation.StartSpanWithRemoteParentFromRequest(request, utils.GetSpanName(spanName))
+		defer span.End()
+		key, logger := logging.GetContextWithLogger(request)
+		requestWithContext, authenticated := identity.GetRequestWithPrincipal(responseWriter,
+			request.WithContext(context.WithValue(ctx, key, logger)))
+		if !authenticated {
+			return
+		}
+		dbClient := cloud.NewDBRepository(requestWithContext.Context())
+		authorizedRequest, authorized := authorizeRequest(responseWriter, requestWithContext, dbClient, permission)
+		if !authorized {
+			return
+		}
+		handler(responseWriter, authorizedRequest, dbClient)
+	}
+}
+
+// AuthorizeRequest checks that the principal of the request has the permission, with the grants of the grant store
+// selected by NewGrantStore. A request without an authenticated principal, when IDENTITY_MODE is not set, is responded
+// with an unauthorized. When the principal does not have the permission it responds with a forbidden, and when the
+// grants could not be read or AUTHORIZATION_MODE is not valid with an internal server error, returning false
+func AuthorizeRequest(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB,
+	permission Permission) bool {
+	_, authorized := authorizeRequest(responseWriter, request, dbClient, permission)
+
+	return authorized
+}
+
+// GetGrantedRetailers returns the IDs of the retailers the principal of the request authorized by Authorize has the
+// role on, or a role of a higher rank, e.g. to list only these retailers. It returns nil when the principal has the
+// role on every retailer or when AUTHORIZATION_MODE is none, and no retailer for a request which was not authorized
+func GetGrantedRetailers(request *http.Request, role string) []string {
+	authorized, ok := request.Context().Value(ctxGrants{}).(authorizedGrants)
+	if !ok {
+		return []string{}
+	}
+	if authorized.unrestricted || IsAllowed(authorized.grants, role, common.AllRetailers) {
+		return nil
+	}
+	retailerIDs := []string{}
+	for _, grant := range authorized.grants {
+		if grant.RetailerID != "" && !utils.Contains(retailerIDs, grant.RetailerID) &&
+			IsAllowed([]Grant{grant}, role, grant.RetailerID) {
+			retailerIDs = append(retailerIDs, grant.RetailerID)
+		}
+	}
+
+	return retailerIDs
+}
+
+// authorizeRequest checks the permission like AuthorizeRequest and returns the request with the grants of its
+// principal in its context
+func authorizeRequest(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB,
+	permission Permission) (*http.Request, bool) {
+	grantStore, ok := getGrantStore(responseWriter, request, dbClient)
+	if !ok {
+		return request, false
+	}
+	if grantStore == nil {
+		return request.WithContext(context.WithValue(request.Context(), ctxGrants{},
+			authorizedGrants{unrestricted: true})), true
+	}
+	principal, grants, ok := getPrincipalGrants(responseWriter, request, grantStore)
+	if !ok {
+		return request, false
+	}
+	authorizedRequest := request.WithContext(context.WithValue(request.Context(), ctxGrants{},
+		authorizedGrants{grants: grants}))
+	if permission.AnyRetailer {
+		if retailerIDs := GetGrantedRetailers(authorizedRequest, permission.Role); retailerIDs != nil &&
+			len(retailerIDs) == 0 {
+			respondWithForbidden(responseWriter, request,
+				fmt.Sprintf("%s does not have the %s role on any retailer", principal, permission.Role))
+
+			return request, false
+		}
+
+		return authorizedRequest, true
+	}
+	retailerID := permission.Retailer(request)
+	if IsAllowed(grants, permission.Role, retailerID) {
+		return authorizedRequest, true
+	}
+
+	message := fmt.Sprintf("%s does not have the %s role on the retailer %s", principal, permission.Role, retailerID)
+	if retailerID == common.AllRetailers {
+		message = fmt.Sprintf("%s does not have the %s role on all the retailers", principal, permission.Role)
+	}
+	respondWithForbidden(responseWriter, request, message)
+
+	return request, false
+}
+
+// getGrantStore returns the grant store selected by NewGrantStore, nil when AUTHORIZATION_MODE is none.
+// When it could not be created, e.g. AUTHORIZATION_MODE is not set, it responds with an internal server error,
+// returning false
+func getGrantStore(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) (GrantStore, bool) {
+	grantStore, err := NewGrantStore(dbClient)
+	if err != nil {
+		logging.GetLoggerFromContext(request.Context()).
+			Errorf("Error occurred while creating the grant store : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return nil, false
+	}
+
+	return grantStore, true
+}
+
+// getPrincipalGrants returns the authenticated principal of the request and its grants. A request without an
+// authenticated principal is responded with an unauthorized, as the grants of a principal which was not authenticated
+// must not be applied, and when the grants could not be read with an internal server error, returning false
+func getPrincipalGrants(responseWriter http.ResponseWriter, request *http.Request,
+	grantStore GrantStore) (string, []Grant, bool) {
+	logger := logging.GetLoggerFromContext(request.Context())
+	principal, authenticated := identity.GetAuthenticatedPrincipalFromContext(request.Context())
+	if !authenticated {
+		logger.Errorf("Request not authorized, %s requires an authenticated principal but %s is not set",
+			common.EnvAuthorizationMode, common.EnvIdentityMode)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnauthorized, "Request not authenticated", nil),
+			response.GetCommonResponseHeaders(request))
+
+		return "", nil, false
+	}
+	grants, err := grantStore.GetGrants(request.Context(), principal)
+	if err != nil {
+		logger.Errorf("Error occurred while reading the grants of %s : %v", principal, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return "", nil, false
+	}
+
+	return principal, grants, true
+}
+
+func respondWithForbidden(responseWriter http.ResponseWriter, request *http.Request, message string) {
+	logging.GetLoggerFromContext(request.Context()).Debugf("Request not authorized : %s", message)
+	response.RespondWithResponseObject(responseWriter,
+		response.NewResponse(http.StatusForbidden, "Request not authorized", []string{message}),
+		response.GetCommonResponseHeaders(request))
+}
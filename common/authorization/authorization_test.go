@@ -0,0 +1,298 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/identity"
+	"github.com/go-andiamo/urit"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestGrants writes the grants file of the tests and returns its path
+func writeTestGrants(t *testing.T, grants string) string {
+	path := filepath.Join(t.TempDir(), "grants.json")
+	assert.Nil(t, os.WriteFile(path, []byte(grants), 0o600))
+
+	return path
+}
+
+func getPrincipalRequest(principal string, path string, retailerID string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set(common.HeaderRetailerID, retailerID)
+
+	return request.WithContext(context.WithValue(request.Context(), identity.CtxPrincipal{}, principal))
+}
+
+func TestIsAllowed(t *testing.T) {
+	grants := []Grant{{RetailerID: "r1", Role: common.RoleEditor}, {RetailerID: "r2", Role: common.RoleReader}}
+	tests := []struct {
+		name       string
+		grants     []Grant
+		role       string
+		retailerID string
+		allowed    bool
+	}{
+		{name: "Role of the grant", grants: grants, role: common.RoleEditor, retailerID: "r1", allowed: true},
+		{name: "Role of a lower rank", grants: grants, role: common.RoleReader, retailerID: "r1", allowed: true},
+		{name: "Role of a higher rank", grants: grants, role: common.RoleEditor, retailerID: "r2"},
+		{name: "Other retailer", grants: grants, role: common.RoleReader, retailerID: "r3"},
+		{name: "No retailer", grants: grants, role: common.RoleReader},
+		{name: "Retailer grants on all the retailers", grants: grants, role: common.RoleReader,
+			retailerID: common.AllRetailers},
+		{name: "Grant on all the retailers", grants: []Grant{{RetailerID: common.AllRetailers, Role: common.RoleAdmin}},
+			role: common.RoleAdmin, retailerID: "r3", allowed: true},
+		{name: "No grants", role: common.RoleReader, retailerID: "r1"},
+		{name: "Unknown role", grants: []Grant{{RetailerID: "r1", Role: "owner"}}, role: common.RoleReader,
+			retailerID: "r1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, IsAllowed(tt.grants, tt.role, tt.retailerID))
+		})
+	}
+}
+
+func TestOnPathRetailer(t *testing.T) {
+	path := urit.MustCreateTemplate(fmt.Sprintf("/retailers/{%s}", common.PathParamRetailerID))
+	permission := OnPathRetailer(common.RoleReader, path)
+	assert.Equal(t, "r1", permission.Retailer(httptest.NewRequest(http.MethodGet, "/retailers/r1", nil)))
+	assert.Equal(t, "", permission.Retailer(httptest.NewRequest(http.MethodGet, "/sites", nil)))
+}
+
+func TestGrantStores(t *testing.T) {
+	t.Run("File grant store", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"editor"}]}`))
+		grantStore, err := NewGrantStore(nil)
+		assert.Nil(t, err)
+		grants, err := grantStore.GetGrants(context.Background(), "jo@example.com")
+		assert.Nil(t, err)
+		assert.Equal(t, []Grant{{RetailerID: "r1", Role: common.RoleEditor}}, grants)
+		grants, err = grantStore.GetGrants(context.Background(), "other@example.com")
+		assert.Nil(t, err)
+		assert.Empty(t, grants)
+		sameGrantStore, _ := NewGrantStore(nil)
+		assert.Same(t, grantStore, sameGrantStore)
+	})
+
+	t.Run("Invalid grants file", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile, writeTestGrants(t, `[]`))
+		_, err := NewGrantStore(nil)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("DB grant store", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeDB)
+		dbClient := cloud.NewMemoryRepository()
+		_, err := dbClient.Save(context.Background(), common.GrantsCollection, "jo@example.com",
+			map[string]interface{}{common.ID: "jo@example.com", common.Grants: []interface{}{
+				map[string]interface{}{"retailer_id": common.AllRetailers, "role": common.RoleReader}}})
+		assert.Nil(t, err)
+		grantStore, err := NewGrantStore(dbClient)
+		assert.Nil(t, err)
+		grants, err := grantStore.GetGrants(context.Background(), "jo@example.com")
+		assert.Nil(t, err)
+		assert.Equal(t, []Grant{{RetailerID: common.AllRetailers, Role: common.RoleReader}}, grants)
+		grants, err = grantStore.GetGrants(context.Background(), "other@example.com")
+		assert.Nil(t, err)
+		assert.Empty(t, grants)
+	})
+
+	t.Run("None authorization mode", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+		grantStore, err := NewGrantStore(nil)
+		assert.Nil(t, err)
+		assert.Nil(t, grantStore)
+	})
+
+	t.Run("Unset or unknown authorization mode", func(t *testing.T) {
+		for _, mode := range []string{"", "DB", "off"} {
+			t.Setenv(common.EnvAuthorizationMode, mode)
+			_, err := NewGrantStore(cloud.NewMemoryRepository())
+			assert.EqualError(t, err, "AUTHORIZATION_MODE must be file, db or none, not '"+mode+"'")
+		}
+	})
+}
+
+func TestAuthorizeRequest(t *testing.T) {
+	t.Run("None authorization mode", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+		w := httptest.NewRecorder()
+		assert.True(t, AuthorizeRequest(w, getPrincipalRequest("jo@example.com", "/sites", "r1"), nil,
+			OnHeaderRetailer(common.RoleAdmin)))
+	})
+
+	t.Run("Unset or unknown authorization mode", func(t *testing.T) {
+		for _, mode := range []string{"", "DB"} {
+			t.Setenv(common.EnvAuthorizationMode, mode)
+			w := httptest.NewRecorder()
+			assert.False(t, AuthorizeRequest(w, getPrincipalRequest("jo@example.com", "/sites", "r1"), nil,
+				OnHeaderRetailer(common.RoleReader)))
+			assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("Authorized request", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"editor"}]}`))
+		w := httptest.NewRecorder()
+		assert.True(t, AuthorizeRequest(w, getPrincipalRequest("jo@example.com", "/sites", "r1"), nil,
+			OnHeaderRetailer(common.RoleReader)))
+	})
+
+	t.Run("Request on another retailer", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"editor"}]}`))
+		w := httptest.NewRecorder()
+		assert.False(t, AuthorizeRequest(w, getPrincipalRequest("jo@example.com", "/sites", "r2"), nil,
+			OnHeaderRetailer(common.RoleReader)))
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":403,\"message\":\"Request not authorized\",\"errors\":"+
+			"[\"jo@example.com does not have the reader role on the retailer r2\"]}", string(bytes))
+	})
+
+	t.Run("Request across the retailers", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"admin"}]}`))
+		w := httptest.NewRecorder()
+		assert.False(t, AuthorizeRequest(w, getPrincipalRequest("jo@example.com", "/retailers", ""), nil,
+			OnAllRetailers(common.RoleAdmin)))
+		assert.Equal(t, http.StatusForbidden, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Contains(t, string(bytes), "jo@example.com does not have the admin role on all the retailers")
+	})
+
+	t.Run("Request without an authenticated principal", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"`+common.User+`":[{"retailer_id":"*","role":"admin"}]}`))
+		w := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/sites", nil)
+		request.Header.Set(common.HeaderRetailerID, "r1")
+		assert.False(t, AuthorizeRequest(w, request, nil, OnHeaderRetailer(common.RoleReader)))
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+
+	t.Run("Missing grants file", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile, filepath.Join(t.TempDir(), "missing.json"))
+		w := httptest.NewRecorder()
+		assert.False(t, AuthorizeRequest(w, getPrincipalRequest("jo@example.com", "/sites", "r1"), nil,
+			OnHeaderRetailer(common.RoleReader)))
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
+
+// authorizeTestRequest authorizes a request of the principal with the permission through Authorize and returns the
+// response and the request passed to the handler, nil when the handler was not called
+func authorizeTestRequest(t *testing.T, principal string, permission Permission) (*http.Response, *http.Request) {
+	t.Setenv(common.EnvDBBackend, common.DBBackendMemory)
+	t.Setenv(common.EnvIdentityMode, common.IdentityModeGateway)
+	var authorizedRequest *http.Request
+	function := Authorize("authorization_test.authorize", permission,
+		func(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB) {
+			authorizedRequest = request
+			responseWriter.WriteHeader(http.StatusOK)
+		})
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/sites", nil)
+	request.Header.Set(common.HeaderRetailerID, "r1")
+	if principal != "" {
+		request.Header.Set(common.DefaultIdentityGatewayHeader, principal)
+	}
+	function(w, request)
+
+	return w.Result(), authorizedRequest
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Run("Authorized request", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"reader"}]}`))
+		response, request := authorizeTestRequest(t, "jo@example.com", OnHeaderRetailer(common.RoleReader))
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.NotNil(t, request)
+	})
+
+	t.Run("Request not authorized", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"reader"}]}`))
+		response, request := authorizeTestRequest(t, "jo@example.com", OnHeaderRetailer(common.RoleEditor))
+		assert.Equal(t, http.StatusForbidden, response.StatusCode)
+		assert.Nil(t, request)
+	})
+
+	t.Run("Request without an identity", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+		response, request := authorizeTestRequest(t, "", OnHeaderRetailer(common.RoleReader))
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+		assert.Nil(t, request)
+	})
+
+	t.Run("Unset or unknown authorization mode", func(t *testing.T) {
+		for _, mode := range []string{"", "DB"} {
+			t.Setenv(common.EnvAuthorizationMode, mode)
+			response, request := authorizeTestRequest(t, "jo@example.com", OnHeaderRetailer(common.RoleReader))
+			assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+			assert.Nil(t, request)
+		}
+	})
+}
+
+func TestGetGrantedRetailers(t *testing.T) {
+	t.Run("None authorization mode", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeNone)
+		_, request := authorizeTestRequest(t, "jo@example.com", OnAnyRetailer(common.RoleReader))
+		assert.Nil(t, GetGrantedRetailers(request, common.RoleReader))
+	})
+
+	t.Run("Grant on all the retailers", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"admin"},`+
+				`{"retailer_id":"*","role":"reader"}]}`))
+		_, request := authorizeTestRequest(t, "jo@example.com", OnAnyRetailer(common.RoleReader))
+		assert.Nil(t, GetGrantedRetailers(request, common.RoleReader))
+	})
+
+	t.Run("Grants on some retailers", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile, writeTestGrants(t, `{"jo@example.com":[`+
+			`{"retailer_id":"r1","role":"editor"},{"retailer_id":"r1","role":"reader"},`+
+			`{"retailer_id":"r2","role":"reader"}]}`))
+		_, request := authorizeTestRequest(t, "jo@example.com", OnAnyRetailer(common.RoleReader))
+		assert.Equal(t, []string{"r1", "r2"}, GetGrantedRetailers(request, common.RoleReader))
+	})
+
+	t.Run("No grant of the role", func(t *testing.T) {
+		t.Setenv(common.EnvAuthorizationMode, common.AuthorizationModeFile)
+		t.Setenv(common.EnvAuthorizationGrantsFile,
+			writeTestGrants(t, `{"jo@example.com":[{"retailer_id":"r1","role":"reader"}]}`))
+		response, request := authorizeTestRequest(t, "jo@example.com", OnAnyRetailer(common.RoleEditor))
+		assert.Nil(t, request)
+		assert.Equal(t, http.StatusForbidden, response.StatusCode)
+		bytes, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(bytes), "jo@example.com does not have the editor role on any retailer")
+	})
+
+	t.Run("Request which was not authorized", func(t *testing.T) {
+		assert.Equal(t, []string{}, GetGrantedRetailers(httptest.NewRequest(http.MethodGet, "/retailers", nil),
+			common.RoleReader))
+	})
+}
@@ -0,0 +1,118 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"sync"
+)
+
+// Grant is a role of a principal on a retailer, or on every retailer with common.AllRetailers
+type Grant struct {
+	RetailerID string `json:"retailer_id" firestore:"retailer_id" structs:"retailer_id"`
+	Role       string `json:"role" firestore:"role" structs:"role"`
+}
+
+// GrantStore returns the grants of a principal, none when the principal is not known
+type GrantStore interface {
+	GetGrants(ctx context.Context, principal string) ([]Grant, error)
+}
+
+// fileGrantStores are the FileGrantStore returned by NewGrantStore by file, shared across the requests
+var fileGrantStores = map[string]*FileGrantStore{}
+var fileGrantStoresMutex sync.Mutex
+
+// NewGrantStore returns the grant store selected by the AUTHORIZATION_MODE env variable, the FileGrantStore of the
+// AUTHORIZATION_GRANTS_FILE file when it is file and the DBGrantStore of the DB when it is db.
+// It returns nil when it is none, the requests are then not authorized, and an error when it is not set or has
+// another value so a missing or misspelled mode never allows every request
+func NewGrantStore(dbClient cloud.DB) (GrantStore, error) {
+	mode := os.Getenv(common.EnvAuthorizationMode)
+	switch mode {
+	case common.AuthorizationModeNone:
+		return nil, nil
+	case common.AuthorizationModeDB:
+		return NewDBGrantStore(dbClient), nil
+	case common.AuthorizationModeFile:
+		path := os.Getenv(common.EnvAuthorizationGrantsFile)
+		fileGrantStoresMutex.Lock()
+		defer fileGrantStoresMutex.Unlock()
+		if grantStore, ok := fileGrantStores[path]; ok {
+			return grantStore, nil
+		}
+		grantStore, err := NewFileGrantStoreFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fileGrantStores[path] = grantStore
+
+		return grantStore, nil
+	}
+
+	return nil, fmt.Errorf("%s must be %s, %s or %s, not '%s'", common.EnvAuthorizationMode,
+		common.AuthorizationModeFile, common.AuthorizationModeDB, common.AuthorizationModeNone, mode)
+}
+
+// FileGrantStore returns the grants it is created with, e.g. the grants of a file deployed with the functions
+type FileGrantStore struct {
+	grants map[string][]Grant
+}
+
+// NewFileGrantStore returns a FileGrantStore of the grants by principal
+func NewFileGrantStore(grants map[string][]Grant) *FileGrantStore {
+	return &FileGrantStore{grants: grants}
+}
+
+// NewFileGrantStoreFromFile returns a FileGrantStore of a JSON file with the list of the grants of each principal
+func NewFileGrantStoreFromFile(path string) (*FileGrantStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var grants map[string][]Grant
+	if err = json.Unmarshal(data, &grants); err != nil {
+		return nil, fmt.Errorf("invalid grants file %s : %w", path, err)
+	}
+
+	return NewFileGrantStore(grants), nil
+}
+
+// GetGrants returns the grants of the principal
+func (f *FileGrantStore) GetGrants(_ context.Context, principal string) ([]Grant, error) {
+	return f.grants[principal], nil
+}
+
+// DBGrantStore returns the grants of the document of the principal in common.GrantsCollection
+type DBGrantStore struct {
+	dbClient cloud.DB
+}
+
+// NewDBGrantStore returns a DBGrantStore of the DB
+func NewDBGrantStore(dbClient cloud.DB) *DBGrantStore {
+	return &DBGrantStore{dbClient: dbClient}
+}
+
+// GetGrants returns the grants field of the document of the principal
+func (d *DBGrantStore) GetGrants(ctx context.Context, principal string) ([]Grant, error) {
+	document, err := d.dbClient.GetByID(ctx, common.GrantsCollection, principal, false)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var grantsDocument struct {
+		Grants []Grant `json:"grants"`
+	}
+	if err = utils.ConvertToObject(document, &grantsDocument); err != nil {
+		return nil, fmt.Errorf("invalid grants of %s : %w", principal, err)
+	}
+
+	return grantsDocument.Grants, nil
+}
@@ -10,7 +10,7 @@ import (
 // This interface is a common interface for the DB operations
 type DB interface {
 	GetAll(ctx context.Context, collectionPath string, pageDetails Page,
-		whereClauses []Where) ([]map[string]interface{}, string, error)
+		whereClauses []Where) ([]map[string]interface{}, *Cursor, error)
 	GetByID(ctx context.Context, collectionPath string, documentID string,
 		skipDeactivated bool) (map[string]interface{}, error)
 	Exists(ctx context.Context, collectionPath string, field string, value string) (bool, error)
@@ -21,17 +21,64 @@ type DB interface {
 		collectionPath string, documentID string, document []firestore.Update) (time.Time, error)
 	CheckSubDocuments(ctx context.Context, collectionPath string, documentID string) (bool, error)
 	Delete(ctx context.Context, collectionPath string, documentID string) (bool, error)
+	SaveInTransaction(ctx context.Context, collectionPath string, documentID string, document interface{},
+		options WriteOptions) (time.Time, error)
+	UpdateInTransaction(ctx context.Context, collectionPath string, documentID string, updates []firestore.Update,
+		options WriteOptions) (time.Time, error)
+	DeleteInTransaction(ctx context.Context, collectionPath string, documentID string,
+		options WriteOptions) (bool, error)
 }
 
 // Queue interface
 // This interface is a common interface for the Queue/PubSub operations
 type Queue interface {
-	Publish(ctx context.Context, topicName string, response any)
+	Publish(ctx context.Context, topicName string, response any) error
 }
 
+// WriteOptions are the additional writes and checks committed atomically with a document write
+type WriteOptions struct {
+	// ReserveKeys must not be held by any other document, they are reserved for the written document
+	ReserveKeys []UniqueKey
+	// ReleaseKeys are no longer held by the written document, e.g. the old name after a rename
+	ReleaseKeys []UniqueKey
+	// Creates are the other documents to be created along with the written document
+	Creates []Document
+	// Updates are the updates on other existing documents to be written along with the written document
+	Updates []DocumentUpdate
+	// Revision is the revision the updated document must have, a document whose revision changed since it was read is
+	// not written and a FailedPrecondition error is returned. It is not checked when nil
+	Revision *int64
+}
+
+// Document identifies a document to be written by its collection path and ID
+type Document struct {
+	CollectionPath string
+	DocumentID     string
+	Data           interface{}
+}
+
+// DocumentUpdate identifies the updates on a document by its collection path and ID.
+// Like WriteOptions.Revision, the Revision the document must have is checked when it is not nil,
+// a DocumentUpdate without Updates only checks the Revision
+type DocumentUpdate struct {
+	CollectionPath string
+	DocumentID     string
+	Updates        []firestore.Update
+	Revision       *int64
+}
+
+// Page describes a page of the documents ordered by the OrderBy fields.
+// The document ID orders the documents with the same OrderBy values, in the direction of the last OrderBy field,
+// so a page starts exactly after the StartAfter cursor even if the OrderBy values are not unique
 type Page struct {
-	StartAfterID any
-	PageSize     int
-	OrderBy      string
-	Sort         firestore.Direction
+	// StartAfter is the cursor of the last document of the previous page, nil for the first page
+	StartAfter *Cursor
+	PageSize   int
+	OrderBy    []Order
+}
+
+// Order is a field the documents are ordered by
+type Order struct {
+	Field string
+	Sort  firestore.Direction
 }
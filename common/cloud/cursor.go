@@ -0,0 +1,144 @@
+package cloud
+
+import (
+	"cloud.google.com/go/firestore"
+	"encoding/json"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cursor is the position of a document in an ordered query, the values of its OrderBy fields and its ID
+type Cursor struct {
+	Values []any
+	ID     string
+}
+
+// cursorValue keeps the type of a cursor value when encoded, a value of a timestamp field stays a time
+type cursorValue struct {
+	String *string    `json:"s,omitempty"`
+	Int    *int64     `json:"i,omitempty"`
+	Float  *float64   `json:"f,omitempty"`
+	Bool   *bool      `json:"b,omitempty"`
+	Time   *time.Time `json:"t,omitempty"`
+}
+
+type encodedCursor struct {
+	Values []cursorValue `json:"v"`
+	ID     string        `json:"id"`
+}
+
+// Encode returns the cursor as a string to be passed in a page token
+func (c *Cursor) Encode() (string, error) {
+	encoded := encodedCursor{ID: c.ID, Values: make([]cursorValue, 0, len(c.Values))}
+	for _, value := range c.Values {
+		var typedValue cursorValue
+		switch value := normalizeValue(value).(type) {
+		case nil:
+		case string:
+			typedValue.String = &value
+		case int64:
+			typedValue.Int = &value
+		case float64:
+			typedValue.Float = &value
+		case bool:
+			typedValue.Bool = &value
+		case time.Time:
+			typedValue.Time = &value
+		default:
+			return "", fmt.Errorf("unsupported cursor value type %T", value)
+		}
+		encoded.Values = append(encoded.Values, typedValue)
+	}
+	bytes, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+// DecodeCursor returns the cursor encoded by Cursor.Encode
+// The page tokens issued before the cursors have the value of the single OrderBy field,
+// which is a time formatted with TimeParseFormat for the audit logs
+func DecodeCursor(value string) (*Cursor, error) {
+	if !strings.HasPrefix(value, "{") {
+		if changedAt, err := time.Parse(common.TimeParseFormat, value); err == nil {
+			return &Cursor{Values: []any{changedAt}}, nil
+		}
+
+		return &Cursor{Values: []any{value}}, nil
+	}
+	var encoded encodedCursor
+	if err := json.Unmarshal([]byte(value), &encoded); err != nil {
+		return nil, err
+	}
+	cursor := &Cursor{ID: encoded.ID, Values: make([]any, 0, len(encoded.Values))}
+	for _, typedValue := range encoded.Values {
+		var value any
+		switch {
+		case typedValue.String != nil:
+			value = *typedValue.String
+		case typedValue.Int != nil:
+			value = *typedValue.Int
+		case typedValue.Float != nil:
+			value = *typedValue.Float
+		case typedValue.Bool != nil:
+			value = *typedValue.Bool
+		case typedValue.Time != nil:
+			value = *typedValue.Time
+		}
+		cursor.Values = append(cursor.Values, value)
+	}
+
+	return cursor, nil
+}
+
+// GetNextPage returns the next page of the cursor, with the page token of the cursor for the query of the request
+func GetNextPage(request *http.Request, cursor *Cursor) (utils.NextPage, error) {
+	value, err := cursor.Encode()
+	if err != nil {
+		return utils.NextPage{}, err
+	}
+	token, err := utils.GetNextPageToken(request, value)
+	if err != nil {
+		return utils.NextPage{}, err
+	}
+
+	return utils.NextPage{Token: token, Cursor: value}, nil
+}
+
+// DecodeNextPageToken returns the cursor of the page_token header of the request, nil if the header is not passed
+func DecodeNextPageToken(request *http.Request, legacyKey string) (*Cursor, error) {
+	if request.Header.Get(common.HeaderPageToken) == "" {
+		return nil, nil
+	}
+	value, err := utils.DecodeNextPageToken(request, legacyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeCursor(value)
+}
+
+// getCursorValues returns the values to start a query after the cursor, the document ID is left out
+// for the cursors of the page tokens issued before the cursors had one
+func getCursorValues(cursor *Cursor) []any {
+	if cursor.ID == "" {
+		return cursor.Values
+	}
+
+	return append(append([]any{}, cursor.Values...), cursor.ID)
+}
+
+// getIDSort returns the direction the documents with the same OrderBy values are ordered in by their ID
+func getIDSort(orders []Order) firestore.Direction {
+	if len(orders) == 0 {
+		return common.SortAscending
+	}
+
+	return orders[len(orders)-1].Sort
+}
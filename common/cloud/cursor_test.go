@@ -0,0 +1,48 @@
+package cloud
+
+import (
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCursor_Encode(t *testing.T) {
+	t.Run("Encoded cursor decoded with the value types", func(t *testing.T) {
+		changedAt := time.Date(2022, 1, 1, 10, 30, 0, 123456789, time.UTC)
+		cursor := &Cursor{Values: []any{"name", 5, 1.5, true, changedAt, nil}, ID: "p12345"}
+		encoded, err := cursor.Encode()
+		assert.Nil(t, err)
+		decoded, err := DecodeCursor(encoded)
+		assert.Nil(t, err)
+		assert.Equal(t, "p12345", decoded.ID)
+		assert.Equal(t, []any{"name", int64(5), 1.5, true}, decoded.Values[:4])
+		assert.True(t, changedAt.Equal(decoded.Values[4].(time.Time)))
+		assert.Nil(t, decoded.Values[5])
+	})
+
+	t.Run("Unsupported value type", func(t *testing.T) {
+		_, err := (&Cursor{Values: []any{map[string]interface{}{"lat": 1.5}}}).Encode()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestDecodeCursor(t *testing.T) {
+	t.Run("Cursor of a page token issued before the cursors", func(t *testing.T) {
+		cursor, err := DecodeCursor("r12345")
+		assert.Nil(t, err)
+		assert.Equal(t, &Cursor{Values: []any{"r12345"}}, cursor)
+	})
+
+	t.Run("Time cursor of a page token issued before the cursors", func(t *testing.T) {
+		changedAt := time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC)
+		cursor, err := DecodeCursor(changedAt.Format(common.TimeParseFormat))
+		assert.Nil(t, err)
+		assert.True(t, changedAt.Equal(cursor.Values[0].(time.Time)))
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		_, err := DecodeCursor("{invalid")
+		assert.NotNil(t, err)
+	})
+}
@@ -136,32 +136,23 @@ func (f *FirestoreRepository) Update(ctx context.Context,
 	return result.UpdateTime, nil
 }
 
-// GetAll will return all documents under the collectionID with deleted param
-// if skipDeactivated is passed true then where clause of deactivated_time==nil will be added to the query
+// GetAll will return a page of the documents under the collectionPath matching the whereClauses
+// along with the cursor of the last document of the page, nil if the page is empty
 func (f *FirestoreRepository) GetAll(ctx context.Context,
-	collectionPath string, pageDetails Page, whereClauses []Where) ([]map[string]interface{}, string, error) {
+	collectionPath string, pageDetails Page, whereClauses []Where) ([]map[string]interface{}, *Cursor, error) {
 	ctx, span := trace.StartSpan(ctx, utils.GetSpanName("firestore.GetAll"))
 	defer span.End()
 	var result []map[string]interface{}
-	collection := f.client.Collection(collectionPath)
-	var query firestore.Query
-	var zeroStartAfterID bool
-	//This switch case has been added so that we come to know what type of object we are dealing with
-	//Check if the startAfterID is zero value which means we don't have to add startAfter in the query
-	//if the startAfterID is non-zero then it's added to startAfter in the query
-	switch startAfterID := pageDetails.StartAfterID.(type) {
-	case time.Time:
-		zeroStartAfterID = startAfterID.IsZero()
-	default:
-		zeroStartAfterID = convert.IsZeroValue(startAfterID)
-	}
-	if !zeroStartAfterID {
-		query = collection.OrderBy(pageDetails.OrderBy, pageDetails.Sort).
-			StartAfter(pageDetails.StartAfterID).
-			Limit(pageDetails.PageSize)
-	} else {
-		query = collection.OrderBy(pageDetails.OrderBy, pageDetails.Sort).Limit(pageDetails.PageSize)
+	query := f.client.Collection(collectionPath).Query
+	for _, order := range pageDetails.OrderBy {
+		query = query.OrderBy(order.Field, order.Sort)
+	}
+	// the document ID breaks the ties of the OrderBy values
+	query = query.OrderBy(firestore.DocumentID, getIDSort(pageDetails.OrderBy))
+	if pageDetails.StartAfter != nil {
+		query = query.StartAfter(getCursorValues(pageDetails.StartAfter)...)
 	}
+	query = query.Limit(pageDetails.PageSize)
 
 	//Add all where clauses to the query
 	for _, where := range whereClauses {
@@ -172,19 +163,24 @@ func (f *FirestoreRepository) GetAll(ctx context.Context,
 	if err != nil {
 		f.logger.Errorf("Error occurred while fetching the documents from DB : %v", err)
 
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	var lastDocID string
+	var cursor *Cursor
 	if len(docs) > 0 {
-		lastDocID = convert.StringDefault(docs[len(docs)-1].Data()[pageDetails.OrderBy], "")
+		lastDoc := docs[len(docs)-1]
+		cursor = &Cursor{ID: lastDoc.Ref.ID}
+		for _, order := range pageDetails.OrderBy {
+			value, _ := lastDoc.DataAt(order.Field)
+			cursor.Values = append(cursor.Values, value)
+		}
 	}
 
 	for _, doc := range docs {
 		result = append(result, doc.Data())
 	}
 
-	return result, lastDocID, nil
+	return result, cursor, nil
 }
 
 // ExistsInCollectionGroup function is used to check if the field==value in the collectionGroup passed
@@ -247,3 +243,187 @@ func (f *FirestoreRepository) Delete(ctx context.Context, collectionPath string,
 
 	return true, nil
 }
+
+// SaveInTransaction creates the document along with the options.Creates documents in a single transaction.
+// The options.ReserveKeys are reserved for the document, if any of them is held by another document
+// a *UniqueKeyError is returned and nothing is written.
+// As transactions do not return the commit time, the time after the commit is returned
+func (f *FirestoreRepository) SaveInTransaction(ctx context.Context, collectionPath string, documentID string,
+	document interface{}, options WriteOptions) (time.Time, error) {
+	ctx, span := trace.StartSpan(ctx, utils.GetSpanName("firestore.SaveInTransaction"))
+	defer span.End()
+	documentRef := f.client.Collection(collectionPath).Doc(documentID)
+	err := f.runWriteTransaction(ctx, getDocumentPath(collectionPath, documentID), options,
+		func(tx *firestore.Transaction) error {
+			return tx.Create(documentRef, document)
+		})
+	if err != nil {
+		f.logger.Errorf("Error occurred while saving the document to DB in transaction : %v", err)
+
+		return time.Time{}, err
+	}
+
+	return time.Now().UTC(), nil
+}
+
+// UpdateInTransaction performs the updates on the document along with the options.Creates documents
+// in a single transaction, reserving options.ReserveKeys and releasing options.ReleaseKeys for the document.
+// With options.Revision the document is read in the transaction and not written if it has another revision
+func (f *FirestoreRepository) UpdateInTransaction(ctx context.Context, collectionPath string, documentID string,
+	updates []firestore.Update, options WriteOptions) (time.Time, error) {
+	ctx, span := trace.StartSpan(ctx, utils.GetSpanName("firestore.UpdateInTransaction"))
+	defer span.End()
+	documentRef := f.client.Collection(collectionPath).Doc(documentID)
+	documentPath := getDocumentPath(collectionPath, documentID)
+	err := f.runWriteTransaction(ctx, documentPath, options,
+		func(tx *firestore.Transaction) error {
+			if options.Revision != nil {
+				// the document is read in the transaction, so it fails if the document changes before the commit
+				snapshot, err := tx.Get(documentRef)
+				if err != nil {
+					return err
+				}
+				if err = checkRevision(documentPath, snapshot.Data(), options.Revision); err != nil {
+					return err
+				}
+			}
+
+			return tx.Update(documentRef, updates)
+		})
+	if err != nil {
+		f.logger.Errorf("Error occurred while updating the document to DB in transaction : %v", err)
+
+		return time.Time{}, err
+	}
+
+	return time.Now().UTC(), nil
+}
+
+// DeleteInTransaction deletes the document along with the options.Creates documents in a single transaction,
+// releasing options.ReleaseKeys held by the document.
+// With options.Revision the document is read in the transaction and not deleted if it has another revision
+func (f *FirestoreRepository) DeleteInTransaction(ctx context.Context, collectionPath string, documentID string,
+	options WriteOptions) (bool, error) {
+	ctx, span := trace.StartSpan(ctx, utils.GetSpanName("firestore.DeleteInTransaction"))
+	defer span.End()
+	documentRef := f.client.Collection(collectionPath).Doc(documentID)
+	documentPath := getDocumentPath(collectionPath, documentID)
+	err := f.runWriteTransaction(ctx, documentPath, options,
+		func(tx *firestore.Transaction) error {
+			if options.Revision != nil {
+				snapshot, err := tx.Get(documentRef)
+				if err != nil {
+					return err
+				}
+				if err = checkRevision(documentPath, snapshot.Data(), options.Revision); err != nil {
+					return err
+				}
+			}
+
+			return tx.Delete(documentRef)
+		})
+	if err != nil {
+		f.logger.Errorf("Error occurred while deleting the document from DB in transaction : %v", err)
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// runWriteTransaction runs the write along with the unique key reservations, options.Creates and options.Updates.
+// All the reservation documents are read before any write as required by firestore transactions
+func (f *FirestoreRepository) runWriteTransaction(ctx context.Context, documentPath string, options WriteOptions,
+	write func(tx *firestore.Transaction) error) error {
+	keysCollection := f.client.Collection(common.UniqueKeysCollection)
+
+	return f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		for _, key := range options.ReserveKeys {
+			owner, err := getReservationOwner(tx, keysCollection.Doc(key.ID()))
+			if err != nil {
+				return err
+			}
+			if owner != "" && owner != documentPath {
+				return &UniqueKeyError{Key: key}
+			}
+		}
+		var releasedRefs []*firestore.DocumentRef
+		for _, key := range options.releasedKeys() {
+			owner, err := getReservationOwner(tx, keysCollection.Doc(key.ID()))
+			if err != nil {
+				return err
+			}
+			if owner == documentPath {
+				releasedRefs = append(releasedRefs, keysCollection.Doc(key.ID()))
+			}
+		}
+
+		if err := f.checkRevisions(tx, options.Updates); err != nil {
+			return err
+		}
+		if err := write(tx); err != nil {
+			return err
+		}
+		for _, document := range options.Creates {
+			err := tx.Create(f.client.Collection(document.CollectionPath).Doc(document.DocumentID), document.Data)
+			if err != nil {
+				return err
+			}
+		}
+		for _, document := range options.Updates {
+			if len(document.Updates) == 0 {
+				continue
+			}
+			err := tx.Update(f.client.Collection(document.CollectionPath).Doc(document.DocumentID), document.Updates)
+			if err != nil {
+				return err
+			}
+		}
+		for _, key := range options.ReserveKeys {
+			if err := tx.Set(keysCollection.Doc(key.ID()), newUniqueKeyReservation(key, documentPath)); err != nil {
+				return err
+			}
+		}
+		for _, releasedRef := range releasedRefs {
+			if err := tx.Delete(releasedRef); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// checkRevisions reads the updated documents with a Revision in the transaction, before any of its writes,
+// and returns a FailedPrecondition error when one of them has another revision
+func (f *FirestoreRepository) checkRevisions(tx *firestore.Transaction, updates []DocumentUpdate) error {
+	for _, document := range updates {
+		if document.Revision == nil {
+			continue
+		}
+		snapshot, err := tx.Get(f.client.Collection(document.CollectionPath).Doc(document.DocumentID))
+		if err != nil {
+			return err
+		}
+		err = checkRevision(getDocumentPath(document.CollectionPath, document.DocumentID), snapshot.Data(),
+			document.Revision)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getReservationOwner returns the path of the document holding the reservation, empty if not reserved
+func getReservationOwner(tx *firestore.Transaction, reservationRef *firestore.DocumentRef) (string, error) {
+	snapshot, err := tx.Get(reservationRef)
+	if status.Code(err) == codes.NotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return convert.StringDefault(snapshot.Data()[common.DocumentPath], ""), nil
+}
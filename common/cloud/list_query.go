@@ -0,0 +1,144 @@
+package cloud
+
+import (
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ListQuery is the allow-list of the filters and the sort fields a list endpoint accepts as query params.
+// The page token is bound to the query params, so the next pages are fetched with the same filters and sort
+type ListQuery struct {
+	Filters []ListFilter
+	// SortFields are the fields the documents can be ordered by with the order_by query param,
+	// the documents are ordered by ID when order_by is not passed
+	SortFields []string
+}
+
+// ListFilter filters the documents on the Field with the value of the QueryParam
+type ListFilter struct {
+	QueryParam string
+	Field      string
+	// Operator is a firestore operator or common.OperatorPrefix
+	Operator string
+	// IsTime is true when the value is a time in RFC3339 format
+	IsTime bool
+}
+
+// GetTimeFilters returns the filters on the created and updated time common to the entities
+func GetTimeFilters() []ListFilter {
+	return []ListFilter{
+		{QueryParam: common.QueryParamCreatedAfter, Field: common.CreatedTime, Operator: common.OperatorGreaterThan,
+			IsTime: true},
+		{QueryParam: common.QueryParamCreatedBefore, Field: common.CreatedTime, Operator: common.OperatorLessThan,
+			IsTime: true},
+		{QueryParam: common.QueryParamUpdatedAfter, Field: common.UpdatedTime, Operator: common.OperatorGreaterThan,
+			IsTime: true},
+		{QueryParam: common.QueryParamUpdatedBefore, Field: common.UpdatedTime, Operator: common.OperatorLessThan,
+			IsTime: true},
+	}
+}
+
+// ParseListQuery returns the where clauses and the order of the filters and the sort passed in the query params
+// of the request, or the validation errors of the query params.
+// Firestore allows range filters on a single field, which must be the field the documents are ordered by,
+// so the documents are ordered by the range filter field when order_by is not passed
+func ParseListQuery(request *http.Request, listQuery ListQuery) ([]Where, []Order, []string) {
+	query := request.URL.Query()
+	var where []Where
+	var errs []string
+	rangeField := ""
+	for _, filter := range listQuery.Filters {
+		if !query.Has(filter.QueryParam) {
+			continue
+		}
+		value, err := getFilterValue(query.Get(filter.QueryParam), filter)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("Invalid value for query param %s : %v", filter.QueryParam, err))
+
+			continue
+		}
+		if filter.Operator == common.OperatorEquals {
+			where = append(where, Where{Field: filter.Field, Operator: filter.Operator, Value: value})
+
+			continue
+		}
+		if rangeField != "" && rangeField != filter.Field {
+			errs = append(errs, fmt.Sprintf("Query param %s can not be combined with a filter on %s",
+				filter.QueryParam, rangeField))
+
+			continue
+		}
+		rangeField = filter.Field
+		where = append(where, getRangeWhere(filter, value)...)
+	}
+
+	order, orderErrs := getListOrder(request, listQuery, rangeField)
+	errs = append(errs, orderErrs...)
+	if errs != nil {
+		return nil, nil, errs
+	}
+
+	return where, []Order{order}, nil
+}
+
+func getFilterValue(value string, filter ListFilter) (any, error) {
+	if value == "" {
+		return nil, fmt.Errorf("value is empty")
+	}
+	if filter.IsTime {
+		return time.Parse(time.RFC3339, value)
+	}
+
+	return value, nil
+}
+
+func getRangeWhere(filter ListFilter, value any) []Where {
+	if filter.Operator != common.OperatorPrefix {
+		return []Where{{Field: filter.Field, Operator: filter.Operator, Value: value}}
+	}
+
+	return []Where{
+		{Field: filter.Field, Operator: common.OperatorGreaterThanOrEquals, Value: value},
+		{Field: filter.Field, Operator: common.OperatorLessThan, Value: fmt.Sprint(value) + common.PrefixRangeEnd},
+	}
+}
+
+// getListOrder returns the order of the order_by and sort query params, ascending by default
+func getListOrder(request *http.Request, listQuery ListQuery, rangeField string) (Order, []string) {
+	var errs []string
+	order := Order{Field: common.ID, Sort: common.SortAscending}
+	if rangeField != "" {
+		order.Field = rangeField
+	}
+
+	query := request.URL.Query()
+	if query.Has(common.QueryParamOrderBy) {
+		orderBy := query.Get(common.QueryParamOrderBy)
+		if !utils.Contains(listQuery.SortFields, orderBy) {
+			errs = append(errs, fmt.Sprintf("Unsupported value for query param %s, supported values are %v",
+				common.QueryParamOrderBy, listQuery.SortFields))
+		} else if rangeField != "" && orderBy != rangeField {
+			errs = append(errs, fmt.Sprintf("Query param %s must be %s when filtering on %s",
+				common.QueryParamOrderBy, rangeField, rangeField))
+		}
+		order.Field = orderBy
+	}
+
+	if query.Has(common.QueryParamSort) {
+		switch strings.ToLower(query.Get(common.QueryParamSort)) {
+		case common.SortAsc:
+			order.Sort = common.SortAscending
+		case common.SortDesc:
+			order.Sort = common.SortDescending
+		default:
+			errs = append(errs, fmt.Sprintf("Unsupported value for query param %s, supported values are %v",
+				common.QueryParamSort, []string{common.SortAsc, common.SortDesc}))
+		}
+	}
+
+	return order, errs
+}
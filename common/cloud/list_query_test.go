@@ -0,0 +1,84 @@
+package cloud
+
+import (
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testListQuery = ListQuery{
+	Filters: append([]ListFilter{
+		{QueryParam: common.Status, Field: common.Status, Operator: common.OperatorEquals},
+		{QueryParam: common.QueryParamNamePrefix, Field: common.Name, Operator: common.OperatorPrefix},
+	}, GetTimeFilters()...),
+	SortFields: []string{common.ID, common.Name, common.CreatedTime},
+}
+
+func TestParseListQuery(t *testing.T) {
+	t.Run("Ordered by ID without query params", func(t *testing.T) {
+		where, orderBy, errs := ParseListQuery(httptest.NewRequest(http.MethodGet, "/sites", nil), testListQuery)
+		assert.Nil(t, errs)
+		assert.Nil(t, where)
+		assert.Equal(t, []Order{{Field: common.ID, Sort: common.SortAscending}}, orderBy)
+	})
+
+	t.Run("Equality filter with order_by and sort", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?status=draft&order_by=name&sort=DESC", nil)
+		where, orderBy, errs := ParseListQuery(r, testListQuery)
+		assert.Nil(t, errs)
+		assert.Equal(t, []Where{{Field: common.Status, Operator: common.OperatorEquals, Value: "draft"}}, where)
+		assert.Equal(t, []Order{{Field: common.Name, Sort: common.SortDescending}}, orderBy)
+	})
+
+	t.Run("Prefix filter ordered by the prefix field", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?name_prefix=abc", nil)
+		where, orderBy, errs := ParseListQuery(r, testListQuery)
+		assert.Nil(t, errs)
+		assert.Equal(t, []Where{
+			{Field: common.Name, Operator: common.OperatorGreaterThanOrEquals, Value: "abc"},
+			{Field: common.Name, Operator: common.OperatorLessThan, Value: "abc" + common.PrefixRangeEnd},
+		}, where)
+		assert.Equal(t, []Order{{Field: common.Name, Sort: common.SortAscending}}, orderBy)
+	})
+
+	t.Run("Time range filter", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet,
+			"/sites?created_after=2022-01-01T00:00:00Z&created_before=2022-02-01T00:00:00Z&sort=desc", nil)
+		where, orderBy, errs := ParseListQuery(r, testListQuery)
+		assert.Nil(t, errs)
+		assert.Equal(t, []Where{
+			{Field: common.CreatedTime, Operator: common.OperatorGreaterThan,
+				Value: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Field: common.CreatedTime, Operator: common.OperatorLessThan,
+				Value: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+		}, where)
+		assert.Equal(t, []Order{{Field: common.CreatedTime, Sort: common.SortDescending}}, orderBy)
+	})
+
+	t.Run("Invalid query params", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?status=&created_after=yesterday&order_by=timezone&sort=up", nil)
+		where, orderBy, errs := ParseListQuery(r, testListQuery)
+		assert.Nil(t, where)
+		assert.Nil(t, orderBy)
+		assert.Equal(t, 4, len(errs))
+		assert.Equal(t, "Invalid value for query param status : value is empty", errs[0])
+		assert.Equal(t, "Unsupported value for query param order_by, supported values are [id name created_time]",
+			errs[2])
+		assert.Equal(t, "Unsupported value for query param sort, supported values are [asc desc]", errs[3])
+	})
+
+	t.Run("Range filters on different fields", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?name_prefix=abc&updated_after=2022-01-01T00:00:00Z", nil)
+		_, _, errs := ParseListQuery(r, testListQuery)
+		assert.Equal(t, []string{"Query param updated_after can not be combined with a filter on name"}, errs)
+	})
+
+	t.Run("Range filter with order_by on another field", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?name_prefix=abc&order_by=created_time", nil)
+		_, _, errs := ParseListQuery(r, testListQuery)
+		assert.Equal(t, []string{"Query param order_by must be name when filtering on name"}, errs)
+	})
+}
@@ -0,0 +1,753 @@
+package cloud
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory implementation of the DB interface.
+// It mimics the firestore behaviour used by the handlers (nested collection paths, where clauses,
+// ordering and pagination, collection groups) and can be used for local development and tests.
+type MemoryRepository struct {
+	mutex       sync.RWMutex
+	collections map[string]map[string]map[string]interface{}
+}
+
+var MemoryRepositoryObj = NewMemoryRepository()
+
+// NewMemoryRepository creates an empty MemoryRepository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{collections: make(map[string]map[string]map[string]interface{})}
+}
+
+// NewDBRepository returns the DB implementation selected by the DB_BACKEND env variable.
+// When DB_BACKEND is memory the shared MemoryRepositoryObj is returned, else the firestore repository is used
+func NewDBRepository(ctx context.Context) DB {
+	if os.Getenv(common.EnvDBBackend) == common.DBBackendMemory {
+		return MemoryRepositoryObj
+	}
+
+	return NewFirestoreRepository(ctx)
+}
+
+// Exists function is used to check if the field==value in the collectionPath passed
+// will return true if the field==value else false
+func (m *MemoryRepository) Exists(_ context.Context, collectionPath string, field string, value string) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(filterDocuments(m.collections[collectionPath],
+		[]Where{{Field: field, Operator: common.OperatorEquals, Value: value}})) > 0, nil
+}
+
+// ExistsInCollectionGroup function is used to check if the field==value in any collection
+// whose last path segment is collectionGroupID
+func (m *MemoryRepository) ExistsInCollectionGroup(_ context.Context,
+	collectionGroupID string, field string, value string) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	where := []Where{{Field: field, Operator: common.OperatorEquals, Value: value}}
+	for collectionPath, documents := range m.collections {
+		if collectionGroup(collectionPath) == collectionGroupID && len(filterDocuments(documents, where)) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Save function will create the document with the given collectionPath and documentID
+// an AlreadyExists error is returned if the document is already present
+func (m *MemoryRepository) Save(_ context.Context,
+	collectionPath string, documentID string, document interface{}) (time.Time, error) {
+	data, err := toDocumentData(document)
+	if err != nil {
+		return time.Time{}, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.collections[collectionPath][documentID]; ok {
+		return time.Time{}, status.Errorf(codes.AlreadyExists, "document %s/%s already exists", collectionPath, documentID)
+	}
+	m.setDocument(collectionPath, documentID, data)
+
+	return time.Now().UTC(), nil
+}
+
+// GetByID returns a single document for the passed collectionPath and documentID
+func (m *MemoryRepository) GetByID(_ context.Context,
+	collectionPath string, documentID string, skipDeactivated bool) (map[string]interface{}, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	where := []Where{{Field: common.ID, Operator: common.OperatorEquals, Value: documentID}}
+	if skipDeactivated {
+		where = append(where, Where{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil})
+	}
+	result := filterDocuments(m.collections[collectionPath], where)
+	if len(result) > 1 {
+		return nil, status.Error(codes.Internal, "Multiple documents with same ID")
+	}
+	if len(result) == 0 {
+		return nil, status.Error(codes.NotFound, "document not found")
+	}
+
+	return copyDocument(result[0].data), nil
+}
+
+// Update will perform all the updates for the collectionPath and documentID
+// a NotFound error is returned if the document is not present
+func (m *MemoryRepository) Update(_ context.Context,
+	collectionPath string, documentID string, updates []firestore.Update) (time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	data, ok := m.collections[collectionPath][documentID]
+	if !ok {
+		return time.Time{}, status.Errorf(codes.NotFound, "document %s/%s not found", collectionPath, documentID)
+	}
+	updated := copyDocument(data)
+	if err := applyUpdates(updated, updates); err != nil {
+		return time.Time{}, err
+	}
+	m.setDocument(collectionPath, documentID, updated)
+
+	return time.Now().UTC(), nil
+}
+
+// GetAll will return the page of documents under the collectionPath matching all the where clauses
+// along with the value of the OrderBy field of the last document, to be used as the StartAfterID of next page
+func (m *MemoryRepository) GetAll(_ context.Context,
+	collectionPath string, pageDetails Page, whereClauses []Where) ([]map[string]interface{}, *Cursor, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	documents := filterDocuments(m.collections[collectionPath], whereClauses)
+	documents = orderDocuments(documents, pageDetails)
+
+	var result []map[string]interface{}
+	for _, document := range documents {
+		result = append(result, copyDocument(document.data))
+	}
+	var cursor *Cursor
+	if len(documents) > 0 {
+		lastDocument := documents[len(documents)-1]
+		cursor = &Cursor{ID: lastDocument.id}
+		for _, order := range pageDetails.OrderBy {
+			value, _ := getField(lastDocument.data, order.Field)
+			cursor.Values = append(cursor.Values, normalizeValue(value))
+		}
+	}
+
+	return result, cursor, nil
+}
+
+// CheckSubDocuments function checks if all the documents under collectionPath are deactivated
+func (m *MemoryRepository) CheckSubDocuments(_ context.Context, collectionPath string, _ string) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(filterDocuments(m.collections[collectionPath],
+		[]Where{{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil}})) == 0, nil
+}
+
+// Delete function will delete the document id provided under the collection path
+// deleting a document which is not present is not an error, same as firestore
+func (m *MemoryRepository) Delete(_ context.Context, collectionPath string, documentID string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.collections[collectionPath], documentID)
+
+	return true, nil
+}
+
+// SaveInTransaction creates the document along with the options.Creates documents atomically,
+// reserving the options.ReserveKeys for the document.
+// A *UniqueKeyError is returned if a key is held by another document
+func (m *MemoryRepository) SaveInTransaction(_ context.Context, collectionPath string, documentID string,
+	document interface{}, options WriteOptions) (time.Time, error) {
+	data, err := toDocumentData(document)
+	if err != nil {
+		return time.Time{}, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.collections[collectionPath][documentID]; ok {
+		return time.Time{}, status.Errorf(codes.AlreadyExists, "document %s/%s already exists", collectionPath, documentID)
+	}
+
+	return m.commit(getDocumentPath(collectionPath, documentID), options, func() {
+		m.setDocument(collectionPath, documentID, data)
+	})
+}
+
+// UpdateInTransaction performs the updates on the document along with the options.Creates documents atomically,
+// reserving options.ReserveKeys and releasing options.ReleaseKeys for the document.
+// Nothing is written if the document does not have the options.Revision
+func (m *MemoryRepository) UpdateInTransaction(_ context.Context, collectionPath string, documentID string,
+	updates []firestore.Update, options WriteOptions) (time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	data, ok := m.collections[collectionPath][documentID]
+	if !ok {
+		return time.Time{}, status.Errorf(codes.NotFound, "document %s/%s not found", collectionPath, documentID)
+	}
+	if err := checkRevision(getDocumentPath(collectionPath, documentID), data, options.Revision); err != nil {
+		return time.Time{}, err
+	}
+	updated := copyDocument(data)
+	if err := applyUpdates(updated, updates); err != nil {
+		return time.Time{}, err
+	}
+
+	return m.commit(getDocumentPath(collectionPath, documentID), options, func() {
+		m.setDocument(collectionPath, documentID, updated)
+	})
+}
+
+// DeleteInTransaction deletes the document along with the options.Creates documents atomically,
+// releasing options.ReleaseKeys held by the document. Nothing is written if the document does not have the
+// options.Revision
+func (m *MemoryRepository) DeleteInTransaction(_ context.Context, collectionPath string, documentID string,
+	options WriteOptions) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if options.Revision != nil {
+		data, ok := m.collections[collectionPath][documentID]
+		if !ok {
+			return false, status.Errorf(codes.NotFound, "document %s/%s not found", collectionPath, documentID)
+		}
+		if err := checkRevision(getDocumentPath(collectionPath, documentID), data, options.Revision); err != nil {
+			return false, err
+		}
+	}
+	_, err := m.commit(getDocumentPath(collectionPath, documentID), options, func() {
+		delete(m.collections[collectionPath], documentID)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// commit validates the options and then applies the write along with the options,
+// nothing is written if any of the validations fail. It must be called with the write lock held
+func (m *MemoryRepository) commit(documentPath string, options WriteOptions, write func()) (time.Time, error) {
+	reservations := m.collections[common.UniqueKeysCollection]
+	for _, key := range options.ReserveKeys {
+		if reservation, ok := reservations[key.ID()]; ok && reservation[common.DocumentPath] != documentPath {
+			return time.Time{}, &UniqueKeyError{Key: key}
+		}
+	}
+	creates := make([]map[string]interface{}, len(options.Creates))
+	for i, document := range options.Creates {
+		if _, ok := m.collections[document.CollectionPath][document.DocumentID]; ok {
+			return time.Time{}, status.Errorf(codes.AlreadyExists, "document %s/%s already exists",
+				document.CollectionPath, document.DocumentID)
+		}
+		data, err := toDocumentData(document.Data)
+		if err != nil {
+			return time.Time{}, err
+		}
+		creates[i] = data
+	}
+	updates, err := m.getUpdatedDocuments(options.Updates)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	write()
+	for i, document := range options.Creates {
+		m.setDocument(document.CollectionPath, document.DocumentID, creates[i])
+	}
+	for i, document := range options.Updates {
+		if len(document.Updates) == 0 {
+			continue
+		}
+		m.setDocument(document.CollectionPath, document.DocumentID, updates[i])
+	}
+	for _, key := range options.releasedKeys() {
+		if reservation, ok := reservations[key.ID()]; ok && reservation[common.DocumentPath] == documentPath {
+			delete(reservations, key.ID())
+		}
+	}
+	for _, key := range options.ReserveKeys {
+		data, _ := toDocumentData(newUniqueKeyReservation(key, documentPath))
+		m.setDocument(common.UniqueKeysCollection, key.ID(), data)
+	}
+
+	return time.Now().UTC(), nil
+}
+
+// getUpdatedDocuments returns the copies of the documents with the updates applied, without writing them.
+// It must be called with the write lock held
+func (m *MemoryRepository) getUpdatedDocuments(documentUpdates []DocumentUpdate) ([]map[string]interface{}, error) {
+	updates := make([]map[string]interface{}, len(documentUpdates))
+	for i, document := range documentUpdates {
+		data, ok := m.collections[document.CollectionPath][document.DocumentID]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "document %s/%s not found",
+				document.CollectionPath, document.DocumentID)
+		}
+		err := checkRevision(getDocumentPath(document.CollectionPath, document.DocumentID), data, document.Revision)
+		if err != nil {
+			return nil, err
+		}
+		updates[i] = copyDocument(data)
+		if err = applyUpdates(updates[i], document.Updates); err != nil {
+			return nil, err
+		}
+	}
+
+	return updates, nil
+}
+
+func (m *MemoryRepository) setDocument(collectionPath string, documentID string, data map[string]interface{}) {
+	if m.collections[collectionPath] == nil {
+		m.collections[collectionPath] = make(map[string]map[string]interface{})
+	}
+	m.collections[collectionPath][documentID] = data
+}
+
+type memoryDocument struct {
+	id   string
+	data map[string]interface{}
+}
+
+// collectionGroup returns the collection ID (last segment) of the collection path
+func collectionGroup(collectionPath string) string {
+	return collectionPath[strings.LastIndex(collectionPath, "/")+1:]
+}
+
+func filterDocuments(documents map[string]map[string]interface{}, whereClauses []Where) []memoryDocument {
+	var result []memoryDocument
+	for documentID, data := range documents {
+		if matchesAll(data, whereClauses) {
+			result = append(result, memoryDocument{id: documentID, data: data})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].id < result[j].id
+	})
+
+	return result
+}
+
+func matchesAll(data map[string]interface{}, whereClauses []Where) bool {
+	for _, where := range whereClauses {
+		value, ok := getField(data, where.Field)
+		if !ok || !matches(value, where) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matches(value interface{}, where Where) bool {
+	switch where.Operator {
+	case common.OperatorEquals:
+		return equalValues(value, normalizeValue(where.Value))
+	case common.OperatorIn:
+		values, ok := normalizeValue(where.Value).([]interface{})
+
+		return ok && containsValue(values, value)
+	case common.OperatorArrayContains:
+		return arrayContainsAny(value, []interface{}{normalizeValue(where.Value)})
+	case common.OperatorArrayContainsAny:
+		values, ok := normalizeValue(where.Value).([]interface{})
+
+		return ok && arrayContainsAny(value, values)
+	default:
+		comparison, ok := compareValues(value, normalizeValue(where.Value))
+		if !ok {
+			return false
+		}
+		switch where.Operator {
+		case "<":
+			return comparison < 0
+		case "<=":
+			return comparison <= 0
+		case ">":
+			return comparison > 0
+		case ">=":
+			return comparison >= 0
+		case "!=":
+			return comparison != 0
+		}
+
+		return false
+	}
+}
+
+// arrayContainsAny returns whether the array field value has any of the values
+func arrayContainsAny(value interface{}, values []interface{}) bool {
+	elements, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, element := range elements {
+		if containsValue(values, element) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, other := range values {
+		if equalValues(value, other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// orderDocuments sorts the documents on pageDetails.OrderBy and then the document ID, as in firestore,
+// skips every document up to and including the StartAfter cursor and limits the result to PageSize
+func orderDocuments(documents []memoryDocument, pageDetails Page) []memoryDocument {
+	var ordered []memoryDocument
+	for _, document := range documents {
+		// firestore does not return documents where an OrderBy field is missing
+		if hasOrderFields(document, pageDetails.OrderBy) {
+			ordered = append(ordered, document)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return compareDocuments(ordered[i], ordered[j], pageDetails.OrderBy) < 0
+	})
+
+	if pageDetails.StartAfter != nil {
+		var remaining []memoryDocument
+		for _, document := range ordered {
+			if compareToCursor(document, pageDetails.StartAfter, pageDetails.OrderBy) > 0 {
+				remaining = append(remaining, document)
+			}
+		}
+		ordered = remaining
+	}
+
+	if pageDetails.PageSize > 0 && len(ordered) > pageDetails.PageSize {
+		ordered = ordered[:pageDetails.PageSize]
+	}
+
+	return ordered
+}
+
+func hasOrderFields(document memoryDocument, orders []Order) bool {
+	for _, order := range orders {
+		if _, ok := getField(document.data, order.Field); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareDocuments compares the documents in the query order, negative if left comes first
+func compareDocuments(left memoryDocument, right memoryDocument, orders []Order) int {
+	values := make([]any, 0, len(orders))
+	for _, order := range orders {
+		value, _ := getField(right.data, order.Field)
+		values = append(values, value)
+	}
+
+	return compareToCursor(left, &Cursor{Values: values, ID: right.id}, orders)
+}
+
+// compareToCursor compares the document to the cursor in the query order, positive if the document comes after.
+// A cursor without the document ID compares only the OrderBy values
+func compareToCursor(document memoryDocument, cursor *Cursor, orders []Order) int {
+	for index, order := range orders {
+		if index >= len(cursor.Values) {
+			return 0
+		}
+		value, _ := getField(document.data, order.Field)
+		comparison, _ := compareValues(normalizeValue(value), normalizeValue(cursor.Values[index]))
+		if order.Sort == firestore.Desc {
+			comparison = -comparison
+		}
+		if comparison != 0 {
+			return comparison
+		}
+	}
+	if cursor.ID == "" {
+		return 0
+	}
+	comparison := strings.Compare(document.id, cursor.ID)
+	if getIDSort(orders) == firestore.Desc {
+		comparison = -comparison
+	}
+
+	return comparison
+}
+
+func getField(data map[string]interface{}, fieldPath string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range strings.Split(fieldPath, ".") {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = currentMap[part]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func applyUpdates(data map[string]interface{}, updates []firestore.Update) error {
+	for _, update := range updates {
+		fieldPath := update.FieldPath
+		if len(fieldPath) == 0 {
+			fieldPath = strings.Split(update.Path, ".")
+		}
+		if len(fieldPath) == 0 || fieldPath[0] == "" {
+			return status.Error(codes.InvalidArgument, "update has an empty field path")
+		}
+		parent := data
+		for _, part := range fieldPath[:len(fieldPath)-1] {
+			child, ok := parent[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				parent[part] = child
+			}
+			parent = child
+		}
+		field := fieldPath[len(fieldPath)-1]
+		switch update.Value {
+		case firestore.Delete:
+			delete(parent, field)
+		case firestore.ServerTimestamp:
+			parent[field] = time.Now().UTC()
+		default:
+			parent[field] = normalizeValue(update.Value)
+		}
+	}
+
+	return nil
+}
+
+// toDocumentData converts the document into the map which firestore would return for it
+func toDocumentData(document interface{}) (map[string]interface{}, error) {
+	data, ok := normalizeValue(document).(map[string]interface{})
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "document of type %T is not a struct or map", document)
+	}
+
+	return data, nil
+}
+
+// normalizeValue converts the value to the types returned by firestore,
+// structs are converted to maps using the firestore tags, integers to int64 and floats to float64
+func normalizeValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	return normalizeReflectValue(reflect.ValueOf(value))
+}
+
+//nolint:cyclop,exhaustive
+func normalizeReflectValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+
+		return normalizeReflectValue(value.Elem())
+	case reflect.Bool:
+		return value.Bool()
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte{}, value.Bytes()...)
+		}
+		values := make([]interface{}, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			values[i] = normalizeReflectValue(value.Index(i))
+		}
+
+		return values
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		values := make(map[string]interface{}, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			values[fmt.Sprint(iterator.Key().Interface())] = normalizeReflectValue(iterator.Value())
+		}
+
+		return values
+	case reflect.Struct:
+		if timeValue, ok := value.Interface().(time.Time); ok {
+			return timeValue.UTC()
+		}
+
+		return normalizeStruct(value)
+	default:
+		return value.Interface()
+	}
+}
+
+func normalizeStruct(value reflect.Value) map[string]interface{} {
+	values := make(map[string]interface{})
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get(common.Firestore), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(options, "omitempty") && value.Field(i).IsZero() {
+			continue
+		}
+		values[name] = normalizeReflectValue(value.Field(i))
+	}
+
+	return values
+}
+
+func equalValues(left interface{}, right interface{}) bool {
+	if comparison, ok := compareValues(left, right); ok {
+		return comparison == 0
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+// compareValues compares two normalized values of the same type,
+// the bool is false when the values are not comparable
+func compareValues(left interface{}, right interface{}) (int, bool) {
+	if left == nil || right == nil {
+		if left == nil && right == nil {
+			return 0, true
+		}
+
+		return 0, false
+	}
+	switch leftValue := left.(type) {
+	case string:
+		if rightValue, ok := right.(string); ok {
+			return strings.Compare(leftValue, rightValue), true
+		}
+	case bool:
+		if rightValue, ok := right.(bool); ok {
+			return compareOrdered(boolToInt(leftValue), boolToInt(rightValue)), true
+		}
+	case time.Time:
+		if rightValue, ok := right.(time.Time); ok {
+			return compareTimes(leftValue, rightValue), true
+		}
+	case int64, float64:
+		leftNumber, _ := toFloat(leftValue)
+		if rightNumber, ok := toFloat(right); ok {
+			return compareOrdered(leftNumber, rightNumber), true
+		}
+	}
+
+	return 0, false
+}
+
+func compareOrdered[T int | float64](left T, right T) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareTimes(left time.Time, right time.Time) int {
+	switch {
+	case left.Before(right):
+		return -1
+	case left.After(right):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	default:
+		return 0, false
+	}
+}
+
+// copyDocument returns a deep copy of the document data so callers can't modify the stored document
+func copyDocument(data map[string]interface{}) map[string]interface{} {
+	copied, _ := copyValue(data).(map[string]interface{})
+
+	return copied
+}
+
+func copyValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(typedValue))
+		for key, item := range typedValue {
+			copied[key] = copyValue(item)
+		}
+
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			copied[i] = copyValue(item)
+		}
+
+		return copied
+	case []byte:
+		return append([]byte{}, typedValue...)
+	default:
+		return value
+	}
+}
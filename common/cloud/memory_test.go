@@ -0,0 +1,426 @@
+package cloud
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+const testSitePath = "site-info-retailers/r12345/site-info-sites"
+
+type testSite struct {
+	ID              string           `firestore:"id"`
+	Name            string           `firestore:"name"`
+	Location        *models.Location `firestore:"location"`
+	DeactivatedTime *time.Time       `firestore:"deactivated_time"`
+	ETag            string           `firestore:"-"`
+}
+
+func getTestSites(t *testing.T, ids ...string) *MemoryRepository {
+	repository := NewMemoryRepository()
+	for _, id := range ids {
+		_, err := repository.Save(context.Background(), testSitePath, id, testSite{ID: id, Name: "name " + id})
+		assert.Nil(t, err)
+	}
+
+	return repository
+}
+
+func TestMemoryRepository_Save(t *testing.T) {
+	t.Run("Save converts the struct using firestore tags", func(t *testing.T) {
+		lat, long := 10.5, 20.5
+		repository := NewMemoryRepository()
+		_, err := repository.Save(context.Background(), testSitePath, "s12345",
+			testSite{ID: "s12345", Name: "Site Name", Location: &models.Location{Latitude: &lat, Longitude: &long},
+				ETag: "etag"})
+		assert.Nil(t, err)
+		data, err := repository.GetByID(context.Background(), testSitePath, "s12345", true)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"id":               "s12345",
+			"name":             "Site Name",
+			"location":         map[string]interface{}{"lat": 10.5, "long": 20.5},
+			"deactivated_time": nil,
+		}, data)
+	})
+
+	t.Run("Save fails when the document already exists", func(t *testing.T) {
+		repository := getTestSites(t, "s12345")
+		_, err := repository.Save(context.Background(), testSitePath, "s12345", testSite{ID: "s12345"})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("Save fails when the document is not a struct or map", func(t *testing.T) {
+		_, err := NewMemoryRepository().Save(context.Background(), testSitePath, "s12345", "invalid")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestMemoryRepository_GetByID(t *testing.T) {
+	t.Run("Document not found", func(t *testing.T) {
+		_, err := getTestSites(t, "s12345").GetByID(context.Background(), testSitePath, "s99999", false)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Deactivated document skipped", func(t *testing.T) {
+		repository := NewMemoryRepository()
+		deactivatedTime := time.Now()
+		_, _ = repository.Save(context.Background(), testSitePath, "s12345",
+			testSite{ID: "s12345", DeactivatedTime: &deactivatedTime})
+		_, err := repository.GetByID(context.Background(), testSitePath, "s12345", true)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		data, err := repository.GetByID(context.Background(), testSitePath, "s12345", false)
+		assert.Nil(t, err)
+		assert.Equal(t, deactivatedTime.UTC(), data[common.DeactivatedTime])
+	})
+
+	t.Run("Returned document is a copy", func(t *testing.T) {
+		repository := getTestSites(t, "s12345")
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s12345", false)
+		data[common.Name] = "changed"
+		data, _ = repository.GetByID(context.Background(), testSitePath, "s12345", false)
+		assert.Equal(t, "name s12345", data[common.Name])
+	})
+}
+
+func TestMemoryRepository_Exists(t *testing.T) {
+	repository := getTestSites(t, "s12345")
+	t.Run("Exists in collection", func(t *testing.T) {
+		exists, err := repository.Exists(context.Background(), testSitePath, common.Name, "name s12345")
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		exists, _ = repository.Exists(context.Background(), testSitePath, common.Name, "name s99999")
+		assert.False(t, exists)
+	})
+
+	t.Run("Exists in collection group", func(t *testing.T) {
+		exists, err := repository.ExistsInCollectionGroup(context.Background(), common.SitesCollection, common.ID, "s12345")
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		exists, _ = repository.ExistsInCollectionGroup(context.Background(), common.SpokesCollection, common.ID, "s12345")
+		assert.False(t, exists)
+	})
+}
+
+func TestMemoryRepository_Update(t *testing.T) {
+	t.Run("Update fields", func(t *testing.T) {
+		repository := getTestSites(t, "s12345")
+		lat, long := 1.0, 2.0
+		_, err := repository.Update(context.Background(), testSitePath, "s12345", []firestore.Update{
+			{Path: common.Name, Value: "new name"},
+			{Path: "location", Value: &models.Location{Latitude: &lat, Longitude: &long}},
+			{Path: "location.lat", Value: 3},
+			{Path: common.DeactivatedTime, Value: firestore.Delete},
+		})
+		assert.Nil(t, err)
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s12345", false)
+		assert.Equal(t, "new name", data[common.Name])
+		assert.Equal(t, map[string]interface{}{"lat": int64(3), "long": 2.0}, data["location"])
+		assert.NotContains(t, data, common.DeactivatedTime)
+	})
+
+	t.Run("Update document not found", func(t *testing.T) {
+		_, err := NewMemoryRepository().Update(context.Background(), testSitePath, "s12345",
+			[]firestore.Update{{Path: common.Name, Value: "name"}})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestMemoryRepository_GetAll(t *testing.T) {
+	repository := getTestSites(t, "s3", "s1", "s5", "s2", "s4")
+	idOrder := []Order{{Field: common.ID, Sort: firestore.Asc}}
+	t.Run("Get first page", func(t *testing.T) {
+		data, cursor, err := repository.GetAll(context.Background(), testSitePath,
+			Page{PageSize: 2, OrderBy: idOrder}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(data))
+		assert.Equal(t, "s1", data[0][common.ID])
+		assert.Equal(t, &Cursor{Values: []any{"s2"}, ID: "s2"}, cursor)
+	})
+
+	t.Run("Get next page", func(t *testing.T) {
+		data, cursor, _ := repository.GetAll(context.Background(), testSitePath,
+			Page{StartAfter: &Cursor{Values: []any{"s2"}, ID: "s2"}, PageSize: 2, OrderBy: idOrder}, nil)
+		assert.Equal(t, "s3", data[0][common.ID])
+		assert.Equal(t, "s4", cursor.ID)
+	})
+
+	t.Run("Get descending page", func(t *testing.T) {
+		data, cursor, _ := repository.GetAll(context.Background(), testSitePath,
+			Page{StartAfter: &Cursor{Values: []any{"s4"}, ID: "s4"}, PageSize: 5,
+				OrderBy: []Order{{Field: common.ID, Sort: firestore.Desc}}}, nil)
+		assert.Equal(t, 3, len(data))
+		assert.Equal(t, "s3", data[0][common.ID])
+		assert.Equal(t, "s1", cursor.ID)
+	})
+
+	t.Run("Get empty page", func(t *testing.T) {
+		data, cursor, _ := repository.GetAll(context.Background(), testSitePath,
+			Page{StartAfter: &Cursor{Values: []any{"s5"}, ID: "s5"}, PageSize: 5, OrderBy: idOrder}, nil)
+		assert.Empty(t, data)
+		assert.Nil(t, cursor)
+	})
+
+	t.Run("Get with where clauses", func(t *testing.T) {
+		data, _, _ := repository.GetAll(context.Background(), testSitePath,
+			Page{PageSize: 5, OrderBy: idOrder}, []Where{
+				{Field: common.ID, Operator: common.OperatorIn, Value: []string{"s2", "s5", "s9"}},
+				{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil},
+			})
+		assert.Equal(t, 2, len(data))
+		assert.Equal(t, "s2", data[0][common.ID])
+		assert.Equal(t, "s5", data[1][common.ID])
+	})
+
+	t.Run("Get with array where clauses", func(t *testing.T) {
+		serviceAreaRepository := NewMemoryRepository()
+		for id, postalCodes := range map[string][]string{"s1": {"10001", "10002"}, "s2": {"10003"}, "s3": nil} {
+			_, _ = serviceAreaRepository.Save(context.Background(), testSitePath, id, map[string]interface{}{
+				common.ID: id, common.ServiceArea: map[string]interface{}{"postal_codes": postalCodes},
+			})
+		}
+		data, _, _ := serviceAreaRepository.GetAll(context.Background(), testSitePath,
+			Page{PageSize: 5, OrderBy: idOrder}, []Where{
+				{Field: common.ServiceAreaPostalCodes, Operator: common.OperatorArrayContains, Value: "10002"},
+			})
+		assert.Equal(t, 1, len(data))
+		assert.Equal(t, "s1", data[0][common.ID])
+		data, _, _ = serviceAreaRepository.GetAll(context.Background(), testSitePath,
+			Page{PageSize: 5, OrderBy: idOrder}, []Where{{Field: common.ServiceAreaPostalCodes,
+				Operator: common.OperatorArrayContainsAny, Value: []string{"10001", "10003", "10009"}}})
+		assert.Equal(t, 2, len(data))
+		assert.Equal(t, "s2", data[1][common.ID])
+	})
+
+	t.Run("Get with time ordering", func(t *testing.T) {
+		auditRepository := NewMemoryRepository()
+		changedAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, id := range []string{"a1", "a2", "a3"} {
+			changedAt = changedAt.Add(time.Hour)
+			_, _ = auditRepository.Save(context.Background(), "audit", id,
+				map[string]interface{}{common.ChangedAt: changedAt})
+		}
+		data, cursor, _ := auditRepository.GetAll(context.Background(), "audit",
+			Page{StartAfter: &Cursor{Values: []any{changedAt}, ID: "a3"}, PageSize: 5,
+				OrderBy: []Order{{Field: common.ChangedAt, Sort: firestore.Desc}}}, nil)
+		assert.Equal(t, 2, len(data))
+		assert.Equal(t, "a1", cursor.ID)
+		assert.True(t, cursor.Values[0].(time.Time).Equal(time.Date(2022, 1, 1, 1, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("Get pages with the same order values", func(t *testing.T) {
+		auditRepository := NewMemoryRepository()
+		changedAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, id := range []string{"a1", "a2", "a3", "a4", "a5"} {
+			_, _ = auditRepository.Save(context.Background(), "audit", id,
+				map[string]interface{}{common.ID: id, common.ChangedAt: changedAt})
+		}
+		page := Page{PageSize: 2, OrderBy: []Order{{Field: common.ChangedAt, Sort: firestore.Desc}}}
+		var ids []interface{}
+		for {
+			data, cursor, _ := auditRepository.GetAll(context.Background(), "audit", page, nil)
+			for _, document := range data {
+				ids = append(ids, document[common.ID])
+			}
+			if len(data) < page.PageSize {
+				break
+			}
+			page.StartAfter = cursor
+		}
+		// the ties are ordered by the document ID in the direction of changed_at
+		assert.Equal(t, []interface{}{"a5", "a4", "a3", "a2", "a1"}, ids)
+	})
+
+	t.Run("Get with multiple order fields", func(t *testing.T) {
+		spokeRepository := NewMemoryRepository()
+		for id, name := range map[string]string{"p1": "b", "p2": "a", "p3": "b", "p4": "a"} {
+			_, _ = spokeRepository.Save(context.Background(), "spokes", id,
+				map[string]interface{}{common.ID: id, common.Name: name})
+		}
+		data, cursor, _ := spokeRepository.GetAll(context.Background(), "spokes",
+			Page{PageSize: 3, OrderBy: []Order{{Field: common.Name, Sort: firestore.Asc},
+				{Field: common.ID, Sort: firestore.Desc}}}, nil)
+		assert.Equal(t, []interface{}{"p4", "p2", "p3"},
+			[]interface{}{data[0][common.ID], data[1][common.ID], data[2][common.ID]})
+		data, _, _ = spokeRepository.GetAll(context.Background(), "spokes",
+			Page{StartAfter: cursor, PageSize: 3, OrderBy: []Order{{Field: common.Name, Sort: firestore.Asc},
+				{Field: common.ID, Sort: firestore.Desc}}}, nil)
+		assert.Equal(t, 1, len(data))
+		assert.Equal(t, "p1", data[0][common.ID])
+	})
+}
+
+func TestMemoryRepository_CheckSubDocumentsAndDelete(t *testing.T) {
+	repository := getTestSites(t, "s12345")
+	noActiveDocuments, err := repository.CheckSubDocuments(context.Background(), testSitePath, "r12345")
+	assert.Nil(t, err)
+	assert.False(t, noActiveDocuments)
+
+	deleted, err := repository.Delete(context.Background(), testSitePath, "s12345")
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	noActiveDocuments, _ = repository.CheckSubDocuments(context.Background(), testSitePath, "r12345")
+	assert.True(t, noActiveDocuments)
+}
+
+func TestMemoryRepository_SaveInTransaction(t *testing.T) {
+	nameKey := NewUniqueKey(testSitePath, common.Name, "Site Name")
+	t.Run("Save reserves the keys and creates the other documents", func(t *testing.T) {
+		repository := NewMemoryRepository()
+		_, err := repository.SaveInTransaction(context.Background(), testSitePath, "s1", testSite{ID: "s1", Name: "Site Name"},
+			WriteOptions{
+				ReserveKeys: []UniqueKey{nameKey},
+				Creates:     []Document{{CollectionPath: "mappings", DocumentID: "m1", Data: map[string]interface{}{"site_id": "s1"}}},
+			})
+		assert.Nil(t, err)
+		assert.Contains(t, repository.collections["mappings"], "m1")
+		assert.Equal(t, testSitePath+"/s1",
+			repository.collections[common.UniqueKeysCollection][nameKey.ID()][common.DocumentPath])
+	})
+
+	t.Run("Save fails without writing when a key is held by another document", func(t *testing.T) {
+		repository := NewMemoryRepository()
+		_, _ = repository.SaveInTransaction(context.Background(), testSitePath, "s1", testSite{ID: "s1"},
+			WriteOptions{ReserveKeys: []UniqueKey{nameKey}})
+		_, err := repository.SaveInTransaction(context.Background(), testSitePath, "s2", testSite{ID: "s2"},
+			WriteOptions{
+				ReserveKeys: []UniqueKey{nameKey},
+				Creates:     []Document{{CollectionPath: "mappings", DocumentID: "m2", Data: map[string]interface{}{}}},
+			})
+		var uniqueKeyError *UniqueKeyError
+		assert.ErrorAs(t, err, &uniqueKeyError)
+		assert.Equal(t, nameKey, uniqueKeyError.Key)
+		_, err = repository.GetByID(context.Background(), testSitePath, "s2", false)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		assert.NotContains(t, repository.collections["mappings"], "m2")
+	})
+
+	t.Run("Save fails when a created document already exists", func(t *testing.T) {
+		repository := getTestSites(t, "s1")
+		_, err := repository.SaveInTransaction(context.Background(), "mappings", "m1", map[string]interface{}{},
+			WriteOptions{Creates: []Document{{CollectionPath: testSitePath, DocumentID: "s1", Data: testSite{}}}})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+}
+
+func TestMemoryRepository_UpdateInTransaction(t *testing.T) {
+	oldKey := NewUniqueKey(testSitePath, common.Name, "old")
+	newKey := NewUniqueKey(testSitePath, common.Name, "new")
+	repository := NewMemoryRepository()
+	_, _ = repository.SaveInTransaction(context.Background(), testSitePath, "s1", testSite{ID: "s1", Name: "old"},
+		WriteOptions{ReserveKeys: []UniqueKey{oldKey}})
+	_, _ = repository.SaveInTransaction(context.Background(), testSitePath, "s2", testSite{ID: "s2", Name: "other"},
+		WriteOptions{ReserveKeys: []UniqueKey{NewUniqueKey(testSitePath, common.Name, "other")}})
+
+	t.Run("Rename reserves the new name and releases the old one", func(t *testing.T) {
+		_, err := repository.UpdateInTransaction(context.Background(), testSitePath, "s1",
+			[]firestore.Update{{Path: common.Name, Value: "new"}},
+			WriteOptions{ReserveKeys: []UniqueKey{newKey}, ReleaseKeys: []UniqueKey{oldKey}})
+		assert.Nil(t, err)
+		assert.NotContains(t, repository.collections[common.UniqueKeysCollection], oldKey.ID())
+		assert.Contains(t, repository.collections[common.UniqueKeysCollection], newKey.ID())
+	})
+
+	t.Run("Rename to a name held by another document fails", func(t *testing.T) {
+		_, err := repository.UpdateInTransaction(context.Background(), testSitePath, "s2",
+			[]firestore.Update{{Path: common.Name, Value: "new"}},
+			WriteOptions{ReserveKeys: []UniqueKey{newKey}})
+		var uniqueKeyError *UniqueKeyError
+		assert.ErrorAs(t, err, &uniqueKeyError)
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s2", false)
+		assert.Equal(t, "other", data[common.Name])
+	})
+
+	t.Run("Update updates the other documents", func(t *testing.T) {
+		_, err := repository.UpdateInTransaction(context.Background(), testSitePath, "s1",
+			[]firestore.Update{{Path: common.Name, Value: "renamed"}},
+			WriteOptions{Updates: []DocumentUpdate{{CollectionPath: testSitePath, DocumentID: "s2",
+				Updates: []firestore.Update{{Path: "copy.name", Value: "renamed"}}}}})
+		assert.Nil(t, err)
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s2", false)
+		assert.Equal(t, map[string]interface{}{common.Name: "renamed"}, data["copy"])
+	})
+
+	t.Run("Update fails without writing when an other document does not exist", func(t *testing.T) {
+		_, err := repository.UpdateInTransaction(context.Background(), testSitePath, "s1",
+			[]firestore.Update{{Path: common.Name, Value: "not written"}},
+			WriteOptions{Updates: []DocumentUpdate{{CollectionPath: testSitePath, DocumentID: "s3",
+				Updates: []firestore.Update{{Path: "copy.name", Value: "not written"}}}}})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s1", false)
+		assert.Equal(t, "renamed", data[common.Name])
+	})
+
+	t.Run("Update of the read revision bumps the revision", func(t *testing.T) {
+		revision := int64(0)
+		_, err := repository.UpdateInTransaction(context.Background(), testSitePath, "s1",
+			[]firestore.Update{{Path: common.Revision, Value: int64(1)}}, WriteOptions{Revision: &revision})
+		assert.Nil(t, err)
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s1", false)
+		assert.Equal(t, int64(1), data[common.Revision])
+	})
+
+	t.Run("Update fails without writing when the document changed since the read revision", func(t *testing.T) {
+		revision := int64(0)
+		_, err := repository.UpdateInTransaction(context.Background(), testSitePath, "s1",
+			[]firestore.Update{{Path: common.Name, Value: "not written"}, {Path: common.Revision, Value: int64(1)}},
+			WriteOptions{Revision: &revision})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s1", false)
+		assert.Equal(t, "renamed", data[common.Name])
+	})
+
+	t.Run("Update fails without writing when an other document changed since the read revision", func(t *testing.T) {
+		revision := int64(1)
+		_, err := repository.UpdateInTransaction(context.Background(), testSitePath, "s1",
+			[]firestore.Update{{Path: common.Name, Value: "not written"}},
+			WriteOptions{Updates: []DocumentUpdate{{CollectionPath: testSitePath, DocumentID: "s2",
+				Updates: []firestore.Update{{Path: common.Revision, Value: int64(2)}}, Revision: &revision}}})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		data, _ := repository.GetByID(context.Background(), testSitePath, "s1", false)
+		assert.Equal(t, "renamed", data[common.Name])
+	})
+}
+
+func TestMemoryRepository_DeleteInTransaction(t *testing.T) {
+	repository := getTestSites(t, "s12345")
+	deleted, err := repository.DeleteInTransaction(context.Background(), testSitePath, "s12345",
+		WriteOptions{Creates: []Document{{CollectionPath: "events", DocumentID: "e1", Data: map[string]interface{}{}}}})
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+	assert.NotContains(t, repository.collections[testSitePath], "s12345")
+	assert.Contains(t, repository.collections["events"], "e1")
+
+	revision := int64(1)
+	_, err = repository.DeleteInTransaction(context.Background(), "events", "e1",
+		WriteOptions{Updates: []DocumentUpdate{{CollectionPath: "events", DocumentID: "e1", Revision: &revision}}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	revision = 0
+	_, err = repository.DeleteInTransaction(context.Background(), "events", "e1",
+		WriteOptions{Updates: []DocumentUpdate{{CollectionPath: "events", DocumentID: "e1", Revision: &revision}}})
+	assert.Nil(t, err)
+	assert.NotContains(t, repository.collections["events"], "e1")
+
+	repository = getTestSites(t, "s1")
+	revision = 1
+	_, err = repository.DeleteInTransaction(context.Background(), testSitePath, "s1", WriteOptions{Revision: &revision})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, repository.collections[testSitePath], "s1")
+	revision = 0
+	_, err = repository.DeleteInTransaction(context.Background(), testSitePath, "s1", WriteOptions{Revision: &revision})
+	assert.Nil(t, err)
+	assert.NotContains(t, repository.collections[testSitePath], "s1")
+	_, err = repository.DeleteInTransaction(context.Background(), testSitePath, "s1", WriteOptions{Revision: &revision})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestNewDBRepository(t *testing.T) {
+	t.Setenv(common.EnvDBBackend, common.DBBackendMemory)
+	assert.Equal(t, MemoryRepositoryObj, NewDBRepository(context.Background()))
+}
@@ -0,0 +1,153 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// NearQuery is a query of the documents with a location within RadiusKm of the point, ordered by distance
+type NearQuery struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+}
+
+// nearDocument is a document within the radius of a near query along with its distance
+type nearDocument struct {
+	data       map[string]interface{}
+	id         string
+	distanceKm float64
+}
+
+// ParseNearQuery returns the near query of the near ("lat,long") and radius_km query params of the request,
+// nil when near is not passed, or the validation errors of the query params.
+// The documents are ordered by distance, so near can't be combined with order_by, sort or a range filter
+func ParseNearQuery(request *http.Request, where []Where) (*NearQuery, []string) {
+	query := request.URL.Query()
+	if !query.Has(common.QueryParamNear) {
+		if query.Has(common.QueryParamRadiusKm) {
+			return nil, []string{fmt.Sprintf("Query param %s requires the query param %s",
+				common.QueryParamRadiusKm, common.QueryParamNear)}
+		}
+
+		return nil, nil
+	}
+
+	var errs []string
+	near := &NearQuery{}
+	var valid bool
+	near.Latitude, near.Longitude, valid = utils.ParseLatLong(query.Get(common.QueryParamNear))
+	if !valid {
+		errs = append(errs, fmt.Sprintf("Invalid value for query param %s, it must be lat,long with -90 < lat < 90 "+
+			"and -180 < long < 180", common.QueryParamNear))
+	}
+	radiusKm, err := strconv.ParseFloat(query.Get(common.QueryParamRadiusKm), 64)
+	if err != nil || radiusKm <= 0 || radiusKm > common.MaxRadiusKm {
+		errs = append(errs, fmt.Sprintf("Invalid value for query param %s, it must be between 0 and %v",
+			common.QueryParamRadiusKm, common.MaxRadiusKm))
+	}
+	near.RadiusKm = radiusKm
+
+	if query.Has(common.QueryParamOrderBy) || query.Has(common.QueryParamSort) {
+		errs = append(errs, fmt.Sprintf("Query param %s can not be combined with %s or %s, "+
+			"the results are ordered by distance", common.QueryParamNear, common.QueryParamOrderBy,
+			common.QueryParamSort))
+	}
+	for _, clause := range where {
+		if clause.Operator != common.OperatorEquals {
+			errs = append(errs, fmt.Sprintf("Query param %s can not be combined with a filter on %s",
+				common.QueryParamNear, clause.Field))
+
+			break
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	return near, nil
+}
+
+// GetAllNear returns the page of the documents under the collectionPath matching the where clauses with a location
+// within the radius of the near query, ordered by distance and then by ID, with the distance in the
+// common.DistanceKm field, along with the cursor of the last document when there are more documents.
+// The documents are read from the geohash ranges of the cells covering the circle and filtered on their distance,
+// so every page reads all the documents of those cells, which is bounded by common.MaxRadiusKm
+func GetAllNear(ctx context.Context, dbClient DB, collectionPath string, near NearQuery, page Page,
+	where []Where) ([]map[string]interface{}, *Cursor, error) {
+	var documents []nearDocument
+	for _, prefix := range utils.GetGeohashPrefixes(near.Latitude, near.Longitude, near.RadiusKm) {
+		data, _, err := dbClient.GetAll(ctx, collectionPath,
+			Page{PageSize: math.MaxInt, OrderBy: []Order{{Field: common.Geohash, Sort: common.SortAscending}}},
+			append([]Where{
+				{Field: common.Geohash, Operator: common.OperatorGreaterThanOrEquals, Value: prefix},
+				{Field: common.Geohash, Operator: common.OperatorLessThan, Value: prefix + common.GeohashRangeEnd},
+			}, where...))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, document := range data {
+			distanceKm, ok := getDistanceKm(document, near)
+			if ok && distanceKm <= near.RadiusKm && isAfterCursor(distanceKm, fmt.Sprint(document[common.ID]),
+				page.StartAfter) {
+				documents = append(documents,
+					nearDocument{data: document, id: fmt.Sprint(document[common.ID]), distanceKm: distanceKm})
+			}
+		}
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		if documents[i].distanceKm != documents[j].distanceKm {
+			return documents[i].distanceKm < documents[j].distanceKm
+		}
+
+		return documents[i].id < documents[j].id
+	})
+
+	var cursor *Cursor
+	if len(documents) > page.PageSize {
+		documents = documents[:page.PageSize]
+		lastDocument := documents[len(documents)-1]
+		cursor = &Cursor{Values: []any{lastDocument.distanceKm}, ID: lastDocument.id}
+	}
+	result := make([]map[string]interface{}, 0, len(documents))
+	for _, document := range documents {
+		document.data[common.DistanceKm] = math.Round(document.distanceKm*1000) / 1000
+		result = append(result, document.data)
+	}
+
+	return result, cursor, nil
+}
+
+// getDistanceKm returns the distance of the location of the document to the point of the near query
+func getDistanceKm(document map[string]interface{}, near NearQuery) (float64, bool) {
+	location, ok := document[common.Location].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	latitude, latitudeOK := toFloat(normalizeValue(location[common.Latitude]))
+	longitude, longitudeOK := toFloat(normalizeValue(location[common.Longitude]))
+	if !latitudeOK || !longitudeOK {
+		return 0, false
+	}
+
+	return utils.GetDistanceKm(near.Latitude, near.Longitude, latitude, longitude), true
+}
+
+// isAfterCursor returns whether the document comes after the cursor of the previous page in the distance order
+func isAfterCursor(distanceKm float64, id string, cursor *Cursor) bool {
+	if cursor == nil || len(cursor.Values) == 0 {
+		return true
+	}
+	cursorDistanceKm, ok := toFloat(normalizeValue(cursor.Values[0]))
+	if !ok || distanceKm != cursorDistanceKm {
+		return !ok || distanceKm > cursorDistanceKm
+	}
+
+	return id > cursor.ID
+}
@@ -0,0 +1,131 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getNearTestSites returns a repository with sites at 0, 1, 3 and 50 km north of the point 37.7749,-122.4194,
+// a deactivated site at 2 km and a site at 0 km without a geohash
+func getNearTestSites(t *testing.T) *MemoryRepository {
+	repository := NewMemoryRepository()
+	for id, site := range map[string]struct {
+		northKm     float64
+		geohash     bool
+		deactivated bool
+	}{
+		"s0": {northKm: 0, geohash: true},
+		"s1": {northKm: 1, geohash: true},
+		"s2": {northKm: 2, geohash: true, deactivated: true},
+		"s3": {northKm: 3, geohash: true},
+		"s4": {northKm: 50, geohash: true},
+		"s5": {northKm: 0},
+	} {
+		latitude := 37.7749 + site.northKm/111.195
+		document := map[string]interface{}{
+			"id":               id,
+			"location":         map[string]interface{}{"lat": latitude, "long": -122.4194},
+			"deactivated_time": nil,
+		}
+		if site.geohash {
+			document["geohash"] = utils.GetGeohash(latitude, -122.4194, common.GeohashPrecision)
+		}
+		if site.deactivated {
+			document["deactivated_time"] = "2022-01-01T00:00:00Z"
+		}
+		_, err := repository.Save(context.Background(), testSitePath, id, document)
+		assert.Nil(t, err)
+	}
+
+	return repository
+}
+
+func TestParseNearQuery(t *testing.T) {
+	t.Run("Near query not passed", func(t *testing.T) {
+		near, errs := ParseNearQuery(httptest.NewRequest(http.MethodGet, "/sites", nil), nil)
+		assert.Nil(t, near)
+		assert.Nil(t, errs)
+	})
+
+	t.Run("Near query with an equality filter", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?near=37.7749,%20-122.4194&radius_km=2.5", nil)
+		near, errs := ParseNearQuery(r, []Where{{Field: common.Status, Operator: common.OperatorEquals, Value: "draft"}})
+		assert.Nil(t, errs)
+		assert.Equal(t, &NearQuery{Latitude: 37.7749, Longitude: -122.4194, RadiusKm: 2.5}, near)
+	})
+
+	t.Run("Radius without near", func(t *testing.T) {
+		_, errs := ParseNearQuery(httptest.NewRequest(http.MethodGet, "/sites?radius_km=2", nil), nil)
+		assert.Equal(t, []string{"Query param radius_km requires the query param near"}, errs)
+	})
+
+	t.Run("Invalid near and radius", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?near=91,10&radius_km=501", nil)
+		near, errs := ParseNearQuery(r, nil)
+		assert.Nil(t, near)
+		assert.Equal(t, []string{
+			"Invalid value for query param near, it must be lat,long with -90 < lat < 90 and -180 < long < 180",
+			"Invalid value for query param radius_km, it must be between 0 and 500",
+		}, errs)
+	})
+
+	t.Run("Near with a sort or a range filter", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sites?near=10,10&radius_km=1&sort=desc", nil)
+		_, errs := ParseNearQuery(r, []Where{{Field: common.Name, Operator: common.OperatorGreaterThanOrEquals}})
+		assert.Equal(t, []string{
+			"Query param near can not be combined with order_by or sort, the results are ordered by distance",
+			"Query param near can not be combined with a filter on name",
+		}, errs)
+	})
+}
+
+func TestGetAllNear(t *testing.T) {
+	near := NearQuery{Latitude: 37.7749, Longitude: -122.4194, RadiusKm: 10}
+	active := []Where{{Field: common.DeactivatedTime, Operator: common.OperatorEquals, Value: nil}}
+
+	t.Run("Documents within the radius ordered by distance", func(t *testing.T) {
+		data, cursor, err := GetAllNear(context.Background(), getNearTestSites(t), testSitePath, near,
+			Page{PageSize: 10}, active)
+		assert.Nil(t, err)
+		assert.Nil(t, cursor)
+		assert.Equal(t, 3, len(data))
+		assert.Equal(t, []interface{}{"s0", "s1", "s3"}, []interface{}{data[0]["id"], data[1]["id"], data[2]["id"]})
+		assert.Equal(t, 0.0, data[0][common.DistanceKm])
+		assert.Equal(t, 1.0, data[1][common.DistanceKm])
+		assert.Equal(t, 3.0, data[2][common.DistanceKm])
+	})
+
+	t.Run("Documents paginated with the cursor", func(t *testing.T) {
+		repository := getNearTestSites(t)
+		data, cursor, err := GetAllNear(context.Background(), repository, testSitePath, near, Page{PageSize: 2}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"s0", "s1"}, []interface{}{data[0]["id"], data[1]["id"]})
+		assert.Equal(t, "s1", cursor.ID)
+
+		data, cursor, err = GetAllNear(context.Background(), repository, testSitePath, near,
+			Page{PageSize: 2, StartAfter: cursor}, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, cursor)
+		assert.Equal(t, []interface{}{"s2", "s3"}, []interface{}{data[0]["id"], data[1]["id"]})
+	})
+
+	t.Run("Error while fetching the documents", func(t *testing.T) {
+		_, _, err := GetAllNear(context.Background(), errorDB{}, testSitePath, near, Page{PageSize: 2}, nil)
+		assert.NotNil(t, err)
+	})
+}
+
+// errorDB fails every query
+type errorDB struct {
+	DB
+}
+
+func (errorDB) GetAll(context.Context, string, Page, []Where) ([]map[string]interface{}, *Cursor, error) {
+	return nil, nil, errors.New("connection timeout")
+}
@@ -40,7 +40,8 @@ func NewPubSubRepository(ctx context.Context) *PubSubRepository {
 }
 
 // Publish is a common method to publish any message to the topicName
-func (p *PubSubRepository) Publish(ctx context.Context, topicName string, message any) {
+// the error is returned when the message could not be published so that the caller can retry
+func (p *PubSubRepository) Publish(ctx context.Context, topicName string, message any) error {
 	ctx, span := trace.StartSpan(ctx, utils.GetSpanName("pubsub.Publish"))
 	defer span.End()
 	topic := p.client.Topic(topicName)
@@ -51,7 +52,7 @@ func (p *PubSubRepository) Publish(ctx context.Context, topicName string, messag
 	if err != nil {
 		logging.GetLoggerFromContext(ctx).Errorf("Error while marshalling message to byte array: %v", err)
 
-		return
+		return err
 	}
 
 	r := topic.Publish(ctx, &pubsub.Message{
@@ -63,10 +64,12 @@ func (p *PubSubRepository) Publish(ctx context.Context, topicName string, messag
 	for _, r := range results {
 		id, err := r.Get(ctx)
 		if err != nil {
-			logging.GetLoggerFromContext(ctx).Errorf("Error while publishing message to audit pubsub: %v", err)
+			logging.GetLoggerFromContext(ctx).Errorf("Error while publishing message to %s pubsub: %v", topicName, err)
 
-			return
+			return err
 		}
 		logging.GetLoggerFromContext(ctx).Debugf("Message successfully published message id : %s", id)
 	}
+
+	return nil
 }
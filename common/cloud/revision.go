@@ -0,0 +1,21 @@
+package cloud
+
+import (
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkRevision returns a FailedPrecondition error when the document data does not have the revision,
+// nil when the revision is nil
+func checkRevision(documentPath string, data map[string]interface{}, revision *int64) error {
+	if revision == nil {
+		return nil
+	}
+	if currentRevision := utils.GetRevision(data); currentRevision != *revision {
+		return status.Errorf(codes.FailedPrecondition, "document %s has the revision %d instead of %d",
+			documentPath, currentRevision, *revision)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package cloud
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// UniqueKey is a field value which can be held by only one document within its scope.
+// The scope is the collection path for values unique within a collection (e.g. site name for a retailer)
+// or the collection ID for values unique across a collection group (e.g. site ID across all retailers)
+type UniqueKey struct {
+	Scope string
+	Field string
+	Value string
+}
+
+// NewUniqueKey creates a UniqueKey for the field value within the scope
+func NewUniqueKey(scope string, field string, value string) UniqueKey {
+	return UniqueKey{Scope: scope, Field: field, Value: value}
+}
+
+// ID returns the ID of the reservation document of the key in the UniqueKeysCollection.
+// A hash is used as the values may contain characters which are not allowed in document IDs
+func (key UniqueKey) ID() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join([]string{key.Scope, key.Field, key.Value}, "\x00"))))
+}
+
+// uniqueKeyReservation is the document stored in the UniqueKeysCollection for a reserved key
+type uniqueKeyReservation struct {
+	Scope        string `firestore:"scope"`
+	Field        string `firestore:"field"`
+	Value        string `firestore:"value"`
+	DocumentPath string `firestore:"document_path"`
+}
+
+func newUniqueKeyReservation(key UniqueKey, documentPath string) uniqueKeyReservation {
+	return uniqueKeyReservation{
+		Scope:        key.Scope,
+		Field:        key.Field,
+		Value:        key.Value,
+		DocumentPath: documentPath,
+	}
+}
+
+// UniqueKeyError is returned by the transactional writes when a key is already held by another document
+type UniqueKeyError struct {
+	Key UniqueKey
+}
+
+func (e *UniqueKeyError) Error() string {
+	return fmt.Sprintf("%s %s is already used in %s", e.Key.Field, e.Key.Value, e.Key.Scope)
+}
+
+// releasedKeys returns the ReleaseKeys which are not reserved again by the same write
+func (options WriteOptions) releasedKeys() []UniqueKey {
+	var keys []UniqueKey
+	for _, key := range options.ReleaseKeys {
+		reserved := false
+		for _, reserveKey := range options.ReserveKeys {
+			reserved = reserved || reserveKey == key
+		}
+		if !reserved {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+func getDocumentPath(collectionPath string, documentID string) string {
+	return fmt.Sprintf("%s/%s", collectionPath, documentID)
+}
@@ -11,6 +11,7 @@ const EnvAuditLogTopic string = "AUDIT_LOG_TOPIC"
 const EnvRetailerMessageTopic = "RETAILER_MESSAGE_TOPIC"
 const EnvSiteMessageTopic = "SITE_MESSAGE_TOPIC"
 const EnvSpokeMessageTopic = "SPOKE_MESSAGE_TOPIC"
+const EnvSiteSpokeCopyTopic = "SITE_SPOKE_COPY_TOPIC"
 
 const ServiceName string = "site-info-svc"
 const RetailersCollection string = "site-info-retailers"
@@ -18,6 +19,7 @@ const SitesCollection string = "site-info-sites"
 const RetailerAuditCollection string = "site-info-retailer-audit"
 const SiteAuditCollection string = "site-info-site-audit"
 const SpokesCollection = "site-info-spokes"
+const SpokeAuditCollection string = "site-info-spoke-audit"
 const SiteSpokeCollection = "site-info-site-spoke"
 
 const RetailerIDPrefix string = "r"
@@ -28,10 +30,30 @@ const SitePath string = "/sites/"
 const SpokePath string = "/spokes/"
 
 const QueryParamDeactivated string = "deactivated"
+const QueryParamDeletion string = "deletion"
+const QueryParamOrderBy string = "order_by"
+const QueryParamSort string = "sort"
+const QueryParamNamePrefix string = "name_prefix"
+const QueryParamCreatedAfter string = "created_after"
+const QueryParamCreatedBefore string = "created_before"
+const QueryParamUpdatedAfter string = "updated_after"
+const QueryParamUpdatedBefore string = "updated_before"
+const QueryParamNear string = "near"
+const QueryParamRadiusKm string = "radius_km"
+const QueryParamLocation string = "location"
+const QueryParamPostalCode string = "postal_code"
+const QueryParamAt string = "at"
+
+// QueryParamAttributesPrefix prefixes the query params filtering on the declared attributes, e.g. attributes.brand
+const QueryParamAttributesPrefix string = "attributes."
 const PathParamSiteID string = "site_id"
 const PathParamRetailerID string = "retailer_id"
 const PathParamSpokeID string = "spoke_id"
 const PathParamDeactivate string = "deactivate"
+const PathParamUndelete string = "undelete"
+const PathParamReplay string = "replay"
+const PathParamDeadLetterID string = "dead_letter_id"
+const PathParamEntity string = "entity"
 
 const MaxRetryCount int = 3
 const DefaultPageSize int = 25
@@ -47,23 +69,61 @@ const HeaderTimezone string = "timezone"
 const HeaderLocation string = "Location"
 const HeaderEtag string = "ETag"
 const HeaderIfMatch string = "If-Match"
+const HeaderIfNoneMatch string = "If-None-Match"
+const HeaderIfModifiedSince string = "If-Modified-Since"
+
+// ETagWildcard matches any ETag in an If-None-Match header, and WeakETagPrefix prefixes a weak ETag
+const ETagWildcard string = "*"
+const WeakETagPrefix string = "W/"
 const HeaderPageToken string = "page_token"
 const HeaderPageSize string = "page_size"
 const HeaderRetailerID string = "retailer_id"
 const HeaderNextPageToken string = "next_page_token"
+const HeaderServiceAreaOverlaps string = "service_area_overlaps"
 
 const HeaderContentType string = "Content-Type"
+const HeaderAuthorization string = "Authorization"
+const HeaderWWWAuthenticate string = "WWW-Authenticate"
+const AuthorizationSchemeBearer string = "Bearer"
 const ContentTypeApplicationJSON string = "application/json"
 
+// ContentTypeMergePatchJSON is the content type of a JSON merge patch (RFC 7396) body of a PATCH request
+const ContentTypeMergePatchJSON string = "application/merge-patch+json"
+
 const Name string = "name"
 const ID string = "id"
 const ETag string = "etag"
+
+// Revision is the revision of a document, incremented by every change. It prefixes the ETag of the document before
+// ETagRevisionSeparator, and a write with a WriteOptions.Revision precondition fails when it changed
+const Revision string = "revision"
+const ETagRevisionSeparator string = "-"
 const RetailersSiteID string = "retailer_site_id"
 const ChangedAt string = "changed_at"
 const DeactivatedTime string = "deactivated_time"
+const CreatedTime string = "created_time"
+const UpdatedTime string = "updated_time"
+const Timezone string = "timezone"
+const Location string = "location"
+const Latitude string = "lat"
+const Longitude string = "long"
+const Geohash string = "geohash"
+const DistanceKm string = "distance_km"
+const ServiceArea string = "service_area"
+const ServiceAreaGeohashes string = "service_area.geohashes"
+const ServiceAreaPostalCodes string = "service_area.postal_codes"
+const OperatingHours string = "operating_hours"
+const Address string = "address"
+const Attributes string = "attributes"
+const AttributeSchema string = "attribute_schema"
 
 const Status string = "status"
 const SiteID string = "site_id"
+const SpokeID string = "spoke_id"
+
+// SiteSpokeSite and SiteSpokeSpoke are the fields of the site spoke mapping with the copies of the site and the spoke
+const SiteSpokeSite string = "site"
+const SiteSpokeSpoke string = "spoke"
 
 const TimeParseFormat string = "2006-01-02 15:04:05 -0700 MST"
 
@@ -74,23 +134,33 @@ const Validate string = "validate"
 const EntityRetailer string = "retailer"
 const EntitySite string = "site"
 const EntitySpoke string = "spoke"
+const EntitySiteSpoke string = "site_spoke"
 
 const AuditTypeCreate string = "create"
 const AuditTypeUpdate string = "update"
 const AuditTypeDeactivate string = "deactivate"
+const AuditTypeUndelete string = "undelete"
+const AuditTypeAttach string = "attach"
+const AuditTypeDetach string = "detach"
 
+// User is the principal of the changes made without an identity, when IDENTITY_MODE is none
 const User string = "api@takeoff.com"
 
 const RandomIDLength int = 5
 const ColonSeparator string = "::::"
 const Underscore = "_"
 const True string = "true"
+
+// the encryption keys are only used to decode the page tokens issued before the signed page tokens,
+// until PAGE_TOKEN_LEGACY_UNTIL
 const RetailersEncryptionKey = "wC1wr8eci3fmWz" + EntityRetailer
 const SitesEncryptionKey = "RCmJqUPtgW2NnjUq8m" + EntitySite
 const SpokesEncryptionKey = "XgZXO5fFV2niFN2op" + EntitySpoke
 
 const SortAscending = firestore.Asc
 const SortDescending = firestore.Desc
+const SortAsc string = "asc"
+const SortDesc string = "desc"
 
 const StatusDraft = "draft"
 const StatusDeprecated = "deprecated"
@@ -99,6 +169,85 @@ const TimezoneAPIUrl = "https://maps.googleapis.com/maps/api/timezone/json"
 const GoogleMapsAPIEnv = "GOOGLE_MAPS_API_KEY"
 const LocationParam = "location"
 const TimestampParam = "timestamp"
+const TimezoneAPITimeout = time.Second * 5
+const TimezoneAPIAttempts int = 3
+const TimezoneAPIRetryDelay = time.Millisecond * 200
+
+// EnvTimezoneResolver selects the timezone resolver, TimezoneResolverOffline by default
+const EnvTimezoneResolver string = "TIMEZONE_RESOLVER"
+const TimezoneResolverGoogle string = "google"
+const TimezoneResolverOffline string = "offline"
+
+// EnvTimezoneCacheSize is the number of coordinates whose timezone is cached, 0 disables the cache
+const EnvTimezoneCacheSize string = "TIMEZONE_CACHE_SIZE"
+const DefaultTimezoneCacheSize int = 10000
+
+// TimezoneCacheDecimals is the number of decimals the coordinates are rounded to for the timezone cache, about 110m
+const TimezoneCacheDecimals int = 3
+
+// GeohashPrecision is the length of the geohash stored with a location, a cell of about 1.2m x 0.6m
+const GeohashPrecision int = 10
+const GeohashAlphabet string = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeohashRangeEnd sorts after every character of GeohashAlphabet, to query the geohashes starting with a prefix
+const GeohashRangeEnd string = "~"
+const EarthRadiusKm float64 = 6371
+const MaxRadiusKm float64 = 500
+
+// GeoJSONPolygon is the GeoJSON type of a service area polygon
+const GeoJSONPolygon string = "Polygon"
+const MaxServiceAreaPositions int = 1000
+const MaxServiceAreaPostalCodes int = 1000
+
+// MaxServiceAreaGeohashes is the maximum number of geohash cells covering the bounding box of a service area polygon
+const MaxServiceAreaGeohashes int = 16
+
+// HoursDateLayout is the layout of the dates of the operating hours exceptions
+const HoursDateLayout string = "2006-01-02"
+const MaxWeeklyHours int = 50
+const MaxHoursExceptions int = 400
+
+// OpenWindowSearchDays is the number of days after a time searched for the next opening window
+const OpenWindowSearchDays int = 366
+
+// MaxAddressLines is the maximum number of street lines of an address, and MaxAddressFieldLength the maximum
+// number of characters of a line or another field of an address
+const MaxAddressLines int = 3
+const MaxAddressFieldLength int = 100
+
+// EnvGeocoder selects the geocoder deriving the location of an address, none by default
+const EnvGeocoder string = "GEOCODER"
+const GeocoderGoogle string = "google"
+const GeocoderLocal string = "local"
+
+// EnvGeocoderLocalFile is the JSON file of the addresses and their locations of the local geocoder
+const EnvGeocoderLocalFile string = "GEOCODER_LOCAL_FILE"
+const GeocodingAPIUrl = "https://maps.googleapis.com/maps/api/geocode/json"
+const GeocodingAPITimeout = time.Second * 5
+const GeocodingAPIAttempts int = 3
+const GeocodingAPIRetryDelay = time.Millisecond * 200
+const AddressParam = "address"
+const ComponentsParam = "components"
+
+// MaxAddressDistanceKm is the maximum distance between a location and the location geocoded from its address
+const MaxAddressDistanceKm float64 = 1
+
+// SchemaTypeString and the other schema types are the types of the JSON Schema of the attributes of a retailer
+const SchemaTypeString string = "string"
+const SchemaTypeNumber string = "number"
+const SchemaTypeInteger string = "integer"
+const SchemaTypeBoolean string = "boolean"
+const SchemaTypeArray string = "array"
+const SchemaTypeObject string = "object"
+const SchemaFormatDate string = "date"
+const SchemaFormatDateTime string = "date-time"
+
+// AttributeNameRegex is the name of an attribute, which can be used as a Firestore field path without quoting
+const AttributeNameRegex string = "^[a-z][a-z0-9_]{0,63}$"
+
+// MaxAttributeSchemaDepth is the maximum nesting of the schemas within the attribute schema of a retailer
+const MaxAttributeSchemaDepth int = 5
+
 const APIKeyParam = "key"
 const NameRegex = "^[a-zA-Z0-9]+(?:[. _-]*[a-zA-Z0-9]+)*$"
 const MinNameLength = 5
@@ -109,6 +258,89 @@ const ReturnError = -1
 const OperatorEquals string = "=="
 const OperatorIn string = "in"
 
+// MaxInValues is the number of values Firestore accepts in an in filter
+const MaxInValues int = 30
+const OperatorGreaterThan string = ">"
+const OperatorGreaterThanOrEquals string = ">="
+const OperatorLessThan string = "<"
+const OperatorArrayContains string = "array-contains"
+const OperatorArrayContainsAny string = "array-contains-any"
+
+// OperatorPrefix is not a firestore operator, a prefix filter is a range on the field from the prefix up to
+// the prefix followed by PrefixRangeEnd, the highest code point commonly used
+const OperatorPrefix string = "prefix"
+const PrefixRangeEnd string = "\uf8ff"
+
+// EnvIdentityMode selects how the principal of the caller is identified, none by default which attributes the changes
+// to User. With jwt it is a claim of the verified bearer JWT and with gateway the value of a header set by a gateway
+const EnvIdentityMode string = "IDENTITY_MODE"
+const IdentityModeJWT string = "jwt"
+const IdentityModeGateway string = "gateway"
+
+// EnvIdentityJWKSFile and EnvIdentityJWKSURL are the JWKS file or URL of the public keys verifying the bearer JWTs
+const EnvIdentityJWKSFile string = "IDENTITY_JWKS_FILE"
+const EnvIdentityJWKSURL string = "IDENTITY_JWKS_URL"
+
+// EnvIdentityIssuer and EnvIdentityAudience are the iss and one of the aud of the bearer JWTs, not checked when empty
+const EnvIdentityIssuer string = "IDENTITY_ISSUER"
+const EnvIdentityAudience string = "IDENTITY_AUDIENCE"
+
+// EnvIdentityPrincipalClaim is the claim of the principal in the bearer JWTs, the email and else the sub by default
+const EnvIdentityPrincipalClaim string = "IDENTITY_PRINCIPAL_CLAIM"
+const ClaimEmail string = "email"
+const ClaimSubject string = "sub"
+
+// EnvIdentityGatewayHeader is the header of the principal set by the gateway, DefaultIdentityGatewayHeader by default
+const EnvIdentityGatewayHeader string = "IDENTITY_GATEWAY_HEADER"
+const DefaultIdentityGatewayHeader string = "X-Authenticated-User"
+
+// JWTClockSkew is the time a bearer JWT is accepted before its nbf and after its exp
+const JWTClockSkew = time.Minute
+const JWKSAPITimeout = time.Second * 5
+
+// JWKSRefreshInterval is the time the keys of the JWKS URL are cached, and JWKSMinRefreshInterval the minimum time
+// between two fetches when a JWT is signed by an unknown key
+const JWKSRefreshInterval = time.Hour
+const JWKSMinRefreshInterval = time.Minute
+
+// EnvAuthorizationMode selects where the grants of the principals on the retailers are read from. With file they are
+// read from the JSON file AUTHORIZATION_GRANTS_FILE and with db from the document of the principal in GrantsCollection.
+// With none every principal is allowed on every retailer, and the requests are rejected when it is not set or has
+// another value
+const EnvAuthorizationMode string = "AUTHORIZATION_MODE"
+const AuthorizationModeFile string = "file"
+const AuthorizationModeDB string = "db"
+const AuthorizationModeNone string = "none"
+const EnvAuthorizationGrantsFile string = "AUTHORIZATION_GRANTS_FILE"
+const GrantsCollection string = "site-info-grants"
+const Grants string = "grants"
+
+// RoleReader reads the entities of a retailer, RoleEditor also changes them and RoleAdmin also deactivates and
+// undeletes them. Each role has the permissions of the roles before it
+const RoleReader string = "reader"
+const RoleEditor string = "editor"
+const RoleAdmin string = "admin"
+
+// AllRetailers is the retailer ID of the grants on every retailer, required by the operations across the retailers
+const AllRetailers string = "*"
+
+// HeaderIdempotencyKey identifies the retries of a create or state changing request, whose first response is stored
+// in IdempotencyKeysCollection for IDEMPOTENCY_KEY_RETENTION and replayed with HeaderIdempotentReplayed on the retries
+const HeaderIdempotencyKey string = "Idempotency-Key"
+const HeaderIdempotentReplayed string = "Idempotent-Replayed"
+const IdempotencyKeysCollection string = "site-info-idempotency-keys"
+const EnvIdempotencyKeyRetention string = "IDEMPOTENCY_KEY_RETENTION"
+const DefaultIdempotencyKeyRetention = time.Hour * 24
+const MaxIdempotencyKeyLength int = 255
+const Completed string = "completed"
+const StatusCode string = "status_code"
+const Headers string = "headers"
+const Body string = "body"
+
+// IdempotencyKeyLockTimeout is the time after which a request that did not complete no longer holds its key,
+// the timeout of the functions
+const IdempotencyKeyLockTimeout = time.Minute
+
 const ChangeTypeCreate string = "create"
 const ChangeTypeUpdate string = "update"
 const ChangeTypeDelete string = "delete"
@@ -127,3 +359,37 @@ func GetSupportedVersions() []string {
 
 const StatusTransitionsCollection string = "site-info-status-transitions"
 const SiteStatusTransitionsDocument string = "site-status-transitions"
+
+const EnvDBBackend string = "DB_BACKEND"
+const DBBackendMemory string = "memory"
+
+const UniqueKeysCollection string = "site-info-unique-keys"
+const Scope string = "scope"
+const Field string = "field"
+const Value string = "value"
+const DocumentPath string = "document_path"
+
+const OutboxCollection string = "site-info-outbox"
+const NextAttemptTime string = "next_attempt_time"
+const Attempts string = "attempts"
+const LastError string = "last_error"
+const OperatorLessThanOrEquals string = "<="
+const OutboxRelayBatchSize int = 100
+const OutboxMinBackoff = time.Second * 30
+const OutboxMaxBackoff = time.Hour
+
+const RequeuePageSize int = 100
+
+// SiteSpokeCopyBatchSize is the number of site spoke mappings whose copy is written in a transaction,
+// below the 500 writes allowed in a Firestore commit
+const SiteSpokeCopyBatchSize int = 400
+const RequeueTimeBudget = time.Second * 40
+const RequeueCursorSeparator string = "/"
+
+const AuditDeadLetterCollection string = "site-info-audit-dead-letter"
+
+const EnvPageTokenKeys string = "PAGE_TOKEN_KEYS"
+const EnvPageTokenLegacyUntil string = "PAGE_TOKEN_LEGACY_UNTIL"
+const PageTokenKeySeparator string = ","
+const PageTokenKeyIDSeparator string = ":"
+const PageTokenSeparator string = "."
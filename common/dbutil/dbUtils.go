@@ -1,22 +1,37 @@
 package dbutil
 
 import (
+	"cloud.google.com/go/firestore"
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/outbox"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/TakeoffTech/site-info-svc/common/utils"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"net/http"
+	"os"
+	"reflect"
+	"strings"
 )
 
 // IsRetailerIDPresentInDB will check retailer with given retailer ID present in db.
 func IsRetailerIDPresentInDB(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB,
 	retailerID string, logger *zap.SugaredLogger, skipDeactivated bool) bool {
+	_, found := GetRetailerFromDB(responseWriter, request, dbClient, retailerID, logger, skipDeactivated)
+
+	return found
+}
+
+// GetRetailerFromDB returns the retailer with given retailer ID from db, e.g. for its attribute schema. When it is not
+// found it responds with not found, and on other errors with an internal server error, returning false
+func GetRetailerFromDB(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB,
+	retailerID string, logger *zap.SugaredLogger, skipDeactivated bool) (map[string]interface{}, bool) {
 	//Checks retailer if ID exists in DB
-	_, err := dbClient.GetByID(request.Context(), common.RetailersCollection, retailerID, skipDeactivated)
+	retailerData, err := dbClient.GetByID(request.Context(), common.RetailersCollection, retailerID, skipDeactivated)
 	if err != nil {
 		if status.Code(err) == codes.NotFound {
 			response.RespondWithNotFoundErrorMessage(responseWriter, request,
@@ -26,10 +41,10 @@ func IsRetailerIDPresentInDB(responseWriter http.ResponseWriter, request *http.R
 			response.RespondWithInternalServerError(responseWriter, request)
 		}
 
-		return false
+		return nil, false
 	}
 
-	return true
+	return retailerData, true
 }
 
 func IsSiteIDPresentInDB(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB,
@@ -69,3 +84,80 @@ func IsSpokeIDPresentInDB(responseWriter http.ResponseWriter, request *http.Requ
 
 	return true
 }
+
+// SiteSpokeCopyMessage asks to copy the site or the spoke, the Entity, onto its site spoke mappings
+type SiteSpokeCopyMessage struct {
+	RetailerID string `json:"retailer_id"`
+	Entity     string `json:"entity"`
+	ID         string `json:"id"`
+}
+
+// GetSiteSpokeCopyMessage returns the message written in the outbox along with an update of a site or a spoke,
+// its mappings are copied in batches by the PushSiteSpokeCopies function once the update is committed
+func GetSiteSpokeCopyMessage(retailerID string, entity string, id string) outbox.Message {
+	return outbox.Message{
+		Topic: os.Getenv(common.EnvSiteSpokeCopyTopic),
+		Data:  SiteSpokeCopyMessage{RetailerID: retailerID, Entity: entity, ID: id},
+	}
+}
+
+// GetServiceAreaUpdate returns the update of the service area of a site or a spoke,
+// deleting the service area when it is removed
+func GetServiceAreaUpdate(serviceArea *models.ServiceArea) firestore.Update {
+	if serviceArea == nil {
+		return firestore.Update{Path: common.ServiceArea, Value: firestore.Delete}
+	}
+
+	return firestore.Update{Path: common.ServiceArea, Value: serviceArea}
+}
+
+// GetOperatingHoursUpdate returns the update of the operating hours of a site or a spoke,
+// deleting the operating hours when they are removed
+func GetOperatingHoursUpdate(operatingHours *models.OperatingHours) firestore.Update {
+	if operatingHours == nil {
+		return firestore.Update{Path: common.OperatingHours, Value: firestore.Delete}
+	}
+
+	return firestore.Update{Path: common.OperatingHours, Value: operatingHours}
+}
+
+// GetAddressUpdate returns the update of the address of a site or a spoke, deleting the address when it is removed
+func GetAddressUpdate(address *models.Address) firestore.Update {
+	if address == nil {
+		return firestore.Update{Path: common.Address, Value: firestore.Delete}
+	}
+
+	return firestore.Update{Path: common.Address, Value: address}
+}
+
+// GetAttributesUpdate returns the update of the attributes of a site or a spoke, deleting the attributes when they
+// are all removed
+func GetAttributesUpdate(attributes map[string]interface{}) firestore.Update {
+	if len(attributes) == 0 {
+		return firestore.Update{Path: common.Attributes, Value: firestore.Delete}
+	}
+
+	return firestore.Update{Path: common.Attributes, Value: attributes}
+}
+
+// GetUpdates returns the minimal updates of a document from the old entity to the entity, a struct of the same type,
+// with an update of each field of the firestore tag which changed. The removed omitempty fields are deleted
+func GetUpdates(entity interface{}, oldEntity interface{}) []firestore.Update {
+	value := reflect.Indirect(reflect.ValueOf(entity))
+	oldValue := reflect.Indirect(reflect.ValueOf(oldEntity))
+	var updates []firestore.Update
+	for i := 0; i < value.NumField(); i++ {
+		path, options, _ := strings.Cut(value.Type().Field(i).Tag.Get(common.Firestore), ",")
+		field := value.Field(i)
+		if path == "" || path == "-" || reflect.DeepEqual(field.Interface(), oldValue.Field(i).Interface()) {
+			continue
+		}
+		if field.IsZero() && strings.Contains(options, "omitempty") {
+			updates = append(updates, firestore.Update{Path: path, Value: firestore.Delete})
+		} else {
+			updates = append(updates, firestore.Update{Path: path, Value: field.Interface()})
+		}
+	}
+
+	return updates
+}
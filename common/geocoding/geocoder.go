@@ -0,0 +1,138 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ErrNotFound is returned when the address has no location, e.g. an address which does not exist
+var ErrNotFound = errors.New("address not found")
+
+// ErrUnavailable is returned when the location of the address could not be geocoded
+var ErrUnavailable = errors.New("geocoder unavailable")
+
+// Geocoder geocodes the location of a postal address
+type Geocoder interface {
+	Geocode(ctx context.Context, address models.Address) (models.Location, error)
+}
+
+// geocoders are the geocoders returned by NewGeocoder by name, shared across the requests
+var geocoders = map[string]Geocoder{}
+var geocodersMutex sync.Mutex
+
+// NewGeocoder returns the geocoder selected by the GEOCODER env variable, the GoogleGeocoder when it is google and the
+// LocalGeocoder of the GEOCODER_LOCAL_FILE file when it is local. It returns nil when no geocoder is selected
+func NewGeocoder() (Geocoder, error) {
+	name := os.Getenv(common.EnvGeocoder)
+	if name != common.GeocoderGoogle && name != common.GeocoderLocal {
+		return nil, nil
+	}
+	if name == common.GeocoderLocal {
+		name = fmt.Sprintf("%s:%s", name, os.Getenv(common.EnvGeocoderLocalFile))
+	}
+	geocodersMutex.Lock()
+	defer geocodersMutex.Unlock()
+	if geocoder, ok := geocoders[name]; ok {
+		return geocoder, nil
+	}
+
+	var geocoder Geocoder = NewGoogleGeocoder()
+	if name != common.GeocoderGoogle {
+		localGeocoder, err := NewLocalGeocoderFromFile(os.Getenv(common.EnvGeocoderLocalFile))
+		if err != nil {
+			return nil, err
+		}
+		geocoder = localGeocoder
+	}
+	geocoders[name] = geocoder
+
+	return geocoder, nil
+}
+
+// GetLocationForRequest returns the location of a site or a spoke with the address. Without a location, it is the
+// location geocoded from the address, and with one, the location after checking that it is within
+// common.MaxAddressDistanceKm of the geocoded location. Without an address or a geocoder, it is the location, which
+// may be nil. When the address has no location or is too far from the location it responds with a bad request, and
+// when the address could not be geocoded with an internal server error, returning false
+func GetLocationForRequest(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	address *models.Address, location *models.Location) (*models.Location, bool) {
+	logger := logging.GetLoggerFromContext(ctx)
+	geocoder, err := NewGeocoder()
+	if err != nil {
+		logger.Errorf("Error occurred while creating the geocoder : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return nil, false
+	}
+	if address == nil || geocoder == nil {
+		return location, true
+	}
+
+	geocoded, err := geocoder.Geocode(ctx, *address)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		logger.Debugf("No location found for the address : %v", err)
+		respondWithBadRequest(responseWriter, request,
+			"No location found for the address. Please provide valid address details")
+
+		return nil, false
+	case err != nil:
+		logger.Errorf("Error occurred while geocoding the address : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return nil, false
+	case location == nil:
+		return &geocoded, true
+	}
+
+	distanceKm := utils.GetDistanceKm(*location.Latitude, *location.Longitude, *geocoded.Latitude,
+		*geocoded.Longitude)
+	if distanceKm > common.MaxAddressDistanceKm {
+		logger.Debugf("Location is %f km from the location %f,%f of the address", distanceKm, *geocoded.Latitude,
+			*geocoded.Longitude)
+		respondWithBadRequest(responseWriter, request,
+			fmt.Sprintf("Location with latitude %f and longitude %f is %.1f km from the address, "+
+				"it must be within %g km", *location.Latitude, *location.Longitude, distanceKm,
+				common.MaxAddressDistanceKm))
+
+		return nil, false
+	}
+
+	return location, true
+}
+
+// GetRequiredLocationForRequest returns the location of a new site or spoke with the address as GetLocationForRequest.
+// When there is no location, as the address is not geocoded without a geocoder, it responds with a bad request,
+// returning false
+func GetRequiredLocationForRequest(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	address *models.Address, location *models.Location) (*models.Location, bool) {
+	location, resolved := GetLocationForRequest(ctx, responseWriter, request, address, location)
+	if resolved && location == nil {
+		logging.GetLoggerFromContext(ctx).Debugf("Neither a location nor a geocoded address")
+		respondWithBadRequest(responseWriter, request,
+			"Location is required when no geocoder is configured for the address")
+
+		return nil, false
+	}
+
+	return location, resolved
+}
+
+func respondWithBadRequest(responseWriter http.ResponseWriter, request *http.Request, message string) {
+	response.RespondWithResponseObject(responseWriter, response.NewResponse(http.StatusBadRequest, message, nil),
+		response.GetCommonResponseHeaders(request))
+}
+
+// newLocation returns the location of the coordinates
+func newLocation(latitude float64, longitude float64) models.Location {
+	return models.Location{Latitude: &latitude, Longitude: &longitude}
+}
@@ -0,0 +1,121 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testAddress = models.Address{Lines: []string{"1 Main St"}, City: "Springfield", Region: "IL",
+	PostalCode: "62701", Country: "US"}
+
+func TestNewGeocoder(t *testing.T) {
+	t.Run("No geocoder by default", func(t *testing.T) {
+		t.Setenv(common.EnvGeocoder, "")
+		geocoder, err := NewGeocoder()
+		assert.Nil(t, err)
+		assert.Nil(t, geocoder)
+	})
+
+	t.Run("Google geocoder", func(t *testing.T) {
+		t.Setenv(common.EnvGeocoder, common.GeocoderGoogle)
+		geocoder, err := NewGeocoder()
+		assert.Nil(t, err)
+		assert.IsType(t, &GoogleGeocoder{}, geocoder)
+	})
+
+	t.Run("Local geocoder of the file", func(t *testing.T) {
+		setLocalGeocoder(t, LocalAddress{Address: testAddress, Location: newLocation(39.8, -89.6)})
+		geocoder, err := NewGeocoder()
+		assert.Nil(t, err)
+		assert.IsType(t, &LocalGeocoder{}, geocoder)
+		other, _ := NewGeocoder()
+		assert.Same(t, geocoder, other)
+	})
+
+	t.Run("Local geocoder without a file", func(t *testing.T) {
+		t.Setenv(common.EnvGeocoder, common.GeocoderLocal)
+		t.Setenv(common.EnvGeocoderLocalFile, "")
+		_, err := NewGeocoder()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetLocationForRequest(t *testing.T) {
+	setLocalGeocoder(t, LocalAddress{Address: testAddress, Location: newLocation(39.8, -89.6)})
+
+	t.Run("Location geocoded from the address", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		location, ok := GetLocationForRequest(context.Background(), w, getRequest(), &testAddress, nil)
+		assert.True(t, ok)
+		assert.Equal(t, 39.8, *location.Latitude)
+		assert.Equal(t, -89.6, *location.Longitude)
+	})
+
+	t.Run("Location near the address", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		requested := newLocation(39.801, -89.601)
+		location, ok := GetLocationForRequest(context.Background(), w, getRequest(), &testAddress, &requested)
+		assert.True(t, ok)
+		assert.Same(t, &requested, location)
+	})
+
+	t.Run("Location far from the address", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		requested := newLocation(39.9, -89.6)
+		_, ok := GetLocationForRequest(context.Background(), w, getRequest(), &testAddress, &requested)
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Location with latitude 39.900000 and longitude -89.600000 is "+
+			"11.1 km from the address, it must be within 1 km\"}", string(bytes))
+	})
+
+	t.Run("Address not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		_, ok := GetLocationForRequest(context.Background(), w, getRequest(),
+			&models.Address{Lines: []string{"1 Main St"}, City: "Springfield", Country: "US"}, nil)
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+
+	t.Run("No address", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		location, ok := GetLocationForRequest(context.Background(), w, getRequest(), nil, nil)
+		assert.True(t, ok)
+		assert.Nil(t, location)
+	})
+}
+
+func TestGetRequiredLocationForRequest(t *testing.T) {
+	t.Setenv(common.EnvGeocoder, "")
+	w := httptest.NewRecorder()
+	_, ok := GetRequiredLocationForRequest(context.Background(), w, getRequest(), &testAddress, nil)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	bytes, _ := io.ReadAll(w.Result().Body)
+	assert.Equal(t, "{\"code\":400,\"message\":\"Location is required when no geocoder is configured for the "+
+		"address\"}", string(bytes))
+}
+
+// setLocalGeocoder selects the local geocoder of a file with the addresses for the test
+func setLocalGeocoder(t *testing.T, addresses ...LocalAddress) {
+	path := filepath.Join(t.TempDir(), "addresses.json")
+	data, err := json.Marshal(addresses)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(path, data, 0o600))
+	t.Setenv(common.EnvGeocoder, common.GeocoderLocal)
+	t.Setenv(common.EnvGeocoderLocalFile, path)
+}
+
+func getRequest() *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/sites", nil)
+}
@@ -0,0 +1,109 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GoogleGeocoder geocodes the addresses with the Google Maps Geocoding API, with the API key in the
+// GOOGLE_MAPS_API_KEY env variable
+type GoogleGeocoder struct {
+	client *http.Client
+}
+
+// NewGoogleGeocoder returns a GoogleGeocoder whose calls time out after common.GeocodingAPITimeout
+func NewGoogleGeocoder() *GoogleGeocoder {
+	return &GoogleGeocoder{client: &http.Client{Timeout: common.GeocodingAPITimeout}}
+}
+
+// Geocode calls the Geocoding API up to common.GeocodingAPIAttempts times while it fails with a network error,
+// a server error or a transient status. The ZERO_RESULTS and INVALID_REQUEST statuses return ErrNotFound
+func (g *GoogleGeocoder) Geocode(ctx context.Context, address models.Address) (models.Location, error) {
+	var err error
+	for attempt := 1; attempt <= common.GeocodingAPIAttempts; attempt++ {
+		var location models.Location
+		var retry bool
+		location, retry, err = g.callGeocodingAPI(ctx, address)
+		if !retry {
+			return location, err
+		}
+		logging.GetLoggerFromContext(ctx).Warnf("Attempt %d of the geocoding API failed : %v", attempt, err)
+		if attempt < common.GeocodingAPIAttempts {
+			select {
+			case <-ctx.Done():
+				return models.Location{}, fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+			case <-time.After(common.GeocodingAPIRetryDelay * time.Duration(attempt)):
+			}
+		}
+	}
+
+	return models.Location{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
+
+// callGeocodingAPI returns the location of the address, or the error along with whether the call can be retried.
+// The country and the postal code are passed as components, which the results must match
+func (g *GoogleGeocoder) callGeocodingAPI(ctx context.Context, address models.Address) (models.Location, bool,
+	error) {
+	apiURL, err := url.Parse(common.GeocodingAPIUrl)
+	if err != nil {
+		return models.Location{}, false, err
+	}
+	components := []string{"country:" + address.Country}
+	if address.PostalCode != "" {
+		components = append(components, "postal_code:"+address.PostalCode)
+	}
+	queryParam := apiURL.Query()
+	fields := append(append([]string{}, address.Lines...), address.City)
+	if address.Region != "" {
+		fields = append(fields, address.Region)
+	}
+	queryParam.Set(common.AddressParam, strings.Join(fields, ", "))
+	queryParam.Set(common.ComponentsParam, strings.Join(components, "|"))
+	queryParam.Set(common.APIKeyParam, os.Getenv(common.GoogleMapsAPIEnv))
+	apiURL.RawQuery = queryParam.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL.String(), nil)
+	if err != nil {
+		return models.Location{}, false, err
+	}
+	resp, err := g.client.Do(request)
+	if err != nil {
+		return models.Location{}, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return models.Location{}, true, fmt.Errorf("geocoding API returned with status code : %d", resp.StatusCode)
+	}
+
+	var googleGeocoding models.GoogleGeocoding
+	if err = json.NewDecoder(resp.Body).Decode(&googleGeocoding); err != nil {
+		return models.Location{}, false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	switch googleGeocoding.Status {
+	case "OK":
+		if len(googleGeocoding.Results) == 0 {
+			return models.Location{}, false, ErrNotFound
+		}
+		location := googleGeocoding.Results[0].Geometry.Location
+
+		return newLocation(location.Latitude, location.Longitude), false, nil
+	case "ZERO_RESULTS", "INVALID_REQUEST":
+		return models.Location{}, false, fmt.Errorf("%w, geocoding API returned with status : %s", ErrNotFound,
+			googleGeocoding.Status)
+	case "UNKNOWN_ERROR", "OVER_QUERY_LIMIT":
+		return models.Location{}, true, fmt.Errorf("geocoding API returned with status : %s",
+			googleGeocoding.Status)
+	default:
+		return models.Location{}, false, fmt.Errorf("%w: geocoding API returned with status : %s", ErrUnavailable,
+			googleGeocoding.Status)
+	}
+}
@@ -0,0 +1,73 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGoogleGeocoder_Geocode(t *testing.T) {
+	address := models.Address{Lines: []string{"1600 Amphitheatre Pkwy"}, City: "Mountain View", Region: "CA",
+		PostalCode: "94043", Country: "US"}
+
+	t.Run("Geocode an address", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			MatchParam("address", "1600 Amphitheatre Pkwy, Mountain View, CA").
+			MatchParam("components", "country:US\\|postal_code:94043").
+			Reply(200).
+			JSON(models.GoogleGeocoding{Status: "OK", Results: []models.GoogleGeocodingResult{
+				{Geometry: models.GoogleGeocodingGeometry{
+					Location: models.GoogleGeocodingLocation{Latitude: 37.4224, Longitude: -122.0842}}},
+			}})
+		location, err := NewGoogleGeocoder().Geocode(context.Background(), address)
+		assert.Nil(t, err)
+		assert.Equal(t, 37.4224, *location.Latitude)
+		assert.Equal(t, -122.0842, *location.Longitude)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("Address not found", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			Reply(200).
+			JSON(models.GoogleGeocoding{Status: "ZERO_RESULTS"})
+		_, err := NewGoogleGeocoder().Geocode(context.Background(), address)
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.Equal(t, "address not found, geocoding API returned with status : ZERO_RESULTS", err.Error())
+	})
+
+	t.Run("Retry after a server error", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			Reply(503)
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			Reply(200).
+			JSON(models.GoogleGeocoding{Status: "OVER_QUERY_LIMIT"})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			Reply(200).
+			JSON(models.GoogleGeocoding{Status: "OK", Results: []models.GoogleGeocodingResult{{}}})
+		_, err := NewGoogleGeocoder().Geocode(context.Background(), address)
+		assert.Nil(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("Request denied is not retried", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/geocode/json").
+			Reply(200).
+			JSON(models.GoogleGeocoding{Status: "REQUEST_DENIED"})
+		_, err := NewGoogleGeocoder().Geocode(context.Background(), address)
+		assert.True(t, errors.Is(err, ErrUnavailable))
+		assert.True(t, gock.IsDone())
+	})
+}
@@ -0,0 +1,81 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"os"
+	"strings"
+)
+
+// LocalAddress is an address and its location geocoded by a LocalGeocoder
+type LocalAddress struct {
+	Address  models.Address  `json:"address"`
+	Location models.Location `json:"location"`
+}
+
+// LocalGeocoder geocodes the addresses it is created with, a stand-in for the tests and the local runs.
+// The addresses match regardless of case and spacing, and an address which is not known has the location of
+// a known address with the same postal code and country, if any
+type LocalGeocoder struct {
+	locations map[string]models.Location
+}
+
+// NewLocalGeocoder returns a LocalGeocoder of the addresses
+func NewLocalGeocoder(addresses []LocalAddress) *LocalGeocoder {
+	locations := map[string]models.Location{}
+	for _, localAddress := range addresses {
+		locations[getAddressKey(localAddress.Address)] = localAddress.Location
+		postalCodeKey := getPostalCodeKey(localAddress.Address)
+		if _, ok := locations[postalCodeKey]; !ok && localAddress.Address.PostalCode != "" {
+			locations[postalCodeKey] = localAddress.Location
+		}
+	}
+
+	return &LocalGeocoder{locations: locations}
+}
+
+// NewLocalGeocoderFromFile returns a LocalGeocoder of the addresses of a JSON file with a list of LocalAddress
+func NewLocalGeocoderFromFile(path string) (*LocalGeocoder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []LocalAddress
+	if err = json.Unmarshal(data, &addresses); err != nil {
+		return nil, fmt.Errorf("invalid local geocoder file %s : %w", path, err)
+	}
+
+	return NewLocalGeocoder(addresses), nil
+}
+
+// Geocode returns the location of the address, else the location of its postal code, else ErrNotFound
+func (l *LocalGeocoder) Geocode(_ context.Context, address models.Address) (models.Location, error) {
+	if location, ok := l.locations[getAddressKey(address)]; ok {
+		return location, nil
+	}
+	if address.PostalCode != "" {
+		if location, ok := l.locations[getPostalCodeKey(address)]; ok {
+			return location, nil
+		}
+	}
+
+	return models.Location{}, ErrNotFound
+}
+
+// getAddressKey returns the fields of the address in lower case with the spaces collapsed
+func getAddressKey(address models.Address) string {
+	fields := append(append([]string{}, address.Lines...), address.City, address.Region, address.PostalCode,
+		address.Country)
+	for index, field := range fields {
+		fields[index] = strings.ToLower(strings.Join(strings.Fields(field), " "))
+	}
+
+	return strings.Join(fields, "|")
+}
+
+// getPostalCodeKey returns the key of the postal code and the country of the address
+func getPostalCodeKey(address models.Address) string {
+	return getAddressKey(models.Address{PostalCode: address.PostalCode, Country: address.Country})
+}
@@ -0,0 +1,54 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalGeocoder_Geocode(t *testing.T) {
+	geocoder := NewLocalGeocoder([]LocalAddress{
+		{Address: models.Address{Lines: []string{"1 Main St"}, City: "Springfield", Region: "IL",
+			PostalCode: "62701", Country: "US"}, Location: newLocation(39.8, -89.6)},
+		{Address: models.Address{Lines: []string{"Unter den Linden 1"}, City: "Berlin", Country: "DE"},
+			Location: newLocation(52.5, 13.4)},
+	})
+
+	t.Run("Address matched regardless of case and spacing", func(t *testing.T) {
+		location, err := geocoder.Geocode(context.Background(), models.Address{Lines: []string{" 1  MAIN st"},
+			City: "springfield", Region: "IL", PostalCode: "62701", Country: "US"})
+		assert.Nil(t, err)
+		assert.Equal(t, 39.8, *location.Latitude)
+	})
+
+	t.Run("Location of the postal code", func(t *testing.T) {
+		location, err := geocoder.Geocode(context.Background(), models.Address{Lines: []string{"9 Elm St"},
+			City: "Springfield", Region: "IL", PostalCode: "62701", Country: "US"})
+		assert.Nil(t, err)
+		assert.Equal(t, -89.6, *location.Longitude)
+	})
+
+	t.Run("Address not found", func(t *testing.T) {
+		_, err := geocoder.Geocode(context.Background(), models.Address{Lines: []string{"2 Unter den Linden"},
+			City: "Berlin", Country: "DE"})
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+}
+
+func TestNewLocalGeocoderFromFile(t *testing.T) {
+	t.Run("Invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "addresses.json")
+		assert.Nil(t, os.WriteFile(path, []byte("{}"), 0o600))
+		_, err := NewLocalGeocoderFromFile(path)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := NewLocalGeocoderFromFile(filepath.Join(t.TempDir(), "missing.json"))
+		assert.NotNil(t, err)
+	})
+}
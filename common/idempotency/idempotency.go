@@ -0,0 +1,293 @@
+package idempotency
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Record is the response to the first request with an Idempotency-Key on a retailer, stored in
+// common.IdempotencyKeysCollection until its ExpireTime. It is not Completed while the first request is in progress
+type Record struct {
+	ID             string              `json:"id" firestore:"id"`
+	RetailerID     string              `json:"retailer_id" firestore:"retailer_id"`
+	IdempotencyKey string              `json:"idempotency_key" firestore:"idempotency_key"`
+	Fingerprint    string              `json:"fingerprint" firestore:"fingerprint"`
+	Completed      bool                `json:"completed" firestore:"completed"`
+	StatusCode     int                 `json:"status_code" firestore:"status_code"`
+	Headers        map[string][]string `json:"headers" firestore:"headers"`
+	Body           string              `json:"body" firestore:"body"`
+	CreatedTime    time.Time           `json:"created_time" firestore:"created_time"`
+	ExpireTime     time.Time           `json:"expire_time" firestore:"expire_time"`
+	Revision       int64               `json:"revision" firestore:"revision"`
+}
+
+// responseRecorder writes the response to the response writer and records its status code and body
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	r.body.Write(data)
+
+	return r.ResponseWriter.Write(data)
+}
+
+// HandleRequest calls the handler once for the requests with the same Idempotency-Key header on the retailer.
+// The response to the first request is stored and replayed to the next requests with the key until it expires.
+// A request with the key of another request, with another method, path or body, is rejected with a 422 and a request
+// with the key of a request in progress with a 409. A response with a 5xx status is not stored, the request can be
+// retried. The requests without an Idempotency-Key header are handled as is
+func HandleRequest(responseWriter http.ResponseWriter, request *http.Request, dbClient cloud.DB, retailerID string,
+	handler http.HandlerFunc) {
+	idempotencyKey := request.Header.Get(common.HeaderIdempotencyKey)
+	if idempotencyKey == "" {
+		handler(responseWriter, request)
+
+		return
+	}
+	ctx, span := trace.StartSpan(request.Context(), utils.GetSpanName("idempotency.HandleRequest"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	if len(idempotencyKey) > common.MaxIdempotencyKeyLength {
+		response.RespondWithResponseObject(responseWriter, response.NewResponse(http.StatusBadRequest,
+			"Request validation failed", []string{fmt.Sprintf("%s must have at most %d characters",
+				common.HeaderIdempotencyKey, common.MaxIdempotencyKeyLength)}),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+	retention, err := getRetention()
+	if err != nil {
+		logger.Errorf("Error occurred while reading the idempotency key retention : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		logger.Errorf("Error occurred while reading the request body : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	currentTime := time.Now().UTC()
+	record := Record{
+		ID:             getRecordID(retailerID, idempotencyKey),
+		RetailerID:     retailerID,
+		IdempotencyKey: idempotencyKey,
+		Fingerprint:    getFingerprint(request, body),
+		CreatedTime:    currentTime,
+		ExpireTime:     currentTime.Add(retention),
+	}
+	storedRecord, err := reserve(ctx, dbClient, &record)
+	if err != nil {
+		logger.Errorf("Error occurred while reserving the idempotency key %s : %v", idempotencyKey, err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	if storedRecord != nil {
+		respondWithStoredRecord(responseWriter, request, record, storedRecord)
+
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: responseWriter}
+	handler(recorder, request)
+	err = complete(ctx, dbClient, record, recorder)
+	if status.Code(err) == codes.FailedPrecondition || status.Code(err) == codes.NotFound {
+		logger.Warnf("The record of the idempotency key %s was replaced by a newer request, the response is not "+
+			"stored : %v", idempotencyKey, err)
+	} else if err != nil {
+		logger.Errorf("Error occurred while storing the response of the idempotency key %s : %v", idempotencyKey, err)
+	}
+}
+
+// getRetention returns the IDEMPOTENCY_KEY_RETENTION duration, common.DefaultIdempotencyKeyRetention when it is not set
+func getRetention() (time.Duration, error) {
+	retention := os.Getenv(common.EnvIdempotencyKeyRetention)
+	if retention == "" {
+		return common.DefaultIdempotencyKeyRetention, nil
+	}
+
+	return time.ParseDuration(retention)
+}
+
+// getRecordID returns the ID of the record of the key on the retailer, a hash as the key may have any character
+func getRecordID(retailerID string, idempotencyKey string) string {
+	hash := sha256.Sum256([]byte(retailerID + "\n" + idempotencyKey))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// getFingerprint returns the hash of the method, the URI and the body of the request
+func getFingerprint(request *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(request.Method + "\n" + request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// reserve creates the record of the request, unless a record of its key has not expired which is returned.
+// An expired record is deleted only if it still has the revision read, so a record replaced by a concurrent request
+// with the key is returned instead of being deleted. The revision of the created record is set on the record
+func reserve(ctx context.Context, dbClient cloud.DB, record *Record) (*Record, error) {
+	storedRecord, err := getRecord(ctx, dbClient, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	record.Revision = 1
+	if storedRecord != nil {
+		if !isExpired(storedRecord, record.CreatedTime) {
+			return storedRecord, nil
+		}
+		record.Revision = storedRecord.Revision + 1
+		_, err = dbClient.DeleteInTransaction(ctx, common.IdempotencyKeysCollection, record.ID, cloud.WriteOptions{
+			Updates: []cloud.DocumentUpdate{{
+				CollectionPath: common.IdempotencyKeysCollection,
+				DocumentID:     record.ID,
+				Revision:       &storedRecord.Revision,
+			}},
+		})
+		if err != nil && status.Code(err) != codes.NotFound {
+			return getConcurrentRecord(ctx, dbClient, *record, err)
+		}
+	}
+
+	_, err = dbClient.Save(ctx, common.IdempotencyKeysCollection, record.ID, record)
+
+	return getConcurrentRecord(ctx, dbClient, *record, err)
+}
+
+// getConcurrentRecord returns the record of a concurrent request with the key when the error is that it created or
+// replaced its record first, the error otherwise
+func getConcurrentRecord(ctx context.Context, dbClient cloud.DB, record Record, err error) (*Record, error) {
+	if status.Code(err) != codes.AlreadyExists && status.Code(err) != codes.FailedPrecondition {
+		return nil, err
+	}
+	storedRecord, err := getRecord(ctx, dbClient, record.ID)
+	if err == nil && storedRecord == nil {
+		// the record of the concurrent request is already deleted, as if its request is in progress
+		storedRecord = &Record{Fingerprint: record.Fingerprint}
+	}
+
+	return storedRecord, err
+}
+
+// getRecord returns the record of the ID, nil when there is none
+func getRecord(ctx context.Context, dbClient cloud.DB, recordID string) (*Record, error) {
+	document, err := dbClient.GetByID(ctx, common.IdempotencyKeysCollection, recordID, false)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err = utils.ConvertToObject(document, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// isExpired returns whether the record expired at the time, or did not complete within
+// common.IdempotencyKeyLockTimeout as its request did not complete
+func isExpired(record *Record, currentTime time.Time) bool {
+	if !record.Completed {
+		return currentTime.After(record.CreatedTime.Add(common.IdempotencyKeyLockTimeout))
+	}
+
+	return currentTime.After(record.ExpireTime)
+}
+
+// respondWithStoredRecord replays the response of the stored record of the key of the request, or rejects the request
+// when the record is of another request or its request is in progress
+func respondWithStoredRecord(responseWriter http.ResponseWriter, request *http.Request, record Record,
+	storedRecord *Record) {
+	if storedRecord.Fingerprint != record.Fingerprint {
+		response.RespondWithResponseObject(responseWriter, response.NewResponse(http.StatusUnprocessableEntity,
+			fmt.Sprintf("%s %s was already used with another request", common.HeaderIdempotencyKey,
+				record.IdempotencyKey), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+	if !storedRecord.Completed {
+		response.RespondWithResponseObject(responseWriter, response.NewResponse(http.StatusConflict,
+			fmt.Sprintf("A request with the %s %s is in progress", common.HeaderIdempotencyKey,
+				record.IdempotencyKey), nil),
+			response.GetCommonResponseHeaders(request))
+
+		return
+	}
+
+	for name, values := range storedRecord.Headers {
+		for _, value := range values {
+			responseWriter.Header().Add(name, value)
+		}
+	}
+	if request.Header.Get(common.HeaderXCorrelationID) != "" {
+		responseWriter.Header().Set(common.HeaderXCorrelationID, request.Header.Get(common.HeaderXCorrelationID))
+	}
+	responseWriter.Header().Set(common.HeaderIdempotentReplayed, strconv.FormatBool(true))
+	responseWriter.WriteHeader(storedRecord.StatusCode)
+	_, _ = responseWriter.Write([]byte(storedRecord.Body))
+}
+
+// complete stores the recorded response in the record, or deletes the record when the response is a 5xx
+// so the request can be retried. The record is written only if it still has the revision reserved, a record replaced
+// by a newer request with the key after the lock timeout is left as is and a FailedPrecondition error is returned
+func complete(ctx context.Context, dbClient cloud.DB, record Record, recorder *responseRecorder) error {
+	statusCode := recorder.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	options := cloud.WriteOptions{Revision: &record.Revision}
+	if statusCode >= http.StatusInternalServerError {
+		_, err := dbClient.DeleteInTransaction(ctx, common.IdempotencyKeysCollection, record.ID, options)
+
+		return err
+	}
+
+	headers := recorder.Header().Clone()
+	headers.Del(common.HeaderXCorrelationID)
+	_, err := dbClient.UpdateInTransaction(ctx, common.IdempotencyKeysCollection, record.ID, []firestore.Update{
+		{Path: common.Completed, Value: true},
+		{Path: common.StatusCode, Value: statusCode},
+		{Path: common.Headers, Value: map[string][]string(headers)},
+		{Path: common.Body, Value: recorder.body.String()},
+	}, options)
+
+	return err
+}
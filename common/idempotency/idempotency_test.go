@@ -0,0 +1,198 @@
+package idempotency
+
+import (
+	"context"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getIdempotentRequest(body string, idempotencyKey string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/sites", strings.NewReader(body))
+	request.Header.Set(common.HeaderXCorrelationID, "correlation-1")
+	if idempotencyKey != "" {
+		request.Header.Set(common.HeaderIdempotencyKey, idempotencyKey)
+	}
+
+	return request
+}
+
+// getCountingHandler returns a handler creating a site with the status code and the number of its calls
+func getCountingHandler(statusCode int) (http.HandlerFunc, *int) {
+	calls := 0
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set(common.HeaderLocation, "/sites/s"+strings.Repeat("1", calls))
+		w.Header().Set(common.HeaderXCorrelationID, r.Header.Get(common.HeaderXCorrelationID))
+		w.WriteHeader(statusCode)
+		_, _ = w.Write(body)
+	}, &calls
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("Request without an Idempotency-Key", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		handler, calls := getCountingHandler(http.StatusCreated)
+		for i := 0; i < 2; i++ {
+			HandleRequest(httptest.NewRecorder(), getIdempotentRequest("{}", ""), dbClient, "r1", handler)
+		}
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Retried request", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		handler, calls := getCountingHandler(http.StatusCreated)
+		w := httptest.NewRecorder()
+		HandleRequest(w, getIdempotentRequest("{\"name\":\"site\"}", "key-1"), dbClient, "r1", handler)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		assert.Empty(t, w.Result().Header.Get(common.HeaderIdempotentReplayed))
+
+		retry := getIdempotentRequest("{\"name\":\"site\"}", "key-1")
+		retry.Header.Set(common.HeaderXCorrelationID, "correlation-2")
+		w = httptest.NewRecorder()
+		HandleRequest(w, retry, dbClient, "r1", handler)
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+		assert.Equal(t, "/sites/s1", w.Result().Header.Get(common.HeaderLocation))
+		assert.Equal(t, "correlation-2", w.Result().Header.Get(common.HeaderXCorrelationID))
+		assert.Equal(t, "true", w.Result().Header.Get(common.HeaderIdempotentReplayed))
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"name\":\"site\"}", string(bytes))
+
+		HandleRequest(httptest.NewRecorder(), getIdempotentRequest("{\"name\":\"site\"}", "key-1"), dbClient, "r2",
+			handler)
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Request with the key of another request", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		handler, calls := getCountingHandler(http.StatusCreated)
+		HandleRequest(httptest.NewRecorder(), getIdempotentRequest("{\"name\":\"site\"}", "key-1"), dbClient, "r1",
+			handler)
+		w := httptest.NewRecorder()
+		HandleRequest(w, getIdempotentRequest("{\"name\":\"other\"}", "key-1"), dbClient, "r1", handler)
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":422,\"message\":\"Idempotency-Key key-1 was already used with another request\"}",
+			string(bytes))
+	})
+
+	t.Run("Request with the key of a request in progress", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		handler, calls := getCountingHandler(http.StatusCreated)
+		request := getIdempotentRequest("{}", "key-1")
+		_, _ = dbClient.Save(context.Background(), common.IdempotencyKeysCollection, getRecordID("r1", "key-1"),
+			Record{ID: getRecordID("r1", "key-1"), Fingerprint: getFingerprint(request, []byte("{}")),
+				CreatedTime: time.Now().UTC()})
+		w := httptest.NewRecorder()
+		HandleRequest(w, request, dbClient, "r1", handler)
+		assert.Equal(t, 0, *calls)
+		assert.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	})
+
+	t.Run("Request with the key of a request that did not complete", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		handler, calls := getCountingHandler(http.StatusCreated)
+		request := getIdempotentRequest("{}", "key-1")
+		_, _ = dbClient.Save(context.Background(), common.IdempotencyKeysCollection, getRecordID("r1", "key-1"),
+			Record{ID: getRecordID("r1", "key-1"), Fingerprint: getFingerprint(request, []byte("{}")),
+				CreatedTime: time.Now().UTC().Add(-2 * common.IdempotencyKeyLockTimeout)})
+		w := httptest.NewRecorder()
+		HandleRequest(w, request, dbClient, "r1", handler)
+		assert.Equal(t, 1, *calls)
+		assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
+	})
+
+	t.Run("Request with the key of a request that did not complete replaced concurrently", func(t *testing.T) {
+		dbClient := mocks.NewDB(t)
+		handler, calls := getCountingHandler(http.StatusCreated)
+		request := getIdempotentRequest("{}", "key-1")
+		recordID := getRecordID("r1", "key-1")
+		fingerprint := getFingerprint(request, []byte("{}"))
+		dbClient.On("GetByID", mock.Anything, common.IdempotencyKeysCollection, recordID, false).
+			Return(map[string]interface{}{common.ID: recordID, "fingerprint": fingerprint, common.Revision: int64(1),
+				"created_time": time.Now().UTC().Add(-2 * common.IdempotencyKeyLockTimeout)}, nil).Once()
+		dbClient.On("DeleteInTransaction", mock.Anything, common.IdempotencyKeysCollection, recordID,
+			mock.MatchedBy(func(options cloud.WriteOptions) bool {
+				return len(options.Updates) == 1 && *options.Updates[0].Revision == 1
+			})).Return(false, status.Error(codes.FailedPrecondition, "revision mismatch"))
+		dbClient.On("GetByID", mock.Anything, common.IdempotencyKeysCollection, recordID, false).
+			Return(map[string]interface{}{common.ID: recordID, "fingerprint": fingerprint, common.Revision: int64(2),
+				"created_time": time.Now().UTC()}, nil).Once()
+		w := httptest.NewRecorder()
+		HandleRequest(w, request, dbClient, "r1", handler)
+		assert.Equal(t, 0, *calls)
+		assert.Equal(t, http.StatusConflict, w.Result().StatusCode)
+	})
+
+	t.Run("Request completing after its record was replaced by a newer request", func(t *testing.T) {
+		for _, statusCode := range []int{http.StatusCreated, http.StatusInternalServerError} {
+			dbClient := cloud.NewMemoryRepository()
+			recordID := getRecordID("r1", "key-1")
+			newerRecord := Record{ID: recordID, Fingerprint: "newer", CreatedTime: time.Now().UTC(), Revision: 2}
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				// the lock timed out and a newer request with the key replaced the record before the response
+				_, _ = dbClient.Delete(context.Background(), common.IdempotencyKeysCollection, recordID)
+				_, _ = dbClient.Save(context.Background(), common.IdempotencyKeysCollection, recordID, newerRecord)
+				w.WriteHeader(statusCode)
+			}
+			w := httptest.NewRecorder()
+			HandleRequest(w, getIdempotentRequest("{}", "key-1"), dbClient, "r1", handler)
+			assert.Equal(t, statusCode, w.Result().StatusCode)
+
+			storedRecord, err := getRecord(context.Background(), dbClient, recordID)
+			assert.Nil(t, err)
+			assert.Equal(t, &newerRecord, storedRecord)
+		}
+	})
+
+	t.Run("Retried request after an internal server error", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		handler, calls := getCountingHandler(http.StatusInternalServerError)
+		for i := 0; i < 2; i++ {
+			HandleRequest(httptest.NewRecorder(), getIdempotentRequest("{}", "key-1"), dbClient, "r1", handler)
+		}
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Retried request after the retention", func(t *testing.T) {
+		t.Setenv(common.EnvIdempotencyKeyRetention, "1ns")
+		dbClient := cloud.NewMemoryRepository()
+		handler, calls := getCountingHandler(http.StatusCreated)
+		for i := 0; i < 2; i++ {
+			HandleRequest(httptest.NewRecorder(), getIdempotentRequest("{}", "key-1"), dbClient, "r1", handler)
+		}
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Invalid retention", func(t *testing.T) {
+		t.Setenv(common.EnvIdempotencyKeyRetention, "a day")
+		handler, calls := getCountingHandler(http.StatusCreated)
+		w := httptest.NewRecorder()
+		HandleRequest(w, getIdempotentRequest("{}", "key-1"), cloud.NewMemoryRepository(), "r1", handler)
+		assert.Equal(t, 0, *calls)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+
+	t.Run("Idempotency-Key too long", func(t *testing.T) {
+		handler, calls := getCountingHandler(http.StatusCreated)
+		w := httptest.NewRecorder()
+		HandleRequest(w, getIdempotentRequest("{}", strings.Repeat("k", common.MaxIdempotencyKeyLength+1)),
+			cloud.NewMemoryRepository(), "r1", handler)
+		assert.Equal(t, 0, *calls)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	})
+}
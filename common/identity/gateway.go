@@ -0,0 +1,33 @@
+package identity
+
+import (
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"net/http"
+	"strings"
+)
+
+// GatewayAuthenticator trusts the principal in a header set by the gateway in front of the functions, which must only
+// be reachable through the gateway so that the header can't be forged
+type GatewayAuthenticator struct {
+	header string
+}
+
+// NewGatewayAuthenticator returns a GatewayAuthenticator of the header, common.DefaultIdentityGatewayHeader when empty
+func NewGatewayAuthenticator(header string) *GatewayAuthenticator {
+	if header == "" {
+		header = common.DefaultIdentityGatewayHeader
+	}
+
+	return &GatewayAuthenticator{header: header}
+}
+
+// Authenticate returns the principal in the header of the request
+func (g *GatewayAuthenticator) Authenticate(request *http.Request) (string, error) {
+	principal := strings.TrimSpace(request.Header.Get(g.header))
+	if principal == "" {
+		return "", fmt.Errorf("%w : no %s header", ErrUnauthenticated, g.header)
+	}
+
+	return principal, nil
+}
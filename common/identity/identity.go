@@ -0,0 +1,135 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ErrUnauthenticated is returned when the request has no valid identity, e.g. a missing or expired bearer JWT
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrUnavailable is returned when the identity of the request could not be verified, e.g. the JWKS URL is down
+var ErrUnavailable = errors.New("identity verification unavailable")
+
+// Authenticator returns the principal of the caller of a request, e.g. its email
+type Authenticator interface {
+	Authenticate(request *http.Request) (string, error)
+}
+
+type CtxPrincipal struct{}
+
+// authenticators are the authenticators returned by NewAuthenticator by mode, shared so that the keys of the JWKS
+// are kept across the requests
+var authenticators = map[string]Authenticator{}
+var authenticatorsMutex sync.Mutex
+
+// NewAuthenticator returns the authenticator selected by the IDENTITY_MODE env variable, the JWTAuthenticator of the
+// IDENTITY_JWKS_FILE file or else the IDENTITY_JWKS_URL URL when it is jwt and the GatewayAuthenticator of the
+// IDENTITY_GATEWAY_HEADER header when it is gateway. It returns nil when no authenticator is selected
+func NewAuthenticator() (Authenticator, error) {
+	mode := os.Getenv(common.EnvIdentityMode)
+	if mode != common.IdentityModeJWT && mode != common.IdentityModeGateway {
+		return nil, nil
+	}
+	name := fmt.Sprintf("%s:%s", mode, os.Getenv(common.EnvIdentityGatewayHeader))
+	if mode == common.IdentityModeJWT {
+		name = fmt.Sprintf("%s:%s:%s:%s:%s:%s", mode, os.Getenv(common.EnvIdentityJWKSFile),
+			os.Getenv(common.EnvIdentityJWKSURL), os.Getenv(common.EnvIdentityIssuer),
+			os.Getenv(common.EnvIdentityAudience), os.Getenv(common.EnvIdentityPrincipalClaim))
+	}
+	authenticatorsMutex.Lock()
+	defer authenticatorsMutex.Unlock()
+	if authenticator, ok := authenticators[name]; ok {
+		return authenticator, nil
+	}
+
+	var authenticator Authenticator = NewGatewayAuthenticator(os.Getenv(common.EnvIdentityGatewayHeader))
+	if mode == common.IdentityModeJWT {
+		keySet, err := newKeySetFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		authenticator = NewJWTAuthenticator(keySet, os.Getenv(common.EnvIdentityIssuer),
+			os.Getenv(common.EnvIdentityAudience), os.Getenv(common.EnvIdentityPrincipalClaim))
+	}
+	authenticators[name] = authenticator
+
+	return authenticator, nil
+}
+
+// newKeySetFromEnv returns the key set of the IDENTITY_JWKS_FILE file, or else of the IDENTITY_JWKS_URL URL
+func newKeySetFromEnv() (*KeySet, error) {
+	if file := os.Getenv(common.EnvIdentityJWKSFile); file != "" {
+		return NewKeySetFromFile(file)
+	}
+	if url := os.Getenv(common.EnvIdentityJWKSURL); url != "" {
+		return NewRemoteKeySet(url), nil
+	}
+
+	return nil, fmt.Errorf("%s or %s is required with the %s identity mode", common.EnvIdentityJWKSFile,
+		common.EnvIdentityJWKSURL, common.IdentityModeJWT)
+}
+
+// GetPrincipalFromContext returns the principal of the caller put in the context by GetRequestWithPrincipal,
+// or common.User when the request has no identity
+func GetPrincipalFromContext(ctx context.Context) string {
+	if principal, ok := GetAuthenticatedPrincipalFromContext(ctx); ok {
+		return principal
+	}
+
+	return common.User
+}
+
+// GetAuthenticatedPrincipalFromContext returns the principal of the caller put in the context by
+// GetRequestWithPrincipal, false when the request has no identity
+func GetAuthenticatedPrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(CtxPrincipal{}).(string)
+
+	return principal, ok && principal != ""
+}
+
+// GetRequestWithPrincipal returns the request with the principal of the caller authenticated by NewAuthenticator in
+// its context. When the request has no valid identity it responds with an unauthorized, and when the identity could
+// not be verified with an internal server error, returning false
+func GetRequestWithPrincipal(responseWriter http.ResponseWriter, request *http.Request) (*http.Request, bool) {
+	logger := logging.GetLoggerFromContext(request.Context())
+	authenticator, err := NewAuthenticator()
+	if err != nil {
+		logger.Errorf("Error occurred while creating the authenticator : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return request, false
+	}
+	if authenticator == nil {
+		return request, true
+	}
+
+	principal, err := authenticator.Authenticate(request)
+	if errors.Is(err, ErrUnauthenticated) {
+		logger.Debugf("Request not authenticated : %v", err)
+		headers := response.GetCommonResponseHeaders(request)
+		if _, ok := authenticator.(*JWTAuthenticator); ok {
+			headers.WithHeader(common.HeaderWWWAuthenticate, common.AuthorizationSchemeBearer)
+		}
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusUnauthorized, "Request not authenticated", nil), headers)
+
+		return request, false
+	}
+	if err != nil {
+		logger.Errorf("Error occurred while authenticating the request : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return request, false
+	}
+	logger.Debugf("Request authenticated as %s", principal)
+
+	return request.WithContext(context.WithValue(request.Context(), CtxPrincipal{}, principal)), true
+}
@@ -0,0 +1,99 @@
+package identity
+
+import (
+	"context"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewAuthenticator(t *testing.T) {
+	t.Run("No identity mode", func(t *testing.T) {
+		authenticator, err := NewAuthenticator()
+		assert.Nil(t, err)
+		assert.Nil(t, authenticator)
+	})
+
+	t.Run("Gateway identity mode", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeGateway)
+		authenticator, err := NewAuthenticator()
+		assert.Nil(t, err)
+		assert.Equal(t, NewGatewayAuthenticator(common.DefaultIdentityGatewayHeader), authenticator)
+	})
+
+	t.Run("JWT identity mode", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeJWT)
+		t.Setenv(common.EnvIdentityJWKSFile, writeTestJWKS(t))
+		authenticator, err := NewAuthenticator()
+		assert.Nil(t, err)
+		assert.IsType(t, &JWTAuthenticator{}, authenticator)
+		sameAuthenticator, _ := NewAuthenticator()
+		assert.Same(t, authenticator, sameAuthenticator)
+	})
+
+	t.Run("JWT identity mode without a JWKS", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeJWT)
+		_, err := NewAuthenticator()
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGatewayAuthenticator(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/sites", nil)
+	_, err := NewGatewayAuthenticator("X-User").Authenticate(request)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	request.Header.Set("X-User", "jo@example.com")
+	principal, err := NewGatewayAuthenticator("X-User").Authenticate(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "jo@example.com", principal)
+}
+
+func TestGetRequestWithPrincipal(t *testing.T) {
+	t.Run("No identity mode", func(t *testing.T) {
+		request, authenticated := GetRequestWithPrincipal(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, "/sites", nil))
+		assert.True(t, authenticated)
+		assert.Equal(t, common.User, GetPrincipalFromContext(request.Context()))
+	})
+
+	t.Run("Authenticated request", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeJWT)
+		t.Setenv(common.EnvIdentityJWKSFile, writeTestJWKS(t))
+		token := signTestToken(t, "RS256", "rsa1", map[string]interface{}{"email": "jo@example.com",
+			"exp": time.Now().Add(time.Minute).Unix()})
+		request, authenticated := GetRequestWithPrincipal(httptest.NewRecorder(), getBearerRequest(token))
+		assert.True(t, authenticated)
+		assert.Equal(t, "jo@example.com", GetPrincipalFromContext(request.Context()))
+	})
+
+	t.Run("Request without a token", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeJWT)
+		t.Setenv(common.EnvIdentityJWKSFile, writeTestJWKS(t))
+		w := httptest.NewRecorder()
+		_, authenticated := GetRequestWithPrincipal(w, getBearerRequest(""))
+		assert.False(t, authenticated)
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+		assert.Equal(t, common.AuthorizationSchemeBearer, w.Result().Header.Get(common.HeaderWWWAuthenticate))
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":401,\"message\":\"Request not authenticated\"}", string(bytes))
+	})
+
+	t.Run("Invalid identity configuration", func(t *testing.T) {
+		t.Setenv(common.EnvIdentityMode, common.IdentityModeJWT)
+		t.Setenv(common.EnvIdentityJWKSFile, "missing.json")
+		w := httptest.NewRecorder()
+		_, authenticated := GetRequestWithPrincipal(w, getBearerRequest(""))
+		assert.False(t, authenticated)
+		assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+	})
+}
+
+func TestGetPrincipalFromContext(t *testing.T) {
+	assert.Equal(t, common.User, GetPrincipalFromContext(context.Background()))
+	assert.Equal(t, "jo@example.com",
+		GetPrincipalFromContext(context.WithValue(context.Background(), CtxPrincipal{}, "jo@example.com")))
+}
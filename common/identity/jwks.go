@@ -0,0 +1,200 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// JSONWebKey is a public key of a JWKS (RFC 7517), an RSA key or an EC key on the P-256, P-384 or P-521 curve
+type JSONWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// JSONWebKeySet is a JWKS document
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// KeySet holds the public keys verifying the JWTs by key id, the keys of a JWKS file or the keys of a JWKS URL which
+// are fetched again after common.JWKSRefreshInterval or when a JWT is signed by an unknown key
+type KeySet struct {
+	url         string
+	client      *http.Client
+	keys        map[string]crypto.PublicKey
+	fetchedTime time.Time
+	mutex       sync.Mutex
+}
+
+// NewKeySet returns a KeySet of the keys by key id
+func NewKeySet(keys map[string]crypto.PublicKey) *KeySet {
+	return &KeySet{keys: keys}
+}
+
+// NewKeySetFromFile returns a KeySet of the keys of a JWKS file
+func NewKeySetFromFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS file %s : %w", path, err)
+	}
+
+	return NewKeySet(keys), nil
+}
+
+// NewRemoteKeySet returns a KeySet of the keys of a JWKS URL, whose calls time out after common.JWKSAPITimeout
+func NewRemoteKeySet(url string) *KeySet {
+	return &KeySet{url: url, client: &http.Client{Timeout: common.JWKSAPITimeout}}
+}
+
+// ParseKeySet returns the signature keys of a JWKS document by key id
+func ParseKeySet(data []byte) (map[string]crypto.PublicKey, error) {
+	var keySet JSONWebKeySet
+	if err := json.Unmarshal(data, &keySet); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jsonWebKey := range keySet.Keys {
+		if jsonWebKey.Use != "" && jsonWebKey.Use != "sig" {
+			continue
+		}
+		key, err := jsonWebKey.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %s : %w", jsonWebKey.KeyID, err)
+		}
+		keys[jsonWebKey.KeyID] = key
+	}
+
+	return keys, nil
+}
+
+// PublicKey returns the *rsa.PublicKey or the *ecdsa.PublicKey of the key
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Curve]
+		if !ok {
+			return nil, fmt.Errorf("unsupported EC curve %s", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on the EC curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.KeyType)
+}
+
+// decodeBigInt returns the unsigned integer of a base64url encoded big-endian value
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid key parameter %q", value)
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
+
+// GetKey returns the key of the key id, or the only key of the set when the key id is empty. It returns
+// ErrUnauthenticated when there is no such key and ErrUnavailable when the keys of the JWKS URL could not be fetched
+func (k *KeySet) GetKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	key, found := k.findKey(keyID)
+	if k.url != "" && (k.keys == nil || time.Since(k.fetchedTime) > common.JWKSRefreshInterval ||
+		(!found && time.Since(k.fetchedTime) > common.JWKSMinRefreshInterval)) {
+		if err := k.fetch(ctx); err != nil && k.keys == nil {
+			return nil, fmt.Errorf("%w : %v", ErrUnavailable, err)
+		}
+		key, found = k.findKey(keyID)
+	}
+	if !found {
+		return nil, fmt.Errorf("%w : unknown key %q", ErrUnauthenticated, keyID)
+	}
+
+	return key, nil
+}
+
+// findKey returns the key of the key id, or the only key when the key id is empty
+func (k *KeySet) findKey(keyID string) (crypto.PublicKey, bool) {
+	if keyID == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[keyID]
+
+	return key, ok
+}
+
+// fetch replaces the keys by the keys of the JWKS URL, keeping the previous keys when it fails
+func (k *KeySet) fetch(ctx context.Context) error {
+	k.fetchedTime = time.Now()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return err
+	}
+	httpResponse, err := k.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("the JWKS URL returned status %d", httpResponse.StatusCode)
+	}
+	data, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+	keys, err := ParseKeySet(data)
+	if err != nil {
+		return err
+	}
+	k.keys = keys
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package identity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers the SHA-256 hash of the RS256, PS256 and ES256 signatures
+	_ "crypto/sha512" // registers the SHA-384 and SHA-512 hashes of the other signatures
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTAuthenticator authenticates the bearer JWT of the Authorization header, signed with RS256, RS384, RS512, PS256,
+// PS384, PS512, ES256, ES384 or ES512 by a key of its key set, and returns the principal claim of the JWT
+type JWTAuthenticator struct {
+	keySet         *KeySet
+	issuer         string
+	audience       string
+	principalClaim string
+}
+
+// jwtHeader is the JOSE header of a JWT
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jwtAlgorithm is the hash and the key type of a signature algorithm of the JWTs
+type jwtAlgorithm struct {
+	hash crypto.Hash
+	kind string
+}
+
+var jwtAlgorithms = map[string]jwtAlgorithm{
+	"RS256": {crypto.SHA256, "RS"}, "RS384": {crypto.SHA384, "RS"}, "RS512": {crypto.SHA512, "RS"},
+	"PS256": {crypto.SHA256, "PS"}, "PS384": {crypto.SHA384, "PS"}, "PS512": {crypto.SHA512, "PS"},
+	"ES256": {crypto.SHA256, "ES"}, "ES384": {crypto.SHA384, "ES"}, "ES512": {crypto.SHA512, "ES"},
+}
+
+// NewJWTAuthenticator returns a JWTAuthenticator of the key set, checking the iss and the aud of the JWTs when they
+// are not empty. The principal is the principal claim, or else the email and else the sub claim when it is empty
+func NewJWTAuthenticator(keySet *KeySet, issuer string, audience string, principalClaim string) *JWTAuthenticator {
+	return &JWTAuthenticator{keySet: keySet, issuer: issuer, audience: audience, principalClaim: principalClaim}
+}
+
+// Authenticate returns the principal of the verified bearer JWT of the request
+func (j *JWTAuthenticator) Authenticate(request *http.Request) (string, error) {
+	scheme, token, _ := strings.Cut(request.Header.Get(common.HeaderAuthorization), " ")
+	if !strings.EqualFold(scheme, common.AuthorizationSchemeBearer) || token == "" {
+		return "", fmt.Errorf("%w : no bearer token", ErrUnauthenticated)
+	}
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w : malformed token", ErrUnauthenticated)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", err
+	}
+	algorithm, ok := jwtAlgorithms[header.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("%w : unsupported algorithm %q", ErrUnauthenticated, header.Algorithm)
+	}
+	key, err := j.keySet.GetKey(request.Context(), header.KeyID)
+	if err != nil {
+		return "", err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("%w : malformed signature", ErrUnauthenticated)
+	}
+	if err = verifySignature(algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return "", err
+	}
+
+	var claims map[string]interface{}
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return "", err
+	}
+	if err = j.verifyClaims(claims, time.Now()); err != nil {
+		return "", err
+	}
+
+	return j.getPrincipal(claims)
+}
+
+// decodeSegment decodes the base64url encoded JSON segment of a JWT into the value
+func decodeSegment(segment string, value interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err == nil {
+		err = json.Unmarshal(data, value)
+	}
+	if err != nil {
+		return fmt.Errorf("%w : malformed token segment : %v", ErrUnauthenticated, err)
+	}
+
+	return nil
+}
+
+// verifySignature checks the signature of the signing input with the key of the type of the algorithm
+func verifySignature(algorithm jwtAlgorithm, key crypto.PublicKey, signingInput string, signature []byte) error {
+	hash := algorithm.hash.New()
+	hash.Write([]byte(signingInput))
+	digest := hash.Sum(nil)
+	var err error
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		switch algorithm.kind {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(publicKey, algorithm.hash, digest, signature)
+		case "PS":
+			err = rsa.VerifyPSS(publicKey, algorithm.hash, digest, signature, nil)
+		default:
+			err = errors.New("algorithm does not match the RSA key")
+		}
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		switch {
+		case algorithm.kind != "ES":
+			err = errors.New("algorithm does not match the EC key")
+		case len(signature) != 2*size:
+			err = errors.New("invalid EC signature length")
+		case !ecdsa.Verify(publicKey, digest, new(big.Int).SetBytes(signature[:size]),
+			new(big.Int).SetBytes(signature[size:])):
+			err = errors.New("invalid EC signature")
+		}
+	default:
+		err = errors.New("unsupported key")
+	}
+	if err != nil {
+		return fmt.Errorf("%w : %v", ErrUnauthenticated, err)
+	}
+
+	return nil
+}
+
+// verifyClaims checks that the JWT has not expired and is valid at the time, allowing common.JWTClockSkew,
+// and has the issuer and the audience
+func (j *JWTAuthenticator) verifyClaims(claims map[string]interface{}, now time.Time) error {
+	expiration, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w : no exp claim", ErrUnauthenticated)
+	}
+	if now.Add(-common.JWTClockSkew).After(time.Unix(int64(expiration), 0)) {
+		return fmt.Errorf("%w : token expired", ErrUnauthenticated)
+	}
+	notBefore, ok := claims["nbf"].(float64)
+	if ok && now.Add(common.JWTClockSkew).Before(time.Unix(int64(notBefore), 0)) {
+		return fmt.Errorf("%w : token not valid yet", ErrUnauthenticated)
+	}
+	if issuer, _ := claims["iss"].(string); j.issuer != "" && issuer != j.issuer {
+		return fmt.Errorf("%w : unexpected issuer %q", ErrUnauthenticated, issuer)
+	}
+	if j.audience != "" && !hasAudience(claims["aud"], j.audience) {
+		return fmt.Errorf("%w : unexpected audience %v", ErrUnauthenticated, claims["aud"])
+	}
+
+	return nil
+}
+
+// hasAudience returns whether the aud claim, a string or a list of strings, has the audience
+func hasAudience(claim interface{}, audience string) bool {
+	switch value := claim.(type) {
+	case string:
+		return value == audience
+	case []interface{}:
+		for _, item := range value {
+			if item == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getPrincipal returns the principal claim of the JWT, or else its email and else its sub
+func (j *JWTAuthenticator) getPrincipal(claims map[string]interface{}) (string, error) {
+	names := []string{common.ClaimEmail, common.ClaimSubject}
+	if j.principalClaim != "" {
+		names = []string{j.principalClaim}
+	}
+	for _, name := range names {
+		if principal, _ := claims[name].(string); principal != "" {
+			return principal, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w : no %s claim", ErrUnauthenticated, strings.Join(names, " or "))
+}
@@ -0,0 +1,178 @@
+package identity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var rsaTestKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+var ecTestKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// signTestToken returns a JWT of the claims signed with RS256 by rsaTestKey, or with ES256 by ecTestKey
+func signTestToken(t *testing.T, algorithm string, keyID string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]interface{}{"alg": algorithm, "kid": keyID, "typ": "JWT"})
+	assert.Nil(t, err)
+	payload, err := json.Marshal(claims)
+	assert.Nil(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	var signature []byte
+	if algorithm == "ES256" {
+		r, s, err := ecdsa.Sign(rand.Reader, ecTestKey, digest[:])
+		assert.Nil(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, rsaTestKey, crypto.SHA256, digest[:])
+		assert.Nil(t, err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// writeTestJWKS writes the JWKS of rsaTestKey as rsa1 and ecTestKey as ec1 and returns its path
+func writeTestJWKS(t *testing.T) string {
+	encode := func(value *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(value.Bytes())
+	}
+	data, err := json.Marshal(JSONWebKeySet{Keys: []JSONWebKey{
+		{KeyType: "RSA", KeyID: "rsa1", Use: "sig", N: encode(rsaTestKey.N), E: encode(big.NewInt(int64(rsaTestKey.E)))},
+		{KeyType: "EC", KeyID: "ec1", Curve: "P-256", X: encode(ecTestKey.X), Y: encode(ecTestKey.Y)},
+		{KeyType: "oct", KeyID: "enc1", Use: "enc"},
+	}})
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func getBearerRequest(token string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/sites", nil)
+	if token != "" {
+		request.Header.Set(common.HeaderAuthorization, "Bearer "+token)
+	}
+
+	return request
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	keySet, err := NewKeySetFromFile(writeTestJWKS(t))
+	assert.Nil(t, err)
+	authenticator := NewJWTAuthenticator(keySet, "https://issuer.example.com", "site-info", "")
+	now := time.Now().Unix()
+	validClaims := func(overrides map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{"iss": "https://issuer.example.com", "aud": []string{"other", "site-info"},
+			"sub": "user-1", "email": "jo@example.com", "exp": now + 300, "nbf": now - 10}
+		for name, value := range overrides {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+
+		return claims
+	}
+	tests := []struct {
+		name      string
+		token     string
+		principal string
+	}{
+		{name: "RS256 token", token: signTestToken(t, "RS256", "rsa1", validClaims(nil)), principal: "jo@example.com"},
+		{name: "ES256 token", token: signTestToken(t, "ES256", "ec1", validClaims(nil)), principal: "jo@example.com"},
+		{name: "Subject without an email", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"email": nil})), principal: "user-1"},
+		{name: "Expired within the clock skew", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"exp": now - 30})), principal: "jo@example.com"},
+		{name: "No token"},
+		{name: "Malformed token", token: "abc.def"},
+		{name: "Unknown key", token: signTestToken(t, "RS256", "rsa2", validClaims(nil))},
+		{name: "Algorithm of another key type", token: signTestToken(t, "ES256", "rsa1", validClaims(nil))},
+		{name: "Unsigned token", token: signTestToken(t, "none", "rsa1", validClaims(nil))},
+		{name: "Expired token", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"exp": now - 120}))},
+		{name: "Token without an expiry", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"exp": nil}))},
+		{name: "Token not valid yet", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"nbf": now + 120}))},
+		{name: "Other issuer", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"iss": "https://other.example.com"}))},
+		{name: "Other audience", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"aud": "other"}))},
+		{name: "No principal", token: signTestToken(t, "RS256", "rsa1",
+			validClaims(map[string]interface{}{"email": nil, "sub": nil}))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(getBearerRequest(tt.token))
+			assert.Equal(t, tt.principal, principal)
+			assert.Equal(t, tt.principal == "", errors.Is(err, ErrUnauthenticated))
+		})
+	}
+
+	t.Run("Tampered token", func(t *testing.T) {
+		token := signTestToken(t, "RS256", "rsa1", validClaims(nil))
+		payload, _ := json.Marshal(validClaims(map[string]interface{}{"email": "admin@example.com"}))
+		parts := strings.Split(token, ".")
+		_, err := authenticator.Authenticate(getBearerRequest(parts[0] + "." +
+			base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]))
+		assert.True(t, errors.Is(err, ErrUnauthenticated))
+	})
+
+	t.Run("Principal claim", func(t *testing.T) {
+		principal, err := NewJWTAuthenticator(keySet, "", "", "preferred_username").Authenticate(getBearerRequest(
+			signTestToken(t, "RS256", "rsa1", validClaims(map[string]interface{}{"preferred_username": "jo"}))))
+		assert.Nil(t, err)
+		assert.Equal(t, "jo", principal)
+	})
+}
+
+func TestKeySet(t *testing.T) {
+	t.Run("Remote key set", func(t *testing.T) {
+		data, _ := os.ReadFile(writeTestJWKS(t))
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_, _ = w.Write(data)
+		}))
+		defer server.Close()
+		keySet := NewRemoteKeySet(server.URL)
+		key, err := keySet.GetKey(getBearerRequest("").Context(), "rsa1")
+		assert.Nil(t, err)
+		assert.Equal(t, &rsaTestKey.PublicKey, key)
+		_, err = keySet.GetKey(getBearerRequest("").Context(), "rsa2")
+		assert.True(t, errors.Is(err, ErrUnauthenticated))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Unavailable remote key set", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		_, err := NewRemoteKeySet(server.URL).GetKey(getBearerRequest("").Context(), "rsa1")
+		assert.True(t, errors.Is(err, ErrUnavailable))
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		_, err := ParseKeySet([]byte(`{"keys":[{"kty":"EC","kid":"ec2","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+		assert.NotNil(t, err)
+	})
+}
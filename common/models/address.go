@@ -0,0 +1,19 @@
+package models
+
+// Address is the postal address of a site or a spoke, validated with the format of its country,
+// an ISO 3166-1 alpha-2 code such as US
+//
+//nolint:lll
+type Address struct {
+	Lines      []string `json:"lines,omitempty" firestore:"lines,omitempty" structs:"lines,omitempty"`
+	City       string   `json:"city,omitempty" firestore:"city,omitempty" structs:"city,omitempty"`
+	Region     string   `json:"region,omitempty" firestore:"region,omitempty" structs:"region,omitempty"`
+	PostalCode string   `json:"postal_code,omitempty" firestore:"postal_code,omitempty" structs:"postal_code,omitempty"`
+	Country    string   `json:"country,omitempty" firestore:"country,omitempty" structs:"country,omitempty"`
+}
+
+// IsEmpty returns whether no field of the address is set
+func (address Address) IsEmpty() bool {
+	return len(address.Lines) == 0 && address.City == "" && address.Region == "" && address.PostalCode == "" &&
+		address.Country == ""
+}
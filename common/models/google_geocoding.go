@@ -0,0 +1,19 @@
+package models
+
+type GoogleGeocoding struct {
+	Results []GoogleGeocodingResult `json:"results"`
+	Status  string                  `json:"status"`
+}
+
+type GoogleGeocodingResult struct {
+	Geometry GoogleGeocodingGeometry `json:"geometry"`
+}
+
+type GoogleGeocodingGeometry struct {
+	Location GoogleGeocodingLocation `json:"location"`
+}
+
+type GoogleGeocodingLocation struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// OperatingHours is the weekly schedule of a site or a spoke along with the dated exceptions to it,
+// on the wall clock of its timezone
+//
+//nolint:lll
+type OperatingHours struct {
+	Weekly     []DayHours       `json:"weekly,omitempty" firestore:"weekly,omitempty" structs:"weekly,omitempty"`
+	Exceptions []HoursException `json:"exceptions,omitempty" firestore:"exceptions,omitempty" structs:"exceptions,omitempty"`
+}
+
+// IsEmpty returns whether the operating hours have neither weekly hours nor exceptions
+func (operatingHours OperatingHours) IsEmpty() bool {
+	return len(operatingHours.Weekly) == 0 && len(operatingHours.Exceptions) == 0
+}
+
+// DayHours is an opening window of a day of the week with the times as HH:MM. A window closing at or before
+// its opening time closes on the next day and 24:00 closes at the end of the day, a day may have several windows
+type DayHours struct {
+	Day   string `json:"day" firestore:"day" structs:"day"`
+	Open  string `json:"open" firestore:"open" structs:"open"`
+	Close string `json:"close" firestore:"close" structs:"close"`
+}
+
+// HoursException replaces the weekly windows of a date as YYYY-MM-DD, e.g. a holiday or a temporary closure.
+// The date is either closed or open from Open to Close
+type HoursException struct {
+	Date   string `json:"date" firestore:"date" structs:"date"`
+	Closed bool   `json:"closed,omitempty" firestore:"closed,omitempty" structs:"closed,omitempty"`
+	Open   string `json:"open,omitempty" firestore:"open,omitempty" structs:"open,omitempty"`
+	Close  string `json:"close,omitempty" firestore:"close,omitempty" structs:"close,omitempty"`
+	Reason string `json:"reason,omitempty" firestore:"reason,omitempty" structs:"reason,omitempty"`
+}
+
+// OpeningWindow is a time range a site or a spoke is open, in its timezone
+type OpeningWindow struct {
+	Open  time.Time `json:"open"`
+	Close time.Time `json:"close"`
+}
+
+// OpenStatus is whether a site or a spoke is open at a time, with the window open at the time and the next window
+type OpenStatus struct {
+	At            time.Time      `json:"at"`
+	Timezone      string         `json:"timezone"`
+	Open          bool           `json:"open"`
+	CurrentWindow *OpeningWindow `json:"current_window,omitempty"`
+	NextWindow    *OpeningWindow `json:"next_window,omitempty"`
+}
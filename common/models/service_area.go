@@ -0,0 +1,91 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ServiceArea is the delivery service area of a site or a spoke, a GeoJSON polygon and/or a list of postal codes
+//
+//nolint:lll
+type ServiceArea struct {
+	Polygon     *Polygon `json:"polygon,omitempty" firestore:"polygon,omitempty" structs:"polygon,omitempty"`
+	PostalCodes []string `json:"postal_codes,omitempty" firestore:"postal_codes,omitempty" structs:"postal_codes,omitempty"`
+	// Geohashes are the geohash cells covering the bounding box of the polygon, to query the service areas of a point.
+	// They are maintained by the service, so they are rejected by the validation of the service area
+	Geohashes []string `json:"geohashes,omitempty" firestore:"geohashes,omitempty" structs:"geohashes,omitempty"`
+}
+
+// IsEmpty returns whether the service area has neither a polygon nor postal codes
+func (serviceArea ServiceArea) IsEmpty() bool {
+	return serviceArea.Polygon == nil && len(serviceArea.PostalCodes) == 0
+}
+
+// Polygon is a GeoJSON polygon, the first ring is the exterior and the others are holes.
+// Firestore does not store nested arrays, so the rings are stored as maps of points and
+// the JSON encoding is the GeoJSON coordinates, decoded from either the coordinates or the stored rings
+type Polygon struct {
+	Type  string       `json:"type" firestore:"type" structs:"type"`
+	Rings []LinearRing `json:"rings" firestore:"rings" structs:"rings"`
+}
+
+// LinearRing is a closed ring of a polygon, the first and the last points are the same
+type LinearRing struct {
+	Points []Point `json:"points" firestore:"points" structs:"points"`
+}
+
+// Point is a position of a polygon
+type Point struct {
+	Longitude float64 `json:"long" firestore:"long" structs:"long"`
+	Latitude  float64 `json:"lat" firestore:"lat" structs:"lat"`
+}
+
+// geoJSONPolygon is the JSON encoding of a Polygon, Rings is the stored encoding
+type geoJSONPolygon struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates,omitempty"`
+	Rings       []LinearRing  `json:"rings,omitempty"`
+}
+
+func (polygon Polygon) MarshalJSON() ([]byte, error) {
+	coordinates := make([][][]float64, 0, len(polygon.Rings))
+	for _, ring := range polygon.Rings {
+		positions := make([][]float64, 0, len(ring.Points))
+		for _, point := range ring.Points {
+			positions = append(positions, []float64{point.Longitude, point.Latitude})
+		}
+		coordinates = append(coordinates, positions)
+	}
+
+	return json.Marshal(geoJSONPolygon{Type: polygon.Type, Coordinates: coordinates})
+}
+
+func (polygon *Polygon) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var decoded geoJSONPolygon
+	if err := decoder.Decode(&decoded); err != nil {
+		return err
+	}
+	polygon.Type = decoded.Type
+	polygon.Rings = decoded.Rings
+	if decoded.Coordinates == nil {
+		return nil
+	}
+
+	polygon.Rings = make([]LinearRing, 0, len(decoded.Coordinates))
+	for _, positions := range decoded.Coordinates {
+		ring := LinearRing{Points: make([]Point, 0, len(positions))}
+		for _, position := range positions {
+			// a GeoJSON position may have an altitude after the longitude and the latitude
+			if len(position) < 2 || len(position) > 3 {
+				return fmt.Errorf("polygon position %v must be [long, lat]", position)
+			}
+			ring.Points = append(ring.Points, Point{Longitude: position[0], Latitude: position[1]})
+		}
+		polygon.Rings = append(polygon.Rings, ring)
+	}
+
+	return nil
+}
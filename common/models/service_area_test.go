@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPolygon_JSON(t *testing.T) {
+	t.Run("GeoJSON coordinates", func(t *testing.T) {
+		var polygon Polygon
+		err := json.Unmarshal([]byte(`{"type":"Polygon","coordinates":[[[1,2],[3,4,100],[5,6],[1,2]]]}`), &polygon)
+		assert.Nil(t, err)
+		assert.Equal(t, Polygon{Type: "Polygon", Rings: []LinearRing{{Points: []Point{
+			{Longitude: 1, Latitude: 2}, {Longitude: 3, Latitude: 4}, {Longitude: 5, Latitude: 6},
+			{Longitude: 1, Latitude: 2},
+		}}}}, polygon)
+		encoded, _ := json.Marshal(polygon)
+		assert.JSONEq(t, `{"type":"Polygon","coordinates":[[[1,2],[3,4],[5,6],[1,2]]]}`, string(encoded))
+	})
+
+	t.Run("Stored rings", func(t *testing.T) {
+		var polygon Polygon
+		err := json.Unmarshal([]byte(`{"type":"Polygon","rings":[{"points":[{"long":1,"lat":2}]}]}`), &polygon)
+		assert.Nil(t, err)
+		assert.Equal(t, []Point{{Longitude: 1, Latitude: 2}}, polygon.Rings[0].Points)
+	})
+
+	t.Run("Invalid position", func(t *testing.T) {
+		var polygon Polygon
+		assert.NotNil(t, json.Unmarshal([]byte(`{"type":"Polygon","coordinates":[[[1]]]}`), &polygon))
+		assert.NotNil(t, json.Unmarshal([]byte(`{"type":"Polygon","points":[]}`), &polygon))
+	})
+}
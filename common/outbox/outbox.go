@@ -0,0 +1,154 @@
+package outbox
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"encoding/json"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/google/uuid"
+	"go.opencensus.io/trace"
+	"time"
+)
+
+// Event is a message stored in the OutboxCollection in the same write as the entity change.
+// It is published to the topic by Dispatch right after the write and by Relay until it is delivered,
+// and then deleted so the OutboxCollection only holds the undelivered events
+type Event struct {
+	ID              string     `json:"id" firestore:"id"`
+	Topic           string     `json:"topic" firestore:"topic"`
+	Payload         string     `json:"payload" firestore:"payload"`
+	Attempts        int        `json:"attempts" firestore:"attempts"`
+	LastError       string     `json:"last_error" firestore:"last_error"`
+	CreatedTime     *time.Time `json:"created_time" firestore:"created_time"`
+	NextAttemptTime *time.Time `json:"next_attempt_time" firestore:"next_attempt_time"`
+}
+
+// Message is the data to be published to the topic
+type Message struct {
+	Topic string
+	Data  any
+}
+
+// NewEvents creates an undelivered event for each of the messages
+func NewEvents(messages ...Message) ([]Event, error) {
+	currentTime := time.Now().UTC()
+	events := make([]Event, 0, len(messages))
+	for _, message := range messages {
+		payload, err := json.Marshal(message.Data)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, Event{
+			ID:              uuid.NewString(),
+			Topic:           message.Topic,
+			Payload:         string(payload),
+			CreatedTime:     &currentTime,
+			NextAttemptTime: &currentTime,
+		})
+	}
+
+	return events, nil
+}
+
+// Documents returns the events as documents to be created in the OutboxCollection along with the entity change
+func Documents(events []Event) []cloud.Document {
+	documents := make([]cloud.Document, 0, len(events))
+	for _, event := range events {
+		documents = append(documents, cloud.Document{
+			CollectionPath: common.OutboxCollection,
+			DocumentID:     event.ID,
+			Data:           event,
+		})
+	}
+
+	return documents
+}
+
+// Dispatch will try to publish the events once right after they are committed,
+// the events which could not be published are left for Relay to retry
+func Dispatch(ctx context.Context, dbClient cloud.DB, pubsubClient cloud.Queue, events []Event) {
+	ctx, span := trace.StartSpan(ctx, utils.GetSpanName("outbox.Dispatch"))
+	defer span.End()
+	for _, event := range events {
+		if err := publish(ctx, dbClient, pubsubClient, event); err != nil {
+			logging.GetLoggerFromContext(ctx).Warnf("Event %s will be published by the relay : %v", event.ID, err)
+		}
+	}
+}
+
+// Relay will publish the events which are due for an attempt and returns the number of events delivered.
+// A failed attempt is recorded on the event and the next attempt is scheduled with an exponential backoff
+func Relay(ctx context.Context, dbClient cloud.DB, pubsubClient cloud.Queue) (int, error) {
+	ctx, span := trace.StartSpan(ctx, utils.GetSpanName("outbox.Relay"))
+	defer span.End()
+	logger := logging.GetLoggerFromContext(ctx)
+	currentTime := time.Now().UTC()
+	data, _, err := dbClient.GetAll(ctx, common.OutboxCollection,
+		cloud.Page{PageSize: common.OutboxRelayBatchSize,
+			OrderBy: []cloud.Order{{Field: common.NextAttemptTime, Sort: common.SortAscending}}},
+		[]cloud.Where{{Field: common.NextAttemptTime, Operator: common.OperatorLessThanOrEquals, Value: currentTime}})
+	if err != nil {
+		logger.Errorf("Error while fetching the undelivered events from DB : %v", err)
+
+		return 0, err
+	}
+
+	delivered := 0
+	for _, eventData := range data {
+		var event Event
+		if err = utils.ConvertToObject(eventData, &event); err != nil {
+			logger.Errorf("Error while converting the event from DB : %v", err)
+
+			continue
+		}
+		publishErr := publish(ctx, dbClient, pubsubClient, event)
+		if publishErr == nil {
+			delivered++
+
+			continue
+		}
+		logger.Errorf("Attempt %d to publish event %s failed : %v", event.Attempts+1, event.ID, publishErr)
+		nextAttemptTime := currentTime.Add(getBackoff(event.Attempts + 1))
+		_, err = dbClient.Update(ctx, common.OutboxCollection, event.ID, []firestore.Update{
+			{Path: common.Attempts, Value: event.Attempts + 1},
+			{Path: common.LastError, Value: publishErr.Error()},
+			{Path: common.NextAttemptTime, Value: &nextAttemptTime},
+		})
+		if err != nil {
+			logger.Errorf("Error while scheduling the next attempt of event %s : %v", event.ID, err)
+		}
+	}
+	logger.Debugf("%d of %d undelivered events published", delivered, len(data))
+
+	return delivered, nil
+}
+
+// publish will publish the event payload to its topic and delete the delivered event
+func publish(ctx context.Context, dbClient cloud.DB, pubsubClient cloud.Queue, event Event) error {
+	err := pubsubClient.Publish(ctx, event.Topic, json.RawMessage(event.Payload))
+	if err != nil {
+		return err
+	}
+	if _, err = dbClient.Delete(ctx, common.OutboxCollection, event.ID); err != nil {
+		// the event was published, it will only be published again by the relay
+		logging.GetLoggerFromContext(ctx).Errorf("Error while deleting the delivered event %s : %v", event.ID, err)
+	}
+
+	return nil
+}
+
+// getBackoff returns the delay before the next attempt, doubling with every failed attempt up to OutboxMaxBackoff
+func getBackoff(attempts int) time.Duration {
+	backoff := common.OutboxMinBackoff
+	for i := 1; i < attempts && backoff < common.OutboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > common.OutboxMaxBackoff {
+		return common.OutboxMaxBackoff
+	}
+
+	return backoff
+}
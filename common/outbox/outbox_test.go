@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/cloud"
+	"github.com/TakeoffTech/site-info-svc/common/utils"
+	"github.com/TakeoffTech/site-info-svc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type testMessage struct {
+	ID string `json:"id"`
+}
+
+func saveEvents(t *testing.T, dbClient cloud.DB, messages ...Message) []Event {
+	events, err := NewEvents(messages...)
+	assert.Nil(t, err)
+	_, err = dbClient.SaveInTransaction(context.Background(), "entities", "e1", map[string]interface{}{common.ID: "e1"},
+		cloud.WriteOptions{Creates: Documents(events)})
+	assert.Nil(t, err)
+
+	return events
+}
+
+func getEvent(t *testing.T, dbClient cloud.DB, eventID string) Event {
+	data, err := dbClient.GetByID(context.Background(), common.OutboxCollection, eventID, false)
+	assert.Nil(t, err)
+	var event Event
+	assert.Nil(t, utils.ConvertToObject(data, &event))
+
+	return event
+}
+
+// isDeleted returns whether the delivered event was deleted from the outbox
+func isDeleted(dbClient cloud.DB, eventID string) bool {
+	_, err := dbClient.GetByID(context.Background(), common.OutboxCollection, eventID, false)
+
+	return status.Code(err) == codes.NotFound
+}
+
+func TestNewEvents(t *testing.T) {
+	events, err := NewEvents(Message{Topic: "topic", Data: testMessage{ID: "s12345"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "topic", events[0].Topic)
+	assert.Equal(t, "{\"id\":\"s12345\"}", events[0].Payload)
+	assert.Equal(t, events[0].CreatedTime, events[0].NextAttemptTime)
+
+	_, err = NewEvents(Message{Topic: "topic", Data: make(chan int)})
+	assert.NotNil(t, err)
+}
+
+func TestDispatch(t *testing.T) {
+	t.Run("Published events are deleted", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		pubsubClient := mocks.NewQueue(t)
+		events := saveEvents(t, dbClient, Message{Topic: "topic", Data: testMessage{ID: "s12345"}})
+		pubsubClient.On("Publish", mock.Anything, "topic", json.RawMessage("{\"id\":\"s12345\"}")).Return(nil).Once()
+		Dispatch(context.Background(), dbClient, pubsubClient, events)
+		assert.True(t, isDeleted(dbClient, events[0].ID))
+	})
+
+	t.Run("Events which could not be published are left for the relay", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		pubsubClient := mocks.NewQueue(t)
+		events := saveEvents(t, dbClient, Message{Topic: "topic", Data: testMessage{ID: "s12345"}})
+		pubsubClient.On("Publish", mock.Anything, "topic", mock.Anything).Return(errors.New("unavailable")).Once()
+		Dispatch(context.Background(), dbClient, pubsubClient, events)
+		event := getEvent(t, dbClient, events[0].ID)
+		assert.Equal(t, 0, event.Attempts)
+	})
+}
+
+func TestRelay(t *testing.T) {
+	t.Run("Undelivered events are published and failures are rescheduled", func(t *testing.T) {
+		dbClient := cloud.NewMemoryRepository()
+		pubsubClient := mocks.NewQueue(t)
+		events := saveEvents(t, dbClient,
+			Message{Topic: "audit", Data: testMessage{ID: "a1"}},
+			Message{Topic: "site", Data: testMessage{ID: "s1"}})
+		pubsubClient.On("Publish", mock.Anything, "audit", mock.Anything).Return(nil).Once()
+		pubsubClient.On("Publish", mock.Anything, "site", mock.Anything).Return(errors.New("unavailable")).Once()
+		delivered, err := Relay(context.Background(), dbClient, pubsubClient)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.True(t, isDeleted(dbClient, events[0].ID))
+		failed := getEvent(t, dbClient, events[1].ID)
+		assert.Equal(t, 1, failed.Attempts)
+		assert.Equal(t, "unavailable", failed.LastError)
+		assert.True(t, failed.NextAttemptTime.After(time.Now().Add(common.OutboxMinBackoff/2)))
+
+		// the failed event is not due yet and the delivered event is not published again
+		delivered, err = Relay(context.Background(), dbClient, pubsubClient)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, delivered)
+	})
+
+	t.Run("Error while fetching the undelivered events", func(t *testing.T) {
+		dbClient := mocks.NewDB(t)
+		dbClient.On("GetAll", mock.Anything, common.OutboxCollection, mock.Anything, mock.Anything).
+			Return(nil, nil, errors.New("connection timeout"))
+		_, err := Relay(context.Background(), dbClient, mocks.NewQueue(t))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetBackoff(t *testing.T) {
+	assert.Equal(t, common.OutboxMinBackoff, getBackoff(1))
+	assert.Equal(t, common.OutboxMinBackoff*4, getBackoff(3))
+	assert.Equal(t, common.OutboxMaxBackoff, getBackoff(50))
+}
@@ -61,6 +61,24 @@ func RespondWithResponseObject(responseWriter http.ResponseWriter, response *Res
 	Respond(responseWriter, response.Code, response, responseHeaders)
 }
 
+// RespondWithPreconditionFailed will create response with precondition failed status, when the If-Match header is not
+// the ETag of the entity
+func RespondWithPreconditionFailed(responseWriter http.ResponseWriter, request *http.Request) {
+	RespondWithResponseObject(responseWriter, NewResponse(http.StatusPreconditionFailed,
+		"If-Match header value incorrect, please get the latest and try again", nil),
+		GetCommonResponseHeaders(request))
+}
+
+// RespondWithNotModified will create a 304 Not Modified response, without a body, with the given responseHeaders
+func RespondWithNotModified(responseWriter http.ResponseWriter, responseHeaders map[string]string) {
+	for headerKey, headerValue := range responseHeaders {
+		if headerKey != common.HeaderContentType {
+			responseWriter.Header().Add(headerKey, headerValue)
+		}
+	}
+	responseWriter.WriteHeader(http.StatusNotModified)
+}
+
 // RespondWithInternalServerError will create response with internal server error status and given error message
 func RespondWithInternalServerError(responseWriter http.ResponseWriter, request *http.Request) {
 	RespondWithResponseObject(responseWriter, NewResponse(http.StatusInternalServerError,
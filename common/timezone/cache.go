@@ -0,0 +1,80 @@
+package timezone
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"sync"
+)
+
+// CachedResolver caches the timezones resolved by its resolver in a least recently used cache, keyed by the
+// coordinates rounded to common.TimezoneCacheDecimals decimals. Only the resolved timezones are cached
+type CachedResolver struct {
+	resolver Resolver
+	size     int
+	mutex    sync.Mutex
+	entries  *list.List
+	elements map[string]*list.Element
+}
+
+// cacheEntry is an entry of the cache, the front of the list is the most recently used
+type cacheEntry struct {
+	key      string
+	timezone string
+}
+
+// NewCachedResolver returns a CachedResolver of the resolver keeping up to size timezones
+func NewCachedResolver(resolver Resolver, size int) *CachedResolver {
+	return &CachedResolver{
+		resolver: resolver,
+		size:     size,
+		entries:  list.New(),
+		elements: map[string]*list.Element{},
+	}
+}
+
+// GetTimezone returns the cached timezone of the location, else the timezone resolved by the resolver
+func (c *CachedResolver) GetTimezone(ctx context.Context, latitude float64, longitude float64) (string, error) {
+	key := fmt.Sprintf("%.*f,%.*f", common.TimezoneCacheDecimals, latitude, common.TimezoneCacheDecimals, longitude)
+	if timezone, ok := c.get(key); ok {
+		return timezone, nil
+	}
+
+	timezone, err := c.resolver.GetTimezone(ctx, latitude, longitude)
+	if err != nil {
+		return "", err
+	}
+	c.add(key, timezone)
+
+	return timezone, nil
+}
+
+func (c *CachedResolver) get(key string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.elements[key]
+	if !ok {
+		return "", false
+	}
+	c.entries.MoveToFront(element)
+
+	return element.Value.(cacheEntry).timezone, true
+}
+
+// add adds the timezone as the most recently used entry, removing the least recently used entry when the cache is full
+func (c *CachedResolver) add(key string, timezone string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.elements[key]; ok {
+		c.entries.MoveToFront(element)
+
+		return
+	}
+	c.elements[key] = c.entries.PushFront(cacheEntry{key: key, timezone: timezone})
+	if c.entries.Len() > c.size {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.elements, oldest.Value.(cacheEntry).key)
+	}
+}
@@ -0,0 +1,61 @@
+package timezone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// countingResolver returns the rounded latitude as the timezone and counts the calls, failing for a negative latitude
+type countingResolver struct {
+	calls int
+}
+
+func (c *countingResolver) GetTimezone(_ context.Context, latitude float64, _ float64) (string, error) {
+	c.calls++
+	if latitude < 0 {
+		return "", ErrNotFound
+	}
+
+	return fmt.Sprintf("zone-%.0f", latitude), nil
+}
+
+func TestCachedResolver_GetTimezone(t *testing.T) {
+	t.Run("Coordinates rounded to the same key are resolved once", func(t *testing.T) {
+		resolver := &countingResolver{}
+		cache := NewCachedResolver(resolver, 2)
+		timezone, err := cache.GetTimezone(context.Background(), 10.0001, 20.0001)
+		assert.Nil(t, err)
+		assert.Equal(t, "zone-10", timezone)
+		timezone, err = cache.GetTimezone(context.Background(), 10.0002, 20.0002)
+		assert.Nil(t, err)
+		assert.Equal(t, "zone-10", timezone)
+		assert.Equal(t, 1, resolver.calls)
+	})
+
+	t.Run("The least recently used entry is removed", func(t *testing.T) {
+		resolver := &countingResolver{}
+		cache := NewCachedResolver(resolver, 2)
+		for _, latitude := range []float64{1, 2, 1, 3, 1, 2} {
+			_, err := cache.GetTimezone(context.Background(), latitude, 0)
+			assert.Nil(t, err)
+		}
+		// 1, 2 and 3 are resolved, 2 is removed when 3 is added and resolved again, 1 is kept
+		assert.Equal(t, 4, resolver.calls)
+		assert.Equal(t, 2, cache.entries.Len())
+		assert.Equal(t, 2, len(cache.elements))
+	})
+
+	t.Run("Errors are not cached", func(t *testing.T) {
+		resolver := &countingResolver{}
+		cache := NewCachedResolver(resolver, 2)
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetTimezone(context.Background(), -1, 0)
+			assert.True(t, errors.Is(err, ErrNotFound))
+		}
+		assert.Equal(t, 2, resolver.calls)
+		assert.Equal(t, 0, cache.entries.Len())
+	})
+}
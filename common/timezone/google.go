@@ -0,0 +1,97 @@
+package timezone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// GoogleResolver resolves the timezones with the Google Maps Time Zone API, with the API key in the
+// GOOGLE_MAPS_API_KEY env variable
+type GoogleResolver struct {
+	client *http.Client
+}
+
+// NewGoogleResolver returns a GoogleResolver whose calls time out after common.TimezoneAPITimeout
+func NewGoogleResolver() *GoogleResolver {
+	return &GoogleResolver{client: &http.Client{Timeout: common.TimezoneAPITimeout}}
+}
+
+// GetTimezone calls the Time Zone API up to common.TimezoneAPIAttempts times while it fails with a network error,
+// a server error or a transient status. The ZERO_RESULTS and INVALID_REQUEST statuses return ErrNotFound
+func (g *GoogleResolver) GetTimezone(ctx context.Context, latitude float64, longitude float64) (string, error) {
+	var err error
+	for attempt := 1; attempt <= common.TimezoneAPIAttempts; attempt++ {
+		var timezone string
+		var retry bool
+		timezone, retry, err = g.callTimezoneAPI(ctx, latitude, longitude)
+		if !retry {
+			return timezone, err
+		}
+		logging.GetLoggerFromContext(ctx).Warnf("Attempt %d of the timezone API failed : %v", attempt, err)
+		if attempt < common.TimezoneAPIAttempts {
+			select {
+			case <-ctx.Done():
+				return "", fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+			case <-time.After(common.TimezoneAPIRetryDelay * time.Duration(attempt)):
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
+
+// callTimezoneAPI returns the timezone of the location, or the error along with whether the call can be retried
+func (g *GoogleResolver) callTimezoneAPI(ctx context.Context, latitude float64,
+	longitude float64) (string, bool, error) {
+	apiURL, err := url.Parse(common.TimezoneAPIUrl)
+	if err != nil {
+		return "", false, err
+	}
+	queryParam := apiURL.Query()
+	queryParam.Set(common.LocationParam, fmt.Sprintf("%f,%f", latitude, longitude))
+	queryParam.Set(common.TimestampParam, fmt.Sprintf("%d", time.Now().Unix()))
+	queryParam.Set(common.APIKeyParam, os.Getenv(common.GoogleMapsAPIEnv))
+	apiURL.RawQuery = queryParam.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL.String(), nil)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := g.client.Do(request)
+	if err != nil {
+		return "", true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", true, fmt.Errorf("timezone API returned with status code : %d", resp.StatusCode)
+	}
+
+	var googleTimeZone models.GoogleTimeZone
+	if err = json.NewDecoder(resp.Body).Decode(&googleTimeZone); err != nil {
+		return "", false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	switch googleTimeZone.Status {
+	case "OK":
+		if googleTimeZone.TimezoneID == "" {
+			return "", false, fmt.Errorf("%w at %f,%f", ErrNotFound, latitude, longitude)
+		}
+
+		return googleTimeZone.TimezoneID, false, nil
+	case "ZERO_RESULTS", "INVALID_REQUEST":
+		return "", false, fmt.Errorf("%w at %f,%f, timezone API returned with status : %s", ErrNotFound,
+			latitude, longitude, googleTimeZone.Status)
+	case "UNKNOWN_ERROR", "OVER_QUERY_LIMIT":
+		return "", true, fmt.Errorf("timezone API returned with status : %s", googleTimeZone.Status)
+	default:
+		return "", false, fmt.Errorf("%w: timezone API returned with status : %s", ErrUnavailable,
+			googleTimeZone.Status)
+	}
+}
@@ -0,0 +1,83 @@
+package timezone
+
+import (
+	"context"
+	"errors"
+	"github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGoogleResolver_GetTimezone(t *testing.T) {
+	t.Run("Get Time Zone for correct values of locations", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			MatchParam("location", "54.250000,13.134000").
+			Reply(200).
+			JSON(models.GoogleTimeZone{
+				RawOffset:    3600,
+				Status:       "OK",
+				TimezoneID:   "Europe/Berlin",
+				TimezoneName: "Central European Standard Time",
+			})
+		timezone, err := NewGoogleResolver().GetTimezone(context.Background(), 54.25, 13.134)
+		assert.Nil(t, err)
+		assert.Equal(t, "Europe/Berlin", timezone)
+	})
+
+	t.Run("Get Time Zone for out of range values of locations", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(models.GoogleTimeZone{Status: "INVALID_REQUEST"})
+		_, err := NewGoogleResolver().GetTimezone(context.Background(), 190.25, 13.134)
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.Equal(t, "timezone not found at 190.250000,13.134000, timezone API returned with status : "+
+			"INVALID_REQUEST", err.Error())
+	})
+
+	t.Run("Retry after a server error", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(503)
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(models.GoogleTimeZone{Status: "UNKNOWN_ERROR"})
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(models.GoogleTimeZone{Status: "OK", TimezoneID: "Europe/Berlin"})
+		timezone, err := NewGoogleResolver().GetTimezone(context.Background(), 54.25, 13.134)
+		assert.Nil(t, err)
+		assert.Equal(t, "Europe/Berlin", timezone)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("Unavailable after the last attempt", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Times(3).
+			Reply(500)
+		_, err := NewGoogleResolver().GetTimezone(context.Background(), 54.25, 13.134)
+		assert.True(t, errors.Is(err, ErrUnavailable))
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("Request denied is not retried", func(t *testing.T) {
+		defer gock.Off()
+		gock.New("https:/maps.googleapis.com").
+			Get("/maps/api/timezone/json").
+			Reply(200).
+			JSON(models.GoogleTimeZone{Status: "REQUEST_DENIED"})
+		_, err := NewGoogleResolver().GetTimezone(context.Background(), 54.25, 13.134)
+		assert.True(t, errors.Is(err, ErrUnavailable))
+		assert.Equal(t, "timezone resolver unavailable: timezone API returned with status : REQUEST_DENIED",
+			err.Error())
+	})
+}
@@ -0,0 +1,42 @@
+package timezone
+
+import (
+	"context"
+	"fmt"
+	"github.com/ringsaturn/tzf"
+	"sync"
+)
+
+// OfflineResolver resolves the timezones from the timezone boundaries bundled with tzf, without any network call.
+// The boundaries are loaded on the first call, which takes a few hundred milliseconds
+type OfflineResolver struct {
+	once   sync.Once
+	finder *tzf.DefaultFinder
+	err    error
+}
+
+// NewOfflineResolver returns an OfflineResolver
+func NewOfflineResolver() *OfflineResolver {
+	return &OfflineResolver{}
+}
+
+// GetTimezone returns the timezone of the boundary containing the location, or ErrNotFound for a location outside
+// the boundaries, e.g. with an invalid latitude or longitude
+func (o *OfflineResolver) GetTimezone(_ context.Context, latitude float64, longitude float64) (string, error) {
+	o.once.Do(func() {
+		o.finder, o.err = tzf.NewDefaultFinder()
+	})
+	if o.err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, o.err)
+	}
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return "", fmt.Errorf("%w at %f,%f", ErrNotFound, latitude, longitude)
+	}
+
+	timezone := o.finder.GetTimezoneName(longitude, latitude)
+	if timezone == "" {
+		return "", fmt.Errorf("%w at %f,%f", ErrNotFound, latitude, longitude)
+	}
+
+	return timezone, nil
+}
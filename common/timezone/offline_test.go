@@ -0,0 +1,35 @@
+package timezone
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOfflineResolver_GetTimezone(t *testing.T) {
+	resolver := NewOfflineResolver()
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		want      string
+	}{
+		{name: "Berlin", latitude: 54.25, longitude: 13.134, want: "Europe/Berlin"},
+		{name: "San Francisco", latitude: 37.7749, longitude: -122.4194, want: "America/Los_Angeles"},
+		{name: "New York", latitude: 40.7128, longitude: -74.006, want: "America/New_York"},
+		{name: "Tokyo", latitude: 35.6762, longitude: 139.6503, want: "Asia/Tokyo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timezone, err := resolver.GetTimezone(context.Background(), tt.latitude, tt.longitude)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, timezone)
+		})
+	}
+
+	t.Run("Out of range location", func(t *testing.T) {
+		_, err := resolver.GetTimezone(context.Background(), 190.25, 13.134)
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+}
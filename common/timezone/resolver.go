@@ -0,0 +1,91 @@
+package timezone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ErrNotFound is returned when the location has no timezone, e.g. an invalid location
+var ErrNotFound = errors.New("timezone not found")
+
+// ErrUnavailable is returned when the timezone of the location could not be resolved
+var ErrUnavailable = errors.New("timezone resolver unavailable")
+
+// Resolver resolves the IANA timezone name of a location, e.g. Europe/Berlin
+type Resolver interface {
+	GetTimezone(ctx context.Context, latitude float64, longitude float64) (string, error)
+}
+
+// resolvers are the resolvers returned by NewResolver by name, shared so that their cache is kept across the requests
+var resolvers = map[string]Resolver{}
+var resolversMutex sync.Mutex
+
+// NewResolver returns the resolver selected by the TIMEZONE_RESOLVER env variable, the GoogleResolver when it is
+// google, else the OfflineResolver. The resolver is wrapped in a CachedResolver of TIMEZONE_CACHE_SIZE entries
+func NewResolver() Resolver {
+	name := os.Getenv(common.EnvTimezoneResolver)
+	if name != common.TimezoneResolverGoogle {
+		name = common.TimezoneResolverOffline
+	}
+	resolversMutex.Lock()
+	defer resolversMutex.Unlock()
+	if resolver, ok := resolvers[name]; ok {
+		return resolver
+	}
+
+	var resolver Resolver = NewOfflineResolver()
+	if name == common.TimezoneResolverGoogle {
+		resolver = NewGoogleResolver()
+	}
+	if size := getCacheSize(); size > 0 {
+		resolver = NewCachedResolver(resolver, size)
+	}
+	resolvers[name] = resolver
+
+	return resolver
+}
+
+// getCacheSize returns the TIMEZONE_CACHE_SIZE env variable, or common.DefaultTimezoneCacheSize if it is not a number
+func getCacheSize() int {
+	size, err := strconv.Atoi(os.Getenv(common.EnvTimezoneCacheSize))
+	if err != nil {
+		return common.DefaultTimezoneCacheSize
+	}
+
+	return size
+}
+
+// GetTimezoneForRequest returns the timezone of the location resolved by NewResolver. When the location has no
+// timezone it responds with a bad request, and when the timezone could not be resolved with an internal server error,
+// returning false
+func GetTimezoneForRequest(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request,
+	latitude float64, longitude float64) (string, bool) {
+	timezone, err := NewResolver().GetTimezone(ctx, latitude, longitude)
+	if err == nil {
+		return timezone, true
+	}
+
+	logger := logging.GetLoggerFromContext(ctx)
+	if errors.Is(err, ErrNotFound) {
+		logger.Debugf("No timezone found for the location : %v", err)
+		response.RespondWithResponseObject(responseWriter,
+			response.NewResponse(http.StatusBadRequest,
+				fmt.Sprintf("Error occurred while retrieving location with latitude %f and longitude %f. "+
+					"No timezone found for the location. "+
+					"Please provide valid location details", latitude, longitude), nil),
+			response.GetCommonResponseHeaders(request))
+	} else {
+		logger.Errorf("Error occurred while retrieving timezone : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+	}
+
+	return "", false
+}
@@ -0,0 +1,56 @@
+package timezone
+
+import (
+	"context"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewResolver(t *testing.T) {
+	t.Run("Offline resolver with the cache by default", func(t *testing.T) {
+		resolvers = map[string]Resolver{}
+		t.Setenv(common.EnvTimezoneResolver, "")
+		t.Setenv(common.EnvTimezoneCacheSize, "")
+		resolver, ok := NewResolver().(*CachedResolver)
+		assert.True(t, ok)
+		assert.Equal(t, common.DefaultTimezoneCacheSize, resolver.size)
+		assert.IsType(t, &OfflineResolver{}, resolver.resolver)
+		assert.Same(t, resolver, NewResolver())
+	})
+
+	t.Run("Google resolver without the cache", func(t *testing.T) {
+		resolvers = map[string]Resolver{}
+		t.Setenv(common.EnvTimezoneResolver, common.TimezoneResolverGoogle)
+		t.Setenv(common.EnvTimezoneCacheSize, "0")
+		assert.IsType(t, &GoogleResolver{}, NewResolver())
+	})
+}
+
+func TestGetTimezoneForRequest(t *testing.T) {
+	resolvers = map[string]Resolver{}
+	t.Setenv(common.EnvTimezoneResolver, common.TimezoneResolverOffline)
+
+	t.Run("Timezone resolved", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/sites", nil)
+		timezone, ok := GetTimezoneForRequest(context.Background(), w, r, 54.25, 13.134)
+		assert.True(t, ok)
+		assert.Equal(t, "Europe/Berlin", timezone)
+	})
+
+	t.Run("No timezone for the location", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/sites", nil)
+		_, ok := GetTimezoneForRequest(context.Background(), w, r, 190.25, 13.134)
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		bytes, _ := io.ReadAll(w.Result().Body)
+		assert.Equal(t, "{\"code\":400,\"message\":\"Error occurred while retrieving location with latitude "+
+			"190.250000 and longitude 13.134000. No timezone found for the location. Please provide valid location "+
+			"details\"}", string(bytes))
+	})
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"golang.org/x/text/language"
+	"regexp"
+	"strings"
+)
+
+// addressFormat is the format of the addresses of a country. The postal code is required when the country has
+// a pattern for it, and the region is required when the country has regions, one of the regions when they are listed
+type addressFormat struct {
+	postalCode     *regexp.Regexp
+	regionRequired bool
+	regions        []string
+}
+
+// defaultPostalCode is the pattern of the postal codes of the countries without a format, where they are optional
+var defaultPostalCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,9}$`)
+
+var addressFormats = map[string]addressFormat{
+	"AU": {postalCode: regexp.MustCompile(`^\d{4}$`), regionRequired: true,
+		regions: []string{"ACT", "NSW", "NT", "QLD", "SA", "TAS", "VIC", "WA"}},
+	"BR": {postalCode: regexp.MustCompile(`^\d{5}-?\d{3}$`), regionRequired: true,
+		regions: []string{"AC", "AL", "AM", "AP", "BA", "CE", "DF", "ES", "GO", "MA", "MG", "MS", "MT", "PA", "PB",
+			"PE", "PI", "PR", "RJ", "RN", "RO", "RR", "RS", "SC", "SE", "SP", "TO"}},
+	"CA": {postalCode: regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+		regionRequired: true,
+		regions:        []string{"AB", "BC", "MB", "NB", "NL", "NS", "NT", "NU", "ON", "PE", "QC", "SK", "YT"}},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"ES": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)},
+	"IL": {postalCode: regexp.MustCompile(`^\d{7}$`)},
+	"IN": {postalCode: regexp.MustCompile(`^\d{6}$`), regionRequired: true},
+	"IT": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"JP": {postalCode: regexp.MustCompile(`^\d{3}-?\d{4}$`), regionRequired: true},
+	"MX": {postalCode: regexp.MustCompile(`^\d{5}$`), regionRequired: true},
+	"NL": {postalCode: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`)},
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), regionRequired: true,
+		regions: []string{"AA", "AE", "AK", "AL", "AP", "AR", "AS", "AZ", "CA", "CO", "CT", "DC", "DE", "FL", "GA",
+			"GU", "HI", "IA", "ID", "IL", "IN", "KS", "KY", "LA", "MA", "MD", "ME", "MI", "MN", "MO", "MP", "MS", "MT",
+			"NC", "ND", "NE", "NH", "NJ", "NM", "NV", "NY", "OH", "OK", "OR", "PA", "PR", "RI", "SC", "SD", "TN", "TX",
+			"UM", "UT", "VA", "VI", "VT", "WA", "WI", "WV", "WY"}},
+}
+
+// NewAddress returns the address to be stored, or nil if it is empty
+func NewAddress(address *commonModels.Address) *commonModels.Address {
+	if address == nil || address.IsEmpty() {
+		return nil
+	}
+
+	return address
+}
+
+// IsValidCountry returns whether the country is an upper case ISO 3166-1 alpha-2 code of a country
+func IsValidCountry(country string) bool {
+	if len(country) != 2 || strings.ToUpper(country) != country {
+		return false
+	}
+	region, err := language.ParseRegion(country)
+
+	return err == nil && region.IsCountry() && region.String() == country
+}
+
+// getAddressLinesErrors returns the failed validations of the street lines of an address,
+// from 1 to common.MaxAddressLines non-empty lines
+func getAddressLinesErrors(lines []string) []string {
+	var errs []string
+	if len(lines) == 0 || len(lines) > common.MaxAddressLines {
+		errs = append(errs, fmt.Sprintf("1 to %d lines", common.MaxAddressLines))
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || len([]rune(line)) > common.MaxAddressFieldLength {
+			return append(errs, fmt.Sprintf("non-empty lines of max %d characters", common.MaxAddressFieldLength))
+		}
+	}
+
+	return errs
+}
+
+// getAddressFieldError returns the failed validation of a field of an address, if any
+func getAddressFieldError(value string, required bool) string {
+	switch {
+	case required && strings.TrimSpace(value) == "":
+		return "required"
+	case len([]rune(value)) > common.MaxAddressFieldLength:
+		return fmt.Sprintf("max %d characters", common.MaxAddressFieldLength)
+	default:
+		return ""
+	}
+}
+
+// getRegionError returns the failed validation of the region of an address of the country, if any
+func getRegionError(region string, country string) string {
+	format := addressFormats[country]
+	if tag := getAddressFieldError(region, format.regionRequired); tag != "" {
+		return tag
+	}
+	if format.regions != nil && !Contains(format.regions, region) {
+		return "region of " + country
+	}
+
+	return ""
+}
+
+// getPostalCodeError returns the failed validation of the postal code of an address of the country, if any
+func getPostalCodeError(postalCode string, country string) string {
+	pattern := addressFormats[country].postalCode
+	if pattern == nil {
+		if postalCode == "" {
+			return ""
+		}
+		pattern = defaultPostalCode
+	}
+	if postalCode == "" {
+		return "required"
+	}
+	if !pattern.MatchString(postalCode) {
+		return "postal code of " + country
+	}
+
+	return ""
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsValidCountry(t *testing.T) {
+	tests := []struct {
+		country string
+		valid   bool
+	}{
+		{"US", true},
+		{"DE", true},
+		{"ZW", true},
+		{"us", false},
+		{"USA", false},
+		{"840", false},
+		{"EU", false},
+		{"ZZ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			assert.Equal(t, tt.valid, IsValidCountry(tt.country))
+		})
+	}
+}
+
+func TestGetPostalCodeError(t *testing.T) {
+	tests := []struct {
+		name       string
+		postalCode string
+		country    string
+		want       string
+	}{
+		{"US ZIP+4", "94043-1351", "US", ""},
+		{"Required in the US", "", "US", "required"},
+		{"Canadian postal code without a space", "K1A0B1", "CA", ""},
+		{"British postcode", "SW1A 1AA", "GB", ""},
+		{"Invalid British postcode", "SW1A", "GB", "postal code of GB"},
+		{"Optional in a country without a format", "", "AE", ""},
+		{"Any format in a country without a format", "1000-01", "PT", ""},
+		{"Invalid characters in a country without a format", "10#00", "PT", "postal code of PT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getPostalCodeError(tt.postalCode, tt.country))
+		})
+	}
+}
+
+func TestNewAddress(t *testing.T) {
+	assert.Nil(t, NewAddress(nil))
+	assert.Nil(t, NewAddress(&commonModels.Address{}))
+	address := &commonModels.Address{Lines: []string{"1 Main St"}, City: "Springfield", Country: "US"}
+	assert.Same(t, address, NewAddress(address))
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IsNotModified returns whether the client already has the representation with the etag and last updated at the
+// updatedTime. The If-None-Match header matches when it has the etag, weak or not and quoted or not, or is *.
+// Without an If-None-Match header the representation is not modified when it was not updated after the
+// If-Modified-Since header, an HTTP date or an RFC3339 time like the Last-Modified headers of the service
+func IsNotModified(request *http.Request, etag string, updatedTime *time.Time) bool {
+	if ifNoneMatch := request.Header.Get(common.HeaderIfNoneMatch); ifNoneMatch != "" {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), common.WeakETagPrefix), "\"")
+			if tag == common.ETagWildcard || (tag == etag && etag != "") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifModifiedSince := request.Header.Get(common.HeaderIfModifiedSince)
+	if ifModifiedSince == "" || updatedTime == nil {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		since, err = time.Parse(time.RFC3339, ifModifiedSince)
+	}
+
+	// the header has a precision of a second
+	return err == nil && !updatedTime.Truncate(time.Second).After(since)
+}
+
+// GetUpdatedTime returns the updated_time of the document, or else its created_time, nil when it has neither
+func GetUpdatedTime(data map[string]interface{}) *time.Time {
+	for _, field := range []string{common.UpdatedTime, common.CreatedTime} {
+		switch value := data[field].(type) {
+		case time.Time:
+			return &value
+		case *time.Time:
+			if value != nil {
+				return value
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetListETag returns the ETag of a page of documents whose ETags are populated, changing when any of the documents,
+// their order or the next page changes, and the latest updated time of the documents.
+// The next page is identified by its cursor, as the page token has a random nonce and differs on every request
+func GetListETag(data []map[string]interface{}, nextPageCursor string) (string, *time.Time, error) {
+	etags := make([]interface{}, 0, len(data)+1)
+	var lastUpdatedTime *time.Time
+	for _, dataMap := range data {
+		etags = append(etags, dataMap[common.ETag])
+		if updatedTime := GetUpdatedTime(dataMap); updatedTime != nil &&
+			(lastUpdatedTime == nil || updatedTime.After(*lastUpdatedTime)) {
+			lastUpdatedTime = updatedTime
+		}
+	}
+	byteArray, err := json.Marshal(append(etags, nextPageCursor))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return computeEtag(byteArray), lastUpdatedTime, nil
+}
+
+// GetLastModified returns the Last-Modified header value of the updated time, empty when it is nil
+func GetLastModified(updatedTime *time.Time) string {
+	if updatedTime == nil {
+		return ""
+	}
+
+	return updatedTime.UTC().Format(time.RFC3339)
+}
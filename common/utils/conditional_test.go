@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsNotModified(t *testing.T) {
+	updatedTime := time.Date(2022, 11, 24, 5, 41, 47, 500, time.UTC)
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		updatedTime *time.Time
+		notModified bool
+	}{
+		{name: "No conditional header", updatedTime: &updatedTime},
+		{name: "Matching ETag", headers: map[string]string{common.HeaderIfNoneMatch: "abc"}, notModified: true},
+		{name: "Matching quoted weak ETag in a list",
+			headers: map[string]string{common.HeaderIfNoneMatch: "\"xyz\", W/\"abc\""}, notModified: true},
+		{name: "Wildcard", headers: map[string]string{common.HeaderIfNoneMatch: "*"}, notModified: true},
+		{name: "Other ETag", headers: map[string]string{common.HeaderIfNoneMatch: "\"xyz\""}},
+		{name: "Other ETag and not modified since", headers: map[string]string{common.HeaderIfNoneMatch: "xyz",
+			common.HeaderIfModifiedSince: "Thu, 24 Nov 2022 05:41:47 GMT"}, updatedTime: &updatedTime},
+		{name: "Not modified since an HTTP date", headers: map[string]string{
+			common.HeaderIfModifiedSince: "Thu, 24 Nov 2022 05:41:47 GMT"}, updatedTime: &updatedTime, notModified: true},
+		{name: "Not modified since an RFC3339 time", headers: map[string]string{
+			common.HeaderIfModifiedSince: "2022-11-24T06:00:00Z"}, updatedTime: &updatedTime, notModified: true},
+		{name: "Modified since", headers: map[string]string{
+			common.HeaderIfModifiedSince: "2022-11-24T05:41:46Z"}, updatedTime: &updatedTime},
+		{name: "Invalid If-Modified-Since", headers: map[string]string{
+			common.HeaderIfModifiedSince: "yesterday"}, updatedTime: &updatedTime},
+		{name: "No updated time", headers: map[string]string{
+			common.HeaderIfModifiedSince: "2022-11-24T06:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/sites/s1", nil)
+			for name, value := range tt.headers {
+				request.Header.Set(name, value)
+			}
+			assert.Equal(t, tt.notModified, IsNotModified(request, "abc", tt.updatedTime))
+		})
+	}
+}
+
+func TestGetListETag(t *testing.T) {
+	createdTime := time.Date(2022, 1, 13, 0, 0, 0, 0, time.UTC)
+	updatedTime := createdTime.Add(time.Hour)
+	data := []map[string]interface{}{
+		{common.ETag: "a", common.CreatedTime: createdTime},
+		{common.ETag: "b", common.CreatedTime: createdTime, common.UpdatedTime: &updatedTime},
+	}
+	etag, lastUpdatedTime, err := GetListETag(data, "")
+	assert.Nil(t, err)
+	assert.Equal(t, &updatedTime, lastUpdatedTime)
+	otherPageETag, _, _ := GetListETag(data, "cursor")
+	assert.NotEqual(t, etag, otherPageETag)
+	otherOrderETag, _, _ := GetListETag([]map[string]interface{}{data[1], data[0]}, "")
+	assert.NotEqual(t, etag, otherOrderETag)
+	emptyETag, lastUpdatedTime, _ := GetListETag(nil, "")
+	assert.NotEmpty(t, emptyETag)
+	assert.Nil(t, lastUpdatedTime)
+	assert.Equal(t, "", GetLastModified(lastUpdatedTime))
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"github.com/TakeoffTech/site-info-svc/common"
+	"math"
+	"strings"
+)
+
+// GetGeohash returns the geohash of the point with the precision number of characters.
+// Every character halves the longitude and latitude ranges in turn 5 times, starting with the longitude
+func GetGeohash(latitude float64, longitude float64, precision int) string {
+	latitudeRange := [2]float64{-90, 90}
+	longitudeRange := [2]float64{-180, 180}
+	var geohash strings.Builder
+	isLongitudeBit := true
+	for geohash.Len() < precision {
+		index := 0
+		for bit := 0; bit < 5; bit++ {
+			index <<= 1
+			if isLongitudeBit {
+				index |= halveRange(&longitudeRange, longitude)
+			} else {
+				index |= halveRange(&latitudeRange, latitude)
+			}
+			isLongitudeBit = !isLongitudeBit
+		}
+		geohash.WriteByte(common.GeohashAlphabet[index])
+	}
+
+	return geohash.String()
+}
+
+// halveRange keeps the half of the value range the value is in, returns 1 for the upper half
+func halveRange(valueRange *[2]float64, value float64) int {
+	middle := (valueRange[0] + valueRange[1]) / 2
+	if value >= middle {
+		valueRange[0] = middle
+
+		return 1
+	}
+	valueRange[1] = middle
+
+	return 0
+}
+
+// GetGeohashPrefixes returns the geohash prefixes of the cells covering the circle of radiusKm around the point,
+// the cell of the point and its neighbours with the longest prefix for which a cell is larger than the radius.
+// An empty prefix covers the whole world when the cells around the point are smaller than the radius at any precision,
+// close to the poles
+func GetGeohashPrefixes(latitude float64, longitude float64, radiusKm float64) []string {
+	// a degree of longitude is the shortest at the latitude of the circle the farthest from the equator
+	longitudeScale := math.Cos(toRadians(math.Min(90, math.Abs(latitude)+kmToDegrees(radiusKm))))
+	precision, latitudeDegrees, longitudeDegrees := 0, 0.0, 0.0
+	for candidate := common.GeohashPrecision; candidate > 0; candidate-- {
+		candidateLatitudeDegrees, candidateLongitudeDegrees := getGeohashCellSize(candidate)
+		if degreesToKm(candidateLatitudeDegrees) >= radiusKm &&
+			degreesToKm(candidateLongitudeDegrees)*longitudeScale >= radiusKm {
+			precision, latitudeDegrees, longitudeDegrees = candidate, candidateLatitudeDegrees, candidateLongitudeDegrees
+
+			break
+		}
+	}
+	if precision == 0 {
+		return []string{""}
+	}
+
+	var prefixes []string
+	for _, latitudeOffset := range []float64{-latitudeDegrees, 0, latitudeDegrees} {
+		for _, longitudeOffset := range []float64{-longitudeDegrees, 0, longitudeDegrees} {
+			neighbourLatitude := math.Max(-90, math.Min(90, latitude+latitudeOffset))
+			neighbourLongitude := math.Mod(longitude+longitudeOffset+540, 360) - 180
+			prefix := GetGeohash(neighbourLatitude, neighbourLongitude, precision)
+			if !Contains(prefixes, prefix) {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+
+	return prefixes
+}
+
+// getGeohashCellSize returns the latitude and the longitude degrees of a geohash cell with the precision
+func getGeohashCellSize(precision int) (float64, float64) {
+	latitudeBits := precision * 5 / 2
+
+	return 180 / math.Pow(2, float64(latitudeBits)), 360 / math.Pow(2, float64(precision*5-latitudeBits))
+}
+
+// GetGeohashAncestors returns the prefixes of the geohash from the empty prefix up to the geohash itself
+func GetGeohashAncestors(geohash string) []string {
+	ancestors := make([]string, 0, len(geohash)+1)
+	for length := 0; length <= len(geohash); length++ {
+		ancestors = append(ancestors, geohash[:length])
+	}
+
+	return ancestors
+}
+
+// GetDistanceKm returns the great-circle distance between the points with the haversine formula
+func GetDistanceKm(latitude1 float64, longitude1 float64, latitude2 float64, longitude2 float64) float64 {
+	latitudeDelta := toRadians(latitude2 - latitude1)
+	longitudeDelta := toRadians(longitude2 - longitude1)
+	haversine := math.Pow(math.Sin(latitudeDelta/2), 2) +
+		math.Cos(toRadians(latitude1))*math.Cos(toRadians(latitude2))*math.Pow(math.Sin(longitudeDelta/2), 2)
+
+	return 2 * common.EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(haversine)))
+}
+
+func degreesToKm(degrees float64) float64 {
+	return toRadians(degrees) * common.EarthRadiusKm
+}
+
+func kmToDegrees(km float64) float64 {
+	return km / common.EarthRadiusKm * 180 / math.Pi
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetGeohash(t *testing.T) {
+	assert.Equal(t, "u4pruydqqv", GetGeohash(57.64911, 10.40744, 10))
+	assert.Equal(t, "9q8yy", GetGeohash(37.7749, -122.4194, 5))
+	assert.Equal(t, "s0000", GetGeohash(0, 0, 5))
+}
+
+func TestGetGeohashPrefixes(t *testing.T) {
+	t.Run("Cell and neighbours covering the radius", func(t *testing.T) {
+		prefixes := GetGeohashPrefixes(37.7749, -122.4194, 2)
+		assert.Equal(t, 9, len(prefixes))
+		assert.Contains(t, prefixes, GetGeohash(37.7749, -122.4194, len(prefixes[0])))
+		for _, prefix := range prefixes {
+			// a cell of 5 characters is about 4.9 km by 4.9 km, of 6 characters about 1.2 km by 0.6 km
+			assert.Equal(t, 5, len(prefix))
+		}
+	})
+
+	t.Run("Neighbours across the antimeridian", func(t *testing.T) {
+		prefixes := GetGeohashPrefixes(0, 179.999, 10)
+		assert.Contains(t, prefixes, GetGeohash(0, -179.999, len(prefixes[0])))
+	})
+
+	t.Run("Whole world close to the pole", func(t *testing.T) {
+		assert.Equal(t, []string{""}, GetGeohashPrefixes(89.9, 0, 500))
+	})
+}
+
+func TestGetDistanceKm(t *testing.T) {
+	assert.Equal(t, 0.0, GetDistanceKm(37.7749, -122.4194, 37.7749, -122.4194))
+	// San Francisco to Los Angeles
+	assert.InDelta(t, 559, GetDistanceKm(37.7749, -122.4194, 34.0522, -118.2437), 1)
+	// a degree of latitude
+	assert.InDelta(t, 111.19, GetDistanceKm(0, 0, 1, 0), 0.01)
+}
+
+func TestGetGeohashAncestors(t *testing.T) {
+	assert.Equal(t, []string{"", "9", "9q", "9q8"}, GetGeohashAncestors("9q8"))
+	assert.Equal(t, []string{""}, GetGeohashAncestors(""))
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// IsMergePatch returns whether the body of the request is a JSON merge patch, sent with the
+// application/merge-patch+json content type
+func IsMergePatch(request *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get(common.HeaderContentType))
+
+	return err == nil && mediaType == common.ContentTypeMergePatchJSON
+}
+
+// MergePatch returns the target document with the JSON merge patch applied as RFC 7396. The members of the patch
+// replace the members of the target, a null member removes the member and an object member is merged into the
+// object member of the target. The target is not modified
+func MergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, _ := target.(map[string]interface{})
+	merged := make(map[string]interface{}, len(targetObject)+len(patchObject))
+	for name, value := range targetObject {
+		merged[name] = value
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = MergePatch(merged[name], value)
+		}
+	}
+
+	return merged
+}
+
+// ApplyMergePatch applies the JSON merge patch validated by ValidateRequest to the entity, a pointer to the stored
+// struct, and validates the patched fields, other than the disallowed fields which are not patched, so the required
+// fields can't be removed by the patch
+func ApplyMergePatch(ctx context.Context, entity interface{}, patch map[string]interface{}) *response.Response {
+	mutableFields := getMergePatchFields(entity, nil, false)
+	mutablePatch := map[string]interface{}{}
+	for name, value := range patch {
+		if _, ok := mutableFields[name]; ok {
+			mutablePatch[name] = value
+		}
+	}
+	data, err := json.Marshal(entity)
+	var original map[string]interface{}
+	if err == nil {
+		err = json.Unmarshal(data, &original)
+	}
+	if err == nil {
+		data, err = json.Marshal(MergePatch(original, mutablePatch))
+	}
+	if err == nil {
+		value := reflect.ValueOf(entity).Elem()
+		value.Set(reflect.Zero(value.Type()))
+		err = json.Unmarshal(data, entity)
+	}
+	if err != nil {
+		return jsonDecodeErrors(ctx, err, &RequestBodyValidation{Entity: entity})
+	}
+
+	patchedFields := getMergePatchFields(entity, mutablePatch, false)
+	fields := make([]string, 0, len(patchedFields))
+	for _, field := range patchedFields {
+		fields = append(fields, field)
+	}
+	if err = validate.StructPartialCtx(ctx, entity, fields...); err != nil {
+		return getValidationErrorsResponse(err)
+	}
+
+	return nil
+}
+
+// validateMergePatch decodes the JSON merge patch of the body into the MergePatch and its values into the Entity,
+// checking that the patch has no unknown fields, no values of the wrong type and no values of the disallowed fields
+func validateMergePatch(ctx context.Context, body io.ReadCloser,
+	requestBodyValidation *RequestBodyValidation) *response.Response {
+	data, err := io.ReadAll(body)
+	var patch map[string]interface{}
+	if err == nil {
+		err = json.Unmarshal(data, &patch)
+	}
+	if err != nil || len(patch) == 0 {
+		return jsonDecodeErrors(ctx, err, requestBodyValidation)
+	}
+	jsonDecoder := json.NewDecoder(bytes.NewReader(data))
+	jsonDecoder.DisallowUnknownFields()
+	if err = jsonDecoder.Decode(requestBodyValidation.Entity); err != nil {
+		return jsonDecodeErrors(ctx, err, requestBodyValidation)
+	}
+
+	disallowedFields := getMergePatchFields(requestBodyValidation.Entity, patch, true)
+	if len(disallowedFields) > 0 {
+		fields := make([]string, 0, len(disallowedFields))
+		for _, field := range disallowedFields {
+			fields = append(fields, field)
+		}
+		if err = validate.StructPartialCtx(ctx, requestBodyValidation.Entity, fields...); err != nil {
+			return getValidationErrorsResponse(err)
+		}
+	}
+	*requestBodyValidation.MergePatch = patch
+
+	return nil
+}
+
+// getMergePatchFields returns the names of the struct fields of the entity by their JSON name, the disallowed fields
+// or else the other fields, limited to the members of the patch when it is not nil
+func getMergePatchFields(entity interface{}, patch map[string]interface{}, disallowed bool) map[string]string {
+	fields := map[string]string{}
+	entityType := reflect.TypeOf(entity)
+	for entityType.Kind() == reflect.Ptr {
+		entityType = entityType.Elem()
+	}
+	for i := 0; i < entityType.NumField(); i++ {
+		field := entityType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if _, ok := patch[name]; patch != nil && !ok {
+			continue
+		}
+		if strings.Contains(field.Tag.Get(common.Validate), common.Disallowed) == disallowed {
+			fields[name] = field.Name
+		}
+	}
+
+	return fields
+}
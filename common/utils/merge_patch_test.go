@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mergePatchEntity struct {
+	ID    string                 `json:"id" validate:"disallowed"`
+	Name  string                 `json:"name" validate:"required"`
+	Note  string                 `json:"note,omitempty"`
+	Extra map[string]interface{} `json:"extra,omitempty"`
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target interface{}
+		patch  interface{}
+		merged interface{}
+	}{
+		{name: "Member replaced", target: map[string]interface{}{"a": "b"}, patch: map[string]interface{}{"a": "c"},
+			merged: map[string]interface{}{"a": "c"}},
+		{name: "Member added", target: map[string]interface{}{"a": "b"}, patch: map[string]interface{}{"b": "c"},
+			merged: map[string]interface{}{"a": "b", "b": "c"}},
+		{name: "Member removed", target: map[string]interface{}{"a": "b", "b": "c"},
+			patch: map[string]interface{}{"a": nil}, merged: map[string]interface{}{"b": "c"}},
+		{name: "Array replaced", target: map[string]interface{}{"a": []interface{}{"b"}},
+			patch: map[string]interface{}{"a": []interface{}{"c"}}, merged: map[string]interface{}{"a": []interface{}{"c"}}},
+		{name: "Object merged", target: map[string]interface{}{"a": map[string]interface{}{"b": "c", "d": "e"}},
+			patch:  map[string]interface{}{"a": map[string]interface{}{"d": nil, "f": "g"}},
+			merged: map[string]interface{}{"a": map[string]interface{}{"b": "c", "f": "g"}}},
+		{name: "Object replacing a value", target: map[string]interface{}{"a": "b"},
+			patch:  map[string]interface{}{"a": map[string]interface{}{"b": "c", "d": nil}},
+			merged: map[string]interface{}{"a": map[string]interface{}{"b": "c"}}},
+		{name: "Value replacing the target", target: map[string]interface{}{"a": "b"}, patch: "c", merged: "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.merged, MergePatch(tt.target, tt.patch))
+		})
+	}
+
+	t.Run("Target not modified", func(t *testing.T) {
+		target := map[string]interface{}{"a": "b"}
+		MergePatch(target, map[string]interface{}{"a": nil})
+		assert.Equal(t, map[string]interface{}{"a": "b"}, target)
+	})
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	t.Run("Mutable fields patched", func(t *testing.T) {
+		entity := mergePatchEntity{ID: "e1", Name: "name", Note: "note", Extra: map[string]interface{}{"a": "b"}}
+		validationResponse := ApplyMergePatch(context.Background(), &entity,
+			map[string]interface{}{"id": "e2", "note": nil, "extra": map[string]interface{}{"c": "d"}})
+		assert.Nil(t, validationResponse)
+		assert.Equal(t, mergePatchEntity{ID: "e1", Name: "name",
+			Extra: map[string]interface{}{"a": "b", "c": "d"}}, entity)
+	})
+
+	t.Run("Required field removed", func(t *testing.T) {
+		entity := mergePatchEntity{ID: "e1", Name: "name"}
+		validationResponse := ApplyMergePatch(context.Background(), &entity, map[string]interface{}{"name": nil})
+		assert.Equal(t, http.StatusBadRequest, validationResponse.Code)
+	})
+}
+
+func TestIsMergePatch(t *testing.T) {
+	tests := []struct {
+		contentType string
+		mergePatch  bool
+	}{
+		{common.ContentTypeMergePatchJSON, true},
+		{common.ContentTypeMergePatchJSON + "; charset=utf-8", true},
+		{common.ContentTypeApplicationJSON, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPatch, "/sites/s1", nil)
+			request.Header.Set(common.HeaderContentType, tt.contentType)
+			assert.Equal(t, tt.mergePatch, IsMergePatch(request))
+		})
+	}
+}
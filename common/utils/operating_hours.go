@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/TakeoffTech/site-info-svc/common"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/TakeoffTech/site-info-svc/common/response"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// the timezones are embedded so that they are evaluated the same on every runtime
+	_ "time/tzdata"
+)
+
+// minutesPerDay is the end of the day in minutes, 24:00
+const minutesPerDay int = 24 * 60
+
+// GetOpenStatus returns whether the operating hours are open at the time in the timezone, with the window open at
+// the time and the next window opening within common.OpenWindowSearchDays days. The windows are on the wall clock of
+// the timezone, so a window across a DST transition is an hour shorter or longer, and the adjoining windows such as
+// 18:00-24:00 and 00:00-02:00 of the next day are a single window
+func GetOpenStatus(operatingHours commonModels.OperatingHours, timezone string,
+	at time.Time) (commonModels.OpenStatus, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return commonModels.OpenStatus{}, err
+	}
+	at = at.In(location)
+	status := commonModels.OpenStatus{At: at, Timezone: timezone}
+	// a window of the previous day may close after the time
+	windows := getOpeningWindows(operatingHours, location, at.AddDate(0, 0, -1), common.OpenWindowSearchDays+1)
+	for index := range windows {
+		if !windows[index].Close.After(at) {
+			continue
+		}
+		if windows[index].Open.After(at) {
+			status.NextWindow = &windows[index]
+
+			break
+		}
+		status.Open = true
+		status.CurrentWindow = &windows[index]
+		if index+1 < len(windows) {
+			status.NextWindow = &windows[index+1]
+		}
+
+		break
+	}
+
+	return status, nil
+}
+
+// getOpeningWindows returns the opening windows of the dates from the date of the time for the number of days,
+// ordered and merged when they overlap or adjoin
+func getOpeningWindows(operatingHours commonModels.OperatingHours, location *time.Location, from time.Time,
+	days int) []commonModels.OpeningWindow {
+	exceptions := map[string]commonModels.HoursException{}
+	for _, exception := range operatingHours.Exceptions {
+		exceptions[exception.Date] = exception
+	}
+	var windows []commonModels.OpeningWindow
+	year, month, day := from.Date()
+	for offset := 0; offset <= days; offset++ {
+		// the date is computed in UTC, a midnight may not exist in the location
+		date := time.Date(year, month, day+offset, 12, 0, 0, 0, time.UTC)
+		for _, hours := range getDateHours(operatingHours, exceptions, date) {
+			windows = append(windows, getOpeningWindow(date, hours, location))
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Open.Before(windows[j].Open)
+	})
+
+	var merged []commonModels.OpeningWindow
+	for _, window := range windows {
+		last := len(merged) - 1
+		if last >= 0 && !window.Open.After(merged[last].Close) {
+			if window.Close.After(merged[last].Close) {
+				merged[last].Close = window.Close
+			}
+
+			continue
+		}
+		merged = append(merged, window)
+	}
+
+	return merged
+}
+
+// getDateHours returns the hours of the exception of the date, or else the weekly hours of its day
+func getDateHours(operatingHours commonModels.OperatingHours, exceptions map[string]commonModels.HoursException,
+	date time.Time) []commonModels.DayHours {
+	if exception, ok := exceptions[date.Format(common.HoursDateLayout)]; ok {
+		if exception.Closed {
+			return nil
+		}
+
+		return []commonModels.DayHours{{Open: exception.Open, Close: exception.Close}}
+	}
+
+	var hours []commonModels.DayHours
+	day := strings.ToLower(date.Weekday().String())
+	for _, dayHours := range operatingHours.Weekly {
+		if dayHours.Day == day {
+			hours = append(hours, dayHours)
+		}
+	}
+
+	return hours
+}
+
+// getOpeningWindow returns the window of the hours on the date in the location,
+// closing on the next day when the close time is not after the open time
+func getOpeningWindow(date time.Time, hours commonModels.DayHours,
+	location *time.Location) commonModels.OpeningWindow {
+	open, _ := parseHoursTime(hours.Open)
+	closing, _ := parseHoursTime(hours.Close)
+	year, month, day := date.Date()
+	closeDay := day
+	if closing <= open {
+		closeDay++
+	}
+
+	return commonModels.OpeningWindow{
+		Open:  getWallClockTime(year, month, day, open, location),
+		Close: getWallClockTime(year, month, closeDay, closing, location),
+	}
+}
+
+// getWallClockTime returns the time of the minutes of the date in the location. A time skipped by a DST transition
+// is moved forward by the transition, e.g. 02:30 to 03:30, and an ambiguous time is its first occurrence
+func getWallClockTime(year int, month time.Month, day int, minutes int, location *time.Location) time.Time {
+	wallClockTime := time.Date(year, month, day, minutes/60, minutes%60, 0, 0, location)
+	if wallClockTime.Hour() == minutes/60%24 && wallClockTime.Minute() == minutes%60 {
+		return wallClockTime
+	}
+	_, offsetBefore := wallClockTime.Zone()
+	_, offsetAfter := wallClockTime.Add(3 * time.Hour).Zone()
+
+	return wallClockTime.Add(time.Duration(offsetAfter-offsetBefore) * time.Second)
+}
+
+// parseHoursTime returns the minutes since the start of the day of a HH:MM time from 00:00 to 24:00,
+// along with whether the time is valid
+func parseHoursTime(value string) (int, bool) {
+	hoursValue, minutesValue, found := strings.Cut(value, ":")
+	if !found || len(hoursValue) != 2 || len(minutesValue) != 2 ||
+		strings.Trim(hoursValue+minutesValue, "0123456789") != "" {
+		return 0, false
+	}
+	hours, hoursErr := strconv.Atoi(hoursValue)
+	minutes, minutesErr := strconv.Atoi(minutesValue)
+	if hoursErr != nil || minutesErr != nil || minutes > 59 {
+		return 0, false
+	}
+	total := hours*60 + minutes
+
+	return total, total <= minutesPerDay
+}
+
+// NewOperatingHours returns the operating hours to be stored, or nil if they are empty
+func NewOperatingHours(operatingHours *commonModels.OperatingHours) *commonModels.OperatingHours {
+	if operatingHours == nil || operatingHours.IsEmpty() {
+		return nil
+	}
+
+	return operatingHours
+}
+
+// ParseAtQueryParam returns the RFC3339 time of the at query param, or the current time when it is not passed,
+// along with the validation response of an invalid time
+func ParseAtQueryParam(request *http.Request) (time.Time, *response.Response) {
+	value := request.URL.Query().Get(common.QueryParamAt)
+	if value == "" {
+		return time.Now(), nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, response.NewResponse(http.StatusBadRequest, "Request validation failed",
+			[]string{fmt.Sprintf("Invalid value for query param %s, it must be an RFC3339 time", common.QueryParamAt)})
+	}
+
+	return at, nil
+}
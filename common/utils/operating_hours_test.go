@@ -0,0 +1,177 @@
+package utils
+
+import (
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetOpenStatus(t *testing.T) {
+	// 2023-03-06 is a monday
+	operatingHours := commonModels.OperatingHours{
+		Weekly: []commonModels.DayHours{
+			{Day: "monday", Open: "09:00", Close: "12:00"},
+			{Day: "monday", Open: "13:00", Close: "17:00"},
+			{Day: "friday", Open: "18:00", Close: "24:00"},
+			{Day: "saturday", Open: "00:00", Close: "02:00"},
+			{Day: "saturday", Open: "22:00", Close: "03:00"},
+			{Day: "sunday", Open: "01:00", Close: "05:00"},
+		},
+		Exceptions: []commonModels.HoursException{
+			{Date: "2023-03-13", Closed: true, Reason: "Holiday"},
+			{Date: "2023-03-14", Open: "10:00", Close: "11:00"},
+		},
+	}
+	newYork, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		name    string
+		at      string
+		open    bool
+		current *commonModels.OpeningWindow
+		next    *commonModels.OpeningWindow
+	}{
+		{
+			name: "Open in the morning window",
+			at:   "2023-03-06T15:00:00Z",
+			open: true,
+			current: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 6, 9, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 6, 12, 0, 0, 0, newYork)},
+			next: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 6, 13, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 6, 17, 0, 0, 0, newYork)},
+		},
+		{
+			name: "Closed at lunch",
+			at:   "2023-03-06T12:30:00-05:00",
+			next: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 6, 13, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 6, 17, 0, 0, 0, newYork)},
+		},
+		{
+			name: "Closing time is closed",
+			at:   "2023-03-06T17:00:00-05:00",
+			next: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 10, 18, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 11, 2, 0, 0, 0, newYork)},
+		},
+		{
+			name: "Adjoining windows of friday and saturday are merged",
+			at:   "2023-03-11T01:00:00-05:00",
+			open: true,
+			current: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 10, 18, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 11, 2, 0, 0, 0, newYork)},
+			next: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 11, 22, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 12, 5, 0, 0, 0, newYork)},
+		},
+		{
+			name: "Overnight window across the DST transition is an hour shorter",
+			at:   "2023-03-12T06:30:00Z",
+			open: true,
+			current: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 11, 22, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 12, 5, 0, 0, 0, newYork)},
+			next: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 14, 10, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 14, 11, 0, 0, 0, newYork)},
+		},
+		{
+			name: "Holiday closed and the next day open with the exception hours",
+			at:   "2023-03-13T10:00:00-04:00",
+			next: &commonModels.OpeningWindow{Open: time.Date(2023, 3, 14, 10, 0, 0, 0, newYork),
+				Close: time.Date(2023, 3, 14, 11, 0, 0, 0, newYork)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339, tt.at)
+			status, err := GetOpenStatus(operatingHours, "America/New_York", at)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.open, status.Open)
+			assert.True(t, at.Equal(status.At))
+			assert.Equal(t, "America/New_York", status.Timezone)
+			assertOpeningWindow(t, tt.current, status.CurrentWindow)
+			assertOpeningWindow(t, tt.next, status.NextWindow)
+		})
+	}
+
+	t.Run("Window of 7 wall clock hours across the DST transition lasts 6 hours", func(t *testing.T) {
+		at, _ := time.Parse(time.RFC3339, "2023-03-12T05:30:00Z")
+		status, err := GetOpenStatus(operatingHours, "America/New_York", at)
+		assert.Nil(t, err)
+		assert.Equal(t, 6*time.Hour, status.CurrentWindow.Close.Sub(status.CurrentWindow.Open))
+	})
+
+	t.Run("Window opening at a time skipped by the DST transition opens after the transition", func(t *testing.T) {
+		hours := commonModels.OperatingHours{Weekly: []commonModels.DayHours{{Day: "sunday", Open: "02:30", Close: "04:00"}}}
+		at, _ := time.Parse(time.RFC3339, "2023-03-12T00:00:00-05:00")
+		status, err := GetOpenStatus(hours, "America/New_York", at)
+		assert.Nil(t, err)
+		assertOpeningWindow(t, &commonModels.OpeningWindow{Open: time.Date(2023, 3, 12, 3, 30, 0, 0, newYork),
+			Close: time.Date(2023, 3, 12, 4, 0, 0, 0, newYork)}, status.NextWindow)
+	})
+
+	t.Run("No operating hours", func(t *testing.T) {
+		status, err := GetOpenStatus(commonModels.OperatingHours{}, "Europe/Berlin", time.Now())
+		assert.Nil(t, err)
+		assert.False(t, status.Open)
+		assert.Nil(t, status.NextWindow)
+	})
+
+	t.Run("Invalid timezone", func(t *testing.T) {
+		_, err := GetOpenStatus(operatingHours, "Mars/Olympus_Mons", time.Now())
+		assert.NotNil(t, err)
+	})
+}
+
+func assertOpeningWindow(t *testing.T, want *commonModels.OpeningWindow, got *commonModels.OpeningWindow) {
+	if want == nil {
+		assert.Nil(t, got)
+
+		return
+	}
+	if assert.NotNil(t, got) {
+		assert.True(t, want.Open.Equal(got.Open), "open %v, want %v", got.Open, want.Open)
+		assert.True(t, want.Close.Equal(got.Close), "close %v, want %v", got.Close, want.Close)
+	}
+}
+
+func TestParseHoursTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		minutes int
+		valid   bool
+	}{
+		{"00:00", 0, true},
+		{"09:30", 570, true},
+		{"24:00", 1440, true},
+		{"24:01", 1441, false},
+		{"9:30", 0, false},
+		{"09:60", 0, false},
+		{"+9:30", 0, false},
+		{"0930", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			minutes, valid := parseHoursTime(tt.value)
+			assert.Equal(t, tt.valid, valid)
+			if tt.valid {
+				assert.Equal(t, tt.minutes, minutes)
+			}
+		})
+	}
+}
+
+func TestParseAtQueryParam(t *testing.T) {
+	t.Run("Time passed", func(t *testing.T) {
+		at, validationResponse := ParseAtQueryParam(httptest.NewRequest(http.MethodGet,
+			"/sites/s1/openStatus?at=2023-03-06T15:00:00%2B01:00", nil))
+		assert.Nil(t, validationResponse)
+		assert.Equal(t, "2023-03-06T14:00:00Z", at.UTC().Format(time.RFC3339))
+	})
+
+	t.Run("Invalid time", func(t *testing.T) {
+		_, validationResponse := ParseAtQueryParam(httptest.NewRequest(http.MethodGet,
+			"/sites/s1/openStatus?at=2023-03-06", nil))
+		assert.Equal(t, http.StatusBadRequest, validationResponse.Code)
+		assert.Equal(t, []string{"Invalid value for query param at, it must be an RFC3339 time"},
+			validationResponse.Errors)
+	})
+}
@@ -2,62 +2,207 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/common"
+	"github.com/TakeoffTech/site-info-svc/common/logging"
 	"go.uber.org/zap"
-	"math"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// GetNextPageToken will take data as string input and a string key of length 22
-// For getting the next page token we are using AES encryption algorithm
-// First we take the key of length 22 then we prefix it with the current timestamp
-// So the final encryption key = timestamp(length 10) + key (length 22)
-// Using this key we encrypt the data.
-// Then we return the base 64 encoded value of "timestamp::::encryptedValue" as the next_page_token
-func GetNextPageToken(data string, key string) (string, error) {
-	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
-	key = timestamp + key
-	cipher, err := aes.NewCipher([]byte(key))
+var errInvalidPageToken = errors.New("invalid page token")
+var errExpiredPageToken = errors.New("page token expired")
+
+// init validates PAGE_TOKEN_KEYS on the cold start of a function, so a missing or invalid key is logged once
+// when the instance starts, the lists would otherwise fail with a 500 on every request which has a next page
+func init() {
+	if _, err := getPageTokenKeys(); err != nil {
+		logging.GetLoggerFromContext(context.Background()).
+			Errorf("The page tokens cannot be issued or decoded, the lists with more than a page will fail : %v", err)
+	}
+}
+
+// pageTokenKey is a key configured to sign the page tokens, the id is part of the token so the keys can be rotated
+type pageTokenKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NextPage is the next page of a list, the page token returned to the client and the encoded cursor sealed in it.
+// Both are empty for the last page
+type NextPage struct {
+	Token  string
+	Cursor string
+}
+
+// pageTokenPayload is the content of the page token
+type pageTokenPayload struct {
+	Cursor   string `json:"c"`
+	IssuedAt int64  `json:"t"`
+}
+
+// GetNextPageToken returns the page token for the cursor (the last document of the page) of the request query
+// The token is "keyID.base64(nonce + AES-GCM sealed payload)" using the first key of PAGE_TOKEN_KEYS.
+// The retailer, path and query params of the request are the additional data of the seal,
+// so the token can only be opened for the same query of the same retailer.
+func GetNextPageToken(request *http.Request, cursor string) (string, error) {
+	keys, err := getPageTokenKeys()
 	if err != nil {
 		return "", err
 	}
-	var array []byte
-	// Calculate the number of blocks for the data that is sent for encryption
-	// according to the AES Block size AES Block size=16
-	// if data consists of 54 elements then block count would be ceil(54/16) = 4
-	blockCount := math.Ceil(float64(len(data)) / float64(cipher.BlockSize()))
-	//Create a byte array for encryption
-	//if size of data is less than block size then we create an array of 1 block size and copy data into it
-	//remaining values would be null
-	if len(data) < cipher.BlockSize() {
-		array = make([]byte, cipher.BlockSize())
-		copy(array, data)
-	} else { //if data is greater than 1 block size then create the array of block count*block size
-		array = make([]byte, cipher.BlockSize()*int(blockCount))
-		copy(array, data)
-	}
-	//Create out array to store the encrypted data
-	out := make([]byte, len(array))
-	//Encrypt the data block by block and store in the out array
-	for i := 0; i < int(blockCount); i++ {
-		blockStart := i * cipher.BlockSize()
-		blockEnd := blockStart + cipher.BlockSize()
-		cipher.Encrypt(out[blockStart:blockEnd], array[blockStart:blockEnd])
-	}
-
-	//Prefix the encrypted data with timestamp separated by ColonSeparator
-	token := timestamp + common.ColonSeparator + string(out)
-
-	return base64.StdEncoding.EncodeToString([]byte(token)), nil
+	payload, err := json.Marshal(pageTokenPayload{Cursor: cursor, IssuedAt: time.Now().Unix()})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, keys[0].aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := keys[0].aead.Seal(nonce, nonce, payload, getPageTokenScope(request, keys[0].id))
+
+	return keys[0].id + common.PageTokenSeparator + base64.RawURLEncoding.EncodeToString(sealed), nil
 }
 
-// DecodeNextPageToken will take the encrypted page_token and decode the data from it
+// DecodeNextPageToken returns the cursor of the page_token header of the request
+// Any of the keys of PAGE_TOKEN_KEYS can open the token, so tokens issued with a rotated key work until they expire.
+// The tokens issued before the signed tokens are decoded with the legacyKey until PAGE_TOKEN_LEGACY_UNTIL,
+// an empty legacyKey is for the lists which never issued them.
+func DecodeNextPageToken(request *http.Request, legacyKey string) (string, error) {
+	token := request.Header.Get(common.HeaderPageToken)
+	if !strings.Contains(token, common.PageTokenSeparator) {
+		if legacyKey == "" {
+			return "", errInvalidPageToken
+		}
+		if !isLegacyPageTokenAccepted() {
+			return "", errExpiredPageToken
+		}
+
+		return decodeLegacyPageToken(token, legacyKey)
+	}
+
+	return openPageToken(request, token)
+}
+
+// ValidatePageToken will validate the page_token value of the header passed into the request
+// The signed tokens are validated to be issued for the request query and not expired.
+// For the tokens issued before the signed tokens, it will check if the value can be base64 decoded without any issues
+// also validate if the token is in correct format
+// also validate the expiration of the token
+func ValidatePageToken(request *http.Request, header string) []string {
+	token := request.Header.Get(header)
+	if strings.Contains(token, common.PageTokenSeparator) {
+		if _, err := openPageToken(request, token); errors.Is(err, errExpiredPageToken) {
+			return []string{fmt.Sprintf("Header %s expired : %v", header, token)}
+		} else if err != nil {
+			return []string{fmt.Sprintf("Invalid %s header : %v", header, token)}
+		}
+
+		return nil
+	}
+
+	errs := validateLegacyPageToken(token, header)
+	if errs == nil && !isLegacyPageTokenAccepted() {
+		errs = append(errs, fmt.Sprintf("Header %s expired : %v", header, token))
+	}
+
+	return errs
+}
+
+func openPageToken(request *http.Request, token string) (string, error) {
+	keyID, encoded, _ := strings.Cut(token, common.PageTokenSeparator)
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errInvalidPageToken
+	}
+	keys, err := getPageTokenKeys()
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if key.id != keyID {
+			continue
+		}
+		if len(sealed) < key.aead.NonceSize() {
+			return "", errInvalidPageToken
+		}
+		payloadBytes, err := key.aead.Open(nil, sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():],
+			getPageTokenScope(request, keyID))
+		if err != nil {
+			return "", errInvalidPageToken
+		}
+		var payload pageTokenPayload
+		if err = json.Unmarshal(payloadBytes, &payload); err != nil {
+			return "", errInvalidPageToken
+		}
+		age := time.Since(time.Unix(payload.IssuedAt, 0)).Minutes()
+		if age < 0 || age > common.ExpireTokenDuration {
+			return "", errExpiredPageToken
+		}
+
+		return payload.Cursor, nil
+	}
+
+	// the key was removed from the configuration
+	return "", errInvalidPageToken
+}
+
+// getPageTokenScope returns what the page token is bound to, the retailer, the path and the query params
+// (the filters and the sort) of the request along with the key id
+func getPageTokenScope(request *http.Request, keyID string) []byte {
+	return []byte(strings.Join([]string{keyID, request.Header.Get(common.HeaderRetailerID), request.URL.Path,
+		request.URL.Query().Encode()}, "\n"))
+}
+
+// getPageTokenKeys parses PAGE_TOKEN_KEYS, "keyID:base64Key" separated by comma
+// The keys are of 32 bytes (AES-256), the first key signs the new tokens.
+// To rotate, a new key is added first and the old key is removed after the tokens signed with it have expired.
+func getPageTokenKeys() ([]pageTokenKey, error) {
+	configuredKeys := os.Getenv(common.EnvPageTokenKeys)
+	if strings.TrimSpace(configuredKeys) == "" {
+		return nil, fmt.Errorf("%s is not set", common.EnvPageTokenKeys)
+	}
+	var keys []pageTokenKey
+	for _, configuredKey := range strings.Split(configuredKeys, common.PageTokenKeySeparator) {
+		keyID, encodedKey, found := strings.Cut(strings.TrimSpace(configuredKey), common.PageTokenKeyIDSeparator)
+		if !found || keyID == "" || strings.Contains(keyID, common.PageTokenSeparator) {
+			return nil, fmt.Errorf("invalid key in %s", common.EnvPageTokenKeys)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(secret) != 32 {
+			return nil, fmt.Errorf("key %s in %s is not a base64 encoded 32 bytes key", keyID, common.EnvPageTokenKeys)
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pageTokenKey{id: keyID, aead: aead})
+	}
+
+	return keys, nil
+}
+
+// isLegacyPageTokenAccepted checks if the time is before PAGE_TOKEN_LEGACY_UNTIL (RFC3339)
+func isLegacyPageTokenAccepted() bool {
+	legacyUntil, err := time.Parse(time.RFC3339, os.Getenv(common.EnvPageTokenLegacyUntil))
+
+	return err == nil && time.Now().Before(legacyUntil)
+}
+
+// decodeLegacyPageToken will take the encrypted page_token and decode the data from it
 // First we do a base64 decode and get the base64 decoded token
 // decodedToken = "timestamp::::encryptedValue"
 // Then we separate the timestamp and the encryptedValue
@@ -65,49 +210,45 @@ func GetNextPageToken(data string, key string) (string, error) {
 // key used for decrypt = "timestamp+key" -> timestamp (length 10) + key (length 22)
 // Note : as AES is symmetric key algo you have to use the same key that was used for encryption to decrypt
 // using this decrypt key we decrypt the encryptedValue and return it as a string
-func DecodeNextPageToken(token string, key string) (string, error) {
+func decodeLegacyPageToken(token string, key string) (string, error) {
 	decodedToken, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
 		return "", err
 	}
 	decodedTokens := strings.Split(string(decodedToken), common.ColonSeparator)
-	cipher, err := aes.NewCipher([]byte(decodedTokens[0] + key))
+	if len(decodedTokens) != 2 || len(decodedTokens[1])%aes.BlockSize != 0 {
+		return "", errInvalidPageToken
+	}
+	block, err := aes.NewCipher([]byte(decodedTokens[0] + key))
 	if err != nil {
 		return "", err
 	}
 
 	data := make([]byte, len(decodedTokens[1]))
-	//Using the length of encrypted data find out the block count
-	blockCount := math.Ceil(float64(len(data)) / float64(cipher.BlockSize()))
 	// Decrypt the encrypted data decodedTokens[1] block by block and store in the data array
-	for i := 0; i < int(blockCount); i++ {
-		blockStart := i * cipher.BlockSize()
-		blockEnd := blockStart + cipher.BlockSize()
-		cipher.Decrypt(data[blockStart:blockEnd], []byte(decodedTokens[1])[blockStart:blockEnd])
+	for blockStart := 0; blockStart < len(data); blockStart += block.BlockSize() {
+		blockEnd := blockStart + block.BlockSize()
+		block.Decrypt(data[blockStart:blockEnd], []byte(decodedTokens[1])[blockStart:blockEnd])
 	}
 
 	// trim the last block if it contains null elements in the byte array
 	return string(bytes.Trim(data, "\x00")), nil
 }
 
-// ValidatePageToken will validate the page_token value of the header passed into the request
-// It will check if the value can be base64 decoded without any issues
-// also validate if the token is in correct format
-// also validate the expiration of the token
-func ValidatePageToken(request *http.Request, header string) []string {
+func validateLegacyPageToken(token string, header string) []string {
 	var errors []string
-	decodedToken, err := base64.StdEncoding.DecodeString(request.Header.Get(header))
+	decodedToken, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
 		errors = append(errors, fmt.Sprintf("Invalid header value, unable to decrypt header : %v", header))
 	} else {
 		decodedTokens := strings.Split(string(decodedToken), common.ColonSeparator)
 		if len(decodedTokens) != 2 || len(decodedTokens[0]) != 10 || len([]byte(decodedTokens[1])) < aes.BlockSize {
-			errors = append(errors, fmt.Sprintf("Invalid %s header : %v", header, request.Header.Get(header)))
+			errors = append(errors, fmt.Sprintf("Invalid %s header : %v", header, token))
 		} else if timestamp, err := strconv.ParseInt(decodedTokens[0], 10, 64); err != nil {
-			errors = append(errors, fmt.Sprintf("Invalid %s : %v", header, request.Header.Get(header)))
+			errors = append(errors, fmt.Sprintf("Invalid %s : %v", header, token))
 		} else if time.Since(time.Unix(timestamp, 0)).Minutes() < 0 ||
 			time.Since(time.Unix(timestamp, 0)).Minutes() > common.ExpireTokenDuration {
-			errors = append(errors, fmt.Sprintf("Header %s expired : %v", header, request.Header.Get(header)))
+			errors = append(errors, fmt.Sprintf("Header %s expired : %v", header, token))
 		}
 	}
 
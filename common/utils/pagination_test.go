@@ -6,45 +6,137 @@ import (
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
+func getPageTokenRequest(url string, retailerID string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	r.Header.Set(common.HeaderRetailerID, retailerID)
+
+	return r
+}
+
 func TestGetNextPageToken(t *testing.T) {
-	t.Run("Get next page token from a valid key", func(t *testing.T) {
-		got, err := GetNextPageToken("r12345", common.RetailersEncryptionKey)
-		assert.NotEmpty(t, got)
+	t.Run("Get next page token and decode it for the same query", func(t *testing.T) {
+		token, err := GetNextPageToken(getPageTokenRequest("/sites?deactivated=true", "r12345"), "s12345")
 		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(token, "test."))
+		r := getPageTokenRequest("/sites?deactivated=true", "r12345")
+		r.Header.Set(common.HeaderPageToken, token)
+		cursor, err := DecodeNextPageToken(r, common.SitesEncryptionKey)
+		assert.Nil(t, err)
+		assert.Equal(t, "s12345", cursor)
 	})
 
-	t.Run("Get next page token from a valid key for big data", func(t *testing.T) {
-		got, err := GetNextPageToken("r123456789098765", common.RetailersEncryptionKey)
-		assert.NotEmpty(t, got)
+	t.Run("Failed to get next page token due to invalid keys", func(t *testing.T) {
+		t.Setenv(common.EnvPageTokenKeys, "test:invalid-key")
+		token, err := GetNextPageToken(getPageTokenRequest("/sites", "r12345"), "s12345")
+		assert.Empty(t, token)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Failed to get next page token without keys", func(t *testing.T) {
+		t.Setenv(common.EnvPageTokenKeys, "")
+		token, err := GetNextPageToken(getPageTokenRequest("/sites", "r12345"), "s12345")
+		assert.Empty(t, token)
+		assert.EqualError(t, err, common.EnvPageTokenKeys+" is not set")
+	})
+}
+
+func TestDecodeNextPageToken(t *testing.T) {
+	t.Run("Failed to decode the token for another retailer", func(t *testing.T) {
+		token, _ := GetNextPageToken(getPageTokenRequest("/sites", "r12345"), "s12345")
+		r := getPageTokenRequest("/sites", "r67890")
+		r.Header.Set(common.HeaderPageToken, token)
+		cursor, err := DecodeNextPageToken(r, common.SitesEncryptionKey)
+		assert.Empty(t, cursor)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Failed to decode the token for another query", func(t *testing.T) {
+		token, _ := GetNextPageToken(getPageTokenRequest("/sites", "r12345"), "s12345")
+		r := getPageTokenRequest("/sites?deactivated=true", "r12345")
+		r.Header.Set(common.HeaderPageToken, token)
+		cursor, err := DecodeNextPageToken(r, common.SitesEncryptionKey)
+		assert.Empty(t, cursor)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Decode the token signed with a rotated key", func(t *testing.T) {
+		token, _ := GetNextPageToken(getPageTokenRequest("/sites", "r12345"), "s12345")
+		t.Setenv(common.EnvPageTokenKeys, "next:ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=,"+
+			"test:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+		r := getPageTokenRequest("/sites", "r12345")
+		r.Header.Set(common.HeaderPageToken, token)
+		cursor, err := DecodeNextPageToken(r, common.SitesEncryptionKey)
 		assert.Nil(t, err)
+		assert.Equal(t, "s12345", cursor)
+		token, _ = GetNextPageToken(r, "s67890")
+		assert.True(t, strings.HasPrefix(token, "next."))
 	})
 
-	t.Run("Failed to get next page token due to invalid key", func(t *testing.T) {
-		got, err := GetNextPageToken("r12345", "invalid-key")
-		assert.Empty(t, got)
+	t.Run("Failed to decode the token signed with a removed key", func(t *testing.T) {
+		token, _ := GetNextPageToken(getPageTokenRequest("/sites", "r12345"), "s12345")
+		t.Setenv(common.EnvPageTokenKeys, "next:ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+		r := getPageTokenRequest("/sites", "r12345")
+		r.Header.Set(common.HeaderPageToken, token)
+		cursor, err := DecodeNextPageToken(r, common.SitesEncryptionKey)
+		assert.Empty(t, cursor)
 		assert.NotNil(t, err)
 	})
+
+	t.Run("Decode the legacy token during the grace period", func(t *testing.T) {
+		t.Setenv(common.EnvPageTokenLegacyUntil, time.Now().Add(time.Hour).Format(time.RFC3339))
+		r := getPageTokenRequest("/retailers", "")
+		r.Header.Set(common.HeaderPageToken, "MTY2ODA3MDU2ODo6OjqiePc+UU1FVfcwyXX6EUpx")
+		cursor, err := DecodeNextPageToken(r, common.RetailersEncryptionKey)
+		assert.Nil(t, err)
+		assert.Equal(t, "r123456789098765", cursor)
+	})
+
+	t.Run("Failed to decode the legacy token after the grace period", func(t *testing.T) {
+		t.Setenv(common.EnvPageTokenLegacyUntil, time.Now().Add(-time.Hour).Format(time.RFC3339))
+		r := getPageTokenRequest("/retailers", "")
+		r.Header.Set(common.HeaderPageToken, "MTY2ODA3MDU2ODo6OjqiePc+UU1FVfcwyXX6EUpx")
+		cursor, err := DecodeNextPageToken(r, common.RetailersEncryptionKey)
+		assert.Empty(t, cursor)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Failed to decode a legacy token of a list without a legacy key", func(t *testing.T) {
+		t.Setenv(common.EnvPageTokenLegacyUntil, time.Now().Add(time.Hour).Format(time.RFC3339))
+		r := getPageTokenRequest("/siteInfoservice/auditDeadLetters", "")
+		r.Header.Set(common.HeaderPageToken, "MTY2ODA3MDU2ODo6OjqiePc+UU1FVfcwyXX6EUpx")
+		cursor, err := DecodeNextPageToken(r, "")
+		assert.Empty(t, cursor)
+		assert.ErrorIs(t, err, errInvalidPageToken)
+	})
 }
 
-func TestDecodeNextPageToken(t *testing.T) {
+func TestDecodeLegacyPageToken(t *testing.T) {
 	t.Run("Decode next page token from a valid key", func(t *testing.T) {
-		got, err := DecodeNextPageToken("MTY2ODA3MDU2ODo6OjqiePc+UU1FVfcwyXX6EUpx", common.RetailersEncryptionKey)
+		got, err := decodeLegacyPageToken("MTY2ODA3MDU2ODo6OjqiePc+UU1FVfcwyXX6EUpx", common.RetailersEncryptionKey)
 		assert.NotEmpty(t, got)
 		assert.Equal(t, "r123456789098765", got)
 		assert.Nil(t, err)
 	})
 
 	t.Run("Failed to get next page token due to invalid key", func(t *testing.T) {
-		got, err := DecodeNextPageToken("MTY2ODA6Ojo6RW5jb2Rl", "invalidity")
+		got, err := decodeLegacyPageToken("MTY2ODA6Ojo6RW5jb2Rl", "invalidity")
 		assert.Empty(t, got)
 		assert.NotNil(t, err)
 	})
 
 	t.Run("Failed to get next page token due to invalid token", func(t *testing.T) {
-		got, err := DecodeNextPageToken("MTY2ODA6Ojo6RW5jb2Rlxsvsc", "invalidity")
+		got, err := decodeLegacyPageToken("MTY2ODA6Ojo6RW5jb2Rlxsvsc", "invalidity")
+		assert.Empty(t, got)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Failed to get next page token without separator", func(t *testing.T) {
+		got, err := decodeLegacyPageToken("MTY2ODBFbmNvZGU=", common.RetailersEncryptionKey)
 		assert.Empty(t, got)
 		assert.NotNil(t, err)
 	})
@@ -52,13 +144,29 @@ func TestDecodeNextPageToken(t *testing.T) {
 
 func TestValidatePageToken(t *testing.T) {
 	t.Run("Test with valid token", func(t *testing.T) {
-		token, _ := GetNextPageToken("123346", common.RetailersEncryptionKey)
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		token, _ := GetNextPageToken(r, "123346")
 		r.Header.Set(common.HeaderPageToken, token)
 		errs := ValidatePageToken(r, common.HeaderPageToken)
 		assert.Nil(t, errs)
 	})
 
+	t.Run("Test with token of another query", func(t *testing.T) {
+		token, _ := GetNextPageToken(httptest.NewRequest(http.MethodGet, "/", nil), "123346")
+		r := httptest.NewRequest(http.MethodGet, "/?deactivated=true", nil)
+		r.Header.Set(common.HeaderPageToken, token)
+		errs := ValidatePageToken(r, common.HeaderPageToken)
+		assert.Equal(t, []string{"Invalid page_token header : " + token}, errs)
+	})
+
+	t.Run("Test with tampered token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		token, _ := GetNextPageToken(r, "123346")
+		r.Header.Set(common.HeaderPageToken, token[:len(token)-2]+"AA")
+		errs := ValidatePageToken(r, common.HeaderPageToken)
+		assert.Equal(t, []string{"Invalid page_token header : " + token[:len(token)-2] + "AA"}, errs)
+	})
+
 	t.Run("Test with invalid token invalid base 64", func(t *testing.T) {
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
 		r.Header.Set(common.HeaderPageToken, "MTY2ODA6Ojo6RW5jb2Rlxsvsc") //invalid base 64
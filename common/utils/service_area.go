@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"github.com/TakeoffTech/site-info-svc/common"
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"math"
+)
+
+// NewServiceArea returns the service area to be stored, with the geohashes of its polygon, or nil if it is empty
+func NewServiceArea(serviceArea *commonModels.ServiceArea) *commonModels.ServiceArea {
+	if serviceArea == nil || serviceArea.IsEmpty() {
+		return nil
+	}
+	newServiceArea := *serviceArea
+	newServiceArea.Geohashes = nil
+	if serviceArea.Polygon != nil {
+		newServiceArea.Geohashes = GetServiceAreaGeohashes(*serviceArea.Polygon)
+	}
+
+	return &newServiceArea
+}
+
+// GetServiceAreaGeohashes returns the geohash cells covering the bounding box of the exterior ring of the polygon,
+// at the longest precision with at most common.MaxServiceAreaGeohashes cells. A service area serves a point only if
+// one of its cells is a prefix of the geohash of the point, so the candidates are queried with GetGeohashAncestors.
+// The empty prefix covers the polygons larger than the cells of every precision
+func GetServiceAreaGeohashes(polygon commonModels.Polygon) []string {
+	if len(polygon.Rings) == 0 || len(polygon.Rings[0].Points) == 0 {
+		return nil
+	}
+	minPoint, maxPoint := getBoundingBox(polygon.Rings[0])
+	for precision := common.GeohashPrecision; precision > 0; precision-- {
+		latitudeDegrees, longitudeDegrees := getGeohashCellSize(precision)
+		// every row and column of cells across the bounding box has a sample, plus one for the edges
+		rows := math.Floor((maxPoint.Latitude-minPoint.Latitude)/latitudeDegrees) + 2
+		columns := math.Floor((maxPoint.Longitude-minPoint.Longitude)/longitudeDegrees) + 2
+		if rows*columns > float64(4*common.MaxServiceAreaGeohashes) {
+			continue
+		}
+		var cells []string
+		for _, latitude := range getSamples(minPoint.Latitude, maxPoint.Latitude, latitudeDegrees) {
+			for _, longitude := range getSamples(minPoint.Longitude, maxPoint.Longitude, longitudeDegrees) {
+				cell := GetGeohash(latitude, longitude, precision)
+				if !Contains(cells, cell) {
+					cells = append(cells, cell)
+				}
+			}
+		}
+		if len(cells) <= common.MaxServiceAreaGeohashes {
+			return cells
+		}
+	}
+
+	return []string{""}
+}
+
+// getBoundingBox returns the south-west and the north-east corners of the bounding box of the ring
+func getBoundingBox(ring commonModels.LinearRing) (commonModels.Point, commonModels.Point) {
+	minPoint, maxPoint := ring.Points[0], ring.Points[0]
+	for _, point := range ring.Points {
+		minPoint.Latitude = math.Min(minPoint.Latitude, point.Latitude)
+		minPoint.Longitude = math.Min(minPoint.Longitude, point.Longitude)
+		maxPoint.Latitude = math.Max(maxPoint.Latitude, point.Latitude)
+		maxPoint.Longitude = math.Max(maxPoint.Longitude, point.Longitude)
+	}
+
+	return minPoint, maxPoint
+}
+
+// getSamples returns the values from min to max a step apart, along with max,
+// so that every interval of the step length between min and max has a sample
+func getSamples(min float64, max float64, step float64) []float64 {
+	var samples []float64
+	for value := min; value < max; value += step {
+		samples = append(samples, value)
+	}
+
+	return append(samples, max)
+}
+
+// IsPointInPolygon returns whether the point is inside the exterior ring of the polygon and outside of its holes
+func IsPointInPolygon(polygon commonModels.Polygon, latitude float64, longitude float64) bool {
+	point := commonModels.Point{Latitude: latitude, Longitude: longitude}
+	for index, ring := range polygon.Rings {
+		// the point must be in the exterior ring, the first one, and not in any of the holes
+		if isPointInRing(ring, point) != (index == 0) {
+			return false
+		}
+	}
+
+	return len(polygon.Rings) > 0
+}
+
+// isPointInRing casts a ray from the point towards the east and counts the edges of the ring it crosses
+func isPointInRing(ring commonModels.LinearRing, point commonModels.Point) bool {
+	inside := false
+	points := ring.Points
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		if (points[i].Latitude > point.Latitude) != (points[j].Latitude > point.Latitude) &&
+			point.Longitude < (points[j].Longitude-points[i].Longitude)*(point.Latitude-points[i].Latitude)/
+				(points[j].Latitude-points[i].Latitude)+points[i].Longitude {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// PolygonsOverlap returns whether the exterior rings of the polygons cross, or one of the polygons is inside the other
+func PolygonsOverlap(polygon commonModels.Polygon, other commonModels.Polygon) bool {
+	if len(polygon.Rings) == 0 || len(other.Rings) == 0 ||
+		len(polygon.Rings[0].Points) == 0 || len(other.Rings[0].Points) == 0 {
+		return false
+	}
+	if ringsIntersect(polygon.Rings[0], other.Rings[0]) {
+		return true
+	}
+	point, otherPoint := polygon.Rings[0].Points[0], other.Rings[0].Points[0]
+
+	return IsPointInPolygon(other, point.Latitude, point.Longitude) ||
+		IsPointInPolygon(polygon, otherPoint.Latitude, otherPoint.Longitude)
+}
+
+// HasSelfIntersection returns whether an edge of the polygon crosses or touches another edge which is not
+// next to it in the same ring
+func HasSelfIntersection(polygon commonModels.Polygon) bool {
+	for index, ring := range polygon.Rings {
+		if isRingSelfIntersecting(ring) {
+			return true
+		}
+		for _, other := range polygon.Rings[index+1:] {
+			if ringsIntersect(ring, other) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isRingSelfIntersecting returns whether two edges of the closed ring intersect other than at their shared point
+func isRingSelfIntersecting(ring commonModels.LinearRing) bool {
+	edges := len(ring.Points) - 1
+	for i := 0; i < edges; i++ {
+		for j := i + 2; j < edges; j++ {
+			// the last edge shares the first point of the ring with the first edge
+			if i == 0 && j == edges-1 {
+				continue
+			}
+			if segmentsIntersect(ring.Points[i], ring.Points[i+1], ring.Points[j], ring.Points[j+1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ringsIntersect returns whether an edge of the ring intersects an edge of the other ring
+func ringsIntersect(ring commonModels.LinearRing, other commonModels.LinearRing) bool {
+	for i := 0; i+1 < len(ring.Points); i++ {
+		for j := 0; j+1 < len(other.Points); j++ {
+			if segmentsIntersect(ring.Points[i], ring.Points[i+1], other.Points[j], other.Points[j+1]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// segmentsIntersect returns whether the segment from p1 to p2 and the segment from q1 to q2 have a common point,
+// treating the longitudes and the latitudes as planar coordinates
+func segmentsIntersect(p1, p2, q1, q2 commonModels.Point) bool {
+	o1, o2 := orientation(p1, p2, q1), orientation(p1, p2, q2)
+	o3, o4 := orientation(q1, q2, p1), orientation(q1, q2, p2)
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+
+	return (o1 == 0 && isOnSegment(p1, q1, p2)) || (o2 == 0 && isOnSegment(p1, q2, p2)) ||
+		(o3 == 0 && isOnSegment(q1, p1, q2)) || (o4 == 0 && isOnSegment(q1, p2, q2))
+}
+
+// orientation returns 0 if the points are collinear, 1 if they turn clockwise and -1 counterclockwise
+func orientation(a, b, c commonModels.Point) int {
+	value := (b.Latitude-a.Latitude)*(c.Longitude-b.Longitude) - (b.Longitude-a.Longitude)*(c.Latitude-b.Latitude)
+	switch {
+	case value > 0:
+		return 1
+	case value < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// isOnSegment returns whether the point b, collinear with a and c, is on the segment from a to c
+func isOnSegment(a, b, c commonModels.Point) bool {
+	return b.Longitude <= math.Max(a.Longitude, c.Longitude) && b.Longitude >= math.Min(a.Longitude, c.Longitude) &&
+		b.Latitude <= math.Max(a.Latitude, c.Latitude) && b.Latitude >= math.Min(a.Latitude, c.Latitude)
+}
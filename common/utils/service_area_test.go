@@ -0,0 +1,89 @@
+package utils
+
+import (
+	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// getTestPolygon returns a polygon with the rings of [long, lat] positions
+func getTestPolygon(rings ...[][2]float64) commonModels.Polygon {
+	polygon := commonModels.Polygon{Type: "Polygon"}
+	for _, positions := range rings {
+		ring := commonModels.LinearRing{}
+		for _, position := range positions {
+			ring.Points = append(ring.Points, commonModels.Point{Longitude: position[0], Latitude: position[1]})
+		}
+		polygon.Rings = append(polygon.Rings, ring)
+	}
+
+	return polygon
+}
+
+func getTestSquare(long float64, lat float64, size float64) [][2]float64 {
+	return [][2]float64{{long, lat}, {long + size, lat}, {long + size, lat + size}, {long, lat + size}, {long, lat}}
+}
+
+func TestNewServiceArea(t *testing.T) {
+	t.Run("Empty service area", func(t *testing.T) {
+		assert.Nil(t, NewServiceArea(nil))
+		assert.Nil(t, NewServiceArea(&commonModels.ServiceArea{PostalCodes: []string{}}))
+	})
+
+	t.Run("Service area with a polygon", func(t *testing.T) {
+		polygon := getTestPolygon(getTestSquare(-122.45, 37.75, 0.05))
+		serviceArea := NewServiceArea(&commonModels.ServiceArea{Polygon: &polygon, PostalCodes: []string{"94103"},
+			Geohashes: []string{"s"}})
+		assert.Equal(t, []string{"94103"}, serviceArea.PostalCodes)
+		assert.Equal(t, GetServiceAreaGeohashes(polygon), serviceArea.Geohashes)
+	})
+
+	t.Run("Service area with postal codes only", func(t *testing.T) {
+		serviceArea := NewServiceArea(&commonModels.ServiceArea{PostalCodes: []string{"94103"}})
+		assert.Nil(t, serviceArea.Geohashes)
+	})
+}
+
+func TestGetServiceAreaGeohashes(t *testing.T) {
+	t.Run("Cells covering the polygon", func(t *testing.T) {
+		polygon := getTestPolygon(getTestSquare(-122.45, 37.75, 0.05))
+		geohashes := GetServiceAreaGeohashes(polygon)
+		assert.LessOrEqual(t, len(geohashes), 16)
+		for _, point := range [][2]float64{{-122.45, 37.75}, {-122.4, 37.8}, {-122.42, 37.77}} {
+			geohash := GetGeohash(point[1], point[0], 10)
+			covered := false
+			for _, cell := range geohashes {
+				covered = covered || geohash[:len(cell)] == cell
+			}
+			assert.True(t, covered, point)
+		}
+	})
+
+	t.Run("Polygon larger than the cells of every precision", func(t *testing.T) {
+		polygon := getTestPolygon([][2]float64{{-170, -80}, {170, -80}, {170, 80}, {-170, 80}, {-170, -80}})
+		assert.Equal(t, []string{""}, GetServiceAreaGeohashes(polygon))
+	})
+}
+
+func TestIsPointInPolygon(t *testing.T) {
+	polygon := getTestPolygon(getTestSquare(0, 0, 10), getTestSquare(4, 4, 2))
+	assert.True(t, IsPointInPolygon(polygon, 2, 2))
+	assert.False(t, IsPointInPolygon(polygon, 5, 5), "inside the hole")
+	assert.False(t, IsPointInPolygon(polygon, 11, 5))
+	assert.False(t, IsPointInPolygon(commonModels.Polygon{}, 0, 0))
+}
+
+func TestPolygonsOverlap(t *testing.T) {
+	polygon := getTestPolygon(getTestSquare(0, 0, 10))
+	assert.True(t, PolygonsOverlap(polygon, getTestPolygon(getTestSquare(5, 5, 10))), "crossing edges")
+	assert.True(t, PolygonsOverlap(polygon, getTestPolygon(getTestSquare(2, 2, 2))), "inside")
+	assert.True(t, PolygonsOverlap(getTestPolygon(getTestSquare(2, 2, 2)), polygon), "containing")
+	assert.False(t, PolygonsOverlap(polygon, getTestPolygon(getTestSquare(20, 20, 5))))
+}
+
+func TestHasSelfIntersection(t *testing.T) {
+	assert.False(t, HasSelfIntersection(getTestPolygon(getTestSquare(0, 0, 10), getTestSquare(4, 4, 2))))
+	assert.True(t, HasSelfIntersection(getTestPolygon([][2]float64{{0, 0}, {10, 10}, {10, 0}, {0, 10}, {0, 0}})))
+	assert.True(t, HasSelfIntersection(getTestPolygon(getTestSquare(0, 0, 10), getTestSquare(8, 8, 4))),
+		"hole crossing the exterior ring")
+}
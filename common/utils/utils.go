@@ -8,17 +8,12 @@ import (
 	"fmt"
 	"github.com/TakeoffTech/site-info-svc/common"
 	"github.com/TakeoffTech/site-info-svc/common/logging"
-	"github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/response"
 	"github.com/fatih/structs"
 	"github.com/hashicorp/packer-plugin-sdk/random"
 	"go.uber.org/zap"
-	"io"
 	"net/http"
-	"net/url"
-	"os"
 	"reflect"
-	"time"
 )
 
 func GetETag(data interface{}) (string, error) {
@@ -28,6 +23,9 @@ func GetETag(data interface{}) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		if dataMap, ok := data.(map[string]interface{}); ok && GetRevision(dataMap) > 0 {
+			return fmt.Sprint(GetRevision(dataMap)) + common.ETagRevisionSeparator + computeEtag(byteArray), nil
+		}
 
 		return computeEtag(byteArray), nil
 	case "struct":
@@ -37,9 +35,23 @@ func GetETag(data interface{}) (string, error) {
 	}
 }
 
+// GetRevision returns the revision of the document data, 0 for a document without a revision
+func GetRevision(data map[string]interface{}) int64 {
+	switch revision := data[common.Revision].(type) {
+	case int64:
+		return revision
+	case int:
+		return int64(revision)
+	case float64:
+		return int64(revision)
+	default:
+		return 0
+	}
+}
+
 func PopulateETags(data []map[string]interface{}, object interface{}) error {
 	for _, dataMap := range data {
-		etag, err := GetETag(dataMap)
+		etag, err := GetETag(withoutDistance(dataMap))
 		if err != nil {
 			return err
 		}
@@ -49,6 +61,22 @@ func PopulateETags(data []map[string]interface{}, object interface{}) error {
 	return ConvertToObject(data, object)
 }
 
+// withoutDistance returns the document without the distance added by a near query,
+// so the etag of the document is the same in the lists and in the get
+func withoutDistance(dataMap map[string]interface{}) map[string]interface{} {
+	if _, ok := dataMap[common.DistanceKm]; !ok {
+		return dataMap
+	}
+	document := make(map[string]interface{}, len(dataMap))
+	for key, value := range dataMap {
+		if key != common.DistanceKm {
+			document[key] = value
+		}
+	}
+
+	return document
+}
+
 // GetEtag accepts []byte will compute the etag and return the has in form of string
 func computeEtag(data []byte) string {
 	hash := sha256.Sum256(data)
@@ -97,47 +125,13 @@ func GetSpanName(spanName string) string {
 	return fmt.Sprintf("%s.%s", common.ServiceName, spanName)
 }
 
-// GetTimeZone will get time zone from the lat, log
-func GetTimeZone(context context.Context, latitude float64, longitude float64) (*models.GoogleTimeZone, error) {
-	apiURL, err := url.Parse(common.TimezoneAPIUrl)
-	if err != nil {
-		return nil, err
-	}
-	queryParam := apiURL.Query()
-	queryParam.Set(common.LocationParam, fmt.Sprintf("%f,%f", latitude, longitude))
-	queryParam.Set(common.TimestampParam, fmt.Sprintf("%d", time.Now().Unix()))
-	queryParam.Set(common.APIKeyParam, os.Getenv(common.GoogleMapsAPIEnv))
-	apiURL.RawQuery = queryParam.Encode()
-	request, err := http.NewRequestWithContext(context, http.MethodGet, apiURL.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := http.DefaultClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	var googleTimeZone *models.GoogleTimeZone
-	if err = json.Unmarshal(body, &googleTimeZone); err != nil {
-		return googleTimeZone, err
-	}
-
-	if googleTimeZone.Status != "OK" {
-		err = fmt.Errorf("error : google Status : %s", googleTimeZone.Status)
-
-		return googleTimeZone, err
-	}
-
-	return googleTimeZone, err
-}
-
 // CreateResponseForGetAllByModel common response for the get all by Model
 // last parameter is object of type which is to be added in array.
+// The response has the ETag and the Last-Modified of the page, and is a 304 Not Modified when the request has the ETag.
+// The Last-Modified of a page is not compared to an If-Modified-Since header, the documents which left the page,
+// e.g. deactivated or no longer matching the filters, do not change it
 func CreateResponseForGetAllByModel[T any](ctx context.Context, responseWriter http.ResponseWriter,
-	request *http.Request, data []map[string]interface{}, nextPageToken string, v T) {
+	request *http.Request, data []map[string]interface{}, nextPage NextPage, v T) {
 	logger := logging.GetLoggerFromContext(ctx)
 	var modelArray []T
 	err := PopulateETags(data, &modelArray)
@@ -147,6 +141,22 @@ func CreateResponseForGetAllByModel[T any](ctx context.Context, responseWriter h
 
 		return
 	}
+	etag, lastUpdatedTime, err := GetListETag(data, nextPage.Cursor)
+	if err != nil {
+		logger.Errorf("Error while getting etag for the list : %v", err)
+		response.RespondWithInternalServerError(responseWriter, request)
+
+		return
+	}
+	responseHeaders := response.GetCommonResponseHeaders(request).
+		WithHeader(common.HeaderNextPageToken, nextPage.Token).
+		WithHeader(common.HeaderEtag, etag).
+		WithHeader(common.HeaderLastModified, GetLastModified(lastUpdatedTime))
+	if IsNotModified(request, etag, nil) {
+		response.RespondWithNotModified(responseWriter, responseHeaders)
+
+		return
+	}
 	var modelArr []T
 	if modelArray != nil {
 		modelArr = modelArray
@@ -154,8 +164,7 @@ func CreateResponseForGetAllByModel[T any](ctx context.Context, responseWriter h
 		modelArr = []T{}
 	}
 
-	response.Respond(responseWriter, http.StatusOK, modelArr,
-		response.GetCommonResponseHeaders(request).WithHeader(common.HeaderNextPageToken, nextPageToken))
+	response.Respond(responseWriter, http.StatusOK, modelArr, responseHeaders)
 	logger.Debugf("%d %T successfully fetched from DB", len(modelArray), v)
 }
 
@@ -196,10 +205,7 @@ func IsValidEtagPresentInHeader(responseWriter http.ResponseWriter, request *htt
 	//Check ETag
 	if request.Header.Get(common.HeaderIfMatch) != etag {
 		logger.Debugf("If-Match header value incorrect. ETag from DB is %s", etag)
-		response.Respond(responseWriter, http.StatusPreconditionFailed,
-			response.NewResponse(http.StatusPreconditionFailed,
-				"If-Match header value incorrect, please get the latest and try again", nil),
-			response.GetCommonResponseHeaders(request))
+		response.RespondWithPreconditionFailed(responseWriter, request)
 
 		return false
 	}
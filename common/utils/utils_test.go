@@ -1,19 +1,16 @@
 package utils
 
 import (
-	"context"
 	"errors"
 	"github.com/TakeoffTech/site-info-svc/cloud-functions/retailers/models"
 	"github.com/TakeoffTech/site-info-svc/common"
-	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
-	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
 )
 
 func init() {
-	err := os.Setenv(common.GoogleMapsAPIEnv, "api-key")
+	err := os.Setenv(common.EnvPageTokenKeys, "test:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
 	if err != nil {
 		return
 	}
@@ -143,6 +140,12 @@ func TestGetETag(t *testing.T) {
 		assert.Equal(t, "502250b1d731426e35876ff70a5ae911fe08e69e0146417fa43df4b316ca62e9", etag)
 	})
 
+	t.Run("Get Etag from Map with a revision", func(t *testing.T) {
+		etag, err := GetETag(map[string]interface{}{"id": "r12345", "revision": int64(2)})
+		assert.Nil(t, err)
+		assert.Equal(t, "2-0ce858b29d8afbd5672209cc26557559c4d660ae38ac62aa71a4b492f87463c1", etag)
+	})
+
 	t.Run("Get Etag from invalid map", func(t *testing.T) {
 		etag, err := GetETag(map[string]interface{}{"id": make(chan int)})
 		assert.NotNil(t, err)
@@ -180,39 +183,6 @@ func TestPopulateETags(t *testing.T) {
 	})
 }
 
-func TestGetTimeZone(t *testing.T) {
-	t.Run("Get Time Zone for correct values of locations", func(t *testing.T) {
-		gock.New("https:/maps.googleapis.com").
-			Get("/maps/api/timezone/json").
-			Reply(200).
-			JSON(commonModels.GoogleTimeZone{
-				DstOffset:    0,
-				RawOffset:    3600,
-				Status:       "OK",
-				TimezoneID:   "Europe/Berlin",
-				TimezoneName: "Central European Standard Time",
-			})
-		TimeZone, err := GetTimeZone(context.Background(), 54.25, 13.134)
-		assert.Nil(t, err)
-		assert.Equal(t, "Europe/Berlin", TimeZone.TimezoneID)
-	})
-
-	t.Run("Get Time Zone for out of range values of locations", func(t *testing.T) {
-		gock.New("https:/maps.googleapis.com").
-			Get("/maps/api/timezone/json").
-			Reply(200).
-			JSON(commonModels.GoogleTimeZone{
-				DstOffset:    0,
-				RawOffset:    3600,
-				Status:       "INVALID_REQUEST",
-				TimezoneID:   "Europe/Berlin",
-				TimezoneName: "Central European Standard Time",
-			})
-		_, err := GetTimeZone(context.Background(), 190.25, 13.134)
-		assert.Equal(t, "error : google Status : INVALID_REQUEST", err.Error())
-	})
-}
-
 func TestGetSitePath(t *testing.T) {
 	t.Run("Get correct path", func(t *testing.T) {
 		path := GetSitePath("r12345")
@@ -9,7 +9,6 @@ import (
 	"github.com/TakeoffTech/site-info-svc/common/logging"
 	commonModels "github.com/TakeoffTech/site-info-svc/common/models"
 	"github.com/TakeoffTech/site-info-svc/common/response"
-	"github.com/fatih/structs"
 	"github.com/go-andiamo/urit"
 	"github.com/go-playground/validator/v10"
 	"io"
@@ -18,6 +17,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var validate = validator.New()
@@ -25,12 +25,18 @@ var validate = validator.New()
 func init() {
 	_ = validate.RegisterValidation("disallowed", validateDisallowed, true)
 	validate.RegisterStructValidation(validateLocation, &commonModels.Location{})
+	validate.RegisterStructValidation(validateServiceArea, &commonModels.ServiceArea{})
+	validate.RegisterStructValidation(validateOperatingHours, &commonModels.OperatingHours{})
+	validate.RegisterStructValidation(validateAddress, &commonModels.Address{})
 	_ = validate.RegisterValidation("name", validateName)
 }
 
 type RequestBodyValidation struct {
 	Entity             interface{}
 	CompleteValidation bool
+	// MergePatch receives the JSON merge patch of a request with the application/merge-patch+json content type,
+	// to be applied to the stored entity with ApplyMergePatch. The Entity receives the patched values
+	MergePatch *map[string]interface{}
 }
 
 type RequestValidation struct {
@@ -58,6 +64,10 @@ func ValidateRequest(request *http.Request,
 			Errors:  validationErrors,
 		}
 	}
+	if requestValidation.RequestBodyValidation != nil && requestValidation.RequestBodyValidation.MergePatch != nil &&
+		IsMergePatch(request) {
+		return pathParams, validateMergePatch(request.Context(), request.Body, requestValidation.RequestBodyValidation)
+	}
 	validateBodyResponse := validateBody(request.Context(), request.Body, requestValidation.RequestBodyValidation)
 	if validateBodyResponse != nil {
 		return pathParams, validateBodyResponse
@@ -77,7 +87,8 @@ func validateBody(ctx context.Context, body io.ReadCloser,
 	jsonDecoder := json.NewDecoder(body)
 	jsonDecoder.DisallowUnknownFields()
 	err := jsonDecoder.Decode(requestBodyValidation.Entity)
-	if err != nil || structs.IsZero(requestBodyValidation.Entity) {
+	// a field set to an empty object, such as an empty service area, makes the body not empty
+	if err != nil || isEmptyEntity(requestBodyValidation.Entity) {
 		return jsonDecodeErrors(ctx, err, requestBodyValidation)
 	}
 	if requestBodyValidation.CompleteValidation {
@@ -88,22 +99,27 @@ func validateBody(ctx context.Context, body io.ReadCloser,
 	}
 
 	if err != nil {
-		var errs []string
-		var valErrs validator.ValidationErrors
-		if errors.As(err, &valErrs) {
-			for _, e := range valErrs {
-				errs = append(errs, e.Error())
-			}
-		}
+		return getValidationErrorsResponse(err)
+	}
 
-		return &response.Response{
-			Code:    http.StatusBadRequest,
-			Message: "Request body validation failed",
-			Errors:  errs,
+	return nil
+}
+
+// getValidationErrorsResponse returns the bad request response of the errors of the validation of a request body
+func getValidationErrorsResponse(err error) *response.Response {
+	var errs []string
+	var valErrs validator.ValidationErrors
+	if errors.As(err, &valErrs) {
+		for _, e := range valErrs {
+			errs = append(errs, e.Error())
 		}
 	}
 
-	return nil
+	return &response.Response{
+		Code:    http.StatusBadRequest,
+		Message: "Request body validation failed",
+		Errors:  errs,
+	}
 }
 
 func validateDisallowed(fieldLevel validator.FieldLevel) bool {
@@ -169,6 +185,11 @@ func GetValidationFields(data interface{}) []string {
 	return fields
 }
 
+// isEmptyEntity returns whether no field of the entity is set
+func isEmptyEntity(entity interface{}) bool {
+	return len(GetValidationFields(entity)) == 0
+}
+
 func validateHeaderRetailerID(request *http.Request, header string) []string {
 	var errs []string
 	if !strings.HasPrefix(request.Header.Get(header), common.RetailerIDPrefix) {
@@ -208,14 +229,211 @@ func validateLocation(structLevel validator.StructLevel) {
 	if location.Latitude == nil {
 		structLevel.ReportError("", "lat", "", "required", "")
 	}
-	if location.Longitude != nil && (*location.Longitude < -180 || *location.Longitude > 180) {
+	if location.Longitude != nil && !isValidLongitude(*location.Longitude) {
 		structLevel.ReportError("", "long", "", "-180 < long < 180", "")
 	}
-	if location.Latitude != nil && (*location.Latitude < -90 || *location.Latitude > 90) {
+	if location.Latitude != nil && !isValidLatitude(*location.Latitude) {
 		structLevel.ReportError("", "lat", "", "-90 < lat < 90", "")
 	}
 }
 
+func isValidLongitude(longitude float64) bool {
+	return longitude >= -180 && longitude <= 180
+}
+
+func isValidLatitude(latitude float64) bool {
+	return latitude >= -90 && latitude <= 90
+}
+
+// ParseLatLong returns the latitude and the longitude of a "lat,long" query param value,
+// along with whether they are valid numbers within the ranges of a location
+func ParseLatLong(value string) (float64, float64, bool) {
+	latitudeValue, longitudeValue, found := strings.Cut(value, ",")
+	latitude, latitudeErr := strconv.ParseFloat(strings.TrimSpace(latitudeValue), 64)
+	longitude, longitudeErr := strconv.ParseFloat(strings.TrimSpace(longitudeValue), 64)
+
+	return latitude, longitude, found && latitudeErr == nil && longitudeErr == nil && isValidLatitude(latitude) &&
+		isValidLongitude(longitude)
+}
+
+// validateServiceArea validates the polygon and the postal codes of the service area, an empty service area is valid.
+// The geohashes are disallowed here rather than with a tag, which a partial validation would skip in a nested struct
+func validateServiceArea(structLevel validator.StructLevel) {
+	serviceArea, _ := structLevel.Current().Interface().(commonModels.ServiceArea)
+	if serviceArea.Geohashes != nil {
+		structLevel.ReportError("", "geohashes", "", common.Disallowed, "")
+	}
+	if serviceArea.Polygon != nil {
+		for _, tag := range getPolygonErrors(*serviceArea.Polygon) {
+			structLevel.ReportError("", "polygon", "", tag, "")
+		}
+	}
+	if len(serviceArea.PostalCodes) > common.MaxServiceAreaPostalCodes {
+		structLevel.ReportError("", "postal_codes", "", fmt.Sprintf("max %d postal codes",
+			common.MaxServiceAreaPostalCodes), "")
+	}
+	var postalCodes []string
+	for _, postalCode := range serviceArea.PostalCodes {
+		if strings.TrimSpace(postalCode) == "" || Contains(postalCodes, postalCode) {
+			structLevel.ReportError("", "postal_codes", "", "unique non-empty postal codes", "")
+
+			break
+		}
+		postalCodes = append(postalCodes, postalCode)
+	}
+}
+
+// getPolygonErrors returns the failed validations of the polygon: a GeoJSON Polygon with closed rings of at least
+// 4 positions within the ranges of a location, and no self-intersection
+func getPolygonErrors(polygon commonModels.Polygon) []string {
+	var errs []string
+	if polygon.Type != common.GeoJSONPolygon {
+		errs = append(errs, "type "+common.GeoJSONPolygon)
+	}
+	if len(polygon.Rings) == 0 {
+		return append(errs, "required")
+	}
+	positions := 0
+	for _, ring := range polygon.Rings {
+		positions += len(ring.Points)
+		if len(ring.Points) < 4 {
+			errs = appendIfMissing(errs, "4 or more positions in a ring")
+
+			continue
+		}
+		if ring.Points[0] != ring.Points[len(ring.Points)-1] {
+			errs = appendIfMissing(errs, "closed rings")
+		}
+		for _, point := range ring.Points {
+			if !isValidLongitude(point.Longitude) {
+				errs = appendIfMissing(errs, "-180 < long < 180")
+			}
+			if !isValidLatitude(point.Latitude) {
+				errs = appendIfMissing(errs, "-90 < lat < 90")
+			}
+		}
+	}
+	if positions > common.MaxServiceAreaPositions {
+		errs = append(errs, fmt.Sprintf("max %d positions", common.MaxServiceAreaPositions))
+	}
+	if errs == nil && HasSelfIntersection(polygon) {
+		errs = append(errs, "no self-intersection")
+	}
+
+	return errs
+}
+
+// validateOperatingHours validates the weekly hours and the exceptions of the operating hours,
+// empty operating hours are valid
+func validateOperatingHours(structLevel validator.StructLevel) {
+	operatingHours, _ := structLevel.Current().Interface().(commonModels.OperatingHours)
+	if len(operatingHours.Weekly) > common.MaxWeeklyHours {
+		structLevel.ReportError("", "weekly", "", fmt.Sprintf("max %d weekly hours", common.MaxWeeklyHours), "")
+	}
+	for _, tag := range getWeeklyHoursErrors(operatingHours.Weekly) {
+		structLevel.ReportError("", "weekly", "", tag, "")
+	}
+	if len(operatingHours.Exceptions) > common.MaxHoursExceptions {
+		structLevel.ReportError("", "exceptions", "", fmt.Sprintf("max %d exceptions", common.MaxHoursExceptions), "")
+	}
+	for _, tag := range getHoursExceptionsErrors(operatingHours.Exceptions) {
+		structLevel.ReportError("", "exceptions", "", tag, "")
+	}
+}
+
+// getWeeklyHoursErrors returns the failed validations of the weekly hours:
+// a day of the week in lower case, an open time from 00:00 to 23:59 and a close time from 00:00 to 24:00
+func getWeeklyHoursErrors(weekly []commonModels.DayHours) []string {
+	var errs []string
+	for _, dayHours := range weekly {
+		if !isValidWeekday(dayHours.Day) {
+			errs = appendIfMissing(errs, "day monday to sunday")
+		}
+		errs = appendHoursErrors(errs, dayHours.Open, dayHours.Close)
+	}
+
+	return errs
+}
+
+// getHoursExceptionsErrors returns the failed validations of the exceptions: a unique YYYY-MM-DD date, which is
+// either closed without hours or open with an open and a close time
+func getHoursExceptionsErrors(exceptions []commonModels.HoursException) []string {
+	var errs []string
+	var dates []string
+	for _, exception := range exceptions {
+		if _, err := time.Parse(common.HoursDateLayout, exception.Date); err != nil {
+			errs = appendIfMissing(errs, "date YYYY-MM-DD")
+		} else if Contains(dates, exception.Date) {
+			errs = appendIfMissing(errs, "unique dates")
+		}
+		dates = append(dates, exception.Date)
+		switch {
+		case exception.Closed && (exception.Open != "" || exception.Close != ""):
+			errs = appendIfMissing(errs, "closed without open and close")
+		case !exception.Closed:
+			errs = appendHoursErrors(errs, exception.Open, exception.Close)
+		}
+	}
+
+	return errs
+}
+
+// appendHoursErrors appends the failed validations of the open and the close times of a window
+func appendHoursErrors(errs []string, openTime string, closeTime string) []string {
+	if openMinutes, valid := parseHoursTime(openTime); !valid || openMinutes == minutesPerDay {
+		errs = appendIfMissing(errs, "open HH:MM from 00:00 to 23:59")
+	}
+	if _, valid := parseHoursTime(closeTime); !valid {
+		errs = appendIfMissing(errs, "close HH:MM from 00:00 to 24:00")
+	}
+
+	return errs
+}
+
+func isValidWeekday(day string) bool {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if day == strings.ToLower(weekday.String()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateAddress validates the lines and the city of the address, and its region and postal code with the format of
+// its country, an empty address is valid
+func validateAddress(structLevel validator.StructLevel) {
+	address, _ := structLevel.Current().Interface().(commonModels.Address)
+	if address.IsEmpty() {
+		return
+	}
+	for _, tag := range getAddressLinesErrors(address.Lines) {
+		structLevel.ReportError("", "lines", "", tag, "")
+	}
+	if tag := getAddressFieldError(address.City, true); tag != "" {
+		structLevel.ReportError("", "city", "", tag, "")
+	}
+	if !IsValidCountry(address.Country) {
+		structLevel.ReportError("", "country", "", "ISO 3166-1 alpha-2 country code", "")
+
+		return
+	}
+	if tag := getRegionError(address.Region, address.Country); tag != "" {
+		structLevel.ReportError("", "region", "", tag, "")
+	}
+	if tag := getPostalCodeError(address.PostalCode, address.Country); tag != "" {
+		structLevel.ReportError("", "postal_code", "", tag, "")
+	}
+}
+
+func appendIfMissing(values []string, value string) []string {
+	if Contains(values, value) {
+		return values
+	}
+
+	return append(values, value)
+}
+
 func validateName(fieldLevel validator.FieldLevel) bool {
 	nameValue := fieldLevel.Field().String()
 	lengthNameValue := len([]rune(nameValue))
@@ -268,7 +486,7 @@ func jsonDecodeErrors(ctx context.Context, err error, requestBodyValidation *Req
 	if err != nil {
 		errs = append(errs, err.Error())
 	}
-	if err == nil && structs.IsZero(requestBodyValidation.Entity) {
+	if err == nil && isEmptyEntity(requestBodyValidation.Entity) {
 		errs = append(errs, "Empty JSON received, please input valid JSON in body")
 	}
 
@@ -489,6 +489,168 @@ func TestValidateBodyPartial(t *testing.T) {
 			response.NewResponse(http.StatusBadRequest, "Request body validation failed",
 				[]string{"Key: 'Site.Name' Error:Field validation for 'Name' failed on the 'name' tag"}),
 		},
+		{
+			"Valid service area",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"service_area":{"polygon":{"type":"Polygon",`+
+					`"coordinates":[[[10,10],[11,10],[11,11],[10,11],[10,10]]]},"postal_codes":["10001","10002"]}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			nil,
+		},
+		{
+			"Service area polygon with an open ring out of range",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"service_area":{"polygon":{"type":"Polygon",`+
+					`"coordinates":[[[10,10],[11,10],[11,91],[10,11]]]}}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", []string{
+				"Key: 'Site.ServiceArea.polygon' Error:Field validation for 'polygon' failed on the 'closed rings' tag",
+				"Key: 'Site.ServiceArea.polygon' Error:Field validation for 'polygon' failed on the '-90 < lat < 90' tag",
+			}),
+		},
+		{
+			"Self-intersecting service area polygon and duplicate postal codes",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"service_area":{"polygon":{"type":"Polygon",`+
+					`"coordinates":[[[10,10],[11,11],[11,10],[10,11],[10,10]]]},"postal_codes":["10001","10001"]}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", []string{
+				"Key: 'Site.ServiceArea.polygon' Error:Field validation for 'polygon' failed on the " +
+					"'no self-intersection' tag",
+				"Key: 'Site.ServiceArea.postal_codes' Error:Field validation for 'postal_codes' failed on the " +
+					"'unique non-empty postal codes' tag",
+			}),
+		},
+		{
+			"Service area geohashes passed",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"service_area":{"geohashes":["9q8yy"]}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", []string{
+				"Key: 'Site.ServiceArea.geohashes' Error:Field validation for 'geohashes' failed on the 'disallowed' tag",
+			}),
+		},
+		{
+			"Valid operating hours",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"operating_hours":{"weekly":[`+
+					`{"day":"monday","open":"09:00","close":"17:00"},{"day":"friday","open":"22:00","close":"02:00"}],`+
+					`"exceptions":[{"date":"2023-12-25","closed":true,"reason":"Christmas"},`+
+					`{"date":"2023-12-24","open":"09:00","close":"24:00"}]}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			nil,
+		},
+		{
+			"Operating hours with an invalid day, times and exceptions",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"operating_hours":{"weekly":[`+
+					`{"day":"Monday","open":"24:00","close":"9:00"}],"exceptions":[`+
+					`{"date":"2023-12-25","closed":true,"open":"09:00"},{"date":"2023-12-25"},`+
+					`{"date":"25/12/2023","open":"09:00","close":"17:00"}]}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", []string{
+				"Key: 'Site.OperatingHours.weekly' Error:Field validation for 'weekly' failed on the " +
+					"'day monday to sunday' tag",
+				"Key: 'Site.OperatingHours.weekly' Error:Field validation for 'weekly' failed on the " +
+					"'open HH:MM from 00:00 to 23:59' tag",
+				"Key: 'Site.OperatingHours.weekly' Error:Field validation for 'weekly' failed on the " +
+					"'close HH:MM from 00:00 to 24:00' tag",
+				"Key: 'Site.OperatingHours.exceptions' Error:Field validation for 'exceptions' failed on the " +
+					"'closed without open and close' tag",
+				"Key: 'Site.OperatingHours.exceptions' Error:Field validation for 'exceptions' failed on the " +
+					"'unique dates' tag",
+				"Key: 'Site.OperatingHours.exceptions' Error:Field validation for 'exceptions' failed on the " +
+					"'open HH:MM from 00:00 to 23:59' tag",
+				"Key: 'Site.OperatingHours.exceptions' Error:Field validation for 'exceptions' failed on the " +
+					"'close HH:MM from 00:00 to 24:00' tag",
+				"Key: 'Site.OperatingHours.exceptions' Error:Field validation for 'exceptions' failed on the " +
+					"'date YYYY-MM-DD' tag",
+			}),
+		},
+		{
+			"Site with an address and no location",
+			args{
+				getRequest(http.MethodPost, "/sites", `{"name":"siteID1","retailer_site_id":"ABS111","address":{`+
+					`"lines":["1600 Amphitheatre Pkwy"],"city":"Mountain View","region":"CA",`+
+					`"postal_code":"94043-1351","country":"US"}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: true,
+				},
+			},
+			nil,
+		},
+		{
+			"Address with invalid lines, region and postal code of the country",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"address":{"lines":["1 Main St"," "],`+
+					`"city":"","region":"Ontario","postal_code":"K1A0B1","country":"CA"}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", []string{
+				"Key: 'Site.Address.lines' Error:Field validation for 'lines' failed on the " +
+					"'non-empty lines of max 100 characters' tag",
+				"Key: 'Site.Address.city' Error:Field validation for 'city' failed on the 'required' tag",
+				"Key: 'Site.Address.region' Error:Field validation for 'region' failed on the 'region of CA' tag",
+			}),
+		},
+		{
+			"Address with an invalid country",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"address":{"lines":["1 Main St"],`+
+					`"city":"Springfield","country":"us"}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", []string{
+				"Key: 'Site.Address.country' Error:Field validation for 'country' failed on the " +
+					"'ISO 3166-1 alpha-2 country code' tag",
+			}),
+		},
+		{
+			"Address with a postal code of another country",
+			args{
+				getRequest(http.MethodPatch, "/sites/s12345", `{"address":{"lines":["Unter den Linden 1"],`+
+					`"city":"Berlin","postal_code":"SW1A 1AA","country":"DE"}}`).Body,
+				&RequestBodyValidation{
+					Entity:             &sitemodel.Site{},
+					CompleteValidation: false,
+				},
+			},
+			response.NewResponse(http.StatusBadRequest, "Request body validation failed", []string{
+				"Key: 'Site.Address.postal_code' Error:Field validation for 'postal_code' failed on the " +
+					"'postal code of DE' tag",
+			}),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -519,15 +681,19 @@ func getRequest(method string, url string, body string, headers ...string) *http
 	for _, header := range headers {
 		if header == common.HeaderAcceptVersion {
 			request.Header.Set(header, common.APIVersionV1)
-		} else if header == common.HeaderPageToken {
-			token, _ := GetNextPageToken("r12345", common.RetailersEncryptionKey)
-			request.Header.Set(header, token)
 		} else if header == common.HeaderPageSize {
 			request.Header.Set(header, "5")
-		} else {
+		} else if header != common.HeaderPageToken {
 			request.Header.Set(header, GetRandomID(common.RandomIDLength))
 		}
 	}
+	// the page token is bound to the other headers, so it is set at last
+	for _, header := range headers {
+		if header == common.HeaderPageToken {
+			token, _ := GetNextPageToken(request, "r12345")
+			request.Header.Set(header, token)
+		}
+	}
 
 	return request
 }
@@ -569,3 +735,14 @@ func TestValidateMethod(t *testing.T) {
 		assert.Nil(t, err)
 	})
 }
+
+func TestParseLatLong(t *testing.T) {
+	latitude, longitude, valid := ParseLatLong("37.7749, -122.4194")
+	assert.True(t, valid)
+	assert.Equal(t, 37.7749, latitude)
+	assert.Equal(t, -122.4194, longitude)
+	for _, value := range []string{"37.7749", "91,0", "0,181", "lat,long", ""} {
+		_, _, valid = ParseLatLong(value)
+		assert.False(t, valid, value)
+	}
+}
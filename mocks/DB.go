@@ -61,6 +61,27 @@ func (_m *DB) Delete(ctx context.Context, collectionPath string, documentID stri
 	return r0, r1
 }
 
+// DeleteInTransaction provides a mock function with given fields: ctx, collectionPath, documentID, options
+func (_m *DB) DeleteInTransaction(ctx context.Context, collectionPath string, documentID string, options cloud.WriteOptions) (bool, error) {
+	ret := _m.Called(ctx, collectionPath, documentID, options)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, cloud.WriteOptions) bool); ok {
+		r0 = rf(ctx, collectionPath, documentID, options)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, cloud.WriteOptions) error); ok {
+		r1 = rf(ctx, collectionPath, documentID, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Exists provides a mock function with given fields: ctx, collectionPath, field, value
 func (_m *DB) Exists(ctx context.Context, collectionPath string, field string, value string) (bool, error) {
 	ret := _m.Called(ctx, collectionPath, field, value)
@@ -104,7 +125,7 @@ func (_m *DB) ExistsInCollectionGroup(ctx context.Context, collectionGroupID str
 }
 
 // GetAll provides a mock function with given fields: ctx, collectionPath, pageDetails, whereClauses
-func (_m *DB) GetAll(ctx context.Context, collectionPath string, pageDetails cloud.Page, whereClauses []cloud.Where) ([]map[string]interface{}, string, error) {
+func (_m *DB) GetAll(ctx context.Context, collectionPath string, pageDetails cloud.Page, whereClauses []cloud.Where) ([]map[string]interface{}, *cloud.Cursor, error) {
 	ret := _m.Called(ctx, collectionPath, pageDetails, whereClauses)
 
 	var r0 []map[string]interface{}
@@ -116,11 +137,13 @@ func (_m *DB) GetAll(ctx context.Context, collectionPath string, pageDetails clo
 		}
 	}
 
-	var r1 string
-	if rf, ok := ret.Get(1).(func(context.Context, string, cloud.Page, []cloud.Where) string); ok {
+	var r1 *cloud.Cursor
+	if rf, ok := ret.Get(1).(func(context.Context, string, cloud.Page, []cloud.Where) *cloud.Cursor); ok {
 		r1 = rf(ctx, collectionPath, pageDetails, whereClauses)
 	} else {
-		r1 = ret.Get(1).(string)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*cloud.Cursor)
+		}
 	}
 
 	var r2 error
@@ -177,6 +200,27 @@ func (_m *DB) Save(ctx context.Context, collectionPath string, documentID string
 	return r0, r1
 }
 
+// SaveInTransaction provides a mock function with given fields: ctx, collectionPath, documentID, document, options
+func (_m *DB) SaveInTransaction(ctx context.Context, collectionPath string, documentID string, document interface{}, options cloud.WriteOptions) (time.Time, error) {
+	ret := _m.Called(ctx, collectionPath, documentID, document, options)
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, interface{}, cloud.WriteOptions) time.Time); ok {
+		r0 = rf(ctx, collectionPath, documentID, document, options)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, interface{}, cloud.WriteOptions) error); ok {
+		r1 = rf(ctx, collectionPath, documentID, document, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Update provides a mock function with given fields: ctx, collectionPath, documentID, document
 func (_m *DB) Update(ctx context.Context, collectionPath string, documentID string, document []firestore.Update) (time.Time, error) {
 	ret := _m.Called(ctx, collectionPath, documentID, document)
@@ -198,6 +242,27 @@ func (_m *DB) Update(ctx context.Context, collectionPath string, documentID stri
 	return r0, r1
 }
 
+// UpdateInTransaction provides a mock function with given fields: ctx, collectionPath, documentID, updates, options
+func (_m *DB) UpdateInTransaction(ctx context.Context, collectionPath string, documentID string, updates []firestore.Update, options cloud.WriteOptions) (time.Time, error) {
+	ret := _m.Called(ctx, collectionPath, documentID, updates, options)
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []firestore.Update, cloud.WriteOptions) time.Time); ok {
+		r0 = rf(ctx, collectionPath, documentID, updates, options)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []firestore.Update, cloud.WriteOptions) error); ok {
+		r1 = rf(ctx, collectionPath, documentID, updates, options)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewDB interface {
 	mock.TestingT
 	Cleanup(func())
@@ -14,8 +14,17 @@ type Queue struct {
 }
 
 // Publish provides a mock function with given fields: ctx, topicName, response
-func (_m *Queue) Publish(ctx context.Context, topicName string, response interface{}) {
-	_m.Called(ctx, topicName, response)
+func (_m *Queue) Publish(ctx context.Context, topicName string, response interface{}) error {
+	ret := _m.Called(ctx, topicName, response)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, interface{}) error); ok {
+		r0 = rf(ctx, topicName, response)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 type mockConstructorTestingTNewQueue interface {